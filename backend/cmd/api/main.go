@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/engramiq/engramiq-backend/internal/config"
-	"github.com/engramiq/engramiq-backend/internal/infrastructure/database"
-	"github.com/engramiq/engramiq-backend/internal/infrastructure/cache"
+	"github.com/engramiq/engramiq-backend/internal/domain"
 	"github.com/engramiq/engramiq-backend/internal/handler"
+	"github.com/engramiq/engramiq-backend/internal/infrastructure/cache"
+	"github.com/engramiq/engramiq-backend/internal/infrastructure/database"
+	"github.com/engramiq/engramiq-backend/internal/llm"
+	"github.com/engramiq/engramiq-backend/internal/middleware"
 	"github.com/engramiq/engramiq-backend/internal/repository"
 	"github.com/engramiq/engramiq-backend/internal/service"
+	"github.com/engramiq/engramiq-backend/internal/worker"
 	"github.com/engramiq/engramiq-backend/pkg/logger"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -32,6 +39,10 @@ func main() {
 	log := logger.New(cfg.Environment)
 	defer log.Sync()
 
+	if cfg.Environment == "production" && cfg.JWT.Secret == config.DefaultJWTSecret {
+		log.Fatal("JWT_SECRET must be set in production")
+	}
+
 	// Initialize database
 	db, err := database.New(cfg.Database)
 	if err != nil {
@@ -44,32 +55,98 @@ func main() {
 	}
 
 	// Initialize Redis cache
-	_ = cache.NewRedis(cfg.Redis)
+	redisClient := cache.NewRedis(cfg.Redis)
 
 	// Initialize repositories
 	siteRepo := repository.NewSiteRepository(db)
 	componentRepo := repository.NewComponentRepository(db)
 	documentRepo := repository.NewDocumentRepository(db)
+	chunkRepo := repository.NewChunkRepository(db)
 	actionRepo := repository.NewActionRepository(db)
 	_ = repository.NewEventRepository(db)
 	queryRepo := repository.NewQueryRepository(db)
-	_ = repository.NewUserRepository(db)
+	conversationRepo := repository.NewConversationRepository(db)
+	usageRepo := repository.NewUsageRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	organizationRepo := repository.NewOrganizationRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
 
 	// Initialize services
+	llmProvider, err := llm.NewProvider(cfg.LLM)
+	if err != nil {
+		log.Fatal("Failed to configure LLM provider", "error", err)
+	}
+	log.Info("LLM provider configured", "provider", llmProvider.Name(), "model", cfg.LLM.Model)
+
+	pricing, err := llm.NewPricing(cfg.LLM.Prices)
+	if err != nil {
+		log.Fatal("Failed to parse LLM prices", "error", err)
+	}
+
 	llmService := service.NewLLMService(
-		cfg.LLM.APIKey,
-		"https://api.openai.com/v1", // Default OpenAI API URL
-		cfg.LLM.Model,
+		llmProvider,
+		service.GenerationSettings{
+			Temperature: cfg.LLM.Temperature,
+			MaxTokens:   cfg.LLM.MaxTokens,
+			Timeout:     cfg.LLM.Timeout,
+		},
+		service.GroundingPolicy{
+			Judge: service.GroundingJudge(cfg.LLM.GroundingJudge),
+		},
+		service.EmbeddingPolicy{
+			Model:     cfg.LLM.EmbeddingModel,
+			BatchSize: cfg.LLM.EmbeddingBatchSize,
+			Cache:     redisClient,
+			CacheTTL:  cfg.LLM.EmbeddingCacheTTL,
+		},
+		pricing,
+		usageRepo,
 		actionRepo,
 		componentRepo,
+		log,
 	)
-	
+
 	// Initialize new PRD services
 	contentFilterService := service.NewContentFilterService()
 	sourceAttributionService := service.NewSourceAttributionService(queryRepo, documentRepo)
-	
-	documentService := service.NewDocumentService(documentRepo, siteRepo, actionRepo, llmService)
-	queryService := service.NewQueryService(queryRepo, actionRepo, documentRepo, componentRepo, llmService, contentFilterService, sourceAttributionService)
+
+	retryPolicy := service.RetryPolicy{
+		MaxAttempts: cfg.Worker.MaxAttempts,
+		BaseDelay:   cfg.Worker.RetryBaseDelay,
+		MaxDelay:    cfg.Worker.RetryMaxDelay,
+	}
+	fusionPolicy := service.FusionPolicy{
+		Method:         service.FusionMethod(cfg.Search.FusionMethod),
+		RRFK:           cfg.Search.RRFK,
+		SemanticWeight: cfg.Search.SemanticWeight,
+		KeywordWeight:  cfg.Search.KeywordWeight,
+	}
+	embeddingService := service.NewEmbeddingService(llmService, actionRepo, componentRepo, log)
+	documentService := service.NewDocumentService(documentRepo, chunkRepo, siteRepo, actionRepo, llmService, embeddingService, redisClient, redisClient, retryPolicy, log)
+	queryService := service.NewQueryService(queryRepo, conversationRepo, actionRepo, documentRepo, chunkRepo, componentRepo, llmService, embeddingService, contentFilterService, sourceAttributionService, fusionPolicy, redisClient, cfg.Redis.QueryCacheTTL, log)
+
+	authService := service.NewAuthService(userRepo, redisClient, service.AuthSettings{
+		Secret:          []byte(cfg.JWT.Secret),
+		AccessTokenTTL:  cfg.JWT.AccessTokenTTL,
+		RefreshTokenTTL: cfg.JWT.RefreshTokenTTL,
+		OperatorEmail:   cfg.JWT.OperatorEmail,
+	}, log)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userRepo, siteRepo, log)
+	siteAccessService := service.NewSiteAccessService(organizationRepo)
+
+	// Start background document processing
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	defer stopWorker()
+	workerDone := make(chan struct{})
+	if cfg.Worker.Enabled {
+		documentWorker := worker.NewDocumentWorker(documentService, embeddingService, redisClient, cfg.Worker, log)
+		go func() {
+			defer close(workerDone)
+			documentWorker.Run(workerCtx)
+		}()
+	} else {
+		close(workerDone)
+	}
 
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
@@ -90,16 +167,16 @@ func main() {
 	app.Use(requestid.New())
 	app.Use(helmet.New())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: cfg.Server.CORSOrigins,
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET, POST, PUT, DELETE, OPTIONS",
+		AllowOrigins:     cfg.Server.CORSOrigins,
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-Session-ID",
+		AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS",
 		AllowCredentials: true,
 	}))
 
 	// Health check
 	app.Get("/api/v1/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
-			"status": "ok",
+			"status":  "ok",
 			"service": "engramiq-reporting-agent",
 		})
 	})
@@ -107,51 +184,136 @@ func main() {
 	// API routes
 	api := app.Group("/api/v1")
 
+	// Routes that wait on the LLM stop its calls when the client disconnects
+	cancelOnDisconnect := middleware.CancelOnDisconnect()
+
 	// Initialize handlers
+	authHandler := handler.NewAuthHandler(authService, handler.RefreshCookie{
+		Secure: cfg.JWT.CookieSecure,
+		TTL:    cfg.JWT.RefreshTokenTTL,
+	})
 	siteHandler := handler.NewSiteHandler(siteRepo)
 	documentHandler := handler.NewDocumentHandler(documentService)
 	queryHandler := handler.NewQueryHandler(queryService)
-	componentHandler := handler.NewComponentHandler(componentRepo, actionRepo)
-	actionHandler := handler.NewActionHandler(actionRepo)
+	conversationHandler := handler.NewConversationHandler(queryService)
+	componentHandler := handler.NewComponentHandler(componentRepo, actionRepo, embeddingService)
+	actionHandler := handler.NewActionHandler(actionRepo, embeddingService, redisClient, log)
+	usageHandler := handler.NewUsageHandler(usageRepo)
+	userHandler := handler.NewUserHandler(userRepo)
+	organizationHandler := handler.NewOrganizationHandler(organizationRepo, userRepo, siteRepo)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+
+	// Auth routes - the refresh token travels in an HttpOnly cookie. Nobody
+	// is signed in yet, so they are rate limited by client IP.
+	authLimit := middleware.RateLimitByIP(redisClient, cfg.RateLimit.AuthRequests, cfg.RateLimit.Window, log)
+	api.Post("/auth/register", authLimit, authHandler.Register)
+	api.Post("/auth/login", authLimit, authHandler.Login)
+	api.Post("/auth/refresh", authLimit, authHandler.Refresh)
+	api.Post("/auth/logout", authLimit, authHandler.Logout)
+
+	// Every route below requires an access token or API key, and the
+	// permission its role (and the key's scopes) grant (see
+	// domain.rolePermissions). Each user and key is rate limited.
+	api.Use(middleware.RequireAuth(authService, apiKeyService))
+	api.Use(middleware.RateLimit(redisClient, cfg.RateLimit.Requests, cfg.RateLimit.Window, log))
+	read := middleware.RequirePermission(domain.PermissionRead)
+	query := middleware.RequirePermission(domain.PermissionQuery)
+	uploadDocuments := middleware.RequirePermission(domain.PermissionUploadDocuments)
+	deleteDocuments := middleware.RequirePermission(domain.PermissionDeleteDocuments)
+	editActions := middleware.RequirePermission(domain.PermissionEditActions)
+	deleteActions := middleware.RequirePermission(domain.PermissionDeleteActions)
+	manageComponents := middleware.RequirePermission(domain.PermissionManageComponents)
+	viewAnalytics := middleware.RequirePermission(domain.PermissionViewAnalytics)
+	manageUsers := middleware.RequirePermission(domain.PermissionManageUsers)
+	managePlatform := middleware.RequirePermission(domain.PermissionManagePlatform)
+
+	// Routes only reach sites the user may access (see
+	// service.SiteAccessService); records addressed by ID are checked against
+	// the site they belong to
+	api.Use(middleware.LoadSiteScope(siteAccessService))
+	site := middleware.RequireSiteAccess("siteId")
+	document := middleware.RequireEntitySiteAccess(siteAccessService, service.SiteResourceDocument, "id", "Document")
+	action := middleware.RequireEntitySiteAccess(siteAccessService, service.SiteResourceAction, "id", "Action")
+	component := middleware.RequireEntitySiteAccess(siteAccessService, service.SiteResourceComponent, "id", "Component")
+	componentActions := middleware.RequireEntitySiteAccess(siteAccessService, service.SiteResourceComponent, "componentId", "Component")
+	conversation := middleware.RequireEntitySiteAccess(siteAccessService, service.SiteResourceConversation, "id", "Conversation")
+	queryRecord := middleware.RequireEntitySiteAccess(siteAccessService, service.SiteResourceQuery, "id", "Query")
+
+	api.Get("/auth/me", authHandler.Me)
+
+	// User routes
+	api.Get("/users", manageUsers, userHandler.ListUsers)
+	api.Put("/users/:id/role", manageUsers, userHandler.UpdateUserRole)
+	api.Delete("/users/:id", manageUsers, userHandler.DeleteUser)
+	api.Put("/users/:id/organization", managePlatform, organizationHandler.AssignUser)
+	api.Get("/users/:id/site-grants", manageUsers, organizationHandler.ListSiteGrants)
+	api.Post("/users/:id/site-grants", manageUsers, organizationHandler.GrantSite)
+	api.Delete("/users/:id/site-grants/:siteId", manageUsers, organizationHandler.RevokeSite)
+
+	// API key routes - keys are for machine clients and act as their creator
+	api.Post("/api-keys", manageUsers, apiKeyHandler.CreateAPIKey)
+	api.Get("/api-keys", manageUsers, apiKeyHandler.ListAPIKeys)
+	api.Delete("/api-keys/:id", manageUsers, apiKeyHandler.RevokeAPIKey)
+
+	// Organization routes - only platform operators work across organizations
+	api.Post("/organizations", managePlatform, organizationHandler.CreateOrganization)
+	api.Get("/organizations", managePlatform, organizationHandler.ListOrganizations)
+	api.Get("/organizations/:id", managePlatform, organizationHandler.GetOrganization)
 
 	// Site routes
-	api.Get("/sites", siteHandler.ListSites)
-	api.Get("/sites/:id", siteHandler.GetSite)
+	api.Get("/sites", read, siteHandler.ListSites)
+	api.Get("/sites/:id", read, siteHandler.GetSite)
+	api.Put("/sites/:id/organization", managePlatform, organizationHandler.AssignSite)
 
 	// Document routes
-	api.Post("/sites/:siteId/documents", documentHandler.UploadDocument)
-	api.Get("/sites/:siteId/documents", documentHandler.ListDocuments)
-	api.Get("/documents/:id", documentHandler.GetDocument)
-	api.Delete("/documents/:id", documentHandler.DeleteDocument)
-	api.Post("/documents/:id/process", documentHandler.ProcessDocument)
-	api.Get("/sites/:siteId/documents/search", documentHandler.SearchDocuments)
+	api.Post("/sites/:siteId/documents", uploadDocuments, site, documentHandler.UploadDocument)
+	api.Get("/sites/:siteId/documents", read, site, documentHandler.ListDocuments)
+	api.Get("/sites/:siteId/documents/failed", read, site, documentHandler.ListFailedDocuments)
+	api.Post("/sites/:siteId/documents/failed/requeue", uploadDocuments, site, documentHandler.RequeueFailedDocuments)
+	api.Get("/documents/:id", read, document, documentHandler.GetDocument)
+	api.Delete("/documents/:id", deleteDocuments, document, documentHandler.DeleteDocument)
+	api.Post("/documents/:id/process", uploadDocuments, document, cancelOnDisconnect, documentHandler.ProcessDocument)
+	api.Get("/documents/:id/attachments", read, document, documentHandler.ListAttachments)
+	api.Post("/documents/:id/requeue", uploadDocuments, document, documentHandler.RequeueDocument)
+	api.Get("/sites/:siteId/documents/search", read, site, cancelOnDisconnect, documentHandler.SearchDocuments)
 
 	// Query routes - specific routes must come before parameterized routes
-	api.Post("/sites/:siteId/queries", queryHandler.CreateQuery)
-	api.Get("/queries/history", queryHandler.GetQueryHistory)
-	api.Get("/queries/:id", queryHandler.GetQuery)
-	api.Get("/sites/:siteId/queries/similar", queryHandler.SearchSimilarQueries)
-	api.Get("/sites/:siteId/analytics/queries", queryHandler.GetQueryAnalytics)
+	api.Post("/sites/:siteId/queries", query, site, cancelOnDisconnect, queryHandler.CreateQuery)
+	api.Get("/sites/:siteId/queries/stream", query, site, queryHandler.StreamQuery)
+	api.Post("/sites/:siteId/queries/stream", query, site, queryHandler.StreamQuery)
+	api.Get("/queries/history", read, queryHandler.GetQueryHistory)
+	api.Get("/queries/:id", read, queryRecord, queryHandler.GetQuery)
+	api.Get("/sites/:siteId/queries/similar", query, site, cancelOnDisconnect, queryHandler.SearchSimilarQueries)
+	api.Get("/sites/:siteId/analytics/queries", viewAnalytics, site, queryHandler.GetQueryAnalytics)
+
+	// LLM usage routes
+	api.Get("/sites/:siteId/analytics/llm-usage", viewAnalytics, site, usageHandler.GetSiteUsage)
+	api.Get("/analytics/llm-usage/sites", manageUsers, usageHandler.ListSiteUsage)
+
+	// Conversation routes - turns are asked through the query routes with a conversation_id
+	api.Post("/sites/:siteId/conversations", query, site, conversationHandler.CreateConversation)
+	api.Get("/sites/:siteId/conversations", read, site, conversationHandler.ListConversations)
+	api.Get("/conversations/:id", read, conversation, conversationHandler.GetConversation)
 
 	// Component routes
-	api.Post("/sites/:siteId/components", componentHandler.CreateComponent)
-	api.Get("/sites/:siteId/components", componentHandler.ListComponents)
-	api.Get("/components/:id", componentHandler.GetComponent)
-	api.Put("/components/:id", componentHandler.UpdateComponent)
-	api.Delete("/components/:id", componentHandler.DeleteComponent)
-	api.Get("/sites/:siteId/components/hierarchy", componentHandler.GetComponentHierarchy)
-	api.Get("/components/:id/maintenance-history", componentHandler.GetComponentMaintenanceHistory)
-	api.Post("/sites/:siteId/components/bulk", componentHandler.BulkCreateComponents)
+	api.Post("/sites/:siteId/components", manageComponents, site, componentHandler.CreateComponent)
+	api.Get("/sites/:siteId/components", read, site, componentHandler.ListComponents)
+	api.Get("/components/:id", read, component, componentHandler.GetComponent)
+	api.Put("/components/:id", manageComponents, component, componentHandler.UpdateComponent)
+	api.Delete("/components/:id", manageComponents, component, componentHandler.DeleteComponent)
+	api.Get("/sites/:siteId/components/hierarchy", read, site, componentHandler.GetComponentHierarchy)
+	api.Get("/components/:id/maintenance-history", read, component, componentHandler.GetComponentMaintenanceHistory)
+	api.Post("/sites/:siteId/components/bulk", manageComponents, site, componentHandler.BulkCreateComponents)
 
 	// Action routes
-	api.Get("/sites/:siteId/actions", actionHandler.ListActions)
-	api.Get("/actions/:id", actionHandler.GetAction)
-	api.Get("/components/:componentId/actions", actionHandler.GetActionsByComponent)
-	api.Get("/work-orders/:workOrder/actions", actionHandler.GetActionsByWorkOrder)
-	api.Get("/sites/:siteId/timeline", actionHandler.GetActionTimeline)
-	api.Put("/actions/:id", actionHandler.UpdateAction)
-	api.Delete("/actions/:id", actionHandler.DeleteAction)
-	api.Get("/sites/:siteId/actions/search", actionHandler.SearchActions)
+	api.Get("/sites/:siteId/actions", read, site, actionHandler.ListActions)
+	api.Get("/actions/:id", read, action, actionHandler.GetAction)
+	api.Get("/components/:componentId/actions", read, componentActions, actionHandler.GetActionsByComponent)
+	api.Get("/work-orders/:workOrder/actions", read, actionHandler.GetActionsByWorkOrder)
+	api.Get("/sites/:siteId/timeline", read, site, actionHandler.GetActionTimeline)
+	api.Put("/actions/:id", editActions, action, actionHandler.UpdateAction)
+	api.Delete("/actions/:id", deleteActions, action, actionHandler.DeleteAction)
+	api.Get("/sites/:siteId/actions/search", read, site, actionHandler.SearchActions)
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -159,8 +321,31 @@ func main() {
 		port = "8080"
 	}
 
-	log.Info("Starting server", "port", port)
-	if err := app.Listen(":" + port); err != nil {
-		log.Fatal("Failed to start server", "error", err)
+	shutdownCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	listenErr := make(chan error, 1)
+	go func() {
+		log.Info("Starting server", "port", port)
+		listenErr <- app.Listen(":" + port)
+	}()
+
+	var serverErr error
+	select {
+	case serverErr = <-listenErr:
+	case <-shutdownCtx.Done():
+		log.Info("Shutting down server")
+		if err := app.Shutdown(); err != nil {
+			log.Error("Failed to shut down server", "error", err)
+		}
+	}
+
+	// Stop taking documents and wait for the in-flight ones to finish
+	stopWorker()
+	<-workerDone
+
+	if serverErr != nil {
+		log.Fatal("Failed to start server", "error", serverErr)
 	}
-}
\ No newline at end of file
+	log.Info("Server stopped")
+}
@@ -13,9 +13,11 @@ type Config struct {
 	Database    DatabaseConfig
 	Redis       RedisConfig
 	JWT         JWTConfig
+	RateLimit   RateLimitConfig
 	LLM         LLMConfig
 	Storage     StorageConfig
 	Search      SearchConfig
+	Worker      WorkerConfig
 }
 
 type ServerConfig struct {
@@ -33,36 +35,100 @@ type RedisConfig struct {
 	URL         string
 	PoolSize    int
 	DialTimeout time.Duration
+	// QueryCacheTTL is how long enhanced query answers are cached; 0
+	// disables the cache
+	QueryCacheTTL time.Duration
 }
 
+// DefaultJWTSecret signs tokens when JWT_SECRET isn't set, which is refused
+// in production
+const DefaultJWTSecret = "your-secret-key-change-in-production"
+
 type JWTConfig struct {
-	Secret           string
-	AccessTokenTTL   time.Duration
-	RefreshTokenTTL  time.Duration
+	Secret          string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// CookieSecure marks the refresh token cookie Secure (HTTPS only)
+	CookieSecure bool
+	// OperatorEmail is the account made admin and platform operator when
+	// it registers
+	OperatorEmail string
+}
+
+// RateLimitConfig limits each user and API key to Requests per Window, and
+// each client IP to AuthRequests per Window on the sign-in routes; 0
+// disables a limit
+type RateLimitConfig struct {
+	Requests     int
+	AuthRequests int
+	Window       time.Duration
 }
 
 type LLMConfig struct {
-	Provider     string
-	APIKey       string
-	Model        string
-	Temperature  float64
-	MaxTokens    int
-	Timeout      time.Duration
-	StripPII     bool
+	// Provider is one of openai, azure, local (any OpenAI-compatible server
+	// such as Ollama or llama.cpp) or offline
+	Provider string
+	APIKey   string
+	BaseURL  string
+	Model    string
+	// EmbeddingModel is the embedding deployment name for Azure
+	EmbeddingModel  string
+	AzureAPIVersion string
+	Temperature     float64
+	MaxTokens       int
+	Timeout         time.Duration
+	StripPII        bool
+	// PIIRestore lists the redacted categories (person, email, phone,
+	// address, or all/none) whose originals are put back into LLM replies
+	PIIRestore string
+	// StructuredOutput sends JSON schemas with requests; turn it off for
+	// servers or API versions that only support plain JSON mode
+	StructuredOutput bool
+	// GroundingJudge is "llm" to have the LLM judge whether the sources
+	// support each claim of an answer, or "rules" for term overlap only
+	GroundingJudge string
+	// EmbeddingBatchSize caps the inputs sent in one embedding request
+	EmbeddingBatchSize int
+	// EmbeddingCacheTTL is how long embeddings are cached by content hash;
+	// 0 disables the cache
+	EmbeddingCacheTTL time.Duration
+	// Prices overrides the built-in model prices used to estimate costs, as
+	// model=prompt/completion in USD per million tokens, comma-separated
+	Prices string
 }
 
 type StorageConfig struct {
-	Provider      string
-	Endpoint      string
-	AccessKey     string
-	SecretKey     string
-	BucketName    string
-	UseSSL        bool
+	Provider   string
+	Endpoint   string
+	AccessKey  string
+	SecretKey  string
+	BucketName string
+	UseSSL     bool
 }
 
 type SearchConfig struct {
 	ElasticsearchURL string
-	Index           string
+	Index            string
+	// Retrieval fuses vector and keyword search; FusionMethod is "rrf"
+	// (reciprocal-rank fusion) or "weighted" (weighted normalised scores)
+	FusionMethod   string
+	RRFK           int
+	SemanticWeight float64
+	KeywordWeight  float64
+}
+
+type WorkerConfig struct {
+	Enabled       bool
+	Concurrency   int
+	PollInterval  time.Duration
+	SweepInterval time.Duration
+	// ProcessingTimeout bounds one attempt and must stay below
+	// StaleTimeout, which only catches documents whose worker died
+	ProcessingTimeout time.Duration
+	StaleTimeout      time.Duration
+	MaxAttempts       int
+	RetryBaseDelay    time.Duration
+	RetryMaxDelay     time.Duration
 }
 
 func Load() *Config {
@@ -78,35 +144,67 @@ func Load() *Config {
 			MaxIdleTime:    getEnvAsDuration("DB_MAX_IDLE_TIME", "15m"),
 		},
 		Redis: RedisConfig{
-			URL:         getEnvOrDefault("REDIS_URL", "redis://localhost:6379"),
-			PoolSize:    getEnvAsInt("REDIS_POOL_SIZE", 10),
-			DialTimeout: getEnvAsDuration("REDIS_DIAL_TIMEOUT", "5s"),
+			URL:           getEnvOrDefault("REDIS_URL", "redis://localhost:6379"),
+			PoolSize:      getEnvAsInt("REDIS_POOL_SIZE", 10),
+			DialTimeout:   getEnvAsDuration("REDIS_DIAL_TIMEOUT", "5s"),
+			QueryCacheTTL: getEnvAsDuration("QUERY_CACHE_TTL", "1h"),
 		},
 		JWT: JWTConfig{
-			Secret:           getEnvOrDefault("JWT_SECRET", "your-secret-key-change-in-production"),
-			AccessTokenTTL:   getEnvAsDuration("JWT_ACCESS_TTL", "15m"),
-			RefreshTokenTTL:  getEnvAsDuration("JWT_REFRESH_TTL", "168h"), // 7 days
+			Secret:          getEnvOrDefault("JWT_SECRET", DefaultJWTSecret),
+			AccessTokenTTL:  getEnvAsDuration("JWT_ACCESS_TTL", "15m"),
+			RefreshTokenTTL: getEnvAsDuration("JWT_REFRESH_TTL", "168h"), // 7 days
+			CookieSecure:    getEnvAsBool("JWT_COOKIE_SECURE", true),
+			OperatorEmail:   getEnvOrDefault("OPERATOR_EMAIL", ""),
+		},
+		RateLimit: RateLimitConfig{
+			Requests:     getEnvAsInt("RATE_LIMIT_REQUESTS", 300),
+			AuthRequests: getEnvAsInt("RATE_LIMIT_AUTH_REQUESTS", 20),
+			Window:       getEnvAsDuration("RATE_LIMIT_WINDOW", "1m"),
 		},
 		LLM: LLMConfig{
-			Provider:     getEnvOrDefault("LLM_PROVIDER", "openai"),
-			APIKey:       os.Getenv("OPENAI_API_KEY"),
-			Model:        getEnvOrDefault("LLM_MODEL", "gpt-4-turbo-preview"),
-			Temperature:  getEnvAsFloat("LLM_TEMPERATURE", 0.3),
-			MaxTokens:    getEnvAsInt("LLM_MAX_TOKENS", 2000),
-			Timeout:      getEnvAsDuration("LLM_TIMEOUT", "60s"),
-			StripPII:     getEnvAsBool("LLM_STRIP_PII", true),
+			Provider:           getEnvOrDefault("LLM_PROVIDER", "openai"),
+			APIKey:             getEnvOrDefault("OPENAI_API_KEY", os.Getenv("LLM_API_KEY")),
+			BaseURL:            os.Getenv("LLM_BASE_URL"),
+			Model:              getEnvOrDefault("LLM_MODEL", "gpt-4-turbo-preview"),
+			EmbeddingModel:     getEnvOrDefault("LLM_EMBEDDING_MODEL", "text-embedding-ada-002"),
+			AzureAPIVersion:    getEnvOrDefault("LLM_AZURE_API_VERSION", "2024-02-01"),
+			Temperature:        getEnvAsFloat("LLM_TEMPERATURE", 0.3),
+			MaxTokens:          getEnvAsInt("LLM_MAX_TOKENS", 2000),
+			Timeout:            getEnvAsDuration("LLM_TIMEOUT", "60s"),
+			StripPII:           getEnvAsBool("LLM_STRIP_PII", true),
+			PIIRestore:         getEnvOrDefault("LLM_PII_RESTORE", "all"),
+			StructuredOutput:   getEnvAsBool("LLM_STRUCTURED_OUTPUT", true),
+			GroundingJudge:     getEnvOrDefault("LLM_GROUNDING_JUDGE", "llm"),
+			EmbeddingBatchSize: getEnvAsInt("LLM_EMBEDDING_BATCH_SIZE", 64),
+			EmbeddingCacheTTL:  getEnvAsDuration("LLM_EMBEDDING_CACHE_TTL", "720h"), // 30 days
+			Prices:             os.Getenv("LLM_PRICES"),
 		},
 		Storage: StorageConfig{
-			Provider:      getEnvOrDefault("STORAGE_PROVIDER", "minio"),
-			Endpoint:      getEnvOrDefault("STORAGE_ENDPOINT", "localhost:9000"),
-			AccessKey:     getEnvOrDefault("STORAGE_ACCESS_KEY", "minioadmin"),
-			SecretKey:     getEnvOrDefault("STORAGE_SECRET_KEY", "minioadmin"),
-			BucketName:    getEnvOrDefault("STORAGE_BUCKET", "engramiq"),
-			UseSSL:        getEnvAsBool("STORAGE_USE_SSL", false),
+			Provider:   getEnvOrDefault("STORAGE_PROVIDER", "minio"),
+			Endpoint:   getEnvOrDefault("STORAGE_ENDPOINT", "localhost:9000"),
+			AccessKey:  getEnvOrDefault("STORAGE_ACCESS_KEY", "minioadmin"),
+			SecretKey:  getEnvOrDefault("STORAGE_SECRET_KEY", "minioadmin"),
+			BucketName: getEnvOrDefault("STORAGE_BUCKET", "engramiq"),
+			UseSSL:     getEnvAsBool("STORAGE_USE_SSL", false),
 		},
 		Search: SearchConfig{
 			ElasticsearchURL: getEnvOrDefault("ELASTICSEARCH_URL", "http://localhost:9200"),
-			Index:           getEnvOrDefault("ELASTICSEARCH_INDEX", "engramiq"),
+			Index:            getEnvOrDefault("ELASTICSEARCH_INDEX", "engramiq"),
+			FusionMethod:     getEnvOrDefault("SEARCH_FUSION_METHOD", "rrf"),
+			RRFK:             getEnvAsInt("SEARCH_RRF_K", 60),
+			SemanticWeight:   getEnvAsFloat("SEARCH_SEMANTIC_WEIGHT", 0.5),
+			KeywordWeight:    getEnvAsFloat("SEARCH_KEYWORD_WEIGHT", 0.5),
+		},
+		Worker: WorkerConfig{
+			Enabled:           getEnvAsBool("WORKER_ENABLED", true),
+			Concurrency:       getEnvAsInt("WORKER_CONCURRENCY", 4),
+			PollInterval:      getEnvAsDuration("WORKER_POLL_INTERVAL", "2s"),
+			SweepInterval:     getEnvAsDuration("WORKER_SWEEP_INTERVAL", "1m"),
+			ProcessingTimeout: getEnvAsDuration("WORKER_PROCESSING_TIMEOUT", "10m"),
+			StaleTimeout:      getEnvAsDuration("WORKER_STALE_TIMEOUT", "15m"),
+			MaxAttempts:       getEnvAsInt("WORKER_MAX_ATTEMPTS", 5),
+			RetryBaseDelay:    getEnvAsDuration("WORKER_RETRY_BASE_DELAY", "30s"),
+			RetryMaxDelay:     getEnvAsDuration("WORKER_RETRY_MAX_DELAY", "30m"),
 		},
 	}
 }
@@ -161,4 +259,4 @@ func getEnvAsDuration(key string, defaultValue string) time.Duration {
 		return d
 	}
 	return duration
-}
\ No newline at end of file
+}
@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix starts every API key, telling them apart from JWT access
+// tokens in the Authorization header
+const APIKeyPrefix = "eiq_"
+
+// APIKeyScope is what a machine client may do with a key
+type APIKeyScope string
+
+const (
+	APIKeyScopeRead   APIKeyScope = "read"
+	APIKeyScopeUpload APIKeyScope = "upload"
+	APIKeyScopeQuery  APIKeyScope = "query"
+)
+
+// apiKeyScopePermissions maps each scope to the permissions it grants
+var apiKeyScopePermissions = map[APIKeyScope][]Permission{
+	APIKeyScopeRead:   {PermissionRead},
+	APIKeyScopeUpload: {PermissionUploadDocuments},
+	APIKeyScopeQuery:  {PermissionQuery},
+}
+
+// Valid reports whether s is one of the declared scopes
+func (s APIKeyScope) Valid() bool {
+	_, ok := apiKeyScopePermissions[s]
+	return ok
+}
+
+// APIKey lets a machine client such as a SCADA bridge call the API without
+// signing in. It acts as the admin who created it, limited to its scopes
+// and sites. Only a hash of the key is stored; revoked keys are soft
+// deleted.
+type APIKey struct {
+	ID   uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name string    `json:"name" gorm:"type:varchar(255);not null"`
+	// Prefix is the start of the key, shown so keys can be told apart
+	Prefix     string         `json:"prefix" gorm:"type:varchar(16);not null"`
+	KeyHash    string         `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserID     uuid.UUID      `json:"user_id" gorm:"type:uuid;not null;index"`
+	Scopes     pq.StringArray `json:"scopes" gorm:"type:text[];not null"`
+	SiteIDs    pq.StringArray `json:"site_ids" gorm:"type:uuid[];not null"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time     `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// Expired reports whether the key is past its expiry at now
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// Can reports whether one of the key's scopes grants permission. The
+// creator's role must grant it too.
+func (k *APIKey) Can(permission Permission) bool {
+	for _, scope := range k.Scopes {
+		for _, granted := range apiKeyScopePermissions[APIKeyScope(scope)] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AllowedSites lists the sites the key may reach
+func (k *APIKey) AllowedSites() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(k.SiteIDs))
+	for _, raw := range k.SiteIDs {
+		if id, err := uuid.Parse(raw); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// CreateAPIKeyRequest creates an API key
+type CreateAPIKeyRequest struct {
+	Name      string        `json:"name" validate:"required,min=2,max=255"`
+	Scopes    []APIKeyScope `json:"scopes" validate:"required,min=1"`
+	SiteIDs   []uuid.UUID   `json:"site_ids" validate:"required,min=1"`
+	ExpiresAt *time.Time    `json:"expires_at,omitempty"`
+}
+
+// CreatedAPIKey is a new key with its secret, which is only ever returned
+// once
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
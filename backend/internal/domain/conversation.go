@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Conversation groups enhanced queries into a multi-turn session. Each turn
+// is a UserQuery; follow-ups are rewritten into standalone queries using the
+// earlier turns before retrieval.
+type Conversation struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	SiteID    uuid.UUID `json:"site_id" gorm:"type:uuid;not null;index"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Title     string    `json:"title" gorm:"type:varchar(500)"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relationships
+	Site  *Site       `json:"site,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	Turns []UserQuery `json:"turns,omitempty" gorm:"foreignKey:ConversationID"`
+}
+
+func (Conversation) TableName() string {
+	return "conversations"
+}
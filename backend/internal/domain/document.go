@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,17 +15,24 @@ type DocumentType string
 
 const (
 	DocumentTypeFieldServiceReport DocumentType = "field_service_report"
-	DocumentTypeEmail             DocumentType = "email"
-	DocumentTypeMeetingTranscript DocumentType = "meeting_transcript"
-	DocumentTypeWorkOrder         DocumentType = "work_order"
-	DocumentTypeInspectionReport  DocumentType = "inspection_report"
-	DocumentTypeWarrantyClaim     DocumentType = "warranty_claim"
-	DocumentTypeContract          DocumentType = "contract"
-	DocumentTypeManual           DocumentType = "manual"
-	DocumentTypeDrawing          DocumentType = "drawing"
-	DocumentTypeOther            DocumentType = "other"
+	DocumentTypeEmail              DocumentType = "email"
+	DocumentTypeMeetingTranscript  DocumentType = "meeting_transcript"
+	DocumentTypeWorkOrder          DocumentType = "work_order"
+	DocumentTypeInspectionReport   DocumentType = "inspection_report"
+	DocumentTypeWarrantyClaim      DocumentType = "warranty_claim"
+	DocumentTypeContract           DocumentType = "contract"
+	DocumentTypeManual             DocumentType = "manual"
+	DocumentTypeDrawing            DocumentType = "drawing"
+	DocumentTypeOther              DocumentType = "other"
 )
 
+// DocumentTypes lists the values of the document_type database enum
+var DocumentTypes = []DocumentType{
+	DocumentTypeFieldServiceReport, DocumentTypeEmail, DocumentTypeMeetingTranscript,
+	DocumentTypeWorkOrder, DocumentTypeInspectionReport, DocumentTypeWarrantyClaim,
+	DocumentTypeContract, DocumentTypeManual, DocumentTypeDrawing, DocumentTypeOther,
+}
+
 type ProcessingStatus string
 
 const (
@@ -30,51 +40,231 @@ const (
 	ProcessingStatusProcessing ProcessingStatus = "processing"
 	ProcessingStatusCompleted  ProcessingStatus = "completed"
 	ProcessingStatusFailed     ProcessingStatus = "failed"
+	// Documents that exhausted their retry budget; only a manual requeue
+	// puts them back on the processing queue
+	ProcessingStatusDeadLetter ProcessingStatus = "dead_letter"
 )
 
 type Document struct {
-	ID                     uuid.UUID        `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	SiteID                 uuid.UUID        `json:"site_id" gorm:"type:uuid;not null"`
-	Site                   *Site            `json:"site,omitempty" gorm:"constraint:OnDelete:CASCADE"`
-	DocumentType           DocumentType     `json:"document_type" gorm:"type:document_type;not null"`
-	Title                  string           `json:"title" gorm:"type:varchar(500)"`
-	SourceType             string           `json:"source_type" gorm:"type:varchar(100)"`
-	SourceIdentifier       string           `json:"source_identifier" gorm:"type:varchar(255)"`
-	RawContent             string           `json:"raw_content,omitempty"`
-	ProcessedContent       string           `json:"processed_content,omitempty"`
-	ContentHash            string           `json:"content_hash" gorm:"type:varchar(64)"`
-	OriginalFilename       string           `json:"original_filename" gorm:"type:varchar(500)"`
-	FileSize               int64            `json:"file_size"`
-	MimeType               string           `json:"mime_type" gorm:"type:varchar(100)"`
-	StoragePath            string           `json:"storage_path" gorm:"type:varchar(1000)"`
-	ProcessingStatus       ProcessingStatus `json:"processing_status" gorm:"type:varchar(50);default:'pending'"`
-	ProcessingStartedAt    *time.Time       `json:"processing_started_at"`
-	ProcessingCompletedAt  *time.Time       `json:"processing_completed_at"`
-	DocumentDate           *time.Time       `json:"document_date"`
-	AuthorName             string           `json:"author_name" gorm:"type:varchar(255)"`
-	AuthorEmail            string           `json:"author_email" gorm:"type:varchar(255)"`
-	DocumentMetadata       JSON             `json:"document_metadata" gorm:"type:jsonb;default:'{}'"`
-	Embedding              pgvector.Vector  `json:"-" gorm:"type:vector(1536)"`
-	ContentVector          string           `json:"-" gorm:"type:tsvector"`
-	UploadedBy             *uuid.UUID       `json:"uploaded_by" gorm:"type:uuid"`
-	CreatedAt              time.Time        `json:"created_at"`
-	UpdatedAt              time.Time        `json:"updated_at"`
-	DeletedAt              gorm.DeletedAt   `json:"deleted_at,omitempty" gorm:"index"`
+	ID     uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	SiteID uuid.UUID `json:"site_id" gorm:"type:uuid;not null"`
+	Site   *Site     `json:"site,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	// ParentDocumentID links attachments to the email they arrived with
+	ParentDocumentID      *uuid.UUID       `json:"parent_document_id,omitempty" gorm:"type:uuid;index"`
+	DocumentType          DocumentType     `json:"document_type" gorm:"type:document_type;not null"`
+	Title                 string           `json:"title" gorm:"type:varchar(500)"`
+	SourceType            string           `json:"source_type" gorm:"type:varchar(100)"`
+	SourceIdentifier      string           `json:"source_identifier" gorm:"type:varchar(255)"`
+	RawContent            string           `json:"raw_content,omitempty"`
+	ProcessedContent      string           `json:"processed_content,omitempty"`
+	ContentHash           string           `json:"content_hash" gorm:"type:varchar(64)"`
+	OriginalFilename      string           `json:"original_filename" gorm:"type:varchar(500)"`
+	FileSize              int64            `json:"file_size"`
+	MimeType              string           `json:"mime_type" gorm:"type:varchar(100)"`
+	StoragePath           string           `json:"storage_path" gorm:"type:varchar(1000)"`
+	ProcessingStatus      ProcessingStatus `json:"processing_status" gorm:"type:varchar(50);default:'pending'"`
+	ProcessingStartedAt   *time.Time       `json:"processing_started_at"`
+	ProcessingCompletedAt *time.Time       `json:"processing_completed_at"`
+	ProcessingAttempts    int              `json:"processing_attempts" gorm:"default:0"`
+	LastProcessingError   string           `json:"last_processing_error,omitempty" gorm:"type:text"`
+	NextRetryAt           *time.Time       `json:"next_retry_at,omitempty"`
+	// ChunkedAt is when chunking last ran, set even when it yielded no chunks
+	ChunkedAt        *time.Time      `json:"chunked_at,omitempty"`
+	DocumentDate     *time.Time      `json:"document_date"`
+	AuthorName       string          `json:"author_name" gorm:"type:varchar(255)"`
+	AuthorEmail      string          `json:"author_email" gorm:"type:varchar(255)"`
+	DocumentMetadata JSON            `json:"document_metadata" gorm:"type:jsonb;default:'{}'"`
+	Embedding        pgvector.Vector `json:"-" gorm:"type:vector(1536)"`
+	ContentVector    string          `json:"-" gorm:"type:tsvector"`
+	UploadedBy       *uuid.UUID      `json:"uploaded_by" gorm:"type:uuid"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt  `json:"deleted_at,omitempty" gorm:"index"`
 }
 
 func (Document) TableName() string {
 	return "documents"
 }
 
+// DocumentSection marks where a heading starts in ProcessedContent. Extractors
+// that understand document structure store these under the "sections" key of
+// DocumentMetadata.
+type DocumentSection struct {
+	Title  string `json:"title"`
+	Level  int    `json:"level"`
+	Offset int    `json:"offset"`
+}
+
+// Sections returns the heading outline recorded at extraction time
+func (d *Document) Sections() []DocumentSection {
+	var sections []DocumentSection
+	decodeMetadata(d.DocumentMetadata, "sections", &sections)
+	return sections
+}
+
+// SectionForRange returns the heading path (e.g. "Findings > Inverter 31")
+// covering the content between start and end. The section in effect at start
+// wins; if start precedes every heading, the first heading inside the range
+// is used instead.
+func (d *Document) SectionForRange(start, end int) string {
+	sections := d.Sections()
+	if len(sections) == 0 {
+		return ""
+	}
+
+	// Track the open heading at each level so nested headings form a path
+	var path []DocumentSection
+	for _, section := range sections {
+		if section.Offset > start {
+			if len(path) == 0 && section.Offset < end {
+				path = append(path, section)
+			}
+			break
+		}
+		for len(path) > 0 && path[len(path)-1].Level >= section.Level {
+			path = path[:len(path)-1]
+		}
+		path = append(path, section)
+	}
+
+	titles := make([]string, len(path))
+	for i, section := range path {
+		titles[i] = section.Title
+	}
+	return strings.Join(titles, " > ")
+}
+
+// DocumentPage marks where a page starts in ProcessedContent. The PDF
+// extractor stores these under the "pages" key of DocumentMetadata.
+type DocumentPage struct {
+	Number int `json:"number"`
+	Offset int `json:"offset"`
+}
+
+// Pages returns the page boundaries recorded at extraction time
+func (d *Document) Pages() []DocumentPage {
+	var pages []DocumentPage
+	decodeMetadata(d.DocumentMetadata, "pages", &pages)
+	return pages
+}
+
+// PageForRange returns the page the content between start and end begins on,
+// or nil when the document has no page information
+func (d *Document) PageForRange(start, end int) *int {
+	var page *int
+	for _, p := range d.Pages() {
+		if p.Offset > start {
+			if page == nil && p.Offset < end {
+				number := p.Number
+				page = &number
+			}
+			break
+		}
+		number := p.Number
+		page = &number
+	}
+	return page
+}
+
+// SpeakerTurn marks where a speaker starts talking in a transcript's
+// ProcessedContent. Transcript extractors store these under the
+// "speaker_turns" key of DocumentMetadata.
+type SpeakerTurn struct {
+	Speaker      string `json:"speaker"`
+	Start        string `json:"start"` // hh:mm:ss
+	StartSeconds int    `json:"start_seconds"`
+	Offset       int    `json:"offset"`
+}
+
+// SpeakerTurns returns the transcript turns recorded at extraction time
+func (d *Document) SpeakerTurns() []SpeakerTurn {
+	var turns []SpeakerTurn
+	decodeMetadata(d.DocumentMetadata, "speaker_turns", &turns)
+	return turns
+}
+
+// SpeakerTurnForRange returns a "Speaker @ 00:12:34" reference for the turn
+// in effect at start, or the first turn inside the range
+func (d *Document) SpeakerTurnForRange(start, end int) string {
+	var current *SpeakerTurn
+	turns := d.SpeakerTurns()
+	for i := range turns {
+		if turns[i].Offset > start {
+			if current == nil && turns[i].Offset < end {
+				current = &turns[i]
+			}
+			break
+		}
+		current = &turns[i]
+	}
+
+	if current == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s @ %s", current.Speaker, current.Start)
+}
+
+// ReferenceForRange returns the most specific location reference available
+// for a span of ProcessedContent: the speaker turn for transcripts, otherwise
+// the heading path
+func (d *Document) ReferenceForRange(start, end int) string {
+	if ref := d.SpeakerTurnForRange(start, end); ref != "" {
+		return ref
+	}
+	return d.SectionForRange(start, end)
+}
+
+// decodeMetadata converts a metadata value back into a typed struct. Values
+// read from JSONB come back as generic maps, so a JSON round trip is the
+// simplest way to recover the original shape.
+func decodeMetadata(metadata JSON, key string, out interface{}) bool {
+	value, ok := metadata[key]
+	if !ok || value == nil {
+		return false
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(raw, out) == nil
+}
+
 type DocumentWithStats struct {
 	Document
 	ExtractedActionsCount int `json:"extracted_actions_count"`
 }
 
+// DocumentChunk is an overlapping window of a document's ProcessedContent with
+// its own embedding and full-text vector, so long manuals and multi-visit
+// reports can be matched on any topic rather than only their first one.
+// Offsets refer to ProcessedContent.
+type DocumentChunk struct {
+	ID               uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	DocumentID       uuid.UUID       `json:"document_id" gorm:"type:uuid;not null;index"`
+	Document         *Document       `json:"document,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	SiteID           uuid.UUID       `json:"site_id" gorm:"type:uuid;not null;index"`
+	ChunkIndex       int             `json:"chunk_index" gorm:"not null"`
+	Content          string          `json:"content" gorm:"type:text;not null"`
+	StartOffset      int             `json:"start_offset"`
+	EndOffset        int             `json:"end_offset"`
+	PageNumber       *int            `json:"page_number,omitempty"`
+	SectionReference string          `json:"section_reference,omitempty" gorm:"type:varchar(255)"`
+	Embedding        pgvector.Vector `json:"-" gorm:"type:vector(1536)"`
+	ContentVector    string          `json:"-" gorm:"type:tsvector"`
+	CreatedAt        time.Time       `json:"created_at"`
+
+	// Score is filled in by search queries (similarity or text rank)
+	Score float64 `json:"score,omitempty" gorm:"->;-:migration"`
+}
+
+func (DocumentChunk) TableName() string {
+	return "document_chunks"
+}
+
 type DocumentProcessingResult struct {
-	DocumentID      uuid.UUID         `json:"document_id"`
-	Status          ProcessingStatus  `json:"processing_status"`
+	DocumentID       uuid.UUID         `json:"document_id"`
+	Status           ProcessingStatus  `json:"processing_status"`
 	ExtractedActions []ExtractedAction `json:"extracted_actions,omitempty"`
-	Errors          []string          `json:"errors,omitempty"`
-	ProcessingTime  time.Duration     `json:"processing_time"`
-}
\ No newline at end of file
+	Errors           []string          `json:"errors,omitempty"`
+	ProcessingTime   time.Duration     `json:"processing_time"`
+}
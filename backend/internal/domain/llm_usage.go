@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LLMCallType distinguishes chat completions from embedding requests
+type LLMCallType string
+
+const (
+	LLMCallChat      LLMCallType = "chat"
+	LLMCallEmbedding LLMCallType = "embedding"
+)
+
+// LLMUsage records a single call to the LLM provider: what it cost and what
+// triggered it. Site, document and query are set when the call was made on
+// their behalf. Rows are kept when the site or document is deleted so past
+// costs still add up.
+type LLMUsage struct {
+	ID               uuid.UUID   `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	SiteID           *uuid.UUID  `json:"site_id,omitempty" gorm:"type:uuid"`
+	DocumentID       *uuid.UUID  `json:"document_id,omitempty" gorm:"type:uuid;index"`
+	QueryID          *uuid.UUID  `json:"query_id,omitempty" gorm:"type:uuid;index"`
+	Operation        string      `json:"operation" gorm:"type:varchar(50);not null"`
+	CallType         LLMCallType `json:"call_type" gorm:"type:varchar(20);not null"`
+	Provider         string      `json:"provider" gorm:"type:varchar(50)"`
+	Model            string      `json:"model" gorm:"type:varchar(100)"`
+	PromptTokens     int         `json:"prompt_tokens"`
+	CompletionTokens int         `json:"completion_tokens"`
+	TotalTokens      int         `json:"total_tokens"`
+	LatencyMs        int         `json:"latency_ms"`
+	EstimatedCostUSD float64     `json:"estimated_cost_usd"`
+	Succeeded        bool        `json:"succeeded"`
+	CreatedAt        time.Time   `json:"created_at"`
+}
+
+func (LLMUsage) TableName() string {
+	return "llm_usage"
+}
+
+// LLMUsageTotals sums the calls in a group. Key names the group: a site ID,
+// a day (YYYY-MM-DD), an operation or a model.
+type LLMUsageTotals struct {
+	Key              string  `json:"key"`
+	Calls            int64   `json:"calls"`
+	FailedCalls      int64   `json:"failed_calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+	AverageLatencyMs float64 `json:"average_latency_ms"`
+}
+
+// LLMUsageReport is a site's LLM usage over a date range, in total and
+// broken down by day, operation and model
+type LLMUsageReport struct {
+	SiteID      uuid.UUID        `json:"site_id"`
+	Total       LLMUsageTotals   `json:"total"`
+	ByDay       []LLMUsageTotals `json:"by_day"`
+	ByOperation []LLMUsageTotals `json:"by_operation"`
+	ByModel     []LLMUsageTotals `json:"by_model"`
+}
@@ -0,0 +1,111 @@
+package domain
+
+import "github.com/google/uuid"
+
+// Permission is an action a role may be allowed to take
+type Permission string
+
+const (
+	// Reading sites, documents, components, actions and the timeline
+	PermissionRead Permission = "read"
+	// Asking queries and holding conversations
+	PermissionQuery Permission = "query"
+	// Uploading, processing and requeueing documents
+	PermissionUploadDocuments Permission = "documents:upload"
+	PermissionDeleteDocuments Permission = "documents:delete"
+	// Correcting extracted actions
+	PermissionEditActions   Permission = "actions:edit"
+	PermissionDeleteActions Permission = "actions:delete"
+	// Creating, updating and deleting site components
+	PermissionManageComponents Permission = "components:manage"
+	// Query analytics and LLM usage of a site
+	PermissionViewAnalytics Permission = "analytics:view"
+	// Listing users of the organization, changing their roles, site grants
+	// and API keys and deleting them, and reports across its sites
+	PermissionManageUsers Permission = "users:manage"
+	// Creating organizations and moving users and sites between them. No
+	// role grants it; only platform operators have it.
+	PermissionManagePlatform Permission = "platform:manage"
+)
+
+var (
+	viewerPermissions = []Permission{
+		PermissionRead,
+		PermissionQuery,
+	}
+	technicianPermissions = grant(viewerPermissions,
+		PermissionUploadDocuments,
+		PermissionEditActions,
+	)
+	managerPermissions = grant(technicianPermissions,
+		PermissionDeleteDocuments,
+		PermissionDeleteActions,
+		PermissionManageComponents,
+		PermissionViewAnalytics,
+	)
+	adminPermissions = grant(managerPermissions,
+		PermissionManageUsers,
+	)
+)
+
+// rolePermissions is the permission matrix. Each role can do everything the
+// role before it can.
+var rolePermissions = map[UserRole][]Permission{
+	UserRoleViewer:     viewerPermissions,
+	UserRoleTechnician: technicianPermissions,
+	UserRoleManager:    managerPermissions,
+	UserRoleAdmin:      adminPermissions,
+}
+
+// grant is base with more permissions added
+func grant(base []Permission, more ...Permission) []Permission {
+	return append(append([]Permission(nil), base...), more...)
+}
+
+// Valid reports whether r is one of the declared roles
+func (r UserRole) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role grants permission
+func (r UserRole) Can(permission Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions lists what the role grants
+func (r UserRole) Permissions() []Permission {
+	return append([]Permission(nil), rolePermissions[r]...)
+}
+
+// Can reports whether the user may take an action: what their role grants,
+// and managing the platform for platform operators
+func (u *User) Can(permission Permission) bool {
+	if permission == PermissionManagePlatform {
+		return u.PlatformOperator
+	}
+	return u.Role.Can(permission)
+}
+
+// Permissions lists what the user may do
+func (u *User) Permissions() []Permission {
+	permissions := u.Role.Permissions()
+	if u.PlatformOperator {
+		permissions = append(permissions, PermissionManagePlatform)
+	}
+	return permissions
+}
+
+// Administers reports whether u may administer users and keys of an
+// organization: platform operators any, everyone else only their own
+func (u *User) Administers(orgID *uuid.UUID) bool {
+	if u.PlatformOperator {
+		return true
+	}
+	return u.OrganizationID != nil && orgID != nil && *u.OrganizationID == *orgID
+}
@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRoleCan(t *testing.T) {
+	// Each row lists the roles, from viewer up, that grant the permission
+	tests := []struct {
+		permission Permission
+		viewer     bool
+		technician bool
+		manager    bool
+		admin      bool
+	}{
+		{PermissionRead, true, true, true, true},
+		{PermissionQuery, true, true, true, true},
+		{PermissionUploadDocuments, false, true, true, true},
+		{PermissionEditActions, false, true, true, true},
+		{PermissionDeleteDocuments, false, false, true, true},
+		{PermissionDeleteActions, false, false, true, true},
+		{PermissionManageComponents, false, false, true, true},
+		{PermissionViewAnalytics, false, false, true, true},
+		{PermissionManageUsers, false, false, false, true},
+		{PermissionManagePlatform, false, false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.permission), func(t *testing.T) {
+			want := map[UserRole]bool{
+				UserRoleViewer:     tt.viewer,
+				UserRoleTechnician: tt.technician,
+				UserRoleManager:    tt.manager,
+				UserRoleAdmin:      tt.admin,
+				UserRole("owner"):  false,
+				UserRole(""):       false,
+			}
+			for role, allowed := range want {
+				if got := role.Can(tt.permission); got != allowed {
+					t.Errorf("%q.Can(%q) = %v, want %v", role, tt.permission, got, allowed)
+				}
+			}
+		})
+	}
+}
+
+func TestRolePermissionsMatchCan(t *testing.T) {
+	for _, role := range []UserRole{UserRoleViewer, UserRoleTechnician, UserRoleManager, UserRoleAdmin} {
+		if !role.Valid() {
+			t.Errorf("%q.Valid() = false, want true", role)
+		}
+		for _, permission := range role.Permissions() {
+			if !role.Can(permission) {
+				t.Errorf("%q lists %q but Can() denies it", role, permission)
+			}
+		}
+	}
+	if UserRole("owner").Valid() {
+		t.Error(`"owner".Valid() = true, want false`)
+	}
+}
+
+func TestUserCan(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       User
+		permission Permission
+		want       bool
+	}{
+		{name: "role grants", user: User{Role: UserRoleManager}, permission: PermissionViewAnalytics, want: true},
+		{name: "role denies", user: User{Role: UserRoleViewer}, permission: PermissionUploadDocuments, want: false},
+		{name: "operator manages the platform", user: User{Role: UserRoleViewer, PlatformOperator: true}, permission: PermissionManagePlatform, want: true},
+		{name: "admin doesn't manage the platform", user: User{Role: UserRoleAdmin}, permission: PermissionManagePlatform, want: false},
+		{name: "operator keeps their role's limits", user: User{Role: UserRoleViewer, PlatformOperator: true}, permission: PermissionDeleteDocuments, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.Can(tt.permission); got != tt.want {
+				t.Errorf("Can(%q) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserAdministers(t *testing.T) {
+	org := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	otherOrg := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+
+	tests := []struct {
+		name  string
+		user  User
+		orgID *uuid.UUID
+		want  bool
+	}{
+		{name: "own organization", user: User{OrganizationID: &org}, orgID: &org, want: true},
+		{name: "other organization", user: User{OrganizationID: &org}, orgID: &otherOrg, want: false},
+		{name: "user without organization", user: User{}, orgID: &org, want: false},
+		{name: "target without organization", user: User{OrganizationID: &org}, orgID: nil, want: false},
+		{name: "operator", user: User{PlatformOperator: true}, orgID: &otherOrg, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.Administers(tt.orgID); got != tt.want {
+				t.Errorf("Administers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,55 +17,80 @@ const (
 	QueryTypeFaultAnalysis      QueryType = "fault_analysis"
 	QueryTypeScheduledEvents    QueryType = "scheduled_events"
 	QueryTypePerformanceMetrics QueryType = "performance_metrics"
-	QueryTypeGeneral           QueryType = "general"
+	QueryTypeGeneral            QueryType = "general"
 )
 
 // QueryRequest represents a natural language query from the user
 type QueryRequest struct {
-	Query               string `json:"query" validate:"required,min=3,max=500"`
+	Query                string `json:"query" validate:"required,min=3,max=500"`
 	IncludeRelatedEvents bool   `json:"include_related_events"`
 }
 
 // QueryResponse contains the AI-generated answer with sources
 type QueryResponse struct {
-	Answer         string                `json:"answer"`
-	Sources        []QuerySourceDetail   `json:"sources"`
-	RelatedEvents  []TimelineEvent       `json:"related_events,omitempty"`
-	Confidence     float64               `json:"confidence"`
-	QueryType      QueryType             `json:"query_type"`
-	ExecutionTime  int                   `json:"execution_time_ms"`
+	Answer        string              `json:"answer"`
+	Sources       []QuerySourceDetail `json:"sources"`
+	RelatedEvents []TimelineEvent     `json:"related_events,omitempty"`
+	Confidence    float64             `json:"confidence"`
+	QueryType     QueryType           `json:"query_type"`
+	ExecutionTime int                 `json:"execution_time_ms"`
 }
 
-
 // QuerySuggestion for autocomplete/suggestions
 type QuerySuggestion struct {
-	Query       string    `json:"query"`
-	QueryType   QueryType `json:"query_type"`
-	Popularity  int       `json:"popularity"`
+	Query      string    `json:"query"`
+	QueryType  QueryType `json:"query_type"`
+	Popularity int       `json:"popularity"`
 }
 
-// QueryAnalytics tracks usage patterns for optimization
+// QueryAnalytics tracks usage patterns for optimization. A row is recorded
+// for every query run, answered, rejected by the content filter or failed.
 type QueryAnalytics struct {
 	ID                uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 	SiteID            uuid.UUID  `json:"site_id" gorm:"type:uuid;not null"`
 	UserID            *uuid.UUID `json:"user_id" gorm:"type:uuid"`
+	QueryID           *uuid.UUID `json:"query_id,omitempty" gorm:"type:uuid;index"`
 	QueryText         string     `json:"query_text" gorm:"not null"`
 	QueryType         QueryType  `json:"query_type" gorm:"type:varchar(50)"`
 	ResultsCount      int        `json:"results_count"`
 	ResponseGenerated bool       `json:"response_generated" gorm:"default:false"`
-	ExecutionTimeMs   int        `json:"execution_time_ms"`
-	SearchTimeMs      int        `json:"search_time_ms"`
-	LLMTimeMs         int        `json:"llm_time_ms"`
-	SessionID         uuid.UUID  `json:"session_id" gorm:"type:uuid"`
-	UserAgent         string     `json:"user_agent"`
-	CreatedAt         time.Time  `json:"created_at"`
-	
-	// Aggregated analytics fields (computed, not stored)
-	TotalQueries        int64             `json:"total_queries" gorm:"-"`
-	SuccessfulQueries   int64             `json:"successful_queries" gorm:"-"`
-	SuccessRate         float64           `json:"success_rate" gorm:"-"`
-	AverageResponseTime float64           `json:"average_response_time" gorm:"-"`
-	QueryTypeBreakdown  map[string]int64  `json:"query_type_breakdown" gorm:"-"`
+	// Rejected queries were refused by the content filter for RejectionReason
+	Rejected        bool       `json:"rejected" gorm:"default:false"`
+	RejectionReason string     `json:"rejection_reason,omitempty" gorm:"type:varchar(255)"`
+	Cached          bool       `json:"cached" gorm:"default:false"`
+	ExecutionTimeMs int        `json:"execution_time_ms"`
+	SearchTimeMs    int        `json:"search_time_ms"`
+	LLMTimeMs       int        `json:"llm_time_ms"`
+	SessionID       *uuid.UUID `json:"session_id" gorm:"type:uuid"`
+	UserAgent       string     `json:"user_agent"`
+	CreatedAt       time.Time  `json:"created_at"`
+
+	// Aggregated analytics fields (computed, not stored); times are in
+	// milliseconds
+	TotalQueries             int64               `json:"total_queries" gorm:"-"`
+	SuccessfulQueries        int64               `json:"successful_queries" gorm:"-"`
+	RejectedQueries          int64               `json:"rejected_queries" gorm:"-"`
+	FailedQueries            int64               `json:"failed_queries" gorm:"-"`
+	CachedQueries            int64               `json:"cached_queries" gorm:"-"`
+	SuccessRate              float64             `json:"success_rate" gorm:"-"`
+	AverageResponseTime      float64             `json:"average_response_time" gorm:"-"`
+	AverageSearchTime        float64             `json:"average_search_time" gorm:"-"`
+	AverageLLMTime           float64             `json:"average_llm_time" gorm:"-"`
+	QueryTypeBreakdown       map[string]int64    `json:"query_type_breakdown" gorm:"-"`
+	RejectionReasonBreakdown map[string]int64    `json:"rejection_reason_breakdown" gorm:"-"`
+	DailyBreakdown           []QueryAnalyticsDay `json:"daily_breakdown" gorm:"-"`
+}
+
+// QueryAnalyticsDay totals a day's queries (UTC); times are in milliseconds
+type QueryAnalyticsDay struct {
+	Date                string  `json:"date"`
+	TotalQueries        int64   `json:"total_queries"`
+	SuccessfulQueries   int64   `json:"successful_queries"`
+	RejectedQueries     int64   `json:"rejected_queries"`
+	FailedQueries       int64   `json:"failed_queries"`
+	AverageResponseTime float64 `json:"average_response_time"`
+	AverageSearchTime   float64 `json:"average_search_time"`
+	AverageLLMTime      float64 `json:"average_llm_time"`
 }
 
 func (QueryAnalytics) TableName() string {
@@ -74,41 +99,66 @@ func (QueryAnalytics) TableName() string {
 
 // UserQuery represents a user's natural language query
 type UserQuery struct {
-	ID               uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	UserID           uuid.UUID       `json:"user_id" gorm:"type:uuid;not null"`
-	SiteID           uuid.UUID       `json:"site_id" gorm:"type:uuid;not null"`
-	QueryText        string          `json:"query_text" gorm:"type:text;not null"`
-	QueryType        QueryType       `json:"query_type" gorm:"type:varchar(50);not null"`
-	Embedding        pgvector.Vector `json:"-" gorm:"type:vector(1536)"`
-	Results          JSON            `json:"results" gorm:"type:jsonb"`
-	ResultCount      int             `json:"result_count" gorm:"default:0"`
-	ConfidenceScore  float64         `json:"confidence_score" gorm:"default:0"`
-	ExtractedEntities JSON           `json:"extracted_entities" gorm:"type:jsonb;default:'{}'"`
-	ProcessedAt      *time.Time      `json:"processed_at"`
-	ErrorMessage     string          `json:"error_message,omitempty" gorm:"type:text"`
-	CreatedAt        time.Time       `json:"created_at"`
-	UpdatedAt        time.Time       `json:"updated_at"`
-	
+	ID                uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID            uuid.UUID       `json:"user_id" gorm:"type:uuid;not null"`
+	SiteID            uuid.UUID       `json:"site_id" gorm:"type:uuid;not null"`
+	QueryText         string          `json:"query_text" gorm:"type:text;not null"`
+	QueryType         QueryType       `json:"query_type" gorm:"type:varchar(50);not null"`
+	Embedding         pgvector.Vector `json:"-" gorm:"type:vector(1536)"`
+	Results           JSON            `json:"results" gorm:"type:jsonb"`
+	ResultCount       int             `json:"result_count" gorm:"default:0"`
+	ConfidenceScore   float64         `json:"confidence_score" gorm:"default:0"`
+	ExtractedEntities JSON            `json:"extracted_entities" gorm:"type:jsonb;default:'{}'"`
+	ProcessedAt       *time.Time      `json:"processed_at"`
+	ErrorMessage      string          `json:"error_message,omitempty" gorm:"type:text"`
+	// Answer is the generated answer of an enhanced query
+	Answer string `json:"answer,omitempty" gorm:"type:text"`
+	// Conversation turns: StandaloneQuery is the follow-up rewritten to make
+	// sense without the earlier turns
+	ConversationID  *uuid.UUID `json:"conversation_id,omitempty" gorm:"type:uuid;index"`
+	TurnIndex       int        `json:"turn_index" gorm:"default:0"`
+	StandaloneQuery string     `json:"standalone_query,omitempty" gorm:"type:text"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
 	// Relationships
-	Site         *Site          `json:"site,omitempty" gorm:"foreignKey:SiteID"`
-	QuerySources []QuerySource  `json:"query_sources,omitempty" gorm:"foreignKey:QueryID"`
+	Site         *Site         `json:"site,omitempty" gorm:"foreignKey:SiteID"`
+	QuerySources []QuerySource `json:"query_sources,omitempty" gorm:"foreignKey:QueryID"`
+
+	// Segments splits Answer into sentences with the sources each cites;
+	// derived from the answer and its sources when the query is loaded
+	Segments []AnswerSegment `json:"segments,omitempty" gorm:"-"`
 }
 
+// QuerySourceType is what a query source quotes
+type QuerySourceType string
+
+const (
+	QuerySourceDocument QuerySourceType = "document"
+	// QuerySourceAction quotes a maintenance action; its DocumentID is the
+	// document the action was extracted from
+	QuerySourceAction QuerySourceType = "action"
+)
+
 // QuerySource represents a document source used to answer a query
 type QuerySource struct {
-	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	QueryID          uuid.UUID  `json:"query_id" gorm:"type:uuid;not null"`
-	DocumentID       uuid.UUID  `json:"document_id" gorm:"type:uuid;not null"`
-	DocumentTitle    string     `json:"document_title" gorm:"type:varchar(500)"`
-	RelevantExcerpt  string     `json:"relevant_excerpt" gorm:"type:text"`
-	RelevanceScore   float64    `json:"relevance_score" gorm:"default:0"`
-	PageNumber       *int       `json:"page_number"`
-	SectionReference string     `json:"section_reference" gorm:"type:varchar(255)"`
-	CreatedAt        time.Time  `json:"created_at"`
-	
+	ID               uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	QueryID          uuid.UUID       `json:"query_id" gorm:"type:uuid;not null"`
+	SourceType       QuerySourceType `json:"source_type" gorm:"type:varchar(20);not null;default:'document'"`
+	DocumentID       uuid.UUID       `json:"document_id" gorm:"type:uuid;not null"`
+	ActionID         *uuid.UUID      `json:"action_id,omitempty" gorm:"type:uuid"`
+	DocumentTitle    string          `json:"document_title" gorm:"type:varchar(500)"`
+	RelevantExcerpt  string          `json:"relevant_excerpt" gorm:"type:text"`
+	RelevanceScore   float64         `json:"relevance_score" gorm:"default:0"`
+	PageNumber       *int            `json:"page_number"`
+	SectionReference string          `json:"section_reference" gorm:"type:varchar(255)"`
+	SourceIndex      int             `json:"source_index" gorm:"default:0"` // N of the answer's [Source N] citations
+	CreatedAt        time.Time       `json:"created_at"`
+
 	// Relationships
-	Query    *UserQuery `json:"query,omitempty" gorm:"foreignKey:QueryID"`
-	Document *Document  `json:"document,omitempty" gorm:"foreignKey:DocumentID"`
+	Query    *UserQuery       `json:"query,omitempty" gorm:"foreignKey:QueryID"`
+	Document *Document        `json:"document,omitempty" gorm:"foreignKey:DocumentID"`
+	Action   *ExtractedAction `json:"action,omitempty" gorm:"foreignKey:ActionID;constraint:OnDelete:SET NULL"`
 }
 
 func (QuerySource) TableName() string {
@@ -117,65 +167,121 @@ func (QuerySource) TableName() string {
 
 // EnhancedQueryResponse represents a structured response with sources per PRD requirements
 type EnhancedQueryResponse struct {
-	Answer           string               `json:"answer"`
-	ConfidenceScore  float64              `json:"confidence_score"`
-	Sources          []QuerySourceDetail  `json:"sources"`
-	RelatedConcepts  []string             `json:"related_concepts"`
+	Answer            string              `json:"answer"`
+	ConfidenceScore   float64             `json:"confidence_score"`
+	Sources           []QuerySourceDetail `json:"sources"`
+	RelatedConcepts   []string            `json:"related_concepts"`
 	ExtractedEntities map[string][]string `json:"extracted_entities"`
-	ResponseType     string               `json:"response_type"` // summary, timeline, list, analysis
-	NoHallucination  bool                 `json:"no_hallucination"` // Validation flag
-	ProcessingTimeMs int                  `json:"processing_time_ms"`
+	ResponseType      string              `json:"response_type"`    // summary, timeline, list, analysis
+	NoHallucination   bool                `json:"no_hallucination"` // Validation flag
+	ProcessingTimeMs  int                 `json:"processing_time_ms"`
+	Segments          []AnswerSegment     `json:"segments"`           // Answer sentences with the sources supporting each
+	UnsupportedClaims []ClaimCheck        `json:"unsupported_claims"` // Claims the sources don't back; NoHallucination is false when any exist
+	Cached            bool                `json:"cached"`             // Answered from the query cache
+	// Set for conversation turns
+	ConversationID  *uuid.UUID `json:"conversation_id,omitempty"`
+	StandaloneQuery string     `json:"standalone_query,omitempty"`
 }
 
-// QuerySourceDetail provides detailed source information for responses
-type QuerySourceDetail struct {
+// QueryStreamToken is a piece of an answer streamed as it is generated
+type QueryStreamToken struct {
+	Text string `json:"text"`
+}
+
+// AnswerSegment is one sentence of an answer, without its citation markers,
+// and the sources it cites
+type AnswerSegment struct {
+	Text            string           `json:"text"`
+	Citations       []AnswerCitation `json:"citations"`
+	StartsParagraph bool             `json:"starts_paragraph,omitempty"` // A line break preceded the sentence
+}
+
+// AnswerCitation points a segment at a source of the answer
+type AnswerCitation struct {
+	SourceIndex      int       `json:"source_index"` // 1-based position in the answer's sources
 	DocumentID       uuid.UUID `json:"document_id"`
-	DocumentTitle    string    `json:"document_title"`
-	DocumentDate     time.Time `json:"document_date"`
-	DocumentType     string    `json:"document_type"`
-	RelevantExcerpt  string    `json:"relevant_excerpt"`
-	RelevanceScore   float64   `json:"relevance_score"`
 	PageNumber       *int      `json:"page_number,omitempty"`
 	SectionReference string    `json:"section_reference,omitempty"`
-	Citation         string    `json:"citation"` // Formatted citation string
+	Citation         string    `json:"citation"`
+}
+
+// ClaimCheck is the verdict on one atomic claim of an answer
+type ClaimCheck struct {
+	Text      string `json:"text"`
+	Sources   []int  `json:"sources"` // Source numbers the claim cites
+	Supported bool   `json:"supported"`
+	Reason    string `json:"reason,omitempty"` // Why the sources don't support it
+}
+
+// QueryStreamResult closes a streamed query. Answer is the final answer
+// after tone enforcement and sanitising, which can differ from the
+// concatenated tokens.
+type QueryStreamResult struct {
+	QueryID           *uuid.UUID      `json:"query_id,omitempty"`
+	Answer            string          `json:"answer"`
+	ConfidenceScore   float64         `json:"confidence_score"`
+	NoHallucination   bool            `json:"no_hallucination"`
+	CitedSources      []int           `json:"cited_sources"` // 1-based positions in the streamed sources
+	CitedDocumentIDs  []uuid.UUID     `json:"cited_document_ids"`
+	Segments          []AnswerSegment `json:"segments"`
+	UnsupportedClaims []ClaimCheck    `json:"unsupported_claims"`
+	ProcessingTimeMs  int             `json:"processing_time_ms"`
+	Cached            bool            `json:"cached"`
+	ConversationID    *uuid.UUID      `json:"conversation_id,omitempty"`
+	StandaloneQuery   string          `json:"standalone_query,omitempty"`
+}
+
+// QuerySourceDetail provides detailed source information for responses
+type QuerySourceDetail struct {
+	SourceType       QuerySourceType `json:"source_type"`
+	DocumentID       uuid.UUID       `json:"document_id"`
+	ActionID         *uuid.UUID      `json:"action_id,omitempty"`
+	DocumentTitle    string          `json:"document_title"`
+	DocumentDate     time.Time       `json:"document_date"`
+	DocumentType     string          `json:"document_type"`
+	RelevantExcerpt  string          `json:"relevant_excerpt"`
+	RelevanceScore   float64         `json:"relevance_score"`
+	PageNumber       *int            `json:"page_number,omitempty"`
+	SectionReference string          `json:"section_reference,omitempty"`
+	Citation         string          `json:"citation"` // Formatted citation string
 }
 
 // QueryIntent represents enhanced intent analysis
 type QueryIntent struct {
-	Type             string                 `json:"type"`        // timeline, search, maintenance_history, component_status, analysis
-	Confidence       float64                `json:"confidence"`
-	ExtractedEntities map[string][]string   `json:"extracted_entities"`
-	RelatedConcepts  []string               `json:"related_concepts"`
-	RequiredSources  []string               `json:"required_sources"` // Document types needed
-	DateRange        *DateRange             `json:"date_range,omitempty"`
-	ComponentFilters []string               `json:"component_filters,omitempty"`
+	Type              string              `json:"type"` // timeline, search, maintenance_history, component_status, analysis
+	Confidence        float64             `json:"confidence"`
+	ExtractedEntities map[string][]string `json:"extracted_entities"`
+	RelatedConcepts   []string            `json:"related_concepts"`
+	RequiredSources   []string            `json:"required_sources"` // Document types needed
+	DateRange         *DateRange          `json:"date_range,omitempty"`
+	ComponentFilters  []string            `json:"component_filters,omitempty"`
 }
 
 // SearchRequest for different search types
 type SearchRequest struct {
-	Query     string              `json:"query" validate:"required"`
-	Filters   map[string]string   `json:"filters"`
-	DateRange *DateRange          `json:"date_range,omitempty"`
-	Limit     int                 `json:"limit" validate:"min=1,max=100"`
+	Query     string            `json:"query" validate:"required"`
+	Filters   map[string]string `json:"filters"`
+	DateRange *DateRange        `json:"date_range,omitempty"`
+	Limit     int               `json:"limit" validate:"min=1,max=100"`
 }
 
 // SemanticSearchRequest for AI-powered search
 type SemanticSearchRequest struct {
-	Query      string      `json:"query" validate:"required,min=3"`
-	Limit      int         `json:"limit" validate:"min=1,max=50"`
-	Threshold  float64     `json:"threshold" validate:"min=0,max=1"`
+	Query     string  `json:"query" validate:"required,min=3"`
+	Limit     int     `json:"limit" validate:"min=1,max=50"`
+	Threshold float64 `json:"threshold" validate:"min=0,max=1"`
 }
 
 // SearchResult represents a search hit
 type SearchResult struct {
-	ID             uuid.UUID     `json:"id"`
-	Type           string        `json:"type"` // document, component, action
-	Title          string        `json:"title"`
-	Excerpt        string        `json:"excerpt"`
-	Score          float64       `json:"score"`
-	SimilarityScore float64      `json:"similarity_score,omitempty"`
-	Context        string        `json:"context,omitempty"`
-	RecentIssues   []string      `json:"recent_issues,omitempty"`
-	Highlights     []string      `json:"highlights,omitempty"`
-	CreatedAt      time.Time     `json:"created_at"`
-}
\ No newline at end of file
+	ID              uuid.UUID `json:"id"`
+	Type            string    `json:"type"` // document, component, action
+	Title           string    `json:"title"`
+	Excerpt         string    `json:"excerpt"`
+	Score           float64   `json:"score"`
+	SimilarityScore float64   `json:"similarity_score,omitempty"`
+	Context         string    `json:"context,omitempty"`
+	RecentIssues    []string  `json:"recent_issues,omitempty"`
+	Highlights      []string  `json:"highlights,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
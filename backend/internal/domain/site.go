@@ -8,18 +8,20 @@ import (
 )
 
 type Site struct {
-	ID                 uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	SiteCode          string          `json:"site_code" gorm:"type:varchar(50);unique;not null"`
-	Name              string          `json:"name" gorm:"type:varchar(255);not null"`
-	Address           string          `json:"address"`
-	Country           string          `json:"country" gorm:"type:varchar(2);default:'US'"`
-	TotalCapacityKW   float64         `json:"total_capacity_kw"`
-	NumberOfInverters int             `json:"number_of_inverters"`
-	InstallationDate  *time.Time      `json:"installation_date"`
-	SiteMetadata      JSON            `json:"site_metadata" gorm:"type:jsonb;default:'{}'"`
-	CreatedAt         time.Time       `json:"created_at"`
-	UpdatedAt         time.Time       `json:"updated_at"`
-	DeletedAt         gorm.DeletedAt  `json:"deleted_at,omitempty" gorm:"index"`
+	ID       uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	SiteCode string    `json:"site_code" gorm:"type:varchar(50);unique;not null"`
+	Name     string    `json:"name" gorm:"type:varchar(255);not null"`
+	// OrganizationID owns the site; sites without one are visible to admins only
+	OrganizationID    *uuid.UUID     `json:"organization_id,omitempty" gorm:"type:uuid;index"`
+	Address           string         `json:"address"`
+	Country           string         `json:"country" gorm:"type:varchar(2);default:'US'"`
+	TotalCapacityKW   float64        `json:"total_capacity_kw"`
+	NumberOfInverters int            `json:"number_of_inverters"`
+	InstallationDate  *time.Time     `json:"installation_date"`
+	SiteMetadata      JSON           `json:"site_metadata" gorm:"type:jsonb;default:'{}'"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 }
 
 func (Site) TableName() string {
@@ -27,13 +29,13 @@ func (Site) TableName() string {
 }
 
 type SiteComponentSummary struct {
-	Inverters        int `json:"inverters"`
-	Combiners        int `json:"combiners"`
-	TotalComponents  int `json:"total_components"`
+	Inverters       int `json:"inverters"`
+	Combiners       int `json:"combiners"`
+	TotalComponents int `json:"total_components"`
 }
 
 type SiteWithDetails struct {
 	Site
-	ComponentSummary     SiteComponentSummary `json:"component_summary"`
-	RecentActivityCount  int                  `json:"recent_activity_count"`
-}
\ No newline at end of file
+	ComponentSummary    SiteComponentSummary `json:"component_summary"`
+	RecentActivityCount int                  `json:"recent_activity_count"`
+}
@@ -11,26 +11,34 @@ import (
 type UserRole string
 
 const (
-	UserRoleAdmin     UserRole = "admin"
-	UserRoleManager   UserRole = "manager"
-	UserRoleViewer    UserRole = "viewer"
+	UserRoleAdmin      UserRole = "admin"
+	UserRoleManager    UserRole = "manager"
+	UserRoleViewer     UserRole = "viewer"
 	UserRoleTechnician UserRole = "technician"
 )
 
 // User represents an asset manager or system user
 // We keep this simple for MVP but extensible for enterprise features
 type User struct {
-	ID            uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	Email         string         `json:"email" gorm:"type:varchar(255);unique;not null"`
-	PasswordHash  string         `json:"-" gorm:"type:varchar(255);not null"` // Never expose in JSON
-	FullName      string         `json:"full_name" gorm:"type:varchar(255)"`
-	AvatarURL     string         `json:"avatar_url,omitempty" gorm:"type:varchar(500)"`
-	Settings      JSON           `json:"settings" gorm:"type:jsonb;default:'{}'"`
-	EmailVerified bool           `json:"email_verified" gorm:"default:false"`
-	LastLoginAt   *time.Time     `json:"last_login_at,omitempty"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Email        string    `json:"email" gorm:"type:varchar(255);unique;not null"`
+	PasswordHash string    `json:"-" gorm:"type:varchar(255);not null"` // Never expose in JSON
+	FullName     string    `json:"full_name" gorm:"type:varchar(255)"`
+	Role         UserRole  `json:"role" gorm:"type:varchar(20);not null;default:'viewer'"`
+	// OrganizationID is the tenant the user works for; users without one
+	// see no sites unless they are platform operators
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty" gorm:"type:uuid;index"`
+	// PlatformOperator runs the deployment itself: they see every
+	// organization's sites and users and set up organizations. Admins
+	// without it only administer their own organization.
+	PlatformOperator bool           `json:"platform_operator" gorm:"not null;default:false"`
+	AvatarURL        string         `json:"avatar_url,omitempty" gorm:"type:varchar(500)"`
+	Settings         JSON           `json:"settings" gorm:"type:jsonb;default:'{}'"`
+	EmailVerified    bool           `json:"email_verified" gorm:"default:false"`
+	LastLoginAt      *time.Time     `json:"last_login_at,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 }
 
 func (User) TableName() string {
@@ -77,7 +85,7 @@ type UserSession struct {
 	ExpiresAt    time.Time `json:"expires_at"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
-	
+
 	// Relationships
 	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
@@ -86,6 +94,11 @@ func (RefreshToken) TableName() string {
 	return "refresh_tokens"
 }
 
+// UpdateRoleRequest changes a user's role
+type UpdateRoleRequest struct {
+	Role UserRole `json:"role" validate:"required"`
+}
+
 // LoginRequest for authentication endpoint
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
@@ -115,4 +128,4 @@ type TokenClaims struct {
 	TokenType string    `json:"typ"` // "access" or "refresh"
 	ExpiresAt int64     `json:"exp"`
 	IssuedAt  int64     `json:"iat"`
-}
\ No newline at end of file
+}
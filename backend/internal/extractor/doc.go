@@ -0,0 +1,397 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/engramiq/engramiq-backend/pkg/cfb"
+)
+
+// Word 97-2003 binary format constants
+const (
+	wordIdent          = 0xA5EC
+	fibFlagEncrypted   = 0x0100
+	fibFlagWhichTable  = 0x0200
+	fcCompressedBit    = 0x40000000
+	fcLcbClxIndex      = 33
+	minPrintableRunLen = 8
+)
+
+var (
+	errNotWordBinary     = errors.New("not a Word 97-2003 binary document")
+	errEncryptedDocument = errors.New("document is password protected")
+)
+
+// ExtractDOC makes a best effort at extracting text from a legacy .doc file.
+// Word 97-2003 files are decoded through their piece table; files that are
+// really RTF or DOCX under a .doc name are handled by the matching parser.
+// As a last resort printable text runs are scraped from the raw bytes, which
+// loses formatting but usually recovers the body text.
+func ExtractDOC(content []byte) (*Result, error) {
+	switch {
+	case bytes.HasPrefix(content, []byte("PK\x03\x04")):
+		return ExtractDOCX(content)
+	case bytes.HasPrefix(bytes.TrimLeft(content, " \r\n\t"), []byte(`{\rtf`)):
+		return textResult(extractRTFText(content))
+	}
+
+	text, err := extractWordBinaryText(content)
+	if err == nil && strings.TrimSpace(text) != "" {
+		return textResult(text)
+	}
+	if errors.Is(err, errEncryptedDocument) {
+		return nil, err
+	}
+
+	// Older Word 6/95 files and damaged documents end up here
+	if fallback := scrapePrintableText(content); fallback != "" {
+		return textResult(fallback)
+	}
+	if err == nil {
+		err = fmt.Errorf("no text found")
+	}
+	return nil, fmt.Errorf("failed to extract Word document text: %w", err)
+}
+
+func textResult(text string) (*Result, error) {
+	var builder textBuilder
+	for _, para := range splitParagraphs(text) {
+		builder.paragraph(para)
+	}
+	return builder.result(), nil
+}
+
+func splitParagraphs(text string) []string {
+	return paragraphBreak.Split(text, -1)
+}
+
+// extractWordBinaryText reads the text of the main document through the piece
+// table (Clx) referenced from the File Information Block
+func extractWordBinaryText(content []byte) (string, error) {
+	file, err := cfb.Open(content)
+	if err != nil {
+		return "", err
+	}
+
+	wordDocument, err := file.ReadStream("WordDocument")
+	if err != nil {
+		return "", errNotWordBinary
+	}
+	if len(wordDocument) < 0x20 || binary.LittleEndian.Uint16(wordDocument) != wordIdent {
+		return "", errNotWordBinary
+	}
+
+	flags := binary.LittleEndian.Uint16(wordDocument[0x0A:])
+	if flags&fibFlagEncrypted != 0 {
+		return "", errEncryptedDocument
+	}
+
+	// Walk the variable-length FIB: FibBase, FibRgW97, FibRgLw97, FibRgFcLcb
+	offset := 0x20
+	readUint16 := func() (int, bool) {
+		if offset+2 > len(wordDocument) {
+			return 0, false
+		}
+		value := int(binary.LittleEndian.Uint16(wordDocument[offset:]))
+		offset += 2
+		return value, true
+	}
+
+	csw, ok := readUint16()
+	if !ok {
+		return "", errNotWordBinary
+	}
+	offset += csw * 2
+
+	cslw, ok := readUint16()
+	if !ok {
+		return "", errNotWordBinary
+	}
+	rgLwStart := offset
+	offset += cslw * 4
+
+	cbRgFcLcb, ok := readUint16()
+	if !ok || cbRgFcLcb <= fcLcbClxIndex || offset+cbRgFcLcb*8 > len(wordDocument) || cslw < 4 {
+		return "", errNotWordBinary
+	}
+
+	// ccpText is the fourth FibRgLw97 field: the length of the main body text
+	ccpText := int(binary.LittleEndian.Uint32(wordDocument[rgLwStart+12:]))
+	fcClx := int(binary.LittleEndian.Uint32(wordDocument[offset+fcLcbClxIndex*8:]))
+	lcbClx := int(binary.LittleEndian.Uint32(wordDocument[offset+fcLcbClxIndex*8+4:]))
+
+	tableName := "0Table"
+	if flags&fibFlagWhichTable != 0 {
+		tableName = "1Table"
+	}
+	table, err := file.ReadStream(tableName)
+	if err != nil {
+		return "", fmt.Errorf("missing %s stream: %w", tableName, err)
+	}
+	if lcbClx <= 0 || fcClx+lcbClx > len(table) {
+		return "", fmt.Errorf("piece table out of range")
+	}
+
+	pieces, err := parsePieceTable(table[fcClx : fcClx+lcbClx])
+	if err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	remaining := ccpText
+	for _, piece := range pieces {
+		if remaining <= 0 {
+			break
+		}
+		chars := piece.cpEnd - piece.cpStart
+		if chars > remaining {
+			chars = remaining
+		}
+		remaining -= chars
+
+		if piece.compressed {
+			end := piece.fc + chars
+			if end > len(wordDocument) {
+				return "", fmt.Errorf("text piece out of range")
+			}
+			text.WriteString(decodeCP1252(wordDocument[piece.fc:end]))
+		} else {
+			end := piece.fc + chars*2
+			if end > len(wordDocument) {
+				return "", fmt.Errorf("text piece out of range")
+			}
+			units := make([]uint16, chars)
+			for i := range units {
+				units[i] = binary.LittleEndian.Uint16(wordDocument[piece.fc+i*2:])
+			}
+			text.WriteString(string(utf16.Decode(units)))
+		}
+	}
+
+	return cleanWordText(text.String()), nil
+}
+
+type textPiece struct {
+	cpStart    int
+	cpEnd      int
+	fc         int
+	compressed bool
+}
+
+// parsePieceTable decodes a Clx: zero or more Prc property blocks followed by
+// the Pcdt whose PlcPcd maps character positions to file offsets
+func parsePieceTable(clx []byte) ([]textPiece, error) {
+	pos := 0
+	for pos < len(clx) && clx[pos] == 0x01 {
+		if pos+3 > len(clx) {
+			return nil, fmt.Errorf("truncated piece table")
+		}
+		pos += 3 + int(binary.LittleEndian.Uint16(clx[pos+1:]))
+	}
+	if pos+5 > len(clx) || clx[pos] != 0x02 {
+		return nil, fmt.Errorf("piece descriptor table not found")
+	}
+
+	lcb := int(binary.LittleEndian.Uint32(clx[pos+1:]))
+	plc := clx[pos+5:]
+	if lcb > len(plc) {
+		return nil, fmt.Errorf("truncated piece descriptor table")
+	}
+	plc = plc[:lcb]
+
+	// A PlcPcd holds n+1 CPs (4 bytes) followed by n PCDs (8 bytes)
+	if (lcb-4)%12 != 0 {
+		return nil, fmt.Errorf("malformed piece descriptor table")
+	}
+	n := (lcb - 4) / 12
+
+	pieces := make([]textPiece, 0, n)
+	for i := 0; i < n; i++ {
+		pcd := plc[(n+1)*4+i*8:]
+		fc := binary.LittleEndian.Uint32(pcd[2:])
+
+		piece := textPiece{
+			cpStart: int(binary.LittleEndian.Uint32(plc[i*4:])),
+			cpEnd:   int(binary.LittleEndian.Uint32(plc[(i+1)*4:])),
+		}
+		if fc&fcCompressedBit != 0 {
+			piece.compressed = true
+			piece.fc = int(fc&^fcCompressedBit) / 2
+		} else {
+			piece.fc = int(fc)
+		}
+		if piece.cpEnd < piece.cpStart {
+			return nil, fmt.Errorf("malformed piece descriptor")
+		}
+		pieces = append(pieces, piece)
+	}
+	return pieces, nil
+}
+
+// cleanWordText converts Word's in-band control characters into plain text:
+// paragraph marks become newlines, table cell marks become pipes and field
+// instructions are dropped in favour of their displayed result
+func cleanWordText(raw string) string {
+	var out strings.Builder
+	runes := []rune(raw)
+
+	// Each open field records whether we're still in its instruction part
+	var fields []bool
+	inInstruction := func() bool {
+		for _, instruction := range fields {
+			if instruction {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch r {
+		case 0x13: // field begin
+			fields = append(fields, true)
+			continue
+		case 0x14: // field separator
+			if len(fields) > 0 {
+				fields[len(fields)-1] = false
+			}
+			continue
+		case 0x15: // field end
+			if len(fields) > 0 {
+				fields = fields[:len(fields)-1]
+			}
+			continue
+		}
+		if inInstruction() {
+			continue
+		}
+
+		switch {
+		case r == '\r':
+			out.WriteRune('\n')
+		case r == 0x07:
+			// Cell marks separate cells; a second mark ends the row
+			if i+1 < len(runes) && runes[i+1] == 0x07 {
+				out.WriteRune('\n')
+				i++
+			} else {
+				out.WriteString(" | ")
+			}
+		case r == 0x0B:
+			out.WriteRune('\n')
+		case r == 0x0C:
+			out.WriteString("\n\n")
+		case r == 0x1E:
+			out.WriteRune('-')
+		case r == 0xA0:
+			out.WriteRune(' ')
+		case r == '\t' || r == '\n':
+			out.WriteRune(r)
+		case r < 0x20:
+			// Pictures, footnote references and other anchors
+		default:
+			out.WriteRune(r)
+		}
+	}
+
+	return strings.TrimSpace(out.String())
+}
+
+// cp1252 maps the 0x80-0x9F range where Windows-1252 differs from Latin-1
+var cp1252 = [32]rune{
+	0x20AC, 0xFFFD, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0xFFFD, 0x017D, 0xFFFD,
+	0xFFFD, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0xFFFD, 0x017E, 0x0178,
+}
+
+func decodeCP1252(raw []byte) string {
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		if b >= 0x80 && b <= 0x9F {
+			runes[i] = cp1252[b-0x80]
+		} else {
+			runes[i] = rune(b)
+		}
+	}
+	return string(runes)
+}
+
+var (
+	rtfDestination = regexp.MustCompile(`\{\\\*[^{}]*(\{[^{}]*\}[^{}]*)*\}`)
+	rtfSkipGroups  = regexp.MustCompile(`\{\\(fonttbl|colortbl|stylesheet|info|pict|header|footer)[^{}]*(\{[^{}]*\}[^{}]*)*\}`)
+	rtfHexEscape   = regexp.MustCompile(`\\'([0-9a-fA-F]{2})`)
+	rtfBreak       = regexp.MustCompile(`\\(par|line|row|sect|page)\b ?`)
+	rtfCell        = regexp.MustCompile(`\\cell\b ?`)
+	rtfControl     = regexp.MustCompile(`\\[a-zA-Z]+-?\d* ?`)
+	blankLines     = regexp.MustCompile(`\n{3,}`)
+	paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+)
+
+// extractRTFText strips RTF markup. It doesn't attempt to be a full RTF
+// parser, only to recover readable body text from simple documents.
+func extractRTFText(content []byte) string {
+	text := string(content)
+	text = rtfDestination.ReplaceAllString(text, "")
+	text = rtfSkipGroups.ReplaceAllString(text, "")
+	text = rtfHexEscape.ReplaceAllStringFunc(text, func(match string) string {
+		var b byte
+		fmt.Sscanf(match[2:], "%02x", &b)
+		return decodeCP1252([]byte{b})
+	})
+	text = rtfBreak.ReplaceAllString(text, "\n")
+	text = rtfCell.ReplaceAllString(text, " | ")
+	text = strings.NewReplacer(`\{`, "\x00lb", `\}`, "\x00rb", `\\`, "\x00bs").Replace(text)
+	text = rtfControl.ReplaceAllString(text, "")
+	text = strings.NewReplacer("{", "", "}", "", "\r", "", "\x00lb", "{", "\x00rb", "}", "\x00bs", `\`).Replace(text)
+	text = blankLines.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// scrapePrintableText recovers runs of readable text from a binary blob,
+// trying both single-byte and UTF-16LE encodings and keeping the better one
+func scrapePrintableText(content []byte) string {
+	isPrintable := func(b byte) bool {
+		return b >= 0x20 && b < 0x7F || b == '\t' || b == '\r' || b == '\n'
+	}
+
+	var single, wide []string
+
+	var run []byte
+	flush := func(runs *[]string) {
+		if len(bytes.TrimSpace(run)) >= minPrintableRunLen {
+			*runs = append(*runs, strings.TrimSpace(string(run)))
+		}
+		run = run[:0]
+	}
+
+	for _, b := range content {
+		if isPrintable(b) {
+			run = append(run, b)
+		} else {
+			flush(&single)
+		}
+	}
+	flush(&single)
+
+	for i := 0; i+1 < len(content); i += 2 {
+		if content[i+1] == 0 && isPrintable(content[i]) {
+			run = append(run, content[i])
+		} else {
+			flush(&wide)
+		}
+	}
+	flush(&wide)
+
+	best := single
+	if len(strings.Join(wide, "")) > len(strings.Join(single, "")) {
+		best = wide
+	}
+	return strings.ReplaceAll(strings.Join(best, "\n"), "\r", "\n")
+}
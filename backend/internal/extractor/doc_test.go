@@ -0,0 +1,355 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// wordStreams builds the WordDocument and 0Table streams of a Word 97-2003
+// document whose body is text, stored as a single compressed (CP1252) piece
+func wordStreams(text string) (wordDocument, table []byte) {
+	const (
+		rgFcLcbStart = 0x36
+		cbRgFcLcb    = fcLcbClxIndex + 1
+		textStart    = rgFcLcbStart + cbRgFcLcb*8
+	)
+
+	wordDocument = make([]byte, textStart+len(text))
+	binary.LittleEndian.PutUint16(wordDocument, wordIdent)
+	binary.LittleEndian.PutUint16(wordDocument[0x20:], 0)                         // csw
+	binary.LittleEndian.PutUint16(wordDocument[0x22:], 4)                         // cslw
+	binary.LittleEndian.PutUint32(wordDocument[0x24+12:], uint32(len(text)))      // ccpText
+	binary.LittleEndian.PutUint16(wordDocument[0x34:], cbRgFcLcb)                 // cbRgFcLcb
+	binary.LittleEndian.PutUint32(wordDocument[rgFcLcbStart+fcLcbClxIndex*8:], 0) // fcClx
+	copy(wordDocument[textStart:], text)
+
+	// Clx: a Pcdt holding a PlcPcd with two CPs and one PCD
+	plc := make([]byte, 16)
+	binary.LittleEndian.PutUint32(plc[4:], uint32(len(text)))
+	binary.LittleEndian.PutUint32(plc[8+2:], uint32(textStart*2)|fcCompressedBit)
+	table = append([]byte{0x02, 16, 0, 0, 0}, plc...)
+	binary.LittleEndian.PutUint32(wordDocument[rgFcLcbStart+fcLcbClxIndex*8+4:], uint32(len(table))) // lcbClx
+	return wordDocument, table
+}
+
+// compoundFile packs streams into a minimal version 3 compound file with
+// 512 byte sectors and every stream in regular sectors
+func compoundFile(t *testing.T, names []string, streams [][]byte) []byte {
+	t.Helper()
+	const sectorSize = 512
+
+	fat := []uint32{0xFFFFFFFD, 0xFFFFFFFE}
+	var data []byte
+	starts := make([]uint32, len(streams))
+	for i, stream := range streams {
+		sectors := (len(stream) + sectorSize - 1) / sectorSize
+		starts[i] = uint32(len(fat))
+		for s := 0; s < sectors; s++ {
+			next := uint32(len(fat) + 1)
+			if s == sectors-1 {
+				next = 0xFFFFFFFE
+			}
+			fat = append(fat, next)
+		}
+		padded := make([]byte, sectors*sectorSize)
+		copy(padded, stream)
+		data = append(data, padded...)
+	}
+	if len(fat) > sectorSize/4 || len(streams) > 3 {
+		t.Fatalf("compoundFile: streams don't fit one FAT and directory sector")
+	}
+
+	header := make([]byte, sectorSize)
+	copy(header, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1})
+	binary.LittleEndian.PutUint16(header[0x1E:], 9)
+	binary.LittleEndian.PutUint16(header[0x20:], 6)
+	binary.LittleEndian.PutUint32(header[0x2C:], 1)
+	binary.LittleEndian.PutUint32(header[0x30:], 1)
+	binary.LittleEndian.PutUint32(header[0x3C:], 0xFFFFFFFE)
+	binary.LittleEndian.PutUint32(header[0x44:], 0xFFFFFFFE)
+
+	fatSector := bytes.Repeat([]byte{0xFF}, sectorSize)
+	for i, next := range fat {
+		binary.LittleEndian.PutUint32(fatSector[i*4:], next)
+	}
+
+	dir := make([]byte, sectorSize)
+	entry := func(index int, name string, entryType byte, right, child, start uint32, size int) {
+		raw := dir[index*128:]
+		units := utf16.Encode([]rune(name))
+		for i, unit := range units {
+			binary.LittleEndian.PutUint16(raw[i*2:], unit)
+		}
+		binary.LittleEndian.PutUint16(raw[64:], uint16((len(units)+1)*2))
+		raw[66] = entryType
+		binary.LittleEndian.PutUint32(raw[68:], 0xFFFFFFFF)
+		binary.LittleEndian.PutUint32(raw[72:], right)
+		binary.LittleEndian.PutUint32(raw[76:], child)
+		binary.LittleEndian.PutUint32(raw[116:], start)
+		binary.LittleEndian.PutUint32(raw[120:], uint32(size))
+	}
+	entry(0, "Root Entry", 5, 0xFFFFFFFF, 1, 0xFFFFFFFE, 0)
+	for i, name := range names {
+		right := uint32(0xFFFFFFFF)
+		if i+1 < len(names) {
+			right = uint32(i + 2)
+		}
+		entry(i+1, name, 2, right, 0xFFFFFFFF, starts[i], len(streams[i]))
+	}
+
+	out := append(header, fatSector...)
+	out = append(out, dir...)
+	return append(out, data...)
+}
+
+func wordDocumentFile(t *testing.T, wordDocument, table []byte) []byte {
+	t.Helper()
+	return compoundFile(t, []string{"WordDocument", "0Table"}, [][]byte{wordDocument, table})
+}
+
+func TestExtractWordBinaryText(t *testing.T) {
+	const body = "Inverter INV001 inspected\rBreaker CB-05\x07reset\x07\x07\x13 PAGE \x145\x15"
+
+	tests := []struct {
+		name    string
+		edit    func(wordDocument, table []byte) ([]byte, []byte)
+		want    string
+		wantErr error
+	}{
+		{
+			name: "valid",
+			want: "Inverter INV001 inspected\nBreaker CB-05 | reset\n5",
+		},
+		{
+			name: "encrypted",
+			edit: func(w, tbl []byte) ([]byte, []byte) {
+				binary.LittleEndian.PutUint16(w[0x0A:], fibFlagEncrypted)
+				return w, tbl
+			},
+			wantErr: errEncryptedDocument,
+		},
+		{
+			name: "wrong identifier",
+			edit: func(w, tbl []byte) ([]byte, []byte) {
+				binary.LittleEndian.PutUint16(w, 0x1234)
+				return w, tbl
+			},
+			wantErr: errNotWordBinary,
+		},
+		{
+			name:    "FIB truncated",
+			edit:    func(w, tbl []byte) ([]byte, []byte) { return w[:0x30], tbl },
+			wantErr: errNotWordBinary,
+		},
+		{
+			name: "FcLcb table too short for the Clx",
+			edit: func(w, tbl []byte) ([]byte, []byte) {
+				binary.LittleEndian.PutUint16(w[0x34:], fcLcbClxIndex)
+				return w, tbl
+			},
+			wantErr: errNotWordBinary,
+		},
+		{
+			name: "FcLcb table past the stream",
+			edit: func(w, tbl []byte) ([]byte, []byte) {
+				binary.LittleEndian.PutUint16(w[0x34:], 0xFFFF)
+				return w, tbl
+			},
+			wantErr: errNotWordBinary,
+		},
+		{
+			name: "piece table past the table stream",
+			edit: func(w, tbl []byte) ([]byte, []byte) {
+				binary.LittleEndian.PutUint32(w[0x36+fcLcbClxIndex*8+4:], 4096)
+				return w, tbl
+			},
+		},
+		{
+			name: "text piece past the stream",
+			edit: func(w, tbl []byte) ([]byte, []byte) {
+				binary.LittleEndian.PutUint32(tbl[5+8+2:], uint32(len(w)*2)|fcCompressedBit)
+				return w, tbl
+			},
+		},
+		{
+			name: "unicode text piece past the stream",
+			edit: func(w, tbl []byte) ([]byte, []byte) {
+				binary.LittleEndian.PutUint32(tbl[5+8+2:], uint32(len(w)-4))
+				return w, tbl
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wordDocument, table := wordStreams(body)
+			if tt.edit != nil {
+				wordDocument, table = tt.edit(wordDocument, table)
+			}
+
+			got, err := extractWordBinaryText(wordDocumentFile(t, wordDocument, table))
+			switch {
+			case tt.want != "":
+				if err != nil {
+					t.Fatalf("extractWordBinaryText() error = %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("extractWordBinaryText() = %q, want %q", got, tt.want)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("extractWordBinaryText() error = %v, want %v", err, tt.wantErr)
+				}
+			case err == nil:
+				t.Errorf("extractWordBinaryText() = %q, want an error", got)
+			}
+		})
+	}
+}
+
+func TestExtractWordBinaryTextMissingStreams(t *testing.T) {
+	wordDocument, table := wordStreams("Body text")
+
+	tests := []struct {
+		name    string
+		names   []string
+		streams [][]byte
+	}{
+		{"no WordDocument", []string{"0Table"}, [][]byte{table}},
+		{"no table stream", []string{"WordDocument"}, [][]byte{wordDocument}},
+		{"other table stream", []string{"WordDocument", "1Table"}, [][]byte{wordDocument, table}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := extractWordBinaryText(compoundFile(t, tt.names, tt.streams)); err == nil {
+				t.Errorf("extractWordBinaryText() succeeded")
+			}
+		})
+	}
+}
+
+func TestParsePieceTable(t *testing.T) {
+	pcdt := func(cps []uint32, fcs []uint32) []byte {
+		plc := make([]byte, 0, len(cps)*4+len(fcs)*8)
+		for _, cp := range cps {
+			plc = binary.LittleEndian.AppendUint32(plc, cp)
+		}
+		for _, fc := range fcs {
+			plc = append(plc, 0, 0)
+			plc = binary.LittleEndian.AppendUint32(plc, fc)
+			plc = append(plc, 0, 0)
+		}
+		return append(binary.LittleEndian.AppendUint32([]byte{0x02}, uint32(len(plc))), plc...)
+	}
+
+	tests := []struct {
+		name    string
+		clx     []byte
+		want    []textPiece
+		wantErr bool
+	}{
+		{
+			name: "compressed and unicode pieces",
+			clx:  pcdt([]uint32{0, 10, 15}, []uint32{0x800 | fcCompressedBit, 0x2000}),
+			want: []textPiece{{cpStart: 0, cpEnd: 10, fc: 0x400, compressed: true}, {cpStart: 10, cpEnd: 15, fc: 0x2000}},
+		},
+		{
+			name: "property blocks before the pieces",
+			clx:  append([]byte{0x01, 2, 0, 0xAA, 0xBB}, pcdt([]uint32{0, 4}, []uint32{0x100})...),
+			want: []textPiece{{cpStart: 0, cpEnd: 4, fc: 0x100}},
+		},
+		{name: "empty", clx: nil, wantErr: true},
+		{name: "property block truncated", clx: []byte{0x01, 0x10}, wantErr: true},
+		{name: "property block runs past the end", clx: []byte{0x01, 0xFF, 0x00}, wantErr: true},
+		{name: "no piece descriptor table", clx: []byte{0x03, 0, 0, 0, 0}, wantErr: true},
+		{name: "descriptor table truncated", clx: pcdt([]uint32{0, 4}, []uint32{0x100})[:12], wantErr: true},
+		{name: "descriptor table length off", clx: append(binary.LittleEndian.AppendUint32([]byte{0x02}, 10), make([]byte, 10)...), wantErr: true},
+		{name: "pieces out of order", clx: pcdt([]uint32{8, 4}, []uint32{0x100}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePieceTable(tt.clx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePieceTable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parsePieceTable() = %+v, want %+v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("piece %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExtractDOC(t *testing.T) {
+	wordDocument, table := wordStreams("Quarterly inspection of INV001\rAll strings within tolerance")
+	valid := wordDocumentFile(t, wordDocument, table)
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "word binary",
+			content: valid,
+			want:    "Quarterly inspection of INV001\nAll strings within tolerance",
+		},
+		{
+			name:    "rtf under a doc name",
+			content: []byte(`{\rtf1\ansi{\fonttbl{\f0 Arial;}}\f0 Replaced fuse F3\par Tested OK}`),
+			want:    "Replaced fuse F3\nTested OK",
+		},
+		{
+			name:    "damaged file falls back to printable text",
+			content: append([]byte{0x00, 0x01, 0x02}, []byte("Technician replaced the combiner fuse\x00\x00")...),
+			want:    "Technician replaced the combiner fuse",
+		},
+		{name: "empty", content: nil, wantErr: true},
+		{name: "binary noise", content: bytes.Repeat([]byte{0x00, 0xFF, 0x13}, 200), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExtractDOC(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExtractDOC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && strings.TrimSpace(result.Text) != tt.want {
+				t.Errorf("ExtractDOC() = %q, want %q", result.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractDOCEncrypted(t *testing.T) {
+	wordDocument, table := wordStreams("Confidential body text")
+	binary.LittleEndian.PutUint16(wordDocument[0x0A:], fibFlagEncrypted)
+
+	if _, err := ExtractDOC(wordDocumentFile(t, wordDocument, table)); !errors.Is(err, errEncryptedDocument) {
+		t.Errorf("ExtractDOC() error = %v, want %v", err, errEncryptedDocument)
+	}
+}
+
+func TestExtractDOCTruncated(t *testing.T) {
+	wordDocument, table := wordStreams(strings.Repeat("Inverter INV001 inspected. ", 40))
+	valid := wordDocumentFile(t, wordDocument, table)
+
+	// Every prefix must fail with an error or return some text, never panic
+	for size := 0; size < len(valid); size += 13 {
+		result, err := ExtractDOC(valid[:size])
+		if err == nil && result == nil {
+			t.Errorf("ExtractDOC(%d bytes) returned neither text nor an error", size)
+		}
+	}
+}
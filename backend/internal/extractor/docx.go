@@ -0,0 +1,339 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDOCXPartSize guards against zip bombs hidden in the document part
+const maxDOCXPartSize = 64 << 20
+
+var headingStyleName = regexp.MustCompile(`(?i)^heading\s*([1-9])$`)
+
+// ExtractDOCX extracts text from an Office Open XML word document. Headings
+// become markdown headings, tables become pipe separated rows and list items
+// are bulleted, so the structure survives into ProcessedContent.
+func ExtractDOCX(content []byte) (*Result, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid docx archive: %w", err)
+	}
+
+	parts := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		parts[file.Name] = file
+	}
+
+	documentPart, ok := parts["word/document.xml"]
+	if !ok {
+		return nil, fmt.Errorf("invalid docx archive: word/document.xml not found")
+	}
+
+	// Styles are optional; without them we fall back to the built-in style IDs
+	headingLevels := map[string]int{}
+	if stylesPart, ok := parts["word/styles.xml"]; ok {
+		if raw, err := readZipPart(stylesPart); err == nil {
+			headingLevels = parseHeadingStyles(raw)
+		}
+	}
+
+	raw, err := readZipPart(documentPart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document part: %w", err)
+	}
+
+	result, err := parseDocumentXML(raw, headingLevels)
+	if err != nil {
+		return nil, err
+	}
+
+	if corePart, ok := parts["docProps/core.xml"]; ok {
+		if raw, err := readZipPart(corePart); err == nil {
+			result.Author, result.Date = parseCoreProperties(raw)
+		}
+	}
+
+	return result, nil
+}
+
+func readZipPart(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxDOCXPartSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDOCXPartSize {
+		return nil, fmt.Errorf("%s exceeds %d bytes", file.Name, maxDOCXPartSize)
+	}
+	return data, nil
+}
+
+// docxParagraph collects the runs of a single w:p element
+type docxParagraph struct {
+	text         strings.Builder
+	style        string
+	outlineLevel int
+	listItem     bool
+}
+
+func parseDocumentXML(raw []byte, headingLevels map[string]int) (*Result, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(raw))
+
+	// Paragraphs nest when a text box (w:txbxContent) sits inside one, so
+	// they are kept on a stack and para is always the innermost
+	var (
+		builder    textBuilder
+		paras      []*docxParagraph
+		para       *docxParagraph
+		inText     bool
+		tableDepth int
+		row        []string
+		cell       *strings.Builder
+	)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse document xml: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para = &docxParagraph{outlineLevel: -1}
+				paras = append(paras, para)
+			case "pStyle":
+				if para != nil {
+					para.style = xmlAttr(t, "val")
+				}
+			case "outlineLvl":
+				if para != nil {
+					if level, err := strconv.Atoi(xmlAttr(t, "val")); err == nil {
+						para.outlineLevel = level
+					}
+				}
+			case "numPr":
+				if para != nil {
+					para.listItem = true
+				}
+			case "t":
+				inText = true
+			case "tab":
+				if para != nil {
+					para.text.WriteString("\t")
+				}
+			case "br", "cr":
+				if para != nil {
+					para.text.WriteString("\n")
+				}
+			case "delText", "instrText", "Fallback":
+				// Deleted revisions, field codes and the legacy copy of
+				// drawing text would all duplicate or pollute the output
+				if err := decoder.Skip(); err != nil {
+					return nil, fmt.Errorf("failed to parse document xml: %w", err)
+				}
+			case "tbl":
+				tableDepth++
+			case "tr":
+				if tableDepth == 1 {
+					row = nil
+				}
+			case "tc":
+				if tableDepth == 1 {
+					cell = &strings.Builder{}
+				}
+			}
+
+		case xml.CharData:
+			if inText && para != nil {
+				para.text.Write(t)
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if para == nil {
+					continue
+				}
+				text := strings.TrimSpace(para.text.String())
+				paras = paras[:len(paras)-1]
+				var outer *docxParagraph
+				if len(paras) > 0 {
+					outer = paras[len(paras)-1]
+				}
+				if outer != nil {
+					// Text box content flattens into the paragraph anchoring it
+					if text != "" {
+						if current := outer.text.String(); current != "" && !strings.HasSuffix(current, " ") {
+							outer.text.WriteString(" ")
+						}
+						outer.text.WriteString(text + " ")
+					}
+				} else if cell != nil {
+					// Nested paragraphs (and nested tables) collapse into the
+					// enclosing top-level cell
+					if text != "" {
+						if cell.Len() > 0 {
+							cell.WriteString(" ")
+						}
+						cell.WriteString(strings.Join(strings.Fields(text), " "))
+					}
+				} else if level := paragraphHeadingLevel(para, headingLevels); level > 0 {
+					builder.heading(level, strings.Join(strings.Fields(text), " "))
+				} else if para.listItem {
+					builder.line("- " + text)
+				} else {
+					builder.paragraph(text)
+				}
+				para = outer
+			case "tc":
+				if tableDepth == 1 && cell != nil {
+					row = append(row, cell.String())
+					cell = nil
+				}
+			case "tr":
+				if tableDepth == 1 {
+					builder.line(strings.Join(row, " | "))
+					row = nil
+				}
+			case "tbl":
+				tableDepth--
+				if tableDepth == 0 {
+					builder.separate()
+				}
+			}
+		}
+	}
+
+	return builder.result(), nil
+}
+
+// paragraphHeadingLevel returns the 1-based heading level of a paragraph or 0
+// for body text
+func paragraphHeadingLevel(para *docxParagraph, headingLevels map[string]int) int {
+	if para.outlineLevel >= 0 && para.outlineLevel < 9 {
+		return para.outlineLevel + 1
+	}
+	if para.style == "" {
+		return 0
+	}
+	if level, ok := headingLevels[para.style]; ok {
+		return level
+	}
+	// Built-in style IDs when styles.xml is missing or unusual
+	if strings.EqualFold(para.style, "Title") {
+		return 1
+	}
+	if m := headingStyleName.FindStringSubmatch(para.style); m != nil {
+		level, _ := strconv.Atoi(m[1])
+		return level
+	}
+	return 0
+}
+
+// parseHeadingStyles maps style IDs to heading levels. Localised and custom
+// templates don't use the "Heading1" IDs, so we go by the style name and
+// outline level rather than the ID, following basedOn for derived styles.
+func parseHeadingStyles(raw []byte) map[string]int {
+	type styleDef struct {
+		ID   string `xml:"styleId,attr"`
+		Name struct {
+			Val string `xml:"val,attr"`
+		} `xml:"name"`
+		BasedOn struct {
+			Val string `xml:"val,attr"`
+		} `xml:"basedOn"`
+		PPr struct {
+			OutlineLvl *struct {
+				Val string `xml:"val,attr"`
+			} `xml:"outlineLvl"`
+		} `xml:"pPr"`
+	}
+	var styles struct {
+		Styles []styleDef `xml:"style"`
+	}
+	if err := xml.Unmarshal(raw, &styles); err != nil {
+		return map[string]int{}
+	}
+
+	direct := make(map[string]int)
+	basedOn := make(map[string]string)
+	for _, style := range styles.Styles {
+		switch {
+		case style.PPr.OutlineLvl != nil:
+			if level, err := strconv.Atoi(style.PPr.OutlineLvl.Val); err == nil && level < 9 {
+				direct[style.ID] = level + 1
+			}
+		case strings.EqualFold(style.Name.Val, "title"):
+			direct[style.ID] = 1
+		default:
+			if m := headingStyleName.FindStringSubmatch(style.Name.Val); m != nil {
+				level, _ := strconv.Atoi(m[1])
+				direct[style.ID] = level
+			}
+		}
+		if style.BasedOn.Val != "" {
+			basedOn[style.ID] = style.BasedOn.Val
+		}
+	}
+
+	levels := make(map[string]int, len(direct))
+	for id := range basedOn {
+		// Bounded walk up the inheritance chain in case of cycles
+		for current, hops := id, 0; current != "" && hops < 10; hops++ {
+			if level, ok := direct[current]; ok {
+				levels[id] = level
+				break
+			}
+			current = basedOn[current]
+		}
+	}
+	for id, level := range direct {
+		levels[id] = level
+	}
+	return levels
+}
+
+// parseCoreProperties reads the author and creation date from docProps/core.xml
+func parseCoreProperties(raw []byte) (string, *time.Time) {
+	var core struct {
+		Creator string `xml:"creator"`
+		Created string `xml:"created"`
+	}
+	if err := xml.Unmarshal(raw, &core); err != nil {
+		return "", nil
+	}
+
+	var created *time.Time
+	if parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(core.Created)); err == nil {
+		created = &parsed
+	}
+	return strings.TrimSpace(core.Creator), created
+}
+
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,350 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxMIMEDepth bounds recursion through nested multipart bodies
+const maxMIMEDepth = 10
+
+// emailMessage is the parsed form shared by the .eml and .msg extractors
+type emailMessage struct {
+	subject     string
+	fromName    string
+	fromAddress string
+	to          string
+	cc          string
+	date        *time.Time
+	messageID   string
+	inReplyTo   string
+	body        string
+	attachments []Attachment
+}
+
+// ExtractEML parses an RFC 5322 / MIME email. Sender and date come from the
+// headers, the body is taken from the plain text part (or the HTML part with
+// markup removed), quoted reply chains are stripped and attachments are
+// returned for separate ingestion.
+func ExtractEML(content []byte) (*Result, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid email message: %w", err)
+	}
+
+	email := &emailMessage{
+		subject:   decodeHeader(msg.Header.Get("Subject")),
+		to:        decodeHeader(msg.Header.Get("To")),
+		cc:        decodeHeader(msg.Header.Get("Cc")),
+		messageID: strings.TrimSpace(msg.Header.Get("Message-Id")),
+		inReplyTo: strings.TrimSpace(msg.Header.Get("In-Reply-To")),
+	}
+
+	if from := msg.Header.Get("From"); from != "" {
+		if addr, err := mimeDecoder.Parse(from); err == nil {
+			email.fromName, email.fromAddress = addr.Name, addr.Address
+		} else {
+			email.fromName = decodeHeader(from)
+		}
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		email.date = &date
+	}
+
+	var plain, htmlBody string
+	walkMIMEPart(msg.Header, msg.Body, 0, &plain, &htmlBody, &email.attachments)
+
+	email.body = plain
+	if strings.TrimSpace(email.body) == "" && htmlBody != "" {
+		email.body = htmlToText(htmlBody)
+	}
+
+	return email.result(), nil
+}
+
+// result renders the message as text with a short header block so the LLM
+// sees who said what and when
+func (e *emailMessage) result() *Result {
+	body, quoted := stripQuotedReplies(normalizeNewlines(e.body))
+
+	var builder textBuilder
+	var header []string
+	if e.subject != "" {
+		header = append(header, "Subject: "+e.subject)
+	}
+	if from := formatAddress(e.fromName, e.fromAddress); from != "" {
+		header = append(header, "From: "+from)
+	}
+	if e.to != "" {
+		header = append(header, "To: "+e.to)
+	}
+	if e.cc != "" {
+		header = append(header, "Cc: "+e.cc)
+	}
+	if e.date != nil {
+		header = append(header, "Date: "+e.date.Format(time.RFC1123Z))
+	}
+	for _, line := range header {
+		builder.line(line)
+	}
+	for _, para := range splitParagraphs(body) {
+		builder.paragraph(para)
+	}
+
+	result := builder.result()
+	result.Title = e.subject
+	result.Author = e.fromName
+	result.AuthorEmail = e.fromAddress
+	result.Date = e.date
+	result.Attachments = e.attachments
+
+	properties := map[string]interface{}{
+		"subject": e.subject,
+		"from":    formatAddress(e.fromName, e.fromAddress),
+	}
+	if e.to != "" {
+		properties["to"] = e.to
+	}
+	if e.cc != "" {
+		properties["cc"] = e.cc
+	}
+	if e.messageID != "" {
+		properties["message_id"] = e.messageID
+	}
+	if e.inReplyTo != "" {
+		properties["in_reply_to"] = e.inReplyTo
+	}
+	if quoted {
+		properties["quoted_text_removed"] = true
+	}
+	result.Properties = map[string]interface{}{"email": properties}
+
+	return result
+}
+
+func formatAddress(name, address string) string {
+	switch {
+	case name != "" && address != "":
+		return fmt.Sprintf("%s <%s>", name, address)
+	case address != "":
+		return address
+	default:
+		return name
+	}
+}
+
+// walkMIMEPart collects the first plain text and HTML bodies and every
+// attachment from a (possibly nested) MIME part
+func walkMIMEPart(header map[string][]string, body io.Reader, depth int, plain, htmlBody *string, attachments *[]Attachment) {
+	if depth > maxMIMEDepth {
+		return
+	}
+
+	get := func(key string) string {
+		if values := header[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	mediaType, params, err := mime.ParseMediaType(get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err != nil {
+				return
+			}
+			walkMIMEPart(part.Header, part, depth+1, plain, htmlBody, attachments)
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransferEncoding(get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(get("Content-Disposition"))
+	filename := decodeHeader(dispositionParams["filename"])
+	if filename == "" {
+		filename = decodeHeader(params["name"])
+	}
+
+	isBody := disposition != "attachment" && filename == "" &&
+		(mediaType == "text/plain" || mediaType == "text/html")
+	if isBody {
+		text := decodeCharset(data, params["charset"])
+		if mediaType == "text/plain" && *plain == "" {
+			*plain = text
+		} else if mediaType == "text/html" && *htmlBody == "" {
+			*htmlBody = text
+		}
+		return
+	}
+
+	if len(data) == 0 {
+		return
+	}
+	if filename == "" {
+		// Forwarded messages are attached without a filename
+		if mediaType != "message/rfc822" {
+			return
+		}
+		filename = fmt.Sprintf("attachment-%d.eml", len(*attachments)+1)
+	}
+
+	*attachments = append(*attachments, Attachment{
+		Filename:    sanitizeFilename(filepath.Base(filename)),
+		ContentType: mediaType,
+		Content:     data,
+	})
+}
+
+func decodeTransferEncoding(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// Line breaks inside base64 bodies are ignored by the decoder
+		return base64.NewDecoder(base64.StdEncoding, &newlineStripper{r: body})
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+// newlineStripper drops CR/LF so wrapped base64 can be streamed to the decoder
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (n *newlineStripper) Read(p []byte) (int, error) {
+	for {
+		count, err := n.r.Read(p)
+		kept := 0
+		for _, b := range p[:count] {
+			if b != '\r' && b != '\n' && b != ' ' && b != '\t' {
+				p[kept] = b
+				kept++
+			}
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+var mimeDecoder = &mail.AddressParser{WordDecoder: &mime.WordDecoder{CharsetReader: charsetReader}}
+
+func decodeHeader(value string) string {
+	decoded, err := mimeDecoder.WordDecoder.DecodeHeader(value)
+	if err != nil {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(decoded)
+}
+
+// charsetReader supports the single-byte charsets we see from Windows mail
+// clients; the standard library only handles UTF-8 and ASCII itself
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(decodeCharset(data, charset)), nil
+}
+
+func decodeCharset(data []byte, charset string) string {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "iso-8859-1", "latin1", "latin-1":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	case "windows-1252", "cp1252":
+		return decodeCP1252(data)
+	default:
+		return strings.ToValidUTF8(string(data), "\uFFFD")
+	}
+}
+
+var (
+	htmlDropBlocks = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	htmlBlockEnd   = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|tr|li|h[1-6]|table|blockquote)>`)
+	htmlCellEnd    = regexp.MustCompile(`(?i)</t[dh]>`)
+	htmlTag        = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlQuoteBlock = regexp.MustCompile(`(?is)<blockquote[^>]*type="cite"[^>]*>.*?</blockquote>`)
+	spaceRuns      = regexp.MustCompile(`[ \t]+`)
+)
+
+// htmlToText reduces an HTML email body to readable text
+func htmlToText(body string) string {
+	body = htmlDropBlocks.ReplaceAllString(body, "")
+	body = htmlQuoteBlock.ReplaceAllString(body, "")
+	body = htmlCellEnd.ReplaceAllString(body, " | ")
+	body = htmlBlockEnd.ReplaceAllString(body, "\n")
+	body = htmlTag.ReplaceAllString(body, "")
+	body = html.UnescapeString(body)
+	body = strings.ReplaceAll(body, "\u00a0", " ")
+
+	lines := strings.Split(normalizeNewlines(body), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spaceRuns.ReplaceAllString(line, " "))
+	}
+	return blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+}
+
+func normalizeNewlines(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+}
+
+var replyMarkers = []*regexp.Regexp{
+	// Gmail / Apple Mail: "On Tue, 5 Mar 2024 at 10:00, Jane <jane@x> wrote:"
+	regexp.MustCompile(`(?m)^On .{0,200}wrote:\s*$`),
+	// Outlook
+	regexp.MustCompile(`(?mi)^-{2,}\s*Original Message\s*-{2,}\s*$`),
+	regexp.MustCompile(`(?m)^_{10,}\s*\n\s*From:`),
+	regexp.MustCompile(`(?m)^From: .+\n(Sent|Date): .+\n`),
+	regexp.MustCompile(`(?mi)^-{2,}\s*Forwarded message\s*-{2,}\s*$`),
+}
+
+// stripQuotedReplies removes the quoted history under a reply so each email
+// in a thread only contributes what the sender actually wrote. If stripping
+// would leave nothing (e.g. a bare forward) the original text is kept.
+func stripQuotedReplies(body string) (string, bool) {
+	cut := len(body)
+	for _, marker := range replyMarkers {
+		if loc := marker.FindStringIndex(body); loc != nil && loc[0] < cut {
+			cut = loc[0]
+		}
+	}
+
+	var kept []string
+	for _, line := range strings.Split(body[:cut], "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), ">") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	stripped := strings.TrimSpace(strings.Join(kept, "\n"))
+	if stripped == "" {
+		return strings.TrimSpace(body), false
+	}
+	return stripped, stripped != strings.TrimSpace(body)
+}
@@ -0,0 +1,244 @@
+package extractor
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// eml joins header and body lines with CRLF the way mail clients write them
+func eml(lines ...string) []byte {
+	return []byte(strings.Join(lines, "\r\n"))
+}
+
+func TestExtractEML(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         []byte
+		wantTitle       string
+		wantAuthor      string
+		wantAuthorEmail string
+		wantText        []string
+		wantNotText     []string
+		wantAttachments []string
+		wantQuoted      bool
+	}{
+		{
+			name: "plain text with headers",
+			content: eml(
+				"From: Dana Field <dana@example.com>",
+				"To: ops@example.com",
+				"Cc: lead@example.com",
+				"Subject: INV001 fault cleared",
+				"Date: Mon, 02 Jun 2025 09:30:00 +0000",
+				"Message-Id: <abc@example.com>",
+				"",
+				"Reset the inverter after the ground fault alarm.",
+			),
+			wantTitle:       "INV001 fault cleared",
+			wantAuthor:      "Dana Field",
+			wantAuthorEmail: "dana@example.com",
+			wantText: []string{
+				"Subject: INV001 fault cleared",
+				"From: Dana Field <dana@example.com>",
+				"To: ops@example.com",
+				"Cc: lead@example.com",
+				"Date: Mon, 02 Jun 2025 09:30:00 +0000",
+				"Reset the inverter after the ground fault alarm.",
+			},
+		},
+		{
+			name: "html only body is converted to text",
+			content: eml(
+				"From: dana@example.com",
+				"Subject: Weekly report",
+				"Content-Type: text/html; charset=utf-8",
+				"",
+				"<html><body><p>Replaced fuse F3 &amp; tested</p></body></html>",
+			),
+			wantTitle:       "Weekly report",
+			wantAuthorEmail: "dana@example.com",
+			wantText:        []string{"Replaced fuse F3 & tested"},
+			wantNotText:     []string{"<p>"},
+		},
+		{
+			name: "base64 body",
+			content: eml(
+				"From: dana@example.com",
+				"Subject: Encoded",
+				"Content-Type: text/plain; charset=utf-8",
+				"Content-Transfer-Encoding: base64",
+				"",
+				"Q29tYmluZXIg",
+				"Ym94IENCLTA1IGluc3BlY3RlZA==",
+			),
+			wantTitle: "Encoded",
+			wantText:  []string{"Combiner box CB-05 inspected"},
+		},
+		{
+			name: "quoted printable body",
+			content: eml(
+				"From: dana@example.com",
+				"Subject: QP",
+				"Content-Type: text/plain; charset=utf-8",
+				"Content-Transfer-Encoding: quoted-printable",
+				"",
+				"Tracker row 12 stowed =3D safe",
+			),
+			wantTitle: "QP",
+			wantText:  []string{"Tracker row 12 stowed = safe"},
+		},
+		{
+			name: "multipart prefers plain text and collects attachments",
+			content: eml(
+				"From: dana@example.com",
+				"Subject: Photos",
+				"Content-Type: multipart/mixed; boundary=outer",
+				"",
+				"--outer",
+				"Content-Type: multipart/alternative; boundary=inner",
+				"",
+				"--inner",
+				"Content-Type: text/plain",
+				"",
+				"See attached photos.",
+				"--inner",
+				"Content-Type: text/html",
+				"",
+				"<p>HTML version</p>",
+				"--inner--",
+				"--outer",
+				"Content-Type: image/jpeg",
+				"Content-Disposition: attachment; filename=\"../site/photo.jpg\"",
+				"Content-Transfer-Encoding: base64",
+				"",
+				"/9j/4AAQ",
+				"--outer",
+				"Content-Type: message/rfc822",
+				"",
+				"Subject: Original",
+				"",
+				"Forwarded body",
+				"--outer--",
+			),
+			wantTitle:       "Photos",
+			wantText:        []string{"See attached photos."},
+			wantNotText:     []string{"HTML version"},
+			wantAttachments: []string{"photo.jpg", "attachment-2.eml"},
+		},
+		{
+			name: "quoted reply is stripped",
+			content: eml(
+				"From: dana@example.com",
+				"Subject: Re: INV001",
+				"",
+				"Confirmed, the alarm has not returned.",
+				"",
+				"On Mon, 2 Jun 2025 at 09:30, Ops <ops@example.com> wrote:",
+				"> Can you check INV001 again?",
+			),
+			wantTitle:   "Re: INV001",
+			wantText:    []string{"Confirmed, the alarm has not returned."},
+			wantNotText: []string{"Can you check INV001 again?"},
+			wantQuoted:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExtractEML(tt.content)
+			if err != nil {
+				t.Fatalf("ExtractEML() error = %v", err)
+			}
+			if result.Title != tt.wantTitle {
+				t.Errorf("Title = %q, want %q", result.Title, tt.wantTitle)
+			}
+			if result.Author != tt.wantAuthor {
+				t.Errorf("Author = %q, want %q", result.Author, tt.wantAuthor)
+			}
+			if tt.wantAuthorEmail != "" && result.AuthorEmail != tt.wantAuthorEmail {
+				t.Errorf("AuthorEmail = %q, want %q", result.AuthorEmail, tt.wantAuthorEmail)
+			}
+			for _, want := range tt.wantText {
+				if !strings.Contains(result.Text, want) {
+					t.Errorf("Text = %q, want it to contain %q", result.Text, want)
+				}
+			}
+			for _, unwanted := range tt.wantNotText {
+				if strings.Contains(result.Text, unwanted) {
+					t.Errorf("Text = %q, want it without %q", result.Text, unwanted)
+				}
+			}
+
+			var filenames []string
+			for _, attachment := range result.Attachments {
+				filenames = append(filenames, attachment.Filename)
+			}
+			if strings.Join(filenames, ",") != strings.Join(tt.wantAttachments, ",") {
+				t.Errorf("Attachments = %v, want %v", filenames, tt.wantAttachments)
+			}
+
+			properties, _ := result.Properties["email"].(map[string]interface{})
+			if _, quoted := properties["quoted_text_removed"]; quoted != tt.wantQuoted {
+				t.Errorf("quoted_text_removed = %v, want %v", quoted, tt.wantQuoted)
+			}
+		})
+	}
+}
+
+func TestExtractEMLInvalid(t *testing.T) {
+	if _, err := ExtractEML([]byte("not an email")); err == nil {
+		t.Error("ExtractEML() error = nil, want an error for a message without headers")
+	}
+}
+
+// msgUnicode encodes s as a PT_UNICODE property stream
+func msgUnicode(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	data := make([]byte, len(units)*2)
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(data[i*2:], unit)
+	}
+	return data
+}
+
+func TestExtractMSG(t *testing.T) {
+	content := compoundFile(t,
+		[]string{
+			msgPropertyName(propSubject, 0x001F),
+			msgPropertyName(propSenderName, 0x001F),
+			msgPropertyName(propBody, 0x001F),
+		},
+		[][]byte{
+			msgUnicode("Tracker outage"),
+			msgUnicode("Dana Field"),
+			msgUnicode("Row 12 tracker stalled at noon.\r\n\r\n-----Original Message-----\r\nFrom: Ops\r\nPlease check row 12."),
+		},
+	)
+
+	result, err := ExtractMSG(content)
+	if err != nil {
+		t.Fatalf("ExtractMSG() error = %v", err)
+	}
+	if result.Title != "Tracker outage" {
+		t.Errorf("Title = %q, want %q", result.Title, "Tracker outage")
+	}
+	if result.Author != "Dana Field" {
+		t.Errorf("Author = %q, want %q", result.Author, "Dana Field")
+	}
+	if !strings.Contains(result.Text, "Row 12 tracker stalled at noon.") {
+		t.Errorf("Text = %q, want it to contain the body", result.Text)
+	}
+	if strings.Contains(result.Text, "Please check row 12.") {
+		t.Errorf("Text = %q, want the original message stripped", result.Text)
+	}
+}
+
+func TestExtractMSGInvalid(t *testing.T) {
+	for _, content := range [][]byte{nil, []byte("From: dana@example.com\r\n\r\nplain email")} {
+		if _, err := ExtractMSG(content); err == nil {
+			t.Errorf("ExtractMSG(%q) error = nil, want an error", content)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package extractor turns uploaded files into plain text for indexing while
+// keeping enough structure (headings, tables) for the LLM and for citations.
+package extractor
+
+import (
+	"strings"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+)
+
+// Result is the text extracted from a file along with any structure the
+// format exposes
+type Result struct {
+	Text        string
+	Sections    []domain.DocumentSection
+	Title       string
+	Author      string
+	AuthorEmail string
+	Date        *time.Time
+	// Properties holds format specific details (e.g. email recipients) that
+	// are stored alongside the structure in the document metadata
+	Properties domain.JSON
+	// Attachments are embedded files that should be ingested as documents of
+	// their own
+	Attachments []Attachment
+}
+
+// Attachment is a file embedded in another document, such as an email
+// attachment
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// Metadata returns the structural information to persist in
+// Document.DocumentMetadata
+func (r *Result) Metadata() domain.JSON {
+	metadata := domain.JSON{}
+	for key, value := range r.Properties {
+		metadata[key] = value
+	}
+	if len(r.Sections) > 0 {
+		metadata["sections"] = r.Sections
+	}
+	return metadata
+}
+
+// textBuilder accumulates extracted text and records heading offsets as it goes
+type textBuilder struct {
+	sb       strings.Builder
+	sections []domain.DocumentSection
+}
+
+// heading writes a markdown style heading and records where it starts
+func (b *textBuilder) heading(level int, title string) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return
+	}
+	if level < 1 {
+		level = 1
+	}
+
+	b.separate()
+	b.sections = append(b.sections, domain.DocumentSection{
+		Title:  title,
+		Level:  level,
+		Offset: b.sb.Len(),
+	})
+	b.sb.WriteString(strings.Repeat("#", level))
+	b.sb.WriteString(" ")
+	b.sb.WriteString(title)
+	b.sb.WriteString("\n")
+}
+
+// paragraph writes a block of text separated from the previous block
+func (b *textBuilder) paragraph(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	b.separate()
+	b.sb.WriteString(text)
+	b.sb.WriteString("\n")
+}
+
+// line writes text directly below the previous line, used for table rows and
+// list items that belong together
+func (b *textBuilder) line(text string) {
+	text = strings.TrimRight(text, " \t")
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	b.sb.WriteString(text)
+	b.sb.WriteString("\n")
+}
+
+// separate inserts a blank line unless we're at the start of the text
+func (b *textBuilder) separate() {
+	if b.sb.Len() == 0 {
+		return
+	}
+	current := b.sb.String()
+	if !strings.HasSuffix(current, "\n") {
+		b.sb.WriteString("\n")
+	}
+	if !strings.HasSuffix(current, "\n\n") {
+		b.sb.WriteString("\n")
+	}
+}
+
+func (b *textBuilder) result() *Result {
+	return &Result{
+		Text:     strings.TrimRight(b.sb.String(), " \t\n"),
+		Sections: b.sections,
+	}
+}
@@ -0,0 +1,270 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/engramiq/engramiq-backend/pkg/cfb"
+)
+
+// MAPI property IDs used when reading Outlook .msg files
+const (
+	propSubject               = 0x0037
+	propClientSubmitTime      = 0x0039
+	propSentRepresentingEmail = 0x0065
+	propTransportHeaders      = 0x007D
+	propSenderName            = 0x0C1A
+	propSenderEmail           = 0x0C1F
+	propDisplayCc             = 0x0E03
+	propDisplayTo             = 0x0E04
+	propMessageDeliveryTime   = 0x0E06
+	propBody                  = 0x1000
+	propHTMLBody              = 0x1013
+	propInternetMessageID     = 0x1035
+	propInReplyTo             = 0x1042
+	propDisplayName           = 0x3001
+	propAttachData            = 0x3701
+	propAttachFilename        = 0x3704
+	propAttachLongFilename    = 0x3707
+	propAttachMimeTag         = 0x370E
+	propSenderSMTPAddress     = 0x5D01
+)
+
+const (
+	msgAttachmentPrefix = "__attach_version1.0_#"
+	msgPropertiesStream = "__properties_version1.0"
+	ptypSystime         = 0x0040
+	// Fixed-length property streams start with a header whose size depends
+	// on whether the storage is the top-level message
+	msgTopLevelHeaderSize = 32
+	msgEmbeddedHeaderSize = 24
+	// Seconds between the FILETIME epoch (1601) and the Unix epoch
+	filetimeUnixOffset = 11644473600
+)
+
+// ExtractMSG parses an Outlook .msg file (a compound file holding MAPI
+// properties) into the same shape as ExtractEML
+func ExtractMSG(content []byte) (*Result, error) {
+	file, err := cfb.Open(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Outlook message: %w", err)
+	}
+
+	email := readMSGStorage(file.Root(), msgTopLevelHeaderSize)
+	return email.result(), nil
+}
+
+func readMSGStorage(storage *cfb.Entry, headerSize int) *emailMessage {
+	email := &emailMessage{
+		subject:   msgString(storage, propSubject),
+		fromName:  msgString(storage, propSenderName),
+		to:        msgString(storage, propDisplayTo),
+		cc:        msgString(storage, propDisplayCc),
+		messageID: msgString(storage, propInternetMessageID),
+		inReplyTo: msgString(storage, propInReplyTo),
+		body:      msgString(storage, propBody),
+	}
+
+	// Exchange users have X.500 style sender addresses, so prefer the SMTP one
+	for _, prop := range []uint16{propSenderSMTPAddress, propSenderEmail, propSentRepresentingEmail} {
+		if address := msgString(storage, prop); strings.Contains(address, "@") {
+			email.fromAddress = address
+			break
+		}
+	}
+
+	for _, prop := range []uint16{propClientSubmitTime, propMessageDeliveryTime} {
+		if date := msgTime(storage, headerSize, prop); date != nil {
+			email.date = date
+			break
+		}
+	}
+
+	// Fill any gaps from the original transport headers when present
+	if headers := msgString(storage, propTransportHeaders); headers != "" {
+		if msg, err := mail.ReadMessage(strings.NewReader(strings.TrimSpace(headers) + "\r\n\r\n")); err == nil {
+			if email.date == nil {
+				if date, err := msg.Header.Date(); err == nil {
+					email.date = &date
+				}
+			}
+			if email.fromAddress == "" {
+				if addr, err := mimeDecoder.Parse(msg.Header.Get("From")); err == nil {
+					email.fromAddress = addr.Address
+				}
+			}
+			if email.messageID == "" {
+				email.messageID = strings.TrimSpace(msg.Header.Get("Message-Id"))
+			}
+			if email.inReplyTo == "" {
+				email.inReplyTo = strings.TrimSpace(msg.Header.Get("In-Reply-To"))
+			}
+		}
+	}
+
+	if strings.TrimSpace(email.body) == "" {
+		html := msgString(storage, propHTMLBody)
+		if html == "" {
+			html = string(msgBinary(storage, propHTMLBody))
+		}
+		email.body = htmlToText(html)
+	}
+
+	for _, child := range storage.Children() {
+		if child.Type != cfb.EntryStorage || !strings.HasPrefix(child.Name, msgAttachmentPrefix) {
+			continue
+		}
+		if attachment, ok := readMSGAttachment(child); ok {
+			email.attachments = append(email.attachments, attachment)
+		}
+	}
+
+	return email
+}
+
+func readMSGAttachment(storage *cfb.Entry) (Attachment, bool) {
+	filename := msgString(storage, propAttachLongFilename)
+	if filename == "" {
+		filename = msgString(storage, propAttachFilename)
+	}
+	if filename == "" {
+		filename = msgString(storage, propDisplayName)
+	}
+
+	// Attached Outlook items are stored as a nested message storage rather
+	// than bytes. Re-encode them as a minimal RFC 5322 message so they go
+	// through the .eml path; their own attachments are not carried over.
+	if embedded := storage.Child(msgPropertyName(propAttachData, 0x000D)); embedded != nil && embedded.Type == cfb.EntryStorage {
+		message := readMSGStorage(embedded, msgEmbeddedHeaderSize)
+		name := message.subject
+		if name == "" {
+			name = "attached-message"
+		}
+		return Attachment{
+			Filename:    sanitizeFilename(name) + ".eml",
+			ContentType: "message/rfc822",
+			Content:     message.encodeRFC5322(),
+		}, true
+	}
+
+	data := msgBinary(storage, propAttachData)
+	if len(data) == 0 || filename == "" {
+		return Attachment{}, false
+	}
+
+	return Attachment{
+		Filename:    sanitizeFilename(filename),
+		ContentType: msgString(storage, propAttachMimeTag),
+		Content:     data,
+	}, true
+}
+
+// encodeRFC5322 renders the message as a plain text email
+func (e *emailMessage) encodeRFC5322() []byte {
+	var buf bytes.Buffer
+	writeHeader := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&buf, "%s: %s\r\n", key, mime.QEncoding.Encode("utf-8", value))
+		}
+	}
+
+	if e.fromAddress != "" {
+		buf.WriteString("From: " + (&mail.Address{Name: e.fromName, Address: e.fromAddress}).String() + "\r\n")
+	} else {
+		writeHeader("From", e.fromName)
+	}
+	writeHeader("To", e.to)
+	writeHeader("Cc", e.cc)
+	writeHeader("Subject", e.subject)
+	if e.date != nil {
+		buf.WriteString("Date: " + e.date.Format(time.RFC1123Z) + "\r\n")
+	}
+	if e.messageID != "" {
+		buf.WriteString("Message-Id: " + e.messageID + "\r\n")
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	buf.WriteString(e.body)
+	return buf.Bytes()
+}
+
+func msgPropertyName(id, propType uint16) string {
+	return fmt.Sprintf("__substg1.0_%04X%04X", id, propType)
+}
+
+// msgString reads a string property stored as either Unicode or 8-bit text
+func msgString(storage *cfb.Entry, id uint16) string {
+	if entry := storage.Child(msgPropertyName(id, 0x001F)); entry != nil {
+		if data, err := entry.Read(); err == nil {
+			units := make([]uint16, len(data)/2)
+			for i := range units {
+				units[i] = binary.LittleEndian.Uint16(data[i*2:])
+			}
+			return strings.TrimSpace(strings.TrimRight(string(utf16.Decode(units)), "\x00"))
+		}
+	}
+	if entry := storage.Child(msgPropertyName(id, 0x001E)); entry != nil {
+		if data, err := entry.Read(); err == nil {
+			return strings.TrimSpace(decodeCP1252(bytes.TrimRight(data, "\x00")))
+		}
+	}
+	return ""
+}
+
+func msgBinary(storage *cfb.Entry, id uint16) []byte {
+	if entry := storage.Child(msgPropertyName(id, 0x0102)); entry != nil {
+		if data, err := entry.Read(); err == nil {
+			return data
+		}
+	}
+	return nil
+}
+
+// msgTime reads a PT_SYSTIME value from the fixed-length property stream
+func msgTime(storage *cfb.Entry, headerSize int, id uint16) *time.Time {
+	entry := storage.Child(msgPropertiesStream)
+	if entry == nil {
+		return nil
+	}
+	data, err := entry.Read()
+	if err != nil || len(data) < headerSize {
+		return nil
+	}
+
+	// Each property is a 16 byte record: tag, flags, then an 8 byte value
+	for off := headerSize; off+16 <= len(data); off += 16 {
+		tag := binary.LittleEndian.Uint32(data[off:])
+		if uint16(tag>>16) != id || uint16(tag) != ptypSystime {
+			continue
+		}
+
+		filetime := binary.LittleEndian.Uint64(data[off+8:])
+		if filetime == 0 {
+			return nil
+		}
+		seconds := int64(filetime/10000000) - filetimeUnixOffset
+		nanos := int64(filetime%10000000) * 100
+		date := time.Unix(seconds, nanos).UTC()
+		return &date
+	}
+	return nil
+}
+
+func sanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if len(name) > 200 {
+		name = strings.ToValidUTF8(name[:200], "")
+	}
+	return name
+}
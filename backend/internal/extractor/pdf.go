@@ -0,0 +1,64 @@
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/ledongthuc/pdf"
+)
+
+// ExtractPDF extracts text page by page, recording where each page starts in
+// the combined text so excerpts can be cited by page number
+func ExtractPDF(content []byte) (result *Result, err error) {
+	// The PDF library panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("failed to parse PDF: %v", r)
+		}
+	}()
+
+	pdfReader, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PDF reader: %w", err)
+	}
+
+	var (
+		builder textBuilder
+		pages   []domain.DocumentPage
+	)
+
+	for pageNum := 1; pageNum <= pdfReader.NumPage(); pageNum++ {
+		page := pdfReader.Page(pageNum)
+		if page.V.IsNull() {
+			continue
+		}
+
+		// A nil font map lets the library decode text with the page's own
+		// fonts instead of emitting raw glyph codes
+		pageText, err := page.GetPlainText(nil)
+		if err != nil || strings.TrimSpace(pageText) == "" {
+			// If we can't extract text from this page, continue with others
+			continue
+		}
+
+		builder.separate()
+		pages = append(pages, domain.DocumentPage{
+			Number: pageNum,
+			Offset: builder.sb.Len(),
+		})
+		builder.sb.WriteString(strings.TrimSpace(normalizeNewlines(pageText)))
+		builder.sb.WriteString("\n")
+	}
+
+	result = builder.result()
+	if result.Text == "" {
+		return nil, fmt.Errorf("no extractable text (the PDF may be scanned images)")
+	}
+	result.Properties = domain.JSON{
+		"pages":      pages,
+		"page_count": pdfReader.NumPage(),
+	}
+	return result, nil
+}
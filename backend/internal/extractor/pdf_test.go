@@ -0,0 +1,115 @@
+package extractor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+)
+
+// pdfFile builds a minimal PDF with one page per entry in pages, each drawing
+// its text in Helvetica. Empty entries produce pages with no content stream.
+func pdfFile(pages []string) []byte {
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	objects = append(objects, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+
+	var kids []string
+	for _, text := range pages {
+		pageID := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageID))
+		if text == "" {
+			objects = append(objects, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> >>")
+			continue
+		}
+		stream := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageID+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var sb strings.Builder
+	sb.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = sb.Len()
+		fmt.Fprintf(&sb, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := sb.Len()
+	fmt.Fprintf(&sb, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&sb, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&sb, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return []byte(sb.String())
+}
+
+func TestExtractPDF(t *testing.T) {
+	tests := []struct {
+		name      string
+		pages     []string
+		wantPages []int
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name:      "single page",
+			pages:     []string{"Inverter INV001 inspected"},
+			wantPages: []int{1},
+			wantCount: 1,
+		},
+		{
+			name:      "blank pages are skipped but keep numbering",
+			pages:     []string{"Site walkdown notes", "", "Breaker CB-05 reset"},
+			wantPages: []int{1, 3},
+			wantCount: 3,
+		},
+		{
+			name:    "no extractable text",
+			pages:   []string{"", ""},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExtractPDF(pdfFile(tt.pages))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExtractPDF() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			pages, _ := result.Properties["pages"].([]domain.DocumentPage)
+			if len(pages) != len(tt.wantPages) {
+				t.Fatalf("pages = %+v, want numbers %v", pages, tt.wantPages)
+			}
+			for i, page := range pages {
+				if page.Number != tt.wantPages[i] {
+					t.Errorf("pages[%d].Number = %d, want %d", i, page.Number, tt.wantPages[i])
+				}
+				// Each offset must point at the text of the page it records
+				want := tt.pages[page.Number-1]
+				if !strings.HasPrefix(result.Text[page.Offset:], want) {
+					t.Errorf("text at pages[%d].Offset = %q, want it to start with %q", i, result.Text[page.Offset:], want)
+				}
+			}
+			if count := result.Properties["page_count"]; count != tt.wantCount {
+				t.Errorf("page_count = %v, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestExtractPDFInvalid(t *testing.T) {
+	valid := pdfFile([]string{"Tracker row 12 stowed"})
+
+	for _, content := range [][]byte{nil, []byte("not a pdf"), valid[:len(valid)/2]} {
+		if _, err := ExtractPDF(content); err == nil {
+			t.Errorf("ExtractPDF(%d bytes) error = nil, want an error", len(content))
+		}
+	}
+}
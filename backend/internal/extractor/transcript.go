@@ -0,0 +1,248 @@
+package extractor
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+)
+
+// unknownSpeaker labels captions that don't name who is talking
+const unknownSpeaker = "Unknown speaker"
+
+// cue is a single caption or transcript line before consecutive lines from
+// the same speaker are merged into turns
+type cue struct {
+	speaker string
+	start   int // seconds
+	text    string
+}
+
+var (
+	cueTiming      = regexp.MustCompile(`^\s*((?:\d+:)?\d{1,2}:\d{1,2}(?:[.,]\d+)?)\s*-->\s*((?:\d+:)?\d{1,2}:\d{1,2}(?:[.,]\d+)?)`)
+	vttVoiceTag    = regexp.MustCompile(`<v(?:\.[^\s>]+)*\s+([^>]+)>`)
+	vttTag         = regexp.MustCompile(`</?[^>]+>`)
+	speakerPrefix  = regexp.MustCompile(`^(?:-\s*)?\[?([A-Z][\w .'’()-]{0,58}?)\]?:\s+(.+)$`)
+	inlineTimeLine = regexp.MustCompile(`^\[?((?:\d+:)?\d{1,2}:\d{2}(?:[.,]\d+)?)\]?\s+([^:\[\]]{1,60}?):\s*(.*)$`)
+	speakerHeader  = regexp.MustCompile(`^\[?([^\d\[\]:][^\[\]:]{0,59}?)\]?\s+((?:\d+:)?\d{1,2}:\d{2})\s*$`)
+	timeHeader     = regexp.MustCompile(`^\[?((?:\d+:)?\d{1,2}:\d{2}(?:[.,]\d+)?)\]?\s*$`)
+)
+
+// ExtractVTT parses a WebVTT caption file. Speakers come from <v Name> voice
+// tags (as exported by Teams) or a "Name:" prefix on the caption text.
+func ExtractVTT(content []byte) (*Result, error) {
+	text := normalizeNewlines(strings.TrimPrefix(string(content), "\ufeff"))
+	if !strings.HasPrefix(strings.TrimSpace(text), "WEBVTT") {
+		return nil, fmt.Errorf("invalid WebVTT file: missing WEBVTT header")
+	}
+
+	cues := parseCueBlocks(text)
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("invalid WebVTT file: no captions found")
+	}
+	return transcriptResult(cues), nil
+}
+
+// ExtractSRT parses a SubRip caption file
+func ExtractSRT(content []byte) (*Result, error) {
+	text := normalizeNewlines(strings.TrimPrefix(string(content), "\ufeff"))
+
+	cues := parseCueBlocks(text)
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("invalid SRT file: no captions found")
+	}
+	return transcriptResult(cues), nil
+}
+
+// ParseTranscriptText recognises transcripts exported as plain text or Word
+// documents by Teams, Zoom and similar tools:
+//
+//	[00:12:34] Jane Smith: text          (time and speaker inline)
+//	Jane Smith   12:34                   (speaker header, text below)
+//	0:0:5.0 --> 0:0:9.0                  (caption timing, speaker, text)
+//
+// It returns false when the text doesn't look like a transcript.
+func ParseTranscriptText(text string) (*Result, bool) {
+	text = normalizeNewlines(text)
+
+	var cues []cue
+	if strings.Contains(text, "-->") {
+		cues = parseCueBlocks(text)
+	} else {
+		cues = parseTranscriptLines(text)
+	}
+
+	// A couple of matches could be coincidence in an ordinary document
+	if len(cues) < 2 {
+		return nil, false
+	}
+	return transcriptResult(cues), true
+}
+
+// parseCueBlocks handles the blank-line separated cue blocks shared by VTT,
+// SRT and old Teams text exports
+func parseCueBlocks(text string) []cue {
+	var cues []cue
+	for _, block := range paragraphBreak.Split(text, -1) {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+
+		timing := -1
+		for i, line := range lines {
+			if cueTiming.MatchString(line) {
+				timing = i
+				break
+			}
+		}
+		if timing < 0 {
+			// Header, NOTE, STYLE and REGION blocks have no timing line
+			continue
+		}
+
+		start := parseTimestamp(cueTiming.FindStringSubmatch(lines[timing])[1])
+		payload := lines[timing+1:]
+
+		speaker := ""
+		// Teams text exports put the speaker alone on the line after the timing
+		if len(payload) > 1 && !strings.Contains(payload[0], ":") && !strings.Contains(payload[0], "<") && len(payload[0]) <= 60 {
+			speaker = strings.TrimSpace(payload[0])
+			payload = payload[1:]
+		}
+
+		raw := strings.Join(payload, " ")
+		if m := vttVoiceTag.FindStringSubmatch(raw); m != nil {
+			speaker = strings.TrimSpace(m[1])
+		}
+		line := strings.TrimSpace(html.UnescapeString(vttTag.ReplaceAllString(raw, "")))
+		if speaker == "" {
+			if m := speakerPrefix.FindStringSubmatch(line); m != nil {
+				speaker, line = strings.TrimSpace(m[1]), m[2]
+			}
+		}
+		if line == "" {
+			continue
+		}
+		cues = append(cues, cue{speaker: speaker, start: start, text: line})
+	}
+	return cues
+}
+
+// parseTranscriptLines handles line oriented exports where each turn starts
+// with either an inline "time speaker: text" line or a "speaker time" header
+func parseTranscriptLines(text string) []cue {
+	var (
+		cues    []cue
+		current *cue
+	)
+	flush := func() {
+		if current != nil && strings.TrimSpace(current.text) != "" {
+			current.text = strings.TrimSpace(current.text)
+			cues = append(cues, *current)
+		}
+		current = nil
+	}
+
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+
+		if m := inlineTimeLine.FindStringSubmatch(line); m != nil {
+			flush()
+			current = &cue{speaker: strings.TrimSpace(m[2]), start: parseTimestamp(m[1]), text: m[3]}
+			continue
+		}
+		if m := speakerHeader.FindStringSubmatch(line); m != nil {
+			flush()
+			current = &cue{speaker: strings.TrimSpace(m[1]), start: parseTimestamp(m[2])}
+			continue
+		}
+		// Zoom style: a bare timestamp line followed by "Speaker: text"
+		if m := timeHeader.FindStringSubmatch(line); m != nil && i+1 < len(lines) {
+			if next := speakerPrefix.FindStringSubmatch(strings.TrimSpace(lines[i+1])); next != nil {
+				flush()
+				current = &cue{speaker: strings.TrimSpace(next[1]), start: parseTimestamp(m[1]), text: next[2]}
+				i++
+				continue
+			}
+		}
+
+		if current != nil {
+			current.text += " " + line
+		}
+	}
+	flush()
+	return cues
+}
+
+// transcriptResult merges consecutive cues from the same speaker into turns
+// and renders them as "[hh:mm:ss] Speaker: text" lines
+func transcriptResult(cues []cue) *Result {
+	var (
+		builder  textBuilder
+		turns    []domain.SpeakerTurn
+		speakers []string
+		seen     = make(map[string]bool)
+		merged   []cue
+	)
+
+	for _, c := range cues {
+		if c.speaker == "" {
+			c.speaker = unknownSpeaker
+		}
+		if n := len(merged); n > 0 && merged[n-1].speaker == c.speaker {
+			merged[n-1].text += " " + c.text
+			continue
+		}
+		merged = append(merged, c)
+	}
+
+	for _, c := range merged {
+		if !seen[c.speaker] {
+			seen[c.speaker] = true
+			speakers = append(speakers, c.speaker)
+		}
+
+		start := formatTimestamp(c.start)
+		turns = append(turns, domain.SpeakerTurn{
+			Speaker:      c.speaker,
+			Start:        start,
+			StartSeconds: c.start,
+			Offset:       builder.sb.Len(),
+		})
+		builder.line(fmt.Sprintf("[%s] %s: %s", start, c.speaker, strings.Join(strings.Fields(c.text), " ")))
+	}
+
+	result := builder.result()
+	result.Properties = domain.JSON{
+		"speaker_turns": turns,
+		"speakers":      speakers,
+	}
+	return result
+}
+
+// parseTimestamp converts "hh:mm:ss.mmm", "mm:ss,mmm" and similar to seconds
+func parseTimestamp(value string) int {
+	value = strings.NewReplacer(",", ".").Replace(strings.TrimSpace(value))
+	if dot := strings.Index(value, "."); dot >= 0 {
+		value = value[:dot]
+	}
+
+	seconds := 0
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
+
+func formatTimestamp(seconds int) string {
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+}
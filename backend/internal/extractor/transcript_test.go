@@ -0,0 +1,147 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+)
+
+func TestExtractVTT(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "voice tags merge consecutive cues",
+			content: "WEBVTT\n\nNOTE exported by Teams\n\n" +
+				"00:00:01.000 --> 00:00:04.000\n<v Jane Smith>INV001 tripped again</v>\n\n" +
+				"00:00:05.000 --> 00:00:07.000\n<v Jane Smith>around noon.</v>\n\n" +
+				"00:01:10.500 --> 00:01:12.000\n<v.loud Bob Lee>I'll reset it.</v>\n",
+			want: "[00:00:01] Jane Smith: INV001 tripped again around noon.\n" +
+				"[00:01:10] Bob Lee: I'll reset it.",
+		},
+		{
+			name:    "speaker prefix and unknown speaker",
+			content: "\ufeffWEBVTT\r\n\r\n1\r\n00:02.000 --> 00:04.000\r\nJane: Check the combiner\r\n\r\n2\r\n00:05.000 --> 00:06.000\r\nall strings look fine\r\n",
+			want: "[00:00:02] Jane: Check the combiner\n" +
+				"[00:00:05] Unknown speaker: all strings look fine",
+		},
+		{name: "missing header", content: "00:00:01.000 --> 00:00:02.000\nhello\n", wantErr: true},
+		{name: "no captions", content: "WEBVTT\n\nNOTE nothing here\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExtractVTT([]byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExtractVTT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && strings.TrimSpace(result.Text) != tt.want {
+				t.Errorf("ExtractVTT() = %q, want %q", result.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractSRT(t *testing.T) {
+	content := "1\n00:00:01,000 --> 00:00:03,000\nJane: Tracker row 12 is stuck\n\n" +
+		"2\n01:00:04,000 --> 01:00:06,000\n- Bob: On my way\n"
+
+	result, err := ExtractSRT([]byte(content))
+	if err != nil {
+		t.Fatalf("ExtractSRT() error = %v", err)
+	}
+	want := "[00:00:01] Jane: Tracker row 12 is stuck\n[01:00:04] Bob: On my way"
+	if got := strings.TrimSpace(result.Text); got != want {
+		t.Errorf("ExtractSRT() = %q, want %q", got, want)
+	}
+
+	turns, _ := result.Properties["speaker_turns"].([]domain.SpeakerTurn)
+	if len(turns) != 2 || turns[1].StartSeconds != 3604 || turns[1].Offset == 0 {
+		t.Errorf("speaker_turns = %+v, want two turns with the second at 3604s", turns)
+	}
+	speakers, _ := result.Properties["speakers"].([]string)
+	if strings.Join(speakers, ",") != "Jane,Bob" {
+		t.Errorf("speakers = %v, want [Jane Bob]", speakers)
+	}
+
+	if _, err := ExtractSRT([]byte("just some text\n")); err == nil {
+		t.Error("ExtractSRT() error = nil, want an error for a file without captions")
+	}
+}
+
+func TestParseTranscriptText(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		want   string
+		wantOK bool
+	}{
+		{
+			name: "inline time and speaker",
+			text: "[00:12:34] Jane Smith: INV001 is offline\n" +
+				"[00:12:40] Bob Lee: Dispatching a tech\nwith spare fuses",
+			want: "[00:12:34] Jane Smith: INV001 is offline\n" +
+				"[00:12:40] Bob Lee: Dispatching a tech with spare fuses",
+			wantOK: true,
+		},
+		{
+			name:   "speaker header",
+			text:   "Jane Smith   0:05\nMorning all.\n\nBob Lee   1:15\nThe tracker fault cleared.",
+			want:   "[00:00:05] Jane Smith: Morning all.\n[00:01:15] Bob Lee: The tracker fault cleared.",
+			wantOK: true,
+		},
+		{
+			name:   "zoom timestamp lines",
+			text:   "00:00:03\nJane: Starting the call\n00:00:09\nBob: Thanks",
+			want:   "[00:00:03] Jane: Starting the call\n[00:00:09] Bob: Thanks",
+			wantOK: true,
+		},
+		{
+			name: "teams caption export",
+			text: "0:0:5.0 --> 0:0:9.0\nJane Smith\nRow 12 is stowed\n\n" +
+				"0:0:10.0 --> 0:0:12.0\nBob Lee\nConfirmed",
+			want:   "[00:00:05] Jane Smith: Row 12 is stowed\n[00:00:10] Bob Lee: Confirmed",
+			wantOK: true,
+		},
+		{
+			name:   "ordinary document",
+			text:   "Maintenance report\n\nNote: the inverter was inspected at 10:30 and found in good order.",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := ParseTranscriptText(tt.text)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseTranscriptText() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && strings.TrimSpace(result.Text) != tt.want {
+				t.Errorf("ParseTranscriptText() = %q, want %q", result.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"00:00:01.000", 1},
+		{"01:02:03,500", 3723},
+		{"12:34", 754},
+		{"0:0:9.0", 9},
+		{"bad", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseTimestamp(tt.value); got != tt.want {
+			t.Errorf("parseTimestamp(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
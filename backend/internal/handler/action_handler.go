@@ -5,18 +5,27 @@ import (
 	"time"
 
 	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/middleware"
 	"github.com/engramiq/engramiq-backend/internal/repository"
+	"github.com/engramiq/engramiq-backend/internal/service"
+	"github.com/engramiq/engramiq-backend/pkg/logger"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
 type ActionHandler struct {
 	actionRepo repository.ActionRepository
+	embeddings service.EmbeddingService
+	versions   service.ContentVersions
+	log        *logger.Logger
 }
 
-func NewActionHandler(actionRepo repository.ActionRepository) *ActionHandler {
+func NewActionHandler(actionRepo repository.ActionRepository, embeddings service.EmbeddingService, versions service.ContentVersions, log *logger.Logger) *ActionHandler {
 	return &ActionHandler{
 		actionRepo: actionRepo,
+		embeddings: embeddings,
+		versions:   versions,
+		log:        log,
 	}
 }
 
@@ -144,8 +153,8 @@ func (h *ActionHandler) GetActionsByWorkOrder(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get actions by work order
-	actions, err := h.actionRepo.GetByWorkOrderNumber(workOrderNumber)
+	// Get actions by work order across the sites the user may access
+	actions, err := h.actionRepo.GetByWorkOrderNumber(middleware.CurrentSiteScope(c), workOrderNumber)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -241,6 +250,8 @@ func (h *ActionHandler) UpdateAction(c *fiber.Ctx) error {
 			"error": err.Error(),
 		})
 	}
+	h.embeddings.RequeueAction(&action.ExtractedAction)
+	service.InvalidateAnswers(h.versions, action.SiteID, h.log)
 
 	return c.JSON(action)
 }
@@ -255,6 +266,13 @@ func (h *ActionHandler) DeleteAction(c *fiber.Ctx) error {
 		})
 	}
 
+	action, err := h.actionRepo.GetByID(actionID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Action not found",
+		})
+	}
+
 	// Delete action
 	err = h.actionRepo.Delete(actionID)
 	if err != nil {
@@ -262,6 +280,7 @@ func (h *ActionHandler) DeleteAction(c *fiber.Ctx) error {
 			"error": err.Error(),
 		})
 	}
+	service.InvalidateAnswers(h.versions, action.SiteID, h.log)
 
 	return c.Status(fiber.StatusNoContent).Send(nil)
 }
@@ -269,7 +288,7 @@ func (h *ActionHandler) DeleteAction(c *fiber.Ctx) error {
 func (h *ActionHandler) SearchActions(c *fiber.Ctx) error {
 	// Get site ID from params
 	siteIDParam := c.Params("siteId")
-	_, err := uuid.Parse(siteIDParam)
+	siteID, err := uuid.Parse(siteIDParam)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid site ID",
@@ -284,8 +303,12 @@ func (h *ActionHandler) SearchActions(c *fiber.Ctx) error {
 		})
 	}
 
-	// Parse parameters
-	_, _ = strconv.Atoi(c.Query("limit", "20"))
+	// Parse parameters; threshold is the largest cosine distance a match
+	// may have
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
 	threshold := 0.8
 	if t := c.Query("threshold"); t != "" {
 		if parsed, parseErr := strconv.ParseFloat(t, 64); parseErr == nil {
@@ -293,9 +316,10 @@ func (h *ActionHandler) SearchActions(c *fiber.Ctx) error {
 		}
 	}
 
-	// Generate embedding for search (this would be done by the LLM service)
-	// For now, return empty results as this requires the embedding generation
-	actions := make([]*domain.ExtractedAction, 0)
+	actions, err := h.embeddings.SearchActions(c.UserContext(), siteID, query, limit, threshold)
+	if err != nil {
+		return errorResponse(c, err)
+	}
 
 	return c.JSON(fiber.Map{
 		"query":     query,
@@ -303,4 +327,4 @@ func (h *ActionHandler) SearchActions(c *fiber.Ctx) error {
 		"count":     len(actions),
 		"threshold": threshold,
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/middleware"
+	"github.com/engramiq/engramiq-backend/internal/service"
+	apperrors "github.com/engramiq/engramiq-backend/pkg/errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// APIKeyHandler lets admins issue and revoke API keys for machine clients
+type APIKeyHandler struct {
+	apiKeys service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeys service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeys: apiKeys,
+	}
+}
+
+// CreateAPIKey issues a key acting as the signed-in admin. The key itself is
+// only in this response.
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	var req domain.CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	key, err := h.apiKeys.Create(middleware.CurrentUser(c), middleware.CurrentSiteScope(c), &req)
+	if err != nil {
+		if appErr, ok := apperrors.IsAppError(err); ok {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{
+				"error": appErr.Message,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create API key",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(key)
+}
+
+func (h *APIKeyHandler) ListAPIKeys(c *fiber.Ctx) error {
+	keys, err := h.apiKeys.List(middleware.CurrentUser(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch API keys",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"api_keys": keys,
+	})
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	keyID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid API key ID",
+		})
+	}
+
+	if err := h.apiKeys.Revoke(middleware.CurrentUser(c), keyID); err != nil {
+		if appErr, ok := apperrors.IsAppError(err); ok {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{
+				"error": appErr.Message,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke API key",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/middleware"
+	"github.com/engramiq/engramiq-backend/internal/service"
+	apperrors "github.com/engramiq/engramiq-backend/pkg/errors"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	refreshTokenCookie = "refresh_token"
+	// refreshTokenPath limits the refresh token cookie to the auth routes
+	refreshTokenPath = "/api/v1/auth"
+)
+
+// RefreshCookie configures the HttpOnly cookie that carries the refresh
+// token
+type RefreshCookie struct {
+	Secure bool
+	TTL    time.Duration
+}
+
+type AuthHandler struct {
+	authService service.AuthService
+	cookie      RefreshCookie
+}
+
+func NewAuthHandler(authService service.AuthService, cookie RefreshCookie) *AuthHandler {
+	return &AuthHandler{
+		authService: authService,
+		cookie:      cookie,
+	}
+}
+
+func (h *AuthHandler) Register(c *fiber.Ctx) error {
+	var req domain.RegisterRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	response, refreshToken, err := h.authService.Register(&req)
+	if err != nil {
+		return authError(c, err)
+	}
+
+	h.setRefreshCookie(c, refreshToken)
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+func (h *AuthHandler) Login(c *fiber.Ctx) error {
+	var req domain.LoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	response, refreshToken, err := h.authService.Login(&req)
+	if err != nil {
+		return authError(c, err)
+	}
+
+	h.setRefreshCookie(c, refreshToken)
+	return c.JSON(response)
+}
+
+// Refresh exchanges the refresh token cookie for a new access token and
+// rotates the cookie. A rejected refresh token clears the cookie.
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	response, refreshToken, err := h.authService.Refresh(c.Cookies(refreshTokenCookie))
+	if err != nil {
+		if _, ok := apperrors.IsAppError(err); ok {
+			h.clearRefreshCookie(c)
+		}
+		return authError(c, err)
+	}
+
+	h.setRefreshCookie(c, refreshToken)
+	return c.JSON(response)
+}
+
+// Logout revokes the refresh token cookie and clears it. Access tokens
+// already issued stay valid until they expire.
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	if err := h.authService.Logout(c.Cookies(refreshTokenCookie)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to log out",
+		})
+	}
+
+	h.clearRefreshCookie(c)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Me returns the authenticated user and what the request may do; for API
+// key requests, also the key
+func (h *AuthHandler) Me(c *fiber.Ctx) error {
+	body := fiber.Map{
+		"user":        middleware.CurrentUser(c),
+		"permissions": middleware.CurrentPermissions(c),
+	}
+	if apiKey := middleware.CurrentAPIKey(c); apiKey != nil {
+		body["api_key"] = apiKey
+	}
+	return c.JSON(body)
+}
+
+func (h *AuthHandler) setRefreshCookie(c *fiber.Ctx, token string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    token,
+		Path:     refreshTokenPath,
+		MaxAge:   int(h.cookie.TTL.Seconds()),
+		Expires:  time.Now().Add(h.cookie.TTL),
+		Secure:   h.cookie.Secure,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+}
+
+func (h *AuthHandler) clearRefreshCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    "",
+		Path:     refreshTokenPath,
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		Secure:   h.cookie.Secure,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+}
+
+// authError responds with an application error's status, and the failing
+// fields of a validation error
+func authError(c *fiber.Ctx, err error) error {
+	appErr, ok := apperrors.IsAppError(err)
+	if !ok {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Authentication failed",
+		})
+	}
+
+	body := fiber.Map{"error": appErr.Message}
+	if appErr.Details != nil {
+		body["details"] = appErr.Details
+	}
+	return c.Status(appErr.StatusCode).JSON(body)
+}
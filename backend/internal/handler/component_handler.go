@@ -5,31 +5,33 @@ import (
 
 	"github.com/engramiq/engramiq-backend/internal/domain"
 	"github.com/engramiq/engramiq-backend/internal/repository"
+	"github.com/engramiq/engramiq-backend/internal/service"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"github.com/pgvector/pgvector-go"
 )
 
 type ComponentHandler struct {
 	componentRepo repository.ComponentRepository
 	actionRepo    repository.ActionRepository
+	embeddings    service.EmbeddingService
 }
 
 type CreateComponentRequest struct {
-	ExternalID      string                 `json:"external_id" validate:"required"`
-	Name            string                 `json:"name" validate:"required"`
-	ComponentType   domain.ComponentType   `json:"component_type" validate:"required"`
-	Label           string                 `json:"label"`
-	GroupName       string                 `json:"group_name"`
-	Specifications  map[string]interface{} `json:"specifications"`
-	Level           int                    `json:"level"`
-	CurrentStatus   domain.ComponentStatus `json:"current_status"`
+	ExternalID     string                 `json:"external_id" validate:"required"`
+	Name           string                 `json:"name" validate:"required"`
+	ComponentType  domain.ComponentType   `json:"component_type" validate:"required"`
+	Label          string                 `json:"label"`
+	GroupName      string                 `json:"group_name"`
+	Specifications map[string]interface{} `json:"specifications"`
+	Level          int                    `json:"level"`
+	CurrentStatus  domain.ComponentStatus `json:"current_status"`
 }
 
-func NewComponentHandler(componentRepo repository.ComponentRepository, actionRepo repository.ActionRepository) *ComponentHandler {
+func NewComponentHandler(componentRepo repository.ComponentRepository, actionRepo repository.ActionRepository, embeddings service.EmbeddingService) *ComponentHandler {
 	return &ComponentHandler{
 		componentRepo: componentRepo,
 		actionRepo:    actionRepo,
+		embeddings:    embeddings,
 	}
 }
 
@@ -53,19 +55,18 @@ func (h *ComponentHandler) CreateComponent(c *fiber.Ctx) error {
 
 	// Create component
 	component := &domain.SiteComponent{
-		ID:              uuid.New(),
-		SiteID:          siteID,
-		ExternalID:      req.ExternalID,
-		Name:            req.Name,
-		ComponentType:   req.ComponentType,
-		Label:           req.Label,
-		Level:           req.Level,
-		GroupName:       req.GroupName,
-		Specifications:  domain.JSON(req.Specifications),
-		ElectricalData:  domain.JSON{},
-		PhysicalData:    domain.JSON{},
-		CurrentStatus:   req.CurrentStatus,
-		Embedding:       pgvector.NewVector(make([]float32, 1536)), // Initialize empty vector
+		ID:             uuid.New(),
+		SiteID:         siteID,
+		ExternalID:     req.ExternalID,
+		Name:           req.Name,
+		ComponentType:  req.ComponentType,
+		Label:          req.Label,
+		Level:          req.Level,
+		GroupName:      req.GroupName,
+		Specifications: domain.JSON(req.Specifications),
+		ElectricalData: domain.JSON{},
+		PhysicalData:   domain.JSON{},
+		CurrentStatus:  req.CurrentStatus,
 	}
 
 	// Set default status if not provided
@@ -73,6 +74,8 @@ func (h *ComponentHandler) CreateComponent(c *fiber.Ctx) error {
 		component.CurrentStatus = domain.ComponentStatusOperational
 	}
 
+	h.embeddings.QueueComponents([]*domain.SiteComponent{component})
+
 	err = h.componentRepo.Create(component)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -187,6 +190,9 @@ func (h *ComponentHandler) UpdateComponent(c *fiber.Ctx) error {
 		})
 	}
 
+	// Re-embed in the background so semantic search reflects the update
+	h.embeddings.RequeueComponent(component)
+
 	return c.JSON(component)
 }
 
@@ -287,16 +293,16 @@ func (h *ComponentHandler) BulkCreateComponents(c *fiber.Ctx) error {
 	components := make([]*domain.SiteComponent, len(req.Components))
 	for i, comp := range req.Components {
 		components[i] = &domain.SiteComponent{
-			ID:              uuid.New(),
-			SiteID:          siteID,
-			ExternalID:      comp.ExternalID,
-			Name:            comp.Name,
-			ComponentType:   comp.ComponentType,
-			Label:           comp.Label,
-			Level:           comp.Level,
-			GroupName:       comp.GroupName,
-			Specifications:  domain.JSON(comp.Specifications),
-			CurrentStatus:   comp.CurrentStatus,
+			ID:             uuid.New(),
+			SiteID:         siteID,
+			ExternalID:     comp.ExternalID,
+			Name:           comp.Name,
+			ComponentType:  comp.ComponentType,
+			Label:          comp.Label,
+			Level:          comp.Level,
+			GroupName:      comp.GroupName,
+			Specifications: domain.JSON(comp.Specifications),
+			CurrentStatus:  comp.CurrentStatus,
 		}
 
 		// Set default status if not provided
@@ -305,6 +311,8 @@ func (h *ComponentHandler) BulkCreateComponents(c *fiber.Ctx) error {
 		}
 	}
 
+	h.embeddings.QueueComponents(components)
+
 	// Bulk create components
 	err = h.componentRepo.BulkCreate(components)
 	if err != nil {
@@ -318,4 +326,4 @@ func (h *ComponentHandler) BulkCreateComponents(c *fiber.Ctx) error {
 		"count":      len(components),
 		"components": components,
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/middleware"
+	"github.com/engramiq/engramiq-backend/internal/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ConversationHandler manages multi-turn query sessions. Turns are asked
+// through the query endpoints with a conversation_id.
+type ConversationHandler struct {
+	queryService service.QueryService
+}
+
+type CreateConversationRequest struct {
+	Title string `json:"title"` // Optional; defaults to the first question
+}
+
+func NewConversationHandler(queryService service.QueryService) *ConversationHandler {
+	return &ConversationHandler{
+		queryService: queryService,
+	}
+}
+
+func (h *ConversationHandler) CreateConversation(c *fiber.Ctx) error {
+	// Get site ID from params
+	siteIDParam := c.Params("siteId")
+	siteID, err := uuid.Parse(siteIDParam)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid site ID",
+		})
+	}
+
+	userID := middleware.CurrentUser(c).ID
+
+	var req CreateConversationRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	conversation, err := h.queryService.CreateConversation(userID, siteID, req.Title)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(conversation)
+}
+
+// ListConversations lists the user's conversations on a site, most recently
+// active first
+func (h *ConversationHandler) ListConversations(c *fiber.Ctx) error {
+	// Get site ID from params
+	siteIDParam := c.Params("siteId")
+	siteID, err := uuid.Parse(siteIDParam)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid site ID",
+		})
+	}
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+
+	pagination := &domain.Pagination{
+		Page:  page,
+		Limit: limit,
+	}
+
+	conversations, err := h.queryService.ListConversations(middleware.CurrentUser(c).ID, siteID, pagination)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"conversations": conversations,
+		"pagination":    pagination,
+	})
+}
+
+// GetConversation returns the history of one of the user's conversations:
+// every turn in order with its answer and the sources it cited
+func (h *ConversationHandler) GetConversation(c *fiber.Ctx) error {
+	conversationIDParam := c.Params("id")
+	conversationID, err := uuid.Parse(conversationIDParam)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid conversation ID",
+		})
+	}
+
+	conversation, err := h.queryService.GetConversation(middleware.CurrentUser(c).ID, conversationID)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+
+	return c.JSON(conversation)
+}
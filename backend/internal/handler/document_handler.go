@@ -49,14 +49,7 @@ func (h *DocumentHandler) UploadDocument(c *fiber.Ctx) error {
 		})
 	}
 
-	// Trigger async document processing to generate embeddings
-	go func() {
-		if processErr := h.docService.ProcessDocument(document.ID); processErr != nil {
-			// Log error but don't fail the upload response
-			// TODO: Add proper logging here
-		}
-	}()
-
+	// Processing is picked up asynchronously by the document worker pool
 	return c.Status(fiber.StatusCreated).JSON(document)
 }
 
@@ -81,6 +74,29 @@ func (h *DocumentHandler) GetDocument(c *fiber.Ctx) error {
 	return c.JSON(document)
 }
 
+func (h *DocumentHandler) ListAttachments(c *fiber.Ctx) error {
+	// Get document ID from params
+	docIDParam := c.Params("id")
+	docID, err := uuid.Parse(docIDParam)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+
+	attachments, err := h.docService.ListAttachments(docID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"attachments": attachments,
+		"count":       len(attachments),
+	})
+}
+
 func (h *DocumentHandler) ListDocuments(c *fiber.Ctx) error {
 	// Get site ID from params
 	siteIDParam := c.Params("siteId")
@@ -138,9 +154,7 @@ func (h *DocumentHandler) DeleteDocument(c *fiber.Ctx) error {
 	// Delete document
 	err = h.docService.DeleteDocument(docID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return errorResponse(c, err)
 	}
 
 	return c.Status(fiber.StatusNoContent).Send(nil)
@@ -157,11 +171,9 @@ func (h *DocumentHandler) ProcessDocument(c *fiber.Ctx) error {
 	}
 
 	// Process document
-	err = h.docService.ProcessDocument(docID)
+	err = h.docService.ProcessDocument(c.UserContext(), docID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return errorResponse(c, err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -169,6 +181,80 @@ func (h *DocumentHandler) ProcessDocument(c *fiber.Ctx) error {
 	})
 }
 
+func (h *DocumentHandler) ListFailedDocuments(c *fiber.Ctx) error {
+	// Get site ID from params
+	siteIDParam := c.Params("siteId")
+	siteID, err := uuid.Parse(siteIDParam)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid site ID",
+		})
+	}
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+
+	pagination := &domain.Pagination{
+		Page:  page,
+		Limit: limit,
+		Sort:  "updated_at DESC",
+	}
+
+	// Optional filter: failed (awaiting retry) or dead_letter
+	status := domain.ProcessingStatus(c.Query("status"))
+
+	documents, err := h.docService.ListFailedDocuments(siteID, status, pagination)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"documents":  documents,
+		"pagination": pagination,
+	})
+}
+
+func (h *DocumentHandler) RequeueDocument(c *fiber.Ctx) error {
+	// Get document ID from params
+	docIDParam := c.Params("id")
+	docID, err := uuid.Parse(docIDParam)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+
+	document, err := h.docService.RequeueDocument(docID)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+
+	return c.JSON(document)
+}
+
+func (h *DocumentHandler) RequeueFailedDocuments(c *fiber.Ctx) error {
+	// Get site ID from params
+	siteIDParam := c.Params("siteId")
+	siteID, err := uuid.Parse(siteIDParam)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid site ID",
+		})
+	}
+
+	status := domain.ProcessingStatus(c.Query("status"))
+
+	requeued, err := h.docService.RequeueFailedDocuments(siteID, status)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"requeued": requeued,
+	})
+}
+
 func (h *DocumentHandler) SearchDocuments(c *fiber.Ctx) error {
 	// Get site ID from params
 	siteIDParam := c.Params("siteId")
@@ -200,15 +286,13 @@ func (h *DocumentHandler) SearchDocuments(c *fiber.Ctx) error {
 				threshold = parsed
 			}
 		}
-		documents, err = h.docService.SearchDocumentsSemantic(siteID, query, limit, threshold)
+		documents, err = h.docService.SearchDocumentsSemantic(c.UserContext(), siteID, query, limit, threshold)
 	} else {
 		documents, err = h.docService.SearchDocuments(siteID, query, limit)
 	}
 
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return errorResponse(c, err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -217,4 +301,4 @@ func (h *DocumentHandler) SearchDocuments(c *fiber.Ctx) error {
 		"documents": documents,
 		"count":     len(documents),
 	})
-}
\ No newline at end of file
+}
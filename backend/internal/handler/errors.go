@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	apperrors "github.com/engramiq/engramiq-backend/pkg/errors"
+	"github.com/gofiber/fiber/v2"
+)
+
+// statusClientClosedRequest is the non-standard status (from nginx) logged
+// when the client went away before the response was ready
+const statusClientClosedRequest = 499
+
+// errorStatus maps a service error to its HTTP status. Application errors,
+// such as an unknown conversation, keep their own status. For requests that
+// wait on the LLM, a call that ran past LLM_TIMEOUT is a gateway timeout and
+// a request cancelled because the client disconnected is never seen by
+// anyone. Anything else is a 500.
+func errorStatus(err error) int {
+	var appErr apperrors.AppError
+	switch {
+	case errors.As(err, &appErr):
+		return appErr.StatusCode
+	case errors.Is(err, context.DeadlineExceeded):
+		return fiber.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+// errorResponse writes err with the status errorStatus maps it to
+func errorResponse(c *fiber.Ctx, err error) error {
+	return c.Status(errorStatus(err)).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
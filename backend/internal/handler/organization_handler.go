@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/middleware"
+	"github.com/engramiq/engramiq-backend/internal/repository"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// OrganizationHandler lets platform operators set up organizations and move
+// users and sites into them, and admins grant users of their organization
+// access to individual sites
+type OrganizationHandler struct {
+	orgRepo  repository.OrganizationRepository
+	userRepo repository.UserRepository
+	siteRepo repository.SiteRepository
+}
+
+func NewOrganizationHandler(orgRepo repository.OrganizationRepository, userRepo repository.UserRepository, siteRepo repository.SiteRepository) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgRepo:  orgRepo,
+		userRepo: userRepo,
+		siteRepo: siteRepo,
+	}
+}
+
+func (h *OrganizationHandler) CreateOrganization(c *fiber.Ctx) error {
+	var req domain.CreateOrganizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Slug = strings.ToLower(strings.TrimSpace(req.Slug))
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Organization name is required",
+		})
+	}
+	if len(req.Slug) > 100 || !slugPattern.MatchString(req.Slug) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Slug must be lowercase letters and digits separated by hyphens",
+		})
+	}
+
+	if _, err := h.orgRepo.GetBySlug(req.Slug); err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "An organization with this slug already exists",
+		})
+	}
+
+	org := &domain.Organization{
+		ID:   uuid.New(),
+		Name: req.Name,
+		Slug: req.Slug,
+	}
+	if err := h.orgRepo.Create(org); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create organization",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(org)
+}
+
+func (h *OrganizationHandler) ListOrganizations(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+	pagination := &domain.Pagination{
+		Page:  page,
+		Limit: limit,
+	}
+
+	orgs, err := h.orgRepo.List(pagination)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch organizations",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"organizations": orgs,
+		"pagination":    pagination,
+	})
+}
+
+func (h *OrganizationHandler) GetOrganization(c *fiber.Ctx) error {
+	orgID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid organization ID",
+		})
+	}
+
+	org, err := h.orgRepo.GetByID(orgID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+	siteIDs, err := h.orgRepo.SiteIDs(orgID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch organization sites",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"organization": org,
+		"site_ids":     siteIDs,
+	})
+}
+
+// AssignUser moves a user to an organization. Their grants on sites of the
+// old organization are dropped.
+func (h *OrganizationHandler) AssignUser(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+	var req domain.AssignOrganizationRequest
+	if err := c.BodyParser(&req); err != nil || req.OrganizationID == uuid.Nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "organization_id is required",
+		})
+	}
+	if _, err := h.orgRepo.GetByID(req.OrganizationID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	if _, err := h.userRepo.GetByID(userID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err := h.orgRepo.AssignUser(userID, req.OrganizationID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to assign user",
+		})
+	}
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch updated user",
+		})
+	}
+	return c.JSON(user)
+}
+
+// AssignSite moves a site to an organization. Grants on it held by users of
+// another organization are dropped.
+func (h *OrganizationHandler) AssignSite(c *fiber.Ctx) error {
+	siteID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid site ID",
+		})
+	}
+	var req domain.AssignOrganizationRequest
+	if err := c.BodyParser(&req); err != nil || req.OrganizationID == uuid.Nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "organization_id is required",
+		})
+	}
+	if _, err := h.orgRepo.GetByID(req.OrganizationID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Organization not found",
+		})
+	}
+
+	if _, err := h.siteRepo.GetByID(siteID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Site not found",
+		})
+	}
+	if err := h.orgRepo.AssignSite(siteID, req.OrganizationID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to assign site",
+		})
+	}
+
+	site, err := h.siteRepo.GetByID(siteID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch updated site",
+		})
+	}
+	return c.JSON(site)
+}
+
+func (h *OrganizationHandler) ListSiteGrants(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+	if _, ok := administeredUser(c, h.userRepo, userID); !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	grants, err := h.orgRepo.ListGrants(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch site grants",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"grants": grants,
+	})
+}
+
+// GrantSite gives a user access to a site of their own organization
+func (h *OrganizationHandler) GrantSite(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+	var req domain.SiteGrantRequest
+	if err := c.BodyParser(&req); err != nil || req.SiteID == uuid.Nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "site_id is required",
+		})
+	}
+
+	user, ok := administeredUser(c, h.userRepo, userID)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	site, err := h.siteRepo.GetByID(req.SiteID)
+	if err != nil || !middleware.CurrentSiteScope(c).Includes(site.ID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Site not found",
+		})
+	}
+	if user.OrganizationID == nil || site.OrganizationID == nil || *user.OrganizationID != *site.OrganizationID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Users can only be granted sites of their own organization",
+		})
+	}
+
+	grantedBy := middleware.CurrentUser(c).ID
+	grant := &domain.SiteGrant{
+		UserID:    userID,
+		SiteID:    site.ID,
+		GrantedBy: &grantedBy,
+	}
+	if err := h.orgRepo.Grant(grant); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to grant site access",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(grant)
+}
+
+func (h *OrganizationHandler) RevokeSite(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+	siteID, err := uuid.Parse(c.Params("siteId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid site ID",
+		})
+	}
+
+	if _, ok := administeredUser(c, h.userRepo, userID); !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err := h.orgRepo.Revoke(userID, siteID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke site access",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -1,10 +1,17 @@
 package handler
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/middleware"
 	"github.com/engramiq/engramiq-backend/internal/service"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -15,9 +22,12 @@ type QueryHandler struct {
 }
 
 type CreateQueryRequest struct {
-	QueryText string            `json:"query_text" validate:"required"`
-	QueryType domain.QueryType  `json:"query_type"`
-	Enhanced  bool              `json:"enhanced,omitempty"` // Use enhanced processing per PRD
+	QueryText string           `json:"query_text" validate:"required"`
+	QueryType domain.QueryType `json:"query_type"`
+	Enhanced  bool             `json:"enhanced,omitempty"` // Use enhanced processing per PRD
+	// ConversationID asks the query as the next turn of a conversation;
+	// conversation turns always use enhanced processing
+	ConversationID *uuid.UUID `json:"conversation_id,omitempty"`
 }
 
 func NewQueryHandler(queryService service.QueryService) *QueryHandler {
@@ -36,9 +46,7 @@ func (h *QueryHandler) CreateQuery(c *fiber.Ctx) error {
 		})
 	}
 
-	// TODO: Get user ID from authentication context
-	// For now, use a placeholder
-	userID := uuid.New()
+	userID := middleware.CurrentUser(c).ID
 
 	// Parse request body
 	var req CreateQueryRequest
@@ -54,27 +62,140 @@ func (h *QueryHandler) CreateQuery(c *fiber.Ctx) error {
 	}
 
 	// Use enhanced processing by default per PRD requirements
-	if req.Enhanced || req.QueryType == "" {
+	if req.Enhanced || req.ConversationID != nil || req.QueryType == "" {
 		// Enhanced query processing with source attribution and no hallucination
-		enhancedResponse, err := h.queryService.ProcessEnhancedQuery(userID, siteID, req.QueryText)
+		enhancedResponse, err := h.queryService.ProcessEnhancedQuery(queryContext(c), userID, siteID, req.QueryText, req.ConversationID)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return errorResponse(c, err)
 		}
 		return c.Status(fiber.StatusCreated).JSON(enhancedResponse)
 	} else {
 		// Legacy query processing
-		query, err := h.queryService.ProcessQuery(userID, siteID, req.QueryText, req.QueryType)
+		query, err := h.queryService.ProcessQuery(queryContext(c), userID, siteID, req.QueryText, req.QueryType)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return errorResponse(c, err)
 		}
 		return c.Status(fiber.StatusCreated).JSON(query)
 	}
 }
 
+// streamHeartbeatInterval is how often a streamed answer sends a keep-alive
+// comment while it has nothing else to send
+const streamHeartbeatInterval = 10 * time.Second
+
+// StreamQuery answers a query over server-sent events: an "intent" event,
+// a "sources" event, "token" events as the answer is generated and a final
+// "done" event with the confidence and cited sources. Failures after the
+// stream has started are sent as an "error" event. GET takes the query in
+// ?q= (and a conversation in ?conversation_id=) for EventSource clients;
+// POST takes the CreateQuery body.
+func (h *QueryHandler) StreamQuery(c *fiber.Ctx) error {
+	// Get site ID from params
+	siteIDParam := c.Params("siteId")
+	siteID, err := uuid.Parse(siteIDParam)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid site ID",
+		})
+	}
+
+	userID := middleware.CurrentUser(c).ID
+
+	queryText := c.Query("q")
+	var conversationID *uuid.UUID
+	if param := c.Query("conversation_id"); param != "" {
+		id, err := uuid.Parse(param)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid conversation ID",
+			})
+		}
+		conversationID = &id
+	}
+	if c.Method() == fiber.MethodPost {
+		var req CreateQueryRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+		queryText = req.QueryText
+		conversationID = req.ConversationID
+	}
+	if strings.TrimSpace(queryText) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Query text is required",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	// Stop nginx from buffering the stream
+	c.Set("X-Accel-Buffering", "no")
+
+	// The writer runs after this handler returns, so it must not touch c.
+	// A failed flush means the client is gone, which cancels the query, as
+	// does the writer returning.
+	client := queryClient(c)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithCancel(service.WithQueryClient(context.Background(), client))
+		defer cancel()
+
+		var mu sync.Mutex
+		write := func(frame string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			w.WriteString(frame)
+			if err := w.Flush(); err != nil {
+				cancel()
+				return err
+			}
+			return nil
+		}
+		send := func(event string, data interface{}) error {
+			payload, err := json.Marshal(data)
+			if err != nil {
+				return err
+			}
+			return write(fmt.Sprintf("event: %s\ndata: %s\n\n", event, payload))
+		}
+
+		// Nothing is written while sources are retrieved or the model thinks,
+		// so keep-alive comments go out meanwhile; a client that left is then
+		// noticed within one interval rather than at the next token
+		done := make(chan struct{})
+		var heartbeat sync.WaitGroup
+		heartbeat.Add(1)
+		go func() {
+			defer heartbeat.Done()
+			ticker := time.NewTicker(streamHeartbeatInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					write(": keep-alive\n\n")
+				}
+			}
+		}()
+		defer func() {
+			close(done)
+			heartbeat.Wait()
+		}()
+
+		if err := h.queryService.StreamEnhancedQuery(ctx, userID, siteID, queryText, conversationID, send); err != nil && ctx.Err() == nil {
+			send("error", fiber.Map{"error": err.Error()})
+		}
+	})
+
+	return nil
+}
+
 func (h *QueryHandler) GetQuery(c *fiber.Ctx) error {
 	// Get query ID from params
 	queryIDParam := c.Params("id")
@@ -97,21 +218,8 @@ func (h *QueryHandler) GetQuery(c *fiber.Ctx) error {
 }
 
 func (h *QueryHandler) GetQueryHistory(c *fiber.Ctx) error {
-	// TODO: Get user ID from authentication context
-	// For now, parse from query parameter
-	userIDParam := c.Query("user_id")
-	if userIDParam == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "User ID is required",
-		})
-	}
-
-	userID, err := uuid.Parse(userIDParam)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid user ID",
-		})
-	}
+	// History is the authenticated user's own, on sites they can still access
+	userID := middleware.CurrentUser(c).ID
 
 	// Parse pagination parameters
 	page, _ := strconv.Atoi(c.Query("page", "1"))
@@ -125,7 +233,7 @@ func (h *QueryHandler) GetQueryHistory(c *fiber.Ctx) error {
 	}
 
 	// Get query history
-	queries, err := h.queryService.GetQueryHistory(userID, pagination)
+	queries, err := h.queryService.GetQueryHistory(userID, middleware.CurrentSiteScope(c), pagination)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -160,17 +268,15 @@ func (h *QueryHandler) SearchSimilarQueries(c *fiber.Ctx) error {
 	limit, _ := strconv.Atoi(c.Query("limit", "10"))
 
 	// Search similar queries
-	queries, err := h.queryService.SearchSimilarQueries(siteID, queryText, limit)
+	queries, err := h.queryService.SearchSimilarQueries(c.UserContext(), siteID, queryText, limit)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return errorResponse(c, err)
 	}
 
 	return c.JSON(fiber.Map{
 		"query":           queryText,
 		"similar_queries": queries,
-		"count":          len(queries),
+		"count":           len(queries),
 	})
 }
 
@@ -184,28 +290,16 @@ func (h *QueryHandler) GetQueryAnalytics(c *fiber.Ctx) error {
 		})
 	}
 
-	// Parse date range
-	startDateStr := c.Query("start_date")
-	endDateStr := c.Query("end_date")
-
-	// Default to last 30 days if not provided
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -30)
-
-	if startDateStr != "" {
-		if parsed, err := time.Parse("2006-01-02", startDateStr); err == nil {
-			startDate = parsed
-		}
-	}
-
-	if endDateStr != "" {
-		if parsed, err := time.Parse("2006-01-02", endDateStr); err == nil {
-			endDate = parsed
-		}
+	// Parse date range, defaulting to the last 30 days
+	startDate, endDate, err := reportDateRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
 	// Get analytics
-	analytics, err := h.queryService.GetQueryAnalytics(siteID, startDate, endDate)
+	analytics, err := h.queryService.GetQueryAnalytics(siteID, startDate, endDate.AddDate(0, 0, 1))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -213,10 +307,25 @@ func (h *QueryHandler) GetQueryAnalytics(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"analytics":  analytics,
+		"analytics": analytics,
 		"date_range": fiber.Map{
 			"start_date": startDate.Format("2006-01-02"),
 			"end_date":   endDate.Format("2006-01-02"),
 		},
 	})
-}
\ No newline at end of file
+}
+
+// queryClient identifies the caller of a query for its analytics record: the
+// session in X-Session-ID, when it is a UUID, and the user agent
+func queryClient(c *fiber.Ctx) service.QueryClient {
+	client := service.QueryClient{UserAgent: c.Get(fiber.HeaderUserAgent)}
+	if id, err := uuid.Parse(c.Get("X-Session-ID")); err == nil {
+		client.SessionID = &id
+	}
+	return client
+}
+
+// queryContext is the request context with the query's caller attached
+func queryContext(c *fiber.Ctx) context.Context {
+	return service.WithQueryClient(c.UserContext(), queryClient(c))
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"strconv"
 
+	"github.com/engramiq/engramiq-backend/internal/middleware"
 	"github.com/engramiq/engramiq-backend/internal/repository"
 	"github.com/gofiber/fiber/v2"
 )
@@ -33,7 +34,8 @@ func (h *SiteHandler) ListSites(c *fiber.Ctx) error {
 		}
 	}
 
-	sites, total, err := h.siteRepo.GetSites(page, limit)
+	// Only the sites the user may access are listed
+	sites, total, err := h.siteRepo.GetSites(middleware.CurrentSiteScope(c), page, limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch sites",
@@ -58,8 +60,10 @@ func (h *SiteHandler) GetSite(c *fiber.Ctx) error {
 		})
 	}
 
+	// Sites outside the user's scope look the same as missing ones, so site
+	// codes of other organizations can't be probed
 	site, err := h.siteRepo.GetSite(siteID)
-	if err != nil {
+	if err != nil || !middleware.CurrentSiteScope(c).Includes(site.ID) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Site not found",
 		})
@@ -68,4 +72,4 @@ func (h *SiteHandler) GetSite(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"data": site,
 	})
-}
\ No newline at end of file
+}
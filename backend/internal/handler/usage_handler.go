@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/middleware"
+	"github.com/engramiq/engramiq-backend/internal/repository"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type UsageHandler struct {
+	usageRepo repository.UsageRepository
+}
+
+func NewUsageHandler(usageRepo repository.UsageRepository) *UsageHandler {
+	return &UsageHandler{
+		usageRepo: usageRepo,
+	}
+}
+
+// GetSiteUsage reports a site's LLM calls, tokens, latency and estimated
+// cost, in total and by day, operation and model
+func (h *UsageHandler) GetSiteUsage(c *fiber.Ctx) error {
+	siteID, err := uuid.Parse(c.Params("siteId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid site ID",
+		})
+	}
+
+	startDate, endDate, err := reportDateRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	report, err := h.usageRepo.SiteReport(siteID, startDate, endDate.AddDate(0, 0, 1))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch LLM usage",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"usage": report,
+		"date_range": fiber.Map{
+			"start_date": startDate.Format("2006-01-02"),
+			"end_date":   endDate.Format("2006-01-02"),
+		},
+	})
+}
+
+// ListSiteUsage totals the LLM usage of every site the admin may access, most
+// expensive first. Calls made for no site, such as the embeddings backfill of
+// rows without one, are totalled under an empty key for platform operators.
+func (h *UsageHandler) ListSiteUsage(c *fiber.Ctx) error {
+	startDate, endDate, err := reportDateRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	sites, err := h.usageRepo.TotalsBySite(middleware.CurrentSiteScope(c), startDate, endDate.AddDate(0, 0, 1))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch LLM usage",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"sites": sites,
+		"date_range": fiber.Map{
+			"start_date": startDate.Format("2006-01-02"),
+			"end_date":   endDate.Format("2006-01-02"),
+		},
+	})
+}
+
+// reportDateRange reads the inclusive start_date and end_date (YYYY-MM-DD, UTC)
+// of a report, defaulting to the last 30 days
+func reportDateRange(c *fiber.Ctx) (time.Time, time.Time, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	endDate := today
+	startDate := today.AddDate(0, 0, -30)
+
+	if s := c.Query("end_date"); s != "" {
+		parsed, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid end_date, expected YYYY-MM-DD")
+		}
+		endDate = parsed
+	}
+	if s := c.Query("start_date"); s != "" {
+		parsed, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid start_date, expected YYYY-MM-DD")
+		}
+		startDate = parsed
+	}
+	if endDate.Before(startDate) {
+		return time.Time{}, time.Time{}, errors.New("end_date is before start_date")
+	}
+
+	return startDate, endDate, nil
+}
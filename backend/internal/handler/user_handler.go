@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/middleware"
+	"github.com/engramiq/engramiq-backend/internal/repository"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// UserHandler lets admins manage the accounts of their organization and
+// their roles; platform operators manage every account
+type UserHandler struct {
+	userRepo repository.UserRepository
+}
+
+func NewUserHandler(userRepo repository.UserRepository) *UserHandler {
+	return &UserHandler{
+		userRepo: userRepo,
+	}
+}
+
+func (h *UserHandler) ListUsers(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+	pagination := &domain.Pagination{
+		Page:  page,
+		Limit: limit,
+	}
+
+	filters := make(map[string]interface{})
+	if role := domain.UserRole(c.Query("role")); role != "" {
+		if !role.Valid() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid role",
+			})
+		}
+		filters["role"] = role
+	}
+	if search := c.Query("search"); search != "" {
+		filters["search"] = search
+	}
+	if admin := middleware.CurrentUser(c); !admin.PlatformOperator {
+		// uuid.Nil matches nobody for admins without an organization
+		orgID := uuid.Nil
+		if admin.OrganizationID != nil {
+			orgID = *admin.OrganizationID
+		}
+		filters["organization_id"] = orgID
+	}
+
+	users, err := h.userRepo.List(pagination, filters)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch users",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"users":      users,
+		"pagination": pagination,
+	})
+}
+
+// UpdateUserRole changes a user's role. Admins can't change their own, so
+// the last admin can't lock everyone out.
+func (h *UserHandler) UpdateUserRole(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var req domain.UpdateRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if !req.Role.Valid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid role",
+		})
+	}
+	if userID == middleware.CurrentUser(c).ID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "You cannot change your own role",
+		})
+	}
+
+	if _, ok := administeredUser(c, h.userRepo, userID); !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err := h.userRepo.Update(userID, map[string]interface{}{"role": req.Role}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update role",
+		})
+	}
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch updated user",
+		})
+	}
+	return c.JSON(user)
+}
+
+func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+	if userID == middleware.CurrentUser(c).ID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "You cannot delete your own account",
+		})
+	}
+
+	if _, ok := administeredUser(c, h.userRepo, userID); !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err := h.userRepo.Delete(userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete user",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// administeredUser loads a user the signed-in admin administers. Users of
+// other organizations look the same as missing ones.
+func administeredUser(c *fiber.Ctx, userRepo repository.UserRepository, userID uuid.UUID) (*domain.User, bool) {
+	user, err := userRepo.GetByID(userID)
+	if err != nil || !middleware.CurrentUser(c).Administers(user.OrganizationID) {
+		return nil, false
+	}
+	return user, true
+}
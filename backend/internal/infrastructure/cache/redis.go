@@ -2,8 +2,10 @@ package cache
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/engramiq/engramiq-backend/internal/config"
@@ -13,7 +15,7 @@ import (
 // Redis wraps the Redis client with common operations
 // We use Redis for:
 // 1. Session management (refresh tokens)
-// 2. Query result caching
+// 2. Query result and embedding caching
 // 3. Rate limiting
 // 4. Background job queuing
 type Redis struct {
@@ -59,6 +61,18 @@ func (r *Redis) GetRefreshToken(token string) (string, error) {
 	return r.client.Get(r.ctx, key).Result()
 }
 
+// ConsumeRefreshToken retrieves and removes a refresh token in one step, so
+// a token can be exchanged only once. The user ID is empty when the token is
+// unknown, expired or already used.
+func (r *Redis) ConsumeRefreshToken(token string) (string, error) {
+	key := fmt.Sprintf("refresh_token:%s", token)
+	userID, err := r.client.GetDel(r.ctx, key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return userID, err
+}
+
 // DeleteRefreshToken removes a refresh token (logout)
 func (r *Redis) DeleteRefreshToken(token string) error {
 	key := fmt.Sprintf("refresh_token:%s", token)
@@ -87,6 +101,87 @@ func (r *Redis) GetQueryCache(siteID, queryHash string, result interface{}) erro
 	return json.Unmarshal(data, result)
 }
 
+// GetContentVersion returns the site's content version, 0 until the site's
+// content first changes. Cached query results are keyed by it, so bumping
+// the version retires them all at once.
+func (r *Redis) GetContentVersion(siteID string) (int64, error) {
+	key := fmt.Sprintf("content_version:%s", siteID)
+	version, err := r.client.Get(r.ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return version, err
+}
+
+// BumpContentVersion records that a site's documents or actions changed
+func (r *Redis) BumpContentVersion(siteID string) error {
+	key := fmt.Sprintf("content_version:%s", siteID)
+	return r.client.Incr(r.ctx, key).Err()
+}
+
+// Embedding caching methods
+
+// GetEmbeddings looks up cached embeddings by key in one round trip. The
+// result has an entry per key, nil where the key isn't cached.
+func (r *Redis) GetEmbeddings(keys []string) ([][]float32, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = fmt.Sprintf("embedding:%s", key)
+	}
+	values, err := r.client.MGet(r.ctx, redisKeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	embeddings := make([][]float32, len(keys))
+	for i, value := range values {
+		if data, ok := value.(string); ok {
+			embeddings[i] = unpackEmbedding([]byte(data))
+		}
+	}
+	return embeddings, nil
+}
+
+// SetEmbeddings caches embeddings by key, stored as packed float32s
+func (r *Redis) SetEmbeddings(embeddings map[string][]float32, ttl time.Duration) error {
+	if len(embeddings) == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	for key, embedding := range embeddings {
+		pipe.Set(r.ctx, fmt.Sprintf("embedding:%s", key), packEmbedding(embedding), ttl)
+	}
+	_, err := pipe.Exec(r.ctx)
+	return err
+}
+
+// packEmbedding encodes an embedding as little-endian float32s
+func packEmbedding(embedding []float32) []byte {
+	data := make([]byte, len(embedding)*4)
+	for i, value := range embedding {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(value))
+	}
+	return data
+}
+
+// unpackEmbedding decodes packEmbedding's output, or returns nil when data
+// isn't a whole number of float32s
+func unpackEmbedding(data []byte) []float32 {
+	if len(data)%4 != 0 {
+		return nil
+	}
+	embedding := make([]float32, len(data)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return embedding
+}
+
 // Document processing queue
 
 // EnqueueDocument adds a document to the processing queue
@@ -94,7 +189,9 @@ func (r *Redis) EnqueueDocument(documentID string, priority int) error {
 	// Use sorted set for priority queue
 	key := "document_processing_queue"
 	score := float64(time.Now().Unix()) - float64(priority*1000) // Higher priority = lower score
-	return r.client.ZAdd(r.ctx, key, redis.Z{
+	// NX keeps the original position if the document is already queued,
+	// so periodic re-enqueueing of pending documents doesn't starve them
+	return r.client.ZAddNX(r.ctx, key, redis.Z{
 		Score:  score,
 		Member: documentID,
 	}).Err()
@@ -108,12 +205,12 @@ func (r *Redis) DequeueDocument() (string, error) {
 	if result.Err() != nil {
 		return "", result.Err()
 	}
-	
+
 	values := result.Val()
 	if len(values) == 0 {
 		return "", redis.Nil
 	}
-	
+
 	return values[0].Member.(string), nil
 }
 
@@ -170,12 +267,12 @@ func (r *Redis) GetComponentStatus(componentID string) (string, error) {
 func (r *Redis) IncrementSiteActivity(siteID, activityType string) error {
 	today := time.Now().Format("2006-01-02")
 	key := fmt.Sprintf("site_activity:%s:%s:%s", siteID, today, activityType)
-	
+
 	// Increment counter
 	if err := r.client.Incr(r.ctx, key).Err(); err != nil {
 		return err
 	}
-	
+
 	// Set expiration to 30 days
 	return r.client.Expire(r.ctx, key, 30*24*time.Hour).Err()
 }
@@ -194,4 +291,4 @@ func (r *Redis) HealthCheck() error {
 // Close closes the Redis connection
 func (r *Redis) Close() error {
 	return r.client.Close()
-}
\ No newline at end of file
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestPackEmbeddingRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		embedding []float32
+	}{
+		{name: "empty", embedding: []float32{}},
+		{name: "typical values", embedding: []float32{0.125, -0.5, 1, 0, 0.0123456}},
+		{name: "extremes", embedding: []float32{math.MaxFloat32, -math.MaxFloat32, math.SmallestNonzeroFloat32, float32(math.Inf(1)), float32(math.Copysign(0, -1))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := packEmbedding(tt.embedding)
+			if len(data) != len(tt.embedding)*4 {
+				t.Fatalf("packEmbedding() = %d bytes, want %d", len(data), len(tt.embedding)*4)
+			}
+
+			got := unpackEmbedding(data)
+			if len(got) != len(tt.embedding) {
+				t.Fatalf("unpackEmbedding() = %v, want %v", got, tt.embedding)
+			}
+			// Compare bits so -0 and +0 aren't treated as equal
+			for i := range got {
+				if math.Float32bits(got[i]) != math.Float32bits(tt.embedding[i]) {
+					t.Errorf("unpackEmbedding()[%d] = %v, want %v", i, got[i], tt.embedding[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPackEmbeddingLayout(t *testing.T) {
+	want := []byte{0x00, 0x00, 0x80, 0x3F, 0x00, 0x00, 0x00, 0xC0}
+	if got := packEmbedding([]float32{1, -2}); !reflect.DeepEqual(got, want) {
+		t.Errorf("packEmbedding() = % x, want % x", got, want)
+	}
+}
+
+func TestUnpackEmbeddingTruncated(t *testing.T) {
+	if got := unpackEmbedding([]byte{0x00, 0x00, 0x80}); got != nil {
+		t.Errorf("unpackEmbedding() = %v, want nil for a partial float", got)
+	}
+}
@@ -18,28 +18,34 @@ func Migrate(db *gorm.DB) error {
 	// Order matters here due to foreign key constraints
 	models := []interface{}{
 		// Core models first
+		&domain.Organization{},
 		&domain.User{},
 		&domain.RefreshToken{},
+		&domain.APIKey{},
 		&domain.Site{},
-		
+		&domain.SiteGrant{},
+
 		// Component models
 		&domain.SiteComponent{},
 		&domain.ComponentRelationship{},
-		
+
 		// Document and processing models
 		&domain.Document{},
+		&domain.DocumentChunk{},
 		&domain.ExtractedAction{},
 		&domain.ActionComponent{},
-		
+
 		// Event and timeline models
 		&domain.SiteEvent{},
-		
+
 		// Query models
+		&domain.Conversation{},
 		&domain.UserQuery{},
 		&domain.QuerySource{},
-		
+
 		// Analytics models
 		&domain.QueryAnalytics{},
+		&domain.LLMUsage{},
 	}
 
 	for _, model := range models {
@@ -61,7 +67,7 @@ func Migrate(db *gorm.DB) error {
 	// Run data migrations
 	runner := NewMigrationRunner(db)
 	migrationsList := migrations.GetAllMigrations()
-	
+
 	// Convert migrations to database.Migration and register them
 	for _, m := range migrationsList {
 		dbMigration := Migration{
@@ -73,7 +79,7 @@ func Migrate(db *gorm.DB) error {
 		}
 		runner.RegisterMigration(dbMigration)
 	}
-	
+
 	if err := runner.RunMigrations(); err != nil {
 		return err
 	}
@@ -165,27 +171,41 @@ func createIndexes(db *gorm.DB) error {
 		// Full-text search indexes
 		`CREATE INDEX IF NOT EXISTS idx_documents_fts ON documents 
 		 USING gin(to_tsvector('english', COALESCE(title, '') || ' ' || COALESCE(processed_content, '')))`,
-		
+
+		`CREATE INDEX IF NOT EXISTS idx_document_chunks_fts ON document_chunks USING gin(content_vector)`,
+
 		// Vector similarity search indexes (requires pgvector)
 		`CREATE INDEX IF NOT EXISTS idx_documents_embedding ON documents 
 		 USING ivfflat (embedding vector_cosine_ops) WITH (lists = 100)`,
-		
+
+		`CREATE INDEX IF NOT EXISTS idx_document_chunks_embedding ON document_chunks 
+		 USING ivfflat (embedding vector_cosine_ops) WITH (lists = 100)`,
+
 		`CREATE INDEX IF NOT EXISTS idx_components_embedding ON site_components 
 		 USING ivfflat (embedding vector_cosine_ops) WITH (lists = 100)`,
-		
+
 		`CREATE INDEX IF NOT EXISTS idx_actions_embedding ON extracted_actions 
 		 USING ivfflat (embedding vector_cosine_ops) WITH (lists = 100)`,
-		
+
 		// JSONB indexes for metadata queries
 		`CREATE INDEX IF NOT EXISTS idx_components_specifications ON site_components USING gin(specifications)`,
 		`CREATE INDEX IF NOT EXISTS idx_components_electrical_data ON site_components USING gin(electrical_data)`,
 		`CREATE INDEX IF NOT EXISTS idx_actions_measurements ON extracted_actions USING gin(measurements)`,
-		
+
 		// Composite indexes for common queries
 		`CREATE INDEX IF NOT EXISTS idx_components_site_type ON site_components(site_id, component_type)`,
 		`CREATE INDEX IF NOT EXISTS idx_events_site_timeline ON site_events(site_id, start_time, end_time)`,
 		`CREATE INDEX IF NOT EXISTS idx_actions_site_date ON extracted_actions(site_id, action_date)`,
-		
+		`CREATE INDEX IF NOT EXISTS idx_documents_retry ON documents(processing_status, next_retry_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_documents_site_hash ON documents(site_id, content_hash)`,
+		`CREATE INDEX IF NOT EXISTS idx_actions_work_order ON extracted_actions(work_order_number, site_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_document_chunks_position ON document_chunks(document_id, chunk_index)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_user_queries_turn ON user_queries(conversation_id, turn_index) WHERE conversation_id IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_conversations_site_updated ON conversations(site_id, updated_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_llm_usage_site_created ON llm_usage(site_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_llm_usage_created ON llm_usage(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_query_analytics_site_created ON query_analytics(site_id, created_at)`,
+
 		// Array indexes
 		`CREATE INDEX IF NOT EXISTS idx_actions_technicians ON extracted_actions USING gin(technician_names)`,
 		`CREATE INDEX IF NOT EXISTS idx_events_affected_components ON site_events USING gin(affected_component_ids)`,
@@ -229,5 +249,24 @@ func createTriggers(db *gorm.DB) error {
 		WHERE content_vector IS NULL;
 	`)
 
+	// Chunks index their section heading alongside the text so a query for
+	// "commissioning" finds the paragraphs under that heading
+	db.Exec(`
+		CREATE OR REPLACE FUNCTION update_chunk_content_vector() RETURNS trigger AS $$
+		BEGIN
+			NEW.content_vector := to_tsvector('english', COALESCE(NEW.section_reference, '') || ' ' || COALESCE(NEW.content, ''));
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;
+	`)
+
+	db.Exec(`DROP TRIGGER IF EXISTS document_chunks_content_vector_trigger ON document_chunks;`)
+	db.Exec(`
+		CREATE TRIGGER document_chunks_content_vector_trigger
+		BEFORE INSERT OR UPDATE OF section_reference, content ON document_chunks
+		FOR EACH ROW
+		EXECUTE FUNCTION update_chunk_content_vector();
+	`)
+
 	return nil
-}
\ No newline at end of file
+}
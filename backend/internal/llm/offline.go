@@ -0,0 +1,189 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// offlineModel is reported as the model name for offline responses
+const offlineModel = "offline-deterministic"
+
+// maxOfflineReply bounds the extractive text returned by offline chat
+const maxOfflineReply = 600
+
+var sentenceEnd = regexp.MustCompile(`[.!?](\s|$)`)
+
+// Offline is a deterministic provider that never leaves the process. It is
+// meant for development, CI and deployments that may not send data to any
+// model endpoint:
+//
+//   - Embed hashes words and word pairs into a fixed-size vector, so texts
+//     sharing vocabulary land close together and the same text always
+//     produces the same vector.
+//   - Chat answers JSON requests with the emptiest reply their schema allows
+//     (or an empty object without one), which callers read as "nothing
+//     found", and other requests with the opening sentences of the last user
+//     message.
+type Offline struct{}
+
+// NewOffline creates the offline provider
+func NewOffline() *Offline {
+	return &Offline{}
+}
+
+func (o *Offline) Name() string {
+	return ProviderOffline
+}
+
+func (o *Offline) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	prompt := ""
+	for _, message := range req.Messages {
+		if message.Role == "user" {
+			prompt = message.Content
+		}
+	}
+
+	content := extractiveReply(prompt)
+	if req.WantsJSON() {
+		content = "{}"
+		if req.Schema != nil {
+			reply, err := json.Marshal(req.Schema.Schema.zeroValue())
+			if err != nil {
+				return nil, err
+			}
+			content = string(reply)
+		}
+	}
+
+	promptTokens := 0
+	for _, message := range req.Messages {
+		promptTokens += EstimateTokens(message.Content)
+	}
+	completionTokens := EstimateTokens(content)
+
+	return &ChatResponse{
+		Content: content,
+		Model:   offlineModel,
+		Usage: Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+	}, nil
+}
+
+// ChatStream delivers the Chat reply a word at a time
+func (o *Offline) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	resp, err := o.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, word := range strings.SplitAfter(resp.Content, " ") {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := onDelta(word); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (o *Offline) Embed(ctx context.Context, inputs []string) (*EmbeddingResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(inputs))
+	tokens := 0
+	for i, input := range inputs {
+		vectors[i] = hashEmbedding(input)
+		tokens += EstimateTokens(input)
+	}
+	return &EmbeddingResponse{
+		Vectors: vectors,
+		Model:   offlineModel,
+		Usage:   Usage{PromptTokens: tokens, TotalTokens: tokens},
+	}, nil
+}
+
+// hashEmbedding is a signed feature-hashing embedding over words and
+// adjacent word pairs, normalised to unit length
+func hashEmbedding(text string) []float32 {
+	vector := make([]float32, EmbeddingDimensions)
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	addFeature := func(feature string, weight float32) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		index := int(sum % EmbeddingDimensions)
+		if sum&(1<<63) != 0 {
+			weight = -weight
+		}
+		vector[index] += weight
+	}
+	for i, word := range words {
+		addFeature(word, 1)
+		if i > 0 {
+			addFeature(words[i-1]+" "+word, 0.5)
+		}
+	}
+
+	var norm float64
+	for _, value := range vector {
+		norm += float64(value) * float64(value)
+	}
+	if norm == 0 {
+		// Keep empty input a valid (non-zero) vector for cosine distance
+		vector[0] = 1
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
+
+// extractiveReply returns the first few sentences of text, up to
+// maxOfflineReply bytes
+func extractiveReply(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= maxOfflineReply {
+		return text
+	}
+
+	cut := 0
+	for _, loc := range sentenceEnd.FindAllStringIndex(text, -1) {
+		if loc[0]+1 > maxOfflineReply {
+			break
+		}
+		cut = loc[0] + 1
+	}
+	if cut == 0 {
+		cut = strings.LastIndex(text[:maxOfflineReply], " ")
+		if cut <= 0 {
+			cut = maxOfflineReply
+		}
+	}
+	return strings.ToValidUTF8(text[:cut], "")
+}
+
+// EstimateTokens approximates token counts at four bytes per token, for
+// providers that report no usage
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
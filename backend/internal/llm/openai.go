@@ -0,0 +1,345 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// openAICompatible talks to the OpenAI REST API and the many servers that
+// mimic it. Azure differs only in its URLs and auth header, so it plugs in
+// through chatURL/embeddingsURL/authorize.
+type openAICompatible struct {
+	name           string
+	apiKey         string
+	chatModel      string
+	embeddingModel string
+	client         *http.Client
+
+	chatURL       string
+	embeddingsURL string
+	authorize     func(req *http.Request)
+
+	// structuredOutput sends response schemas to the API; without it schema
+	// requests fall back to plain JSON mode
+	structuredOutput bool
+	// streamUsage asks for token usage at the end of a stream; not every
+	// server or API version accepts the option
+	streamUsage bool
+}
+
+type chatCompletionRequest struct {
+	Model          string          `json:"model,omitempty"`
+	Messages       []Message       `json:"messages"`
+	Temperature    *float64        `json:"temperature,omitempty"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+	Stream         bool            `json:"stream,omitempty"`
+	StreamOptions  *streamOptions  `json:"stream_options,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type responseFormat struct {
+	Type       string          `json:"type"`
+	JSONSchema *jsonSchemaSpec `json:"json_schema,omitempty"`
+}
+
+type jsonSchemaSpec struct {
+	Name   string      `json:"name"`
+	Strict bool        `json:"strict"`
+	Schema *JSONSchema `json:"schema"`
+}
+
+type chatCompletionResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage Usage     `json:"usage"`
+	Error *apiError `json:"error,omitempty"`
+}
+
+type chatCompletionChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *Usage    `json:"usage,omitempty"`
+	Error *apiError `json:"error,omitempty"`
+}
+
+type embeddingRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Model string    `json:"model"`
+	Usage Usage     `json:"usage"`
+	Error *apiError `json:"error,omitempty"`
+}
+
+type apiError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+func newOpenAICompatible(name, baseURL, apiKey, chatModel, embeddingModel string, client *http.Client) *openAICompatible {
+	baseURL = strings.TrimRight(baseURL, "/")
+	p := &openAICompatible{
+		name:           name,
+		apiKey:         apiKey,
+		chatModel:      chatModel,
+		embeddingModel: embeddingModel,
+		client:         client,
+		chatURL:        baseURL + "/chat/completions",
+		embeddingsURL:  baseURL + "/embeddings",
+	}
+	p.authorize = func(req *http.Request) {
+		if p.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		}
+	}
+	return p
+}
+
+// newAzureOpenAI addresses models by deployment name; chatDeployment and
+// embeddingDeployment are the deployments created in the Azure portal
+func newAzureOpenAI(endpoint, apiKey, chatDeployment, embeddingDeployment, apiVersion string, client *http.Client) *openAICompatible {
+	endpoint = strings.TrimRight(endpoint, "/")
+	deploymentURL := func(deployment, operation string) string {
+		return fmt.Sprintf("%s/openai/deployments/%s/%s?api-version=%s",
+			endpoint, url.PathEscape(deployment), operation, url.QueryEscape(apiVersion))
+	}
+
+	p := &openAICompatible{
+		name:           ProviderAzure,
+		apiKey:         apiKey,
+		chatModel:      chatDeployment,
+		embeddingModel: embeddingDeployment,
+		client:         client,
+		chatURL:        deploymentURL(chatDeployment, "chat/completions"),
+		embeddingsURL:  deploymentURL(embeddingDeployment, "embeddings"),
+	}
+	p.authorize = func(req *http.Request) {
+		req.Header.Set("api-key", p.apiKey)
+	}
+	return p
+}
+
+func (p *openAICompatible) Name() string {
+	return p.name
+}
+
+func (p *openAICompatible) chatBody(req ChatRequest) chatCompletionRequest {
+	body := chatCompletionRequest{
+		Model:       p.chatModel,
+		Messages:    req.Messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+	}
+	switch {
+	case req.Schema != nil && p.structuredOutput:
+		body.ResponseFormat = &responseFormat{
+			Type: "json_schema",
+			JSONSchema: &jsonSchemaSpec{
+				Name:   req.Schema.Name,
+				Strict: true,
+				Schema: req.Schema.Schema,
+			},
+		}
+	case req.WantsJSON():
+		body.ResponseFormat = &responseFormat{Type: "json_object"}
+	}
+	return body
+}
+
+func (p *openAICompatible) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	var resp chatCompletionResponse
+	if err := p.post(ctx, p.chatURL, p.chatBody(req), &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s API error: %s", p.name, resp.Error.Message)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%s: no response choices returned", p.name)
+	}
+
+	model := resp.Model
+	if model == "" {
+		model = p.chatModel
+	}
+	return &ChatResponse{
+		Content: resp.Choices[0].Message.Content,
+		Model:   model,
+		Usage:   resp.Usage,
+	}, nil
+}
+
+func (p *openAICompatible) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	body := p.chatBody(req)
+	body.Stream = true
+	if p.streamUsage {
+		body.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+
+	httpResp, err := p.send(ctx, p.chatURL, body)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	result := &ChatResponse{Model: p.chatModel}
+	var content strings.Builder
+
+	// The stream is server-sent events: one "data:" line per chunk, ending
+	// with "data: [DONE]"
+	scanner := bufio.NewScanner(httpResp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk chatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode %s stream chunk: %w", p.name, err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("%s API error: %s", p.name, chunk.Error.Message)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		content.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s stream: %w", p.name, err)
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+func (p *openAICompatible) Embed(ctx context.Context, inputs []string) (*EmbeddingResponse, error) {
+	if len(inputs) == 0 {
+		return &EmbeddingResponse{Model: p.embeddingModel}, nil
+	}
+
+	var resp embeddingResponse
+	if err := p.post(ctx, p.embeddingsURL, embeddingRequest{Model: p.embeddingModel, Input: inputs}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s API error: %s", p.name, resp.Error.Message)
+	}
+	if len(resp.Data) != len(inputs) {
+		return nil, fmt.Errorf("%s: expected %d embeddings, got %d", p.name, len(inputs), len(resp.Data))
+	}
+
+	vectors := make([][]float32, len(inputs))
+	for i, item := range resp.Data {
+		// Results carry their input index; don't rely on response order
+		index := item.Index
+		if index < 0 || index >= len(vectors) {
+			index = i
+		}
+		vector, err := FitDimensions(item.Embedding)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.name, err)
+		}
+		vectors[index] = vector
+	}
+
+	model := resp.Model
+	if model == "" {
+		model = p.embeddingModel
+	}
+	return &EmbeddingResponse{Vectors: vectors, Model: model, Usage: resp.Usage}, nil
+}
+
+func (p *openAICompatible) post(ctx context.Context, endpoint string, body, out interface{}) error {
+	resp, err := p.send(ctx, endpoint, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %w", p.name, err)
+	}
+
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", p.name, err)
+	}
+	return nil
+}
+
+// send posts body as JSON and returns the response, or an error carrying
+// the API's message for error statuses
+func (p *openAICompatible) send(ctx context.Context, endpoint string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	p.authorize(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to %s: %w", p.name, err)
+	}
+	if resp.StatusCode < http.StatusBadRequest {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+	var failure struct {
+		Error *apiError `json:"error"`
+	}
+	if json.Unmarshal(raw, &failure) == nil && failure.Error != nil && failure.Error.Message != "" {
+		return nil, fmt.Errorf("%s API error (%d): %s", p.name, resp.StatusCode, failure.Error.Message)
+	}
+	return nil, fmt.Errorf("%s API error: %s", p.name, resp.Status)
+}
@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Price is what a model costs in USD per million tokens
+type Price struct {
+	Prompt     float64
+	Completion float64
+}
+
+// defaultPrices are list prices of the hosted OpenAI models. Self-hosted
+// and offline models cost nothing per token.
+var defaultPrices = map[string]Price{
+	"gpt-4-turbo-preview":    {Prompt: 10, Completion: 30},
+	"gpt-4-0125-preview":     {Prompt: 10, Completion: 30},
+	"gpt-4-1106-preview":     {Prompt: 10, Completion: 30},
+	"gpt-4-turbo":            {Prompt: 10, Completion: 30},
+	"gpt-4o-mini":            {Prompt: 0.15, Completion: 0.6},
+	"gpt-4o":                 {Prompt: 2.5, Completion: 10},
+	"gpt-4":                  {Prompt: 30, Completion: 60},
+	"gpt-3.5-turbo":          {Prompt: 0.5, Completion: 1.5},
+	"text-embedding-ada-002": {Prompt: 0.1},
+	"text-embedding-3-small": {Prompt: 0.02},
+	"text-embedding-3-large": {Prompt: 0.13},
+}
+
+// Pricing estimates the cost of LLM calls from their token usage
+type Pricing struct {
+	prices map[string]Price
+	// models by descending name length, so dated versions such as
+	// gpt-4o-2024-08-06 match gpt-4o rather than gpt-4
+	models []string
+}
+
+// NewPricing returns the default prices overridden by spec, a comma-separated
+// list of model=prompt/completion prices in USD per million tokens, e.g.
+// "gpt-4o=2.5/10,text-embedding-3-small=0.02"
+func NewPricing(spec string) (*Pricing, error) {
+	prices := make(map[string]Price, len(defaultPrices))
+	for model, price := range defaultPrices {
+		prices[model] = price
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, rates, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(model) == "" {
+			return nil, fmt.Errorf("llm: price %q is not model=prompt/completion", entry)
+		}
+
+		prompt, completion, _ := strings.Cut(rates, "/")
+		var price Price
+		var err error
+		if price.Prompt, err = strconv.ParseFloat(strings.TrimSpace(prompt), 64); err != nil {
+			return nil, fmt.Errorf("llm: invalid prompt price in %q", entry)
+		}
+		if completion != "" {
+			if price.Completion, err = strconv.ParseFloat(strings.TrimSpace(completion), 64); err != nil {
+				return nil, fmt.Errorf("llm: invalid completion price in %q", entry)
+			}
+		}
+		prices[strings.ToLower(strings.TrimSpace(model))] = price
+	}
+
+	models := make([]string, 0, len(prices))
+	for model := range prices {
+		models = append(models, model)
+	}
+	sort.Slice(models, func(i, j int) bool {
+		if len(models[i]) != len(models[j]) {
+			return len(models[i]) > len(models[j])
+		}
+		return models[i] < models[j]
+	})
+
+	return &Pricing{prices: prices, models: models}, nil
+}
+
+// Cost estimates the USD cost of a call. Models without a price, matched
+// exactly or as the prefix of a dated version, cost nothing.
+func (p *Pricing) Cost(model string, usage Usage) float64 {
+	if p == nil {
+		return 0
+	}
+
+	model = strings.ToLower(model)
+	price, ok := p.prices[model]
+	if !ok {
+		for _, known := range p.models {
+			if strings.HasPrefix(model, known+"-") {
+				price, ok = p.prices[known], true
+				break
+			}
+		}
+	}
+	if !ok {
+		return 0
+	}
+
+	return (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1e6
+}
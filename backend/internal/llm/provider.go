@@ -0,0 +1,180 @@
+// Package llm abstracts the chat and embedding backends behind a Provider so
+// deployments can choose OpenAI, Azure OpenAI, a self-hosted OpenAI-compatible
+// server (Ollama, llama.cpp) or a deterministic offline backend.
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/config"
+	"github.com/engramiq/engramiq-backend/internal/redact"
+)
+
+// EmbeddingDimensions is the width of every vector column in the database
+const EmbeddingDimensions = 1536
+
+// Provider names accepted in LLM_PROVIDER
+const (
+	ProviderOpenAI  = "openai"
+	ProviderAzure   = "azure"
+	ProviderLocal   = "local"
+	ProviderOffline = "offline"
+)
+
+const (
+	defaultOpenAIBaseURL   = "https://api.openai.com/v1"
+	defaultLocalBaseURL    = "http://localhost:11434/v1"
+	defaultAzureAPIVersion = "2024-02-01"
+	defaultEmbeddingModel  = "text-embedding-ada-002"
+	defaultHTTPTimeout     = 120 * time.Second
+)
+
+// Message is a single chat message
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatRequest is a provider-neutral chat completion request
+type ChatRequest struct {
+	Messages []Message
+	// JSON asks the model to reply with a single JSON object
+	JSON bool
+	// Schema constrains the JSON reply, on providers that support it, and
+	// implies JSON
+	Schema *ResponseSchema
+	// Temperature is sent only when set, so models that reject the
+	// parameter can still be used with the provider default
+	Temperature *float64
+	// MaxTokens caps the completion length; zero leaves it to the provider
+	MaxTokens int
+}
+
+// WantsJSON reports whether the reply must be a JSON object
+func (r ChatRequest) WantsJSON() bool {
+	return r.JSON || r.Schema != nil
+}
+
+// ChatResponse is the first choice of a chat completion
+type ChatResponse struct {
+	Content string
+	Model   string
+	Usage   Usage
+}
+
+// EmbeddingResponse holds one vector per input, in order
+type EmbeddingResponse struct {
+	Vectors [][]float32
+	Model   string
+	Usage   Usage
+}
+
+// Usage reports the tokens a request consumed
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Provider is a chat and embedding backend
+type Provider interface {
+	// Name identifies the provider in logs and errors
+	Name() string
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	// ChatStream is Chat with the reply passed to onDelta piece by piece as
+	// it is generated. An error from onDelta aborts the call and is returned.
+	// The response holds the complete reply.
+	ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error)
+	// Embed returns one vector per input, in order
+	Embed(ctx context.Context, inputs []string) (*EmbeddingResponse, error)
+}
+
+// NewProvider builds the provider selected by cfg.Provider. With
+// cfg.StripPII set, providers that send text out of the process are wrapped
+// in Redacting.
+func NewProvider(cfg config.LLMConfig) (Provider, error) {
+	provider, err := newProvider(cfg)
+	if err != nil || !cfg.StripPII || provider.Name() == ProviderOffline {
+		return provider, err
+	}
+
+	restore, err := redact.ParseCategories(cfg.PIIRestore)
+	if err != nil {
+		return nil, fmt.Errorf("llm: LLM_PII_RESTORE: %w", err)
+	}
+	return NewRedacting(provider, restore), nil
+}
+
+func newProvider(cfg config.LLMConfig) (Provider, error) {
+	// Callers bound each call through its context; the client timeout is
+	// only a backstop for callers that don't
+	timeout := defaultHTTPTimeout
+	if cfg.Timeout > timeout {
+		timeout = cfg.Timeout
+	}
+	client := &http.Client{Timeout: timeout}
+
+	embeddingModel := cfg.EmbeddingModel
+	if embeddingModel == "" {
+		embeddingModel = defaultEmbeddingModel
+	}
+
+	var p *openAICompatible
+	switch strings.ToLower(strings.TrimSpace(cfg.Provider)) {
+	case "", ProviderOpenAI:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("llm: provider %q requires an API key", ProviderOpenAI)
+		}
+		p = newOpenAICompatible(ProviderOpenAI, withDefault(cfg.BaseURL, defaultOpenAIBaseURL), cfg.APIKey, cfg.Model, embeddingModel, client)
+		p.streamUsage = true
+
+	case ProviderAzure:
+		if cfg.BaseURL == "" || cfg.APIKey == "" {
+			return nil, fmt.Errorf("llm: provider %q requires LLM_BASE_URL and an API key", ProviderAzure)
+		}
+		p = newAzureOpenAI(cfg.BaseURL, cfg.APIKey, cfg.Model, embeddingModel, withDefault(cfg.AzureAPIVersion, defaultAzureAPIVersion), client)
+
+	case ProviderLocal:
+		// Local servers usually don't check the key, but some proxies do
+		p = newOpenAICompatible(ProviderLocal, withDefault(cfg.BaseURL, defaultLocalBaseURL), cfg.APIKey, cfg.Model, embeddingModel, client)
+		p.streamUsage = true
+
+	case ProviderOffline:
+		return NewOffline(), nil
+
+	default:
+		return nil, fmt.Errorf("llm: unknown provider %q", cfg.Provider)
+	}
+
+	p.structuredOutput = cfg.StructuredOutput
+	return p, nil
+}
+
+// FitDimensions zero-pads vectors from models with narrower embeddings (most
+// local models) to EmbeddingDimensions. Padding with zeros leaves cosine
+// distances between padded vectors unchanged.
+func FitDimensions(vector []float32) ([]float32, error) {
+	switch {
+	case len(vector) == EmbeddingDimensions:
+		return vector, nil
+	case len(vector) == 0:
+		return nil, fmt.Errorf("llm: empty embedding")
+	case len(vector) > EmbeddingDimensions:
+		return nil, fmt.Errorf("llm: embedding has %d dimensions, at most %d are supported", len(vector), EmbeddingDimensions)
+	}
+
+	padded := make([]float32, EmbeddingDimensions)
+	copy(padded, vector)
+	return padded, nil
+}
+
+func withDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
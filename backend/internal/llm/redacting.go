@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"strings"
+
+	"github.com/engramiq/engramiq-backend/internal/redact"
+)
+
+// Redacting strips personal information from everything sent to the
+// wrapped provider. Each chat call redacts all of its messages in one
+// session, so a person keeps the same placeholder throughout the prompt,
+// and the reply gets the originals of the restorable categories back;
+// placeholders of other categories stay in the reply, and so in whatever
+// the caller stores.
+type Redacting struct {
+	provider Provider
+	restore  []redact.Category
+}
+
+// NewRedacting wraps provider. restore lists the categories whose originals
+// may be put back into replies.
+func NewRedacting(provider Provider, restore []redact.Category) *Redacting {
+	return &Redacting{
+		provider: provider,
+		restore:  restore,
+	}
+}
+
+func (r *Redacting) Name() string {
+	return r.provider.Name()
+}
+
+func (r *Redacting) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	session := redact.NewSession()
+	req = r.redactRequest(session, req)
+
+	resp, err := r.provider.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Content = r.restoreReply(session, req, resp.Content)
+	return resp, nil
+}
+
+// ChatStream restores placeholders in the deltas as well as in the final
+// reply. A placeholder can be split across deltas, so text from an unclosed
+// "[" is held back until the bracket closes or the text grows too long to be
+// a placeholder.
+func (r *Redacting) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	session := redact.NewSession()
+	req = r.redactRequest(session, req)
+
+	pending := ""
+	resp, err := r.provider.ChatStream(ctx, req, func(delta string) error {
+		text := pending + delta
+		pending = ""
+		if open := strings.LastIndex(text, "["); open >= 0 && !strings.Contains(text[open:], "]") && len(text)-open < maxPlaceholderLength {
+			text, pending = text[:open], text[open:]
+		}
+		if text == "" {
+			return nil
+		}
+		return onDelta(r.restoreReply(session, req, text))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pending != "" {
+		if err := onDelta(r.restoreReply(session, req, pending)); err != nil {
+			return nil, err
+		}
+	}
+
+	resp.Content = r.restoreReply(session, req, resp.Content)
+	return resp, nil
+}
+
+// maxPlaceholderLength is longer than any placeholder a session issues
+const maxPlaceholderLength = 24
+
+func (r *Redacting) redactRequest(session *redact.Session, req ChatRequest) ChatRequest {
+	messages := make([]Message, len(req.Messages))
+	for i, message := range req.Messages {
+		messages[i] = Message{Role: message.Role, Content: session.Redact(message.Content)}
+	}
+	req.Messages = messages
+	return req
+}
+
+func (r *Redacting) restoreReply(session *redact.Session, req ChatRequest, reply string) string {
+	if req.WantsJSON() {
+		return session.RestoreJSON(reply, r.restore...)
+	}
+	return session.Restore(reply, r.restore...)
+}
+
+// Embed redacts each input on its own; embeddings are never restored
+func (r *Redacting) Embed(ctx context.Context, inputs []string) (*EmbeddingResponse, error) {
+	redacted := make([]string, len(inputs))
+	for i, input := range inputs {
+		redacted[i] = redact.NewSession().Redact(input)
+	}
+	return r.provider.Embed(ctx, redacted)
+}
@@ -0,0 +1,200 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// JSONSchema is the subset of JSON Schema that providers accept for
+// structured output. Objects built with Object require every property and
+// reject others, as strict structured output demands; optional values are
+// expressed as empty strings or arrays.
+type JSONSchema struct {
+	Type                 string                 `json:"type"`
+	Description          string                 `json:"description,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+}
+
+// ResponseSchema names the schema a JSON reply must follow
+type ResponseSchema struct {
+	Name   string
+	Schema *JSONSchema
+}
+
+// Object is an object schema requiring all of its properties
+func Object(properties map[string]*JSONSchema) *JSONSchema {
+	required := make([]string, 0, len(properties))
+	for name := range properties {
+		required = append(required, name)
+	}
+	sort.Strings(required)
+
+	closed := false
+	return &JSONSchema{
+		Type:                 "object",
+		Properties:           properties,
+		Required:             required,
+		AdditionalProperties: &closed,
+	}
+}
+
+// Array is an array schema whose elements follow items
+func Array(items *JSONSchema) *JSONSchema {
+	return &JSONSchema{Type: "array", Items: items}
+}
+
+// String is a free-form string schema
+func String(description string) *JSONSchema {
+	return &JSONSchema{Type: "string", Description: description}
+}
+
+// Number is a numeric schema
+func Number(description string) *JSONSchema {
+	return &JSONSchema{Type: "number", Description: description}
+}
+
+// Integer is a whole-number schema
+func Integer(description string) *JSONSchema {
+	return &JSONSchema{Type: "integer", Description: description}
+}
+
+// Boolean is a true/false schema
+func Boolean(description string) *JSONSchema {
+	return &JSONSchema{Type: "boolean", Description: description}
+}
+
+// Enum is a string schema restricted to values
+func Enum[T ~string](values ...T) *JSONSchema {
+	enum := make([]string, len(values))
+	for i, value := range values {
+		enum[i] = string(value)
+	}
+	return &JSONSchema{Type: "string", Enum: enum}
+}
+
+// Check decodes a reply and validates it against the schema. It returns one
+// message per violation, or none when the reply conforms.
+func (r ResponseSchema) Check(reply string) []string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(reply), &value); err != nil {
+		return []string{fmt.Sprintf("the reply is not valid JSON: %v", err)}
+	}
+
+	var problems []string
+	r.Schema.validate("$", value, &problems)
+	return problems
+}
+
+func (s *JSONSchema) validate(path string, value interface{}, problems *[]string) {
+	report := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			report("expected an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				report("missing property %q", name)
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					report("unexpected property %q", name)
+				}
+				continue
+			}
+			property.validate(path+"."+name, object[name], problems)
+		}
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			report("expected an array")
+			return
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			report("expected a string")
+			return
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, text) {
+			report("%q is not one of %s", text, strings.Join(s.Enum, ", "))
+		}
+
+	case "number", "integer":
+		number, ok := value.(float64)
+		if !ok {
+			report("expected a number")
+			return
+		}
+		if s.Type == "integer" && number != math.Trunc(number) {
+			report("expected an integer")
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			report("expected a boolean")
+		}
+	}
+}
+
+// zeroValue is the smallest value conforming to the schema
+func (s *JSONSchema) zeroValue() interface{} {
+	switch s.Type {
+	case "object":
+		object := make(map[string]interface{}, len(s.Properties))
+		for _, name := range s.Required {
+			if property, ok := s.Properties[name]; ok {
+				object[name] = property.zeroValue()
+			}
+		}
+		return object
+	case "array":
+		return []interface{}{}
+	case "string":
+		if len(s.Enum) > 0 {
+			return s.Enum[0]
+		}
+		return ""
+	case "number", "integer":
+		return 0
+	case "boolean":
+		return false
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/service"
+	apperrors "github.com/engramiq/engramiq-backend/pkg/errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// fakeAPIKeys authenticates the keys it holds as their admin
+type fakeAPIKeys struct {
+	service.APIKeyService
+	keys map[string]*domain.APIKey
+	user *domain.User
+}
+
+func (f *fakeAPIKeys) Authenticate(secret string) (*domain.APIKey, *domain.User, error) {
+	key, ok := f.keys[secret]
+	if !ok {
+		return nil, nil, apperrors.NewUnauthorized("Invalid API key")
+	}
+	return key, f.user, nil
+}
+
+func TestRequirePermissionAPIKeyScopes(t *testing.T) {
+	admin := testUsers()["admin"]
+	viewer := testUsers()["viewer"]
+
+	tests := []struct {
+		name       string
+		user       *domain.User
+		scopes     []string
+		permission domain.Permission
+		want       int
+	}{
+		{name: "read scope reads", user: admin, scopes: []string{"read"}, permission: domain.PermissionRead, want: fiber.StatusOK},
+		{name: "read scope can't query", user: admin, scopes: []string{"read"}, permission: domain.PermissionQuery, want: fiber.StatusForbidden},
+		{name: "read scope can't upload", user: admin, scopes: []string{"read"}, permission: domain.PermissionUploadDocuments, want: fiber.StatusForbidden},
+		{name: "query scope queries", user: admin, scopes: []string{"query"}, permission: domain.PermissionQuery, want: fiber.StatusOK},
+		{name: "query scope can't read", user: admin, scopes: []string{"query"}, permission: domain.PermissionRead, want: fiber.StatusForbidden},
+		{name: "upload scope uploads", user: admin, scopes: []string{"upload"}, permission: domain.PermissionUploadDocuments, want: fiber.StatusOK},
+		{name: "combined scopes", user: admin, scopes: []string{"read", "upload"}, permission: domain.PermissionUploadDocuments, want: fiber.StatusOK},
+		{name: "no scope deletes documents", user: admin, scopes: []string{"read", "upload", "query"}, permission: domain.PermissionDeleteDocuments, want: fiber.StatusForbidden},
+		{name: "no scope manages users", user: admin, scopes: []string{"read", "upload", "query"}, permission: domain.PermissionManageUsers, want: fiber.StatusForbidden},
+		{name: "unknown scope grants nothing", user: admin, scopes: []string{"admin"}, permission: domain.PermissionRead, want: fiber.StatusForbidden},
+		{name: "scope can't exceed the creator's role", user: viewer, scopes: []string{"upload"}, permission: domain.PermissionUploadDocuments, want: fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiKey := &domain.APIKey{Scopes: pq.StringArray(tt.scopes)}
+			app := newTestApp(tt.user, apiKey, func(app *fiber.App) {
+				app.Get("/", RequirePermission(tt.permission), func(c *fiber.Ctx) error { return nil })
+			})
+			if status := statusOf(t, app, "/"); status != tt.want {
+				t.Errorf("status = %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrentPermissionsAPIKey(t *testing.T) {
+	admin := testUsers()["admin"]
+
+	tests := []struct {
+		name   string
+		apiKey *domain.APIKey
+		want   []domain.Permission
+	}{
+		{name: "access token", want: admin.Permissions()},
+		{
+			name:   "read and query key",
+			apiKey: &domain.APIKey{Scopes: pq.StringArray{"read", "query"}},
+			want:   []domain.Permission{domain.PermissionRead, domain.PermissionQuery},
+		},
+		{
+			name:   "upload key",
+			apiKey: &domain.APIKey{Scopes: pq.StringArray{"upload"}},
+			want:   []domain.Permission{domain.PermissionUploadDocuments},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []domain.Permission
+			app := newTestApp(admin, tt.apiKey, func(app *fiber.App) {
+				app.Get("/", func(c *fiber.Ctx) error {
+					got = CurrentPermissions(c)
+					return nil
+				})
+			})
+			statusOf(t, app, "/")
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CurrentPermissions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSiteScopeAPIKeySites(t *testing.T) {
+	access := service.NewSiteAccessService(&fakeOrganizations{})
+	const ok, notFound = fiber.StatusOK, fiber.StatusNotFound
+
+	// Statuses for testSite, testOtherSite and testForeignSite
+	tests := []struct {
+		name  string
+		user  string
+		sites []string
+		want  [3]int
+	}{
+		{name: "key limited to one site", user: "admin", sites: []string{testSite.String()}, want: [3]int{ok, notFound, notFound}},
+		{name: "key can't reach sites outside the organization", user: "admin", sites: []string{testSite.String(), testForeignSite.String()}, want: [3]int{ok, notFound, notFound}},
+		{name: "key can't reach sites its creator lost", user: "granted technician", sites: []string{testSite.String(), testOtherSite.String()}, want: [3]int{ok, notFound, notFound}},
+		{name: "operator key is still limited to its sites", user: "operator", sites: []string{testForeignSite.String()}, want: [3]int{notFound, notFound, ok}},
+		{name: "key without sites", user: "admin", want: [3]int{notFound, notFound, notFound}},
+		{name: "malformed site IDs are ignored", user: "admin", sites: []string{"not-a-uuid", testOtherSite.String()}, want: [3]int{notFound, ok, notFound}},
+	}
+
+	users := testUsers()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiKey := &domain.APIKey{Scopes: pq.StringArray{"read"}, SiteIDs: pq.StringArray(tt.sites)}
+			app := newTestApp(users[tt.user], apiKey, func(app *fiber.App) {
+				app.Get("/sites/:site_id", LoadSiteScope(access), RequireSiteAccess("site_id"), func(c *fiber.Ctx) error { return nil })
+			})
+			for i, site := range []uuid.UUID{testSite, testOtherSite, testForeignSite} {
+				if status := statusOf(t, app, "/sites/"+site.String()); status != tt.want[i] {
+					t.Errorf("GET site %s status = %d, want %d", site, status, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRequireAuthAPIKey(t *testing.T) {
+	apiKey := &domain.APIKey{Scopes: pq.StringArray{"read"}}
+	keys := &fakeAPIKeys{keys: map[string]*domain.APIKey{"eiq_valid": apiKey}, user: testUsers()["admin"]}
+
+	var gotKey *domain.APIKey
+	app := fiber.New()
+	app.Get("/", RequireAuth(nil, keys), func(c *fiber.Ctx) error {
+		gotKey = CurrentAPIKey(c)
+		return nil
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{name: "valid key", header: "Bearer eiq_valid", want: fiber.StatusOK},
+		{name: "unknown key", header: "Bearer eiq_revoked", want: fiber.StatusUnauthorized},
+		{name: "no token", want: fiber.StatusUnauthorized},
+		{name: "other scheme", header: "Basic eiq_valid", want: fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotKey = nil
+			req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.header)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("GET /: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == fiber.StatusOK && gotKey != apiKey {
+				t.Errorf("CurrentAPIKey() = %v, want the authenticated key", gotKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Package middleware holds Fiber middleware shared by the API routes.
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CancelOnDisconnect cancels the request's user context (c.UserContext())
+// when the client closes the connection, so handlers that pass it down stop
+// waiting on slow upstream calls such as LLM completions nobody will read.
+//
+// fasthttp has no close notification, so the connection is watched with a
+// blocking one-byte read while the handler runs. Clients don't send the next
+// request on a connection until they have the response to this one, except
+// with HTTP pipelining, which browsers and common clients don't use; a
+// pipelined byte read here would be lost.
+func CancelOnDisconnect() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		conn := c.Context().Conn()
+		if conn == nil {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithCancel(c.UserContext())
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			var buf [1]byte
+			_, err := conn.Read(buf[:])
+			if err != nil && !isTimeout(err) {
+				cancel()
+			}
+		}()
+
+		err := c.Next()
+
+		// Unblock the watcher and hand the connection back to fasthttp
+		// without a deadline
+		conn.SetReadDeadline(time.Now())
+		<-done
+		conn.SetReadDeadline(time.Time{})
+
+		return err
+	}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/pkg/logger"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimiter counts requests in a sliding window. The Redis cache
+// implements it.
+type RateLimiter interface {
+	CheckRateLimit(identifier string, limit int, window time.Duration) (bool, int, error)
+}
+
+// RateLimit allows each user, and each API key separately from the user it
+// acts as, limit requests per window and rejects the rest with 429. It runs
+// after RequireAuth; a limit of 0 disables it. Requests are let through
+// when the limiter is unavailable.
+func RateLimit(limiter RateLimiter, limit int, window time.Duration, log *logger.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		identifier := "user:" + CurrentUser(c).ID.String()
+		if apiKey := CurrentAPIKey(c); apiKey != nil {
+			identifier = "api_key:" + apiKey.ID.String()
+		}
+		return limitRequests(c, limiter, identifier, limit, window, log)
+	}
+}
+
+// RateLimitByIP limits requests by client IP, for the routes used before
+// anyone is signed in, like login and registration
+func RateLimitByIP(limiter RateLimiter, limit int, window time.Duration, log *logger.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return limitRequests(c, limiter, "ip:"+c.IP(), limit, window, log)
+	}
+}
+
+func limitRequests(c *fiber.Ctx, limiter RateLimiter, identifier string, limit int, window time.Duration, log *logger.Logger) error {
+	if limit <= 0 {
+		return c.Next()
+	}
+
+	allowed, count, err := limiter.CheckRateLimit(identifier, limit, window)
+	if err != nil {
+		log.Warnw("Rate limit check failed", "identifier", identifier, "error", err)
+		return c.Next()
+	}
+
+	c.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	c.Set("X-RateLimit-Remaining", strconv.Itoa(max(limit-count, 0)))
+	if !allowed {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(window.Seconds())))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error": "Rate limit exceeded",
+			"code":  "RATE_LIMITED",
+		})
+	}
+	return c.Next()
+}
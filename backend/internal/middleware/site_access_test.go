@@ -0,0 +1,258 @@
+package middleware
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/repository"
+	"github.com/engramiq/engramiq-backend/internal/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	testOrg       = uuid.MustParse("00000000-0000-0000-0000-0000000000a1")
+	testSite      = uuid.MustParse("00000000-0000-0000-0000-0000000000b1")
+	testOtherSite = uuid.MustParse("00000000-0000-0000-0000-0000000000b2")
+	// testForeignSite belongs to another organization
+	testForeignSite = uuid.MustParse("00000000-0000-0000-0000-0000000000b3")
+	testGrantee     = uuid.MustParse("00000000-0000-0000-0000-0000000000c1")
+
+	errSiteLookup = errors.New("site lookup failed")
+)
+
+// fakeOrganizations backs the real site access service: testOrg owns
+// testSite and testOtherSite, and testGrantee is granted testSite. Records
+// are keyed by ID to the site they belong to.
+type fakeOrganizations struct {
+	repository.OrganizationRepository
+	records map[uuid.UUID]uuid.UUID
+	broken  map[uuid.UUID]bool
+}
+
+func (f *fakeOrganizations) SiteIDs(orgID uuid.UUID) ([]uuid.UUID, error) {
+	if orgID != testOrg {
+		return nil, nil
+	}
+	return []uuid.UUID{testSite, testOtherSite}, nil
+}
+
+func (f *fakeOrganizations) GrantedSiteIDs(userID, orgID uuid.UUID) ([]uuid.UUID, error) {
+	if userID != testGrantee || orgID != testOrg {
+		return nil, nil
+	}
+	return []uuid.UUID{testSite}, nil
+}
+
+func (f *fakeOrganizations) SiteOf(model interface{}, id uuid.UUID) (uuid.UUID, error) {
+	if f.broken[id] {
+		return uuid.Nil, errSiteLookup
+	}
+	siteID, ok := f.records[id]
+	if !ok {
+		return uuid.Nil, gorm.ErrRecordNotFound
+	}
+	return siteID, nil
+}
+
+// testUsers are the users site access depends on: operators, the roles that
+// see their whole organization and those that only see granted sites
+func testUsers() map[string]*domain.User {
+	org := testOrg
+	return map[string]*domain.User{
+		"operator":           {ID: uuid.New(), Role: domain.UserRoleViewer, PlatformOperator: true},
+		"admin":              {ID: uuid.New(), Role: domain.UserRoleAdmin, OrganizationID: &org},
+		"manager":            {ID: uuid.New(), Role: domain.UserRoleManager, OrganizationID: &org},
+		"granted technician": {ID: testGrantee, Role: domain.UserRoleTechnician, OrganizationID: &org},
+		"granted viewer":     {ID: testGrantee, Role: domain.UserRoleViewer, OrganizationID: &org},
+		"viewer":             {ID: uuid.New(), Role: domain.UserRoleViewer, OrganizationID: &org},
+		"admin without org":  {ID: uuid.New(), Role: domain.UserRoleAdmin},
+	}
+}
+
+// newTestApp serves routes behind a stand-in for RequireAuth that signs the
+// request in as user, with apiKey when it isn't nil
+func newTestApp(user *domain.User, apiKey *domain.APIKey, register func(app *fiber.App)) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if user != nil {
+			c.Locals(userKey, user)
+		}
+		if apiKey != nil {
+			c.Locals(apiKeyKey, apiKey)
+		}
+		return c.Next()
+	})
+	register(app)
+	return app
+}
+
+func statusOf(t *testing.T, app *fiber.App, path string) int {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	return resp.StatusCode
+}
+
+func TestCurrentSiteScope(t *testing.T) {
+	access := service.NewSiteAccessService(&fakeOrganizations{})
+
+	tests := []struct {
+		user     string
+		wantAll  bool
+		wantSite map[uuid.UUID]bool
+	}{
+		{user: "operator", wantAll: true, wantSite: map[uuid.UUID]bool{testSite: true, testOtherSite: true, testForeignSite: true}},
+		{user: "admin", wantSite: map[uuid.UUID]bool{testSite: true, testOtherSite: true}},
+		{user: "manager", wantSite: map[uuid.UUID]bool{testSite: true, testOtherSite: true}},
+		{user: "granted technician", wantSite: map[uuid.UUID]bool{testSite: true}},
+		{user: "granted viewer", wantSite: map[uuid.UUID]bool{testSite: true}},
+		{user: "viewer", wantSite: map[uuid.UUID]bool{}},
+		{user: "admin without org", wantSite: map[uuid.UUID]bool{}},
+	}
+
+	users := testUsers()
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			var scope domain.SiteScope
+			app := newTestApp(users[tt.user], nil, func(app *fiber.App) {
+				app.Get("/", LoadSiteScope(access), func(c *fiber.Ctx) error {
+					scope = CurrentSiteScope(c)
+					return nil
+				})
+			})
+			if status := statusOf(t, app, "/"); status != fiber.StatusOK {
+				t.Fatalf("status = %d, want %d", status, fiber.StatusOK)
+			}
+
+			if scope.All != tt.wantAll {
+				t.Errorf("scope.All = %v, want %v", scope.All, tt.wantAll)
+			}
+			for _, site := range []uuid.UUID{testSite, testOtherSite, testForeignSite} {
+				if got := scope.Includes(site); got != tt.wantSite[site] {
+					t.Errorf("scope.Includes(%s) = %v, want %v", site, got, tt.wantSite[site])
+				}
+			}
+		})
+	}
+}
+
+func TestCurrentSiteScopeUnguarded(t *testing.T) {
+	var scope domain.SiteScope
+	app := newTestApp(testUsers()["admin"], nil, func(app *fiber.App) {
+		app.Get("/", func(c *fiber.Ctx) error {
+			scope = CurrentSiteScope(c)
+			return nil
+		})
+	})
+	statusOf(t, app, "/")
+
+	if scope.All || scope.Includes(testSite) {
+		t.Errorf("CurrentSiteScope() without LoadSiteScope = %+v, want no sites", scope)
+	}
+}
+
+func TestLoadSiteScopeRequiresUser(t *testing.T) {
+	access := service.NewSiteAccessService(&fakeOrganizations{})
+	app := newTestApp(nil, nil, func(app *fiber.App) {
+		app.Get("/", LoadSiteScope(access), func(c *fiber.Ctx) error { return nil })
+	})
+	if status := statusOf(t, app, "/"); status != fiber.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", status, fiber.StatusUnauthorized)
+	}
+}
+
+func TestRequireSiteAccess(t *testing.T) {
+	access := service.NewSiteAccessService(&fakeOrganizations{})
+	const ok, notFound = fiber.StatusOK, fiber.StatusNotFound
+
+	// Statuses for testSite, testOtherSite and testForeignSite
+	tests := []struct {
+		user string
+		want [3]int
+	}{
+		{user: "operator", want: [3]int{ok, ok, ok}},
+		{user: "admin", want: [3]int{ok, ok, notFound}},
+		{user: "manager", want: [3]int{ok, ok, notFound}},
+		{user: "granted technician", want: [3]int{ok, notFound, notFound}},
+		{user: "granted viewer", want: [3]int{ok, notFound, notFound}},
+		{user: "viewer", want: [3]int{notFound, notFound, notFound}},
+		{user: "admin without org", want: [3]int{notFound, notFound, notFound}},
+	}
+
+	users := testUsers()
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			app := newTestApp(users[tt.user], nil, func(app *fiber.App) {
+				app.Get("/sites/:site_id", LoadSiteScope(access), RequireSiteAccess("site_id"), func(c *fiber.Ctx) error { return nil })
+			})
+			for i, site := range []uuid.UUID{testSite, testOtherSite, testForeignSite} {
+				if status := statusOf(t, app, "/sites/"+site.String()); status != tt.want[i] {
+					t.Errorf("GET site %s status = %d, want %d", site, status, tt.want[i])
+				}
+			}
+			if status := statusOf(t, app, "/sites/not-a-uuid"); status != fiber.StatusBadRequest {
+				t.Errorf("GET invalid site ID status = %d, want %d", status, fiber.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestRequireEntitySiteAccess(t *testing.T) {
+	document := uuid.MustParse("00000000-0000-0000-0000-0000000000d1")
+	otherDocument := uuid.MustParse("00000000-0000-0000-0000-0000000000d2")
+	foreignDocument := uuid.MustParse("00000000-0000-0000-0000-0000000000d3")
+	missingDocument := uuid.MustParse("00000000-0000-0000-0000-0000000000d4")
+	brokenDocument := uuid.MustParse("00000000-0000-0000-0000-0000000000d5")
+
+	access := service.NewSiteAccessService(&fakeOrganizations{
+		records: map[uuid.UUID]uuid.UUID{document: testSite, otherDocument: testOtherSite, foreignDocument: testForeignSite},
+		broken:  map[uuid.UUID]bool{brokenDocument: true},
+	})
+	const ok, notFound = fiber.StatusOK, fiber.StatusNotFound
+
+	// Statuses for document, otherDocument and foreignDocument
+	tests := []struct {
+		user string
+		want [3]int
+	}{
+		{user: "operator", want: [3]int{ok, ok, ok}},
+		{user: "admin", want: [3]int{ok, ok, notFound}},
+		{user: "manager", want: [3]int{ok, ok, notFound}},
+		{user: "granted technician", want: [3]int{ok, notFound, notFound}},
+		{user: "granted viewer", want: [3]int{ok, notFound, notFound}},
+		{user: "viewer", want: [3]int{notFound, notFound, notFound}},
+		{user: "admin without org", want: [3]int{notFound, notFound, notFound}},
+	}
+
+	users := testUsers()
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			app := newTestApp(users[tt.user], nil, func(app *fiber.App) {
+				app.Get("/documents/:id", LoadSiteScope(access),
+					RequireEntitySiteAccess(access, service.SiteResourceDocument, "id", "Document"),
+					func(c *fiber.Ctx) error { return nil })
+			})
+			for i, id := range []uuid.UUID{document, otherDocument, foreignDocument} {
+				if status := statusOf(t, app, "/documents/"+id.String()); status != tt.want[i] {
+					t.Errorf("GET document %s status = %d, want %d", id, status, tt.want[i])
+				}
+			}
+
+			for path, want := range map[string]int{
+				"/documents/" + missingDocument.String(): notFound,
+				"/documents/" + brokenDocument.String():  fiber.StatusInternalServerError,
+				"/documents/not-a-uuid":                  fiber.StatusBadRequest,
+			} {
+				if status := statusOf(t, app, path); status != want {
+					t.Errorf("GET %s status = %d, want %d", path, status, want)
+				}
+			}
+		})
+	}
+}
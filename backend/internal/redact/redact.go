@@ -0,0 +1,218 @@
+// Package redact swaps personal information (names, email addresses, phone
+// numbers and street addresses) for numbered placeholders such as
+// [PERSON_1], so text can be sent to an external model without it, and puts
+// the originals back into the model's reply.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Category is the kind of personal information a placeholder stands for
+type Category string
+
+const (
+	CategoryPerson  Category = "PERSON"
+	CategoryEmail   Category = "EMAIL"
+	CategoryPhone   Category = "PHONE"
+	CategoryAddress Category = "ADDRESS"
+)
+
+// AllCategories lists every category, in the order they are redacted
+var AllCategories = []Category{CategoryEmail, CategoryAddress, CategoryPhone, CategoryPerson}
+
+const (
+	// name is a capitalised word, optionally hyphenated or with an
+	// apostrophe (Mary-Jane, O'Neil)
+	name = `[A-Z][a-z]+(?:['\x{2019}-][A-Z]?[a-z]+)?`
+	// fullName is one to three names with an optional middle initial
+	fullName = name + `(?:\s+[A-Z]\.)?(?:[ \t]+` + name + `){0,2}`
+	// nameList is one or more names joined by commas, "&" or "and"
+	nameList = fullName + `(?:\s*(?:,|&|\band\b)\s*` + fullName + `)*`
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+	// Phone numbers need separators, brackets or a country code so serial
+	// numbers and readings aren't mistaken for them
+	phonePattern = regexp.MustCompile(`\+\d{1,3}(?:[ .-]?\(?\d{1,5}\)?){2,5}\b|(?:\(\d{3}\)\s?|\b\d{3}[.-])\d{3}[.-]\d{4}\b`)
+
+	addressPattern = regexp.MustCompile(`\b\d{1,6}\s+(?:[A-Z0-9][A-Za-z0-9.'-]*\s+){1,4}` +
+		`(?i:street|st|avenue|ave|road|rd|boulevard|blvd|lane|ln|drive|dr|court|ct|way|highway|hwy|parkway|pkwy|place|pl|circle|cir|terrace|ter|trail|trl)\b\.?` +
+		`(?:,?\s+(?i:suite|ste|apt|unit|#)\s*[\w-]+)?` +
+		`(?:,\s*[A-Z][A-Za-z .'-]+,\s*[A-Z]{2}(?:\s+\d{5}(?:-\d{4})?)?)?` +
+		`|\b(?i:p\.?\s?o\.?\s?box)\s+\d+`)
+
+	// Names can't be recognised in general, so only names in the places
+	// reports, emails and transcripts put them are detected. Every other
+	// mention of a detected name is redacted as well.
+	namePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\b(?i:technicians?|techs?|engineers?|inspectors?|operators?|contacts?|supervisors?|crew|attendees|participants|customer|name|from|to|cc)\s*:[ \t]*(` + nameList + `)`),
+		regexp.MustCompile(`\b(?i:performed|completed|reported|prepared|submitted|approved|signed|inspected|serviced|replaced|repaired|verified|checked)\s+by\s+(` + nameList + `)`),
+		regexp.MustCompile(`\b(?:Mr|Mrs|Ms|Miss|Mx|Dr)\.?\s+(` + fullName + `)`),
+		// Email display names: John Smith <john@example.com>
+		regexp.MustCompile(`(` + fullName + `)\s*<[^<>@\s]+@`),
+		// Transcript speaker turns: [00:01:02] John Smith: ...
+		regexp.MustCompile(`(?m)^\[\d{2}:\d{2}:\d{2}\]\s*(` + fullName + `):`),
+	}
+	namePart        = regexp.MustCompile(fullName)
+	placeholderForm = regexp.MustCompile(`\[(PERSON|EMAIL|PHONE|ADDRESS)_(\d+)\]`)
+)
+
+// notNames are capitalised words the name patterns can pick up that must
+// not be redacted wherever else they appear
+var notNames = map[string]bool{
+	"January": true, "February": true, "March": true, "April": true, "May": true, "June": true,
+	"July": true, "August": true, "September": true, "October": true, "November": true, "December": true,
+	"Monday": true, "Tuesday": true, "Wednesday": true, "Thursday": true, "Friday": true,
+	"Saturday": true, "Sunday": true, "Site": true, "Team": true, "The": true, "Unknown": true,
+}
+
+// ParseCategories reads a comma-separated list of category names, e.g.
+// "person,email". "all" selects every category and "none" none of them.
+func ParseCategories(list string) ([]Category, error) {
+	switch strings.ToLower(strings.TrimSpace(list)) {
+	case "all":
+		return AllCategories, nil
+	case "", "none":
+		return nil, nil
+	}
+
+	var categories []Category
+	for _, item := range strings.Split(list, ",") {
+		category := Category(strings.ToUpper(strings.TrimSpace(item)))
+		switch category {
+		case CategoryPerson, CategoryEmail, CategoryPhone, CategoryAddress:
+			categories = append(categories, category)
+		case "":
+		default:
+			return nil, fmt.Errorf("redact: unknown category %q", item)
+		}
+	}
+	return categories, nil
+}
+
+// Session redacts the texts of one exchange with a model. Within a session
+// the same value always gets the same placeholder, so the model can still
+// tell that two mentions refer to the same person.
+type Session struct {
+	placeholders map[string]string // original -> placeholder
+	originals    map[string]string // placeholder -> original
+	counts       map[Category]int
+}
+
+// NewSession starts an empty session
+func NewSession() *Session {
+	return &Session{
+		placeholders: make(map[string]string),
+		originals:    make(map[string]string),
+		counts:       make(map[Category]int),
+	}
+}
+
+// Redact replaces the personal information in text with placeholders
+func (s *Session) Redact(text string) string {
+	names := detectNames(text)
+
+	text = s.replaceAll(text, emailPattern, CategoryEmail)
+	text = s.replaceAll(text, addressPattern, CategoryAddress)
+	text = s.replaceAll(text, phonePattern, CategoryPhone)
+
+	for _, n := range names {
+		pattern := regexp.MustCompile(`\b` + regexp.QuoteMeta(n) + `\b`)
+		text = s.replaceAll(text, pattern, CategoryPerson)
+	}
+	return text
+}
+
+// Restore puts back the originals of placeholders in the given categories.
+// Placeholders of other categories, and any the session didn't issue, are
+// left as they are.
+func (s *Session) Restore(text string, categories ...Category) string {
+	return s.restore(text, categories, func(original string) string { return original })
+}
+
+// RestoreJSON is Restore for a JSON document: originals are escaped so they
+// stay valid inside JSON strings
+func (s *Session) RestoreJSON(text string, categories ...Category) string {
+	return s.restore(text, categories, func(original string) string {
+		quoted, err := json.Marshal(original)
+		if err != nil {
+			return original
+		}
+		return string(quoted[1 : len(quoted)-1])
+	})
+}
+
+func (s *Session) restore(text string, categories []Category, format func(string) string) string {
+	allowed := make(map[Category]bool, len(categories))
+	for _, category := range categories {
+		allowed[category] = true
+	}
+
+	return placeholderForm.ReplaceAllStringFunc(text, func(placeholder string) string {
+		category := Category(placeholderForm.FindStringSubmatch(placeholder)[1])
+		original, ok := s.originals[placeholder]
+		if !ok || !allowed[category] {
+			return placeholder
+		}
+		return format(original)
+	})
+}
+
+func (s *Session) replaceAll(text string, pattern *regexp.Regexp, category Category) string {
+	return pattern.ReplaceAllStringFunc(text, func(match string) string {
+		return s.placeholder(match, category)
+	})
+}
+
+func (s *Session) placeholder(original string, category Category) string {
+	if placeholder, ok := s.placeholders[original]; ok {
+		return placeholder
+	}
+	s.counts[category]++
+	placeholder := "[" + string(category) + "_" + strconv.Itoa(s.counts[category]) + "]"
+	s.placeholders[original] = placeholder
+	s.originals[placeholder] = original
+	return placeholder
+}
+
+// detectNames returns the names mentioned in text, longest first so a full
+// name is replaced before its parts. The first and last names of a full
+// name are included on their own, since reports often repeat only one of
+// them; each part restores to itself, not to the full name.
+func detectNames(text string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(n string) {
+		if len(n) < 3 || notNames[n] || seen[n] {
+			return
+		}
+		seen[n] = true
+		names = append(names, n)
+	}
+
+	for _, pattern := range namePatterns {
+		for _, match := range pattern.FindAllStringSubmatch(text, -1) {
+			for _, full := range namePart.FindAllString(match[1], -1) {
+				full = strings.Join(strings.Fields(full), " ")
+				add(full)
+				if parts := strings.Fields(full); len(parts) > 1 {
+					add(parts[0])
+					add(parts[len(parts)-1])
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j])
+	})
+	return names
+}
@@ -0,0 +1,160 @@
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedactRestoreRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		// removed must not survive redaction
+		removed []string
+	}{
+		{
+			name:    "technician list",
+			text:    "Technicians: John Smith, Mary-Jane O'Neil\nJohn replaced the DC disconnect on INV001.",
+			removed: []string{"John Smith", "Mary-Jane O'Neil", "John"},
+		},
+		{
+			name:    "email and phone",
+			text:    "Contact: Alice Brown <alice.brown@example.com>, call (555) 123-4567 or +1 555 987 6543.",
+			removed: []string{"Alice Brown", "alice.brown@example.com", "(555) 123-4567", "+1 555 987 6543"},
+		},
+		{
+			name:    "street address",
+			text:    "Site visit at 1200 Solar Farm Road, Suite 4, Fresno, CA 93722 performed by Dr. Raj Patel.",
+			removed: []string{"1200 Solar Farm Road", "Raj Patel"},
+		},
+		{
+			name:    "transcript speaker",
+			text:    "[00:01:02] Sam Lee: the breaker CB-05 tripped again\n[00:01:09] Sam Lee: resetting now",
+			removed: []string{"Sam Lee"},
+		},
+		{
+			name: "nothing personal",
+			text: "Inverter INV001 reported fault code 4011 on March 5; serial 1234567890.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := NewSession()
+			redacted := session.Redact(tt.text)
+
+			for _, value := range tt.removed {
+				if strings.Contains(redacted, value) {
+					t.Errorf("Redact() kept %q: %q", value, redacted)
+				}
+			}
+			if restored := session.Restore(redacted, AllCategories...); restored != tt.text {
+				t.Errorf("Restore() = %q, want %q", restored, tt.text)
+			}
+		})
+	}
+}
+
+func TestSessionReusesPlaceholders(t *testing.T) {
+	session := NewSession()
+	first := session.Redact("Performed by John Smith.")
+	second := session.Redact("Report prepared by John Smith.")
+
+	if first != "Performed by [PERSON_1]." {
+		t.Errorf("first = %q", first)
+	}
+	if second != "Report prepared by [PERSON_1]." {
+		t.Errorf("second = %q", second)
+	}
+}
+
+func TestRestoreCategories(t *testing.T) {
+	session := NewSession()
+	redacted := session.Redact("From: Ann Lee <ann@example.com>")
+
+	tests := []struct {
+		name       string
+		categories []Category
+		want       string
+	}{
+		{"all", AllCategories, "From: Ann Lee <ann@example.com>"},
+		{"person only", []Category{CategoryPerson}, "From: Ann Lee <[EMAIL_1]>"},
+		{"email only", []Category{CategoryEmail}, "From: [PERSON_1] <ann@example.com>"},
+		{"none", nil, redacted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := session.Restore(redacted, tt.categories...); got != tt.want {
+				t.Errorf("Restore() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreLeavesUnknownPlaceholders(t *testing.T) {
+	session := NewSession()
+	session.Redact("Performed by John Smith.")
+
+	// The model may invent placeholders the session never issued
+	text := "[PERSON_1] and [PERSON_7] met [EMAIL_2]."
+	want := "John Smith and [PERSON_7] met [EMAIL_2]."
+	if got := session.Restore(text, AllCategories...); got != want {
+		t.Errorf("Restore() = %q, want %q", got, want)
+	}
+}
+
+func TestRestoreJSONRoundTrip(t *testing.T) {
+	session := NewSession()
+	redacted := session.Redact(`Replaced by Shaquille O'Neal (shaq@example.com), who noted "no damage"`)
+
+	reply, err := json.Marshal(map[string]string{"summary": redacted})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var decoded map[string]string
+	if err := json.Unmarshal([]byte(session.RestoreJSON(string(reply), AllCategories...)), &decoded); err != nil {
+		t.Fatalf("RestoreJSON produced invalid JSON: %v", err)
+	}
+	if want := session.Restore(redacted, AllCategories...); decoded["summary"] != want {
+		t.Errorf("summary = %q, want %q", decoded["summary"], want)
+	}
+}
+
+func TestParseCategories(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []Category
+		wantErr bool
+	}{
+		{"all", AllCategories, false},
+		{"ALL", AllCategories, false},
+		{"none", nil, false},
+		{"", nil, false},
+		{"person, email", []Category{CategoryPerson, CategoryEmail}, false},
+		{"phone,,address", []Category{CategoryPhone, CategoryAddress}, false},
+		{"person,ssn", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			got, err := ParseCategories(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCategories(%q) error = %v, wantErr %v", tt.list, err, tt.wantErr)
+			}
+			if strings.Join(categoryNames(got), ",") != strings.Join(categoryNames(tt.want), ",") {
+				t.Errorf("ParseCategories(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+		})
+	}
+}
+
+func categoryNames(categories []Category) []string {
+	names := make([]string, len(categories))
+	for i, category := range categories {
+		names[i] = string(category)
+	}
+	return names
+}
@@ -1,7 +1,6 @@
 package repository
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/engramiq/engramiq-backend/internal/domain"
@@ -18,10 +17,17 @@ type ActionRepository interface {
 	ListByComponent(componentID uuid.UUID, pagination *domain.Pagination) ([]*domain.ExtractedAction, error)
 	Update(id uuid.UUID, updates map[string]interface{}) error
 	Delete(id uuid.UUID) error
+	// ReplaceForDocument swaps the actions extracted from a document
+	ReplaceForDocument(documentID uuid.UUID, actions []*domain.ExtractedAction) error
 	SearchSemantic(siteID uuid.UUID, embedding pgvector.Vector, limit int, threshold float64) ([]*domain.ExtractedAction, error)
-	GetByWorkOrderNumber(workOrder string) ([]*domain.ExtractedAction, error)
+	// GetByWorkOrderNumber searches every site in scope; work order numbers
+	// are only unique within an asset owner's system
+	GetByWorkOrderNumber(scope domain.SiteScope, workOrder string) ([]*domain.ExtractedAction, error)
 	GetMaintenanceHistory(componentID uuid.UUID, limit int) ([]*domain.ExtractedAction, error)
 	GetByDateRange(siteID uuid.UUID, startDate, endDate time.Time) ([]*domain.ExtractedAction, error)
+	GetUnembedded(limit int) ([]*domain.ExtractedAction, error)
+	// UpdateEmbedding stores an action's embedding; nil clears it
+	UpdateEmbedding(id uuid.UUID, embedding *pgvector.Vector) error
 }
 
 type actionRepository struct {
@@ -44,18 +50,18 @@ func (r *actionRepository) CreateWithComponents(action *domain.ExtractedAction,
 		if err := tx.Create(action).Error; err != nil {
 			return err
 		}
-		
+
 		// Create component relationships
 		for i := range components {
 			components[i].ActionID = action.ID
 		}
-		
+
 		if len(components) > 0 {
 			if err := tx.Create(&components).Error; err != nil {
 				return err
 			}
 		}
-		
+
 		return nil
 	})
 }
@@ -82,7 +88,7 @@ func (r *actionRepository) GetByID(id uuid.UUID) (*domain.ActionWithComponents,
 	for i, ac := range actionComponents {
 		var component domain.SiteComponent
 		r.db.First(&component, "id = ?", ac.ComponentID)
-		
+
 		relatedComponents[i] = domain.ActionComponentDetail{
 			ComponentID:     ac.ComponentID,
 			Component:       component,
@@ -99,57 +105,57 @@ func (r *actionRepository) GetByID(id uuid.UUID) (*domain.ActionWithComponents,
 
 func (r *actionRepository) ListBySite(siteID uuid.UUID, pagination *domain.Pagination, filters map[string]interface{}) ([]*domain.ExtractedAction, error) {
 	var actions []*domain.ExtractedAction
-	
+
 	query := r.db.Model(&domain.ExtractedAction{}).
 		Preload("PrimaryComponent").
 		Where("site_id = ?", siteID)
-	
+
 	query = r.ApplyFilters(query, filters)
-	
+
 	// Additional specific filters
 	if componentID, ok := filters["component_id"].(uuid.UUID); ok {
 		// Join with action_components table to find actions related to specific component
 		query = query.Joins("LEFT JOIN action_components ac ON extracted_actions.id = ac.action_id").
 			Where("extracted_actions.primary_component_id = ? OR ac.component_id = ?", componentID, componentID)
 	}
-	
+
 	if workOrder, ok := filters["work_order_number"].(string); ok && workOrder != "" {
 		query = query.Where("work_order_number = ?", workOrder)
 	}
-	
+
 	// Count total for pagination
 	count, err := r.CountTotal(query, &domain.ExtractedAction{})
 	if err != nil {
 		return nil, err
 	}
 	pagination.SetTotalPages(count)
-	
+
 	// Apply pagination and get results
 	query = r.BuildQuery(query, pagination)
 	err = query.Find(&actions).Error
-	
+
 	return actions, err
 }
 
 func (r *actionRepository) ListByComponent(componentID uuid.UUID, pagination *domain.Pagination) ([]*domain.ExtractedAction, error) {
 	var actions []*domain.ExtractedAction
-	
+
 	query := r.db.Model(&domain.ExtractedAction{}).
 		Joins("LEFT JOIN action_components ac ON extracted_actions.id = ac.action_id").
 		Where("extracted_actions.primary_component_id = ? OR ac.component_id = ?", componentID, componentID).
 		Order("action_date DESC, created_at DESC")
-	
+
 	// Count total for pagination
 	count, err := r.CountTotal(query, &domain.ExtractedAction{})
 	if err != nil {
 		return nil, err
 	}
 	pagination.SetTotalPages(count)
-	
+
 	// Apply pagination
 	query = r.BuildQuery(query, pagination)
 	err = query.Find(&actions).Error
-	
+
 	return actions, err
 }
 
@@ -163,39 +169,60 @@ func (r *actionRepository) Delete(id uuid.UUID) error {
 		if err := tx.Delete(&domain.ActionComponent{}, "action_id = ?", id).Error; err != nil {
 			return err
 		}
-		
+
 		// Delete the action
 		return tx.Delete(&domain.ExtractedAction{}, "id = ?", id).Error
 	})
 }
 
+// ReplaceForDocument deletes a document's actions and saves the new ones in
+// one transaction, so reprocessing a document never leaves duplicates
+func (r *actionRepository) ReplaceForDocument(documentID uuid.UUID, actions []*domain.ExtractedAction) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		existing := tx.Model(&domain.ExtractedAction{}).Select("id").Where("document_id = ?", documentID)
+		if err := tx.Where("action_id IN (?)", existing).Delete(&domain.ActionComponent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("document_id = ?", documentID).Delete(&domain.ExtractedAction{}).Error; err != nil {
+			return err
+		}
+		if len(actions) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(actions, 100).Error
+	})
+}
+
 func (r *actionRepository) SearchSemantic(siteID uuid.UUID, embedding pgvector.Vector, limit int, threshold float64) ([]*domain.ExtractedAction, error) {
 	var actions []*domain.ExtractedAction
-	
+
+	// threshold is a cosine distance, matching documentRepository.SearchSemantic
 	err := r.db.Preload("PrimaryComponent").
+		Select("extracted_actions.*, 1 - (embedding <=> ?) AS score", embedding).
 		Where("site_id = ?", siteID).
 		Where("embedding <=> ? < ?", embedding, threshold).
-		Order(fmt.Sprintf("embedding <=> '%v'", embedding)).
+		Order("score DESC").
 		Limit(limit).
 		Find(&actions).Error
-	
+
 	return actions, err
 }
 
-func (r *actionRepository) GetByWorkOrderNumber(workOrder string) ([]*domain.ExtractedAction, error) {
+func (r *actionRepository) GetByWorkOrderNumber(scope domain.SiteScope, workOrder string) ([]*domain.ExtractedAction, error) {
 	var actions []*domain.ExtractedAction
-	
-	err := r.db.Preload("PrimaryComponent").
+
+	err := scopeSites(r.db, scope, "site_id").
+		Preload("PrimaryComponent").
 		Where("work_order_number = ?", workOrder).
 		Order("action_date DESC").
 		Find(&actions).Error
-	
+
 	return actions, err
 }
 
 func (r *actionRepository) GetMaintenanceHistory(componentID uuid.UUID, limit int) ([]*domain.ExtractedAction, error) {
 	var actions []*domain.ExtractedAction
-	
+
 	err := r.db.Preload("Document").
 		Joins("LEFT JOIN action_components ac ON extracted_actions.id = ac.action_id").
 		Where("extracted_actions.primary_component_id = ? OR ac.component_id = ?", componentID, componentID).
@@ -203,18 +230,38 @@ func (r *actionRepository) GetMaintenanceHistory(componentID uuid.UUID, limit in
 		Order("action_date DESC, created_at DESC").
 		Limit(limit).
 		Find(&actions).Error
-	
+
 	return actions, err
 }
 
 func (r *actionRepository) GetByDateRange(siteID uuid.UUID, startDate, endDate time.Time) ([]*domain.ExtractedAction, error) {
 	var actions []*domain.ExtractedAction
-	
+
 	err := r.db.Preload("PrimaryComponent").
 		Where("site_id = ?", siteID).
 		Where("action_date BETWEEN ? AND ?", startDate, endDate).
 		Order("action_date ASC").
 		Find(&actions).Error
-	
+
 	return actions, err
-}
\ No newline at end of file
+}
+
+// GetUnembedded finds actions without an embedding, i.e. those extracted
+// before actions were embedded or whose embedding failed
+func (r *actionRepository) GetUnembedded(limit int) ([]*domain.ExtractedAction, error) {
+	var actions []*domain.ExtractedAction
+
+	err := r.db.Where("embedding IS NULL").
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&actions).Error
+
+	return actions, err
+}
+
+func (r *actionRepository) UpdateEmbedding(id uuid.UUID, embedding *pgvector.Vector) error {
+	if embedding == nil {
+		return r.db.Model(&domain.ExtractedAction{}).Where("id = ?", id).Update("embedding", gorm.Expr("NULL")).Error
+	}
+	return r.db.Model(&domain.ExtractedAction{}).Where("id = ?", id).Update("embedding", *embedding).Error
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(key *domain.APIKey) error
+	GetByID(id uuid.UUID) (*domain.APIKey, error)
+	// GetByHash finds an unrevoked key by the hash of its secret
+	GetByHash(hash string) (*domain.APIKey, error)
+	List() ([]*domain.APIKey, error)
+	// ListByOrganization lists the keys created by users of an organization
+	ListByOrganization(orgID uuid.UUID) ([]*domain.APIKey, error)
+	// Delete revokes a key
+	Delete(id uuid.UUID) error
+	UpdateLastUsed(id uuid.UUID, usedAt time.Time) error
+}
+
+type apiKeyRepository struct {
+	*BaseRepository
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{
+		BaseRepository: NewBaseRepository(db),
+	}
+}
+
+func (r *apiKeyRepository) Create(key *domain.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *apiKeyRepository) GetByID(id uuid.UUID) (*domain.APIKey, error) {
+	var key domain.APIKey
+	err := r.db.First(&key, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) GetByHash(hash string) (*domain.APIKey, error) {
+	var key domain.APIKey
+	err := r.db.First(&key, "key_hash = ?", hash).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) List() ([]*domain.APIKey, error) {
+	var keys []*domain.APIKey
+	err := r.db.Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *apiKeyRepository) ListByOrganization(orgID uuid.UUID) ([]*domain.APIKey, error) {
+	var keys []*domain.APIKey
+	err := r.db.Joins("JOIN users ON users.id = api_keys.user_id AND users.deleted_at IS NULL").
+		Where("users.organization_id = ?", orgID).
+		Order("api_keys.created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+func (r *apiKeyRepository) Delete(id uuid.UUID) error {
+	return r.db.Delete(&domain.APIKey{}, "id = ?", id).Error
+}
+
+func (r *apiKeyRepository) UpdateLastUsed(id uuid.UUID, usedAt time.Time) error {
+	return r.db.Model(&domain.APIKey{}).Where("id = ?", id).
+		UpdateColumn("last_used_at", usedAt).Error
+}
@@ -27,16 +27,28 @@ func (r *BaseRepository) WithTx(tx *gorm.DB) *BaseRepository {
 	return &BaseRepository{db: tx}
 }
 
+// scopeSites limits query to the sites in scope by column, matching nothing
+// when the scope is empty
+func scopeSites(query *gorm.DB, scope domain.SiteScope, column string) *gorm.DB {
+	if scope.All {
+		return query
+	}
+	if len(scope.SiteIDs) == 0 {
+		return query.Where("1 = 0")
+	}
+	return query.Where(column+" IN ?", scope.SiteIDs)
+}
+
 // BuildQuery applies pagination and filtering to queries
 func (r *BaseRepository) BuildQuery(query *gorm.DB, pagination *domain.Pagination) *gorm.DB {
 	if pagination.Sort != "" {
 		query = query.Order(pagination.Sort)
 	}
-	
+
 	if pagination.Limit > 0 {
 		query = query.Offset(pagination.GetOffset()).Limit(pagination.Limit)
 	}
-	
+
 	return query
 }
 
@@ -85,27 +97,27 @@ func (r *BaseRepository) ApplySearch(query *gorm.DB, searchTerm string, fields .
 	if searchTerm == "" {
 		return query
 	}
-	
+
 	// Use PostgreSQL full-text search
 	searchQuery := "%" + searchTerm + "%"
-	
+
 	if len(fields) == 0 {
 		// Default search fields
 		fields = []string{"title", "name", "description", "content"}
 	}
-	
+
 	// Build OR condition for multiple fields
 	conditions := make([]interface{}, 0, len(fields)*2)
 	placeholders := make([]string, 0, len(fields))
-	
+
 	for _, field := range fields {
 		placeholders = append(placeholders, field+" ILIKE ?")
 		conditions = append(conditions, searchQuery)
 	}
-	
+
 	if len(placeholders) > 0 {
 		query = query.Where(strings.Join(placeholders, " OR "), conditions...)
 	}
-	
+
 	return query
-}
\ No newline at end of file
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+	"gorm.io/gorm"
+)
+
+const (
+	// anyTermQuery matches chunks containing any of the query's terms rather
+	// than all of them, so filler words in a question don't exclude a chunk
+	anyTermQuery = "replace(plainto_tsquery('english', ?)::text, ' & ', ' | ')::tsquery"
+	// identifierMatches counts the exact identifiers (work orders, fault
+	// codes) a chunk contains; stemming and tokenising can split these
+	identifierMatches = "(SELECT COUNT(*) FROM unnest(?::text[]) AS term WHERE document_chunks.content ILIKE '%' || term || '%')"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+type ChunkRepository interface {
+	ReplaceForDocument(documentID uuid.UUID, chunks []*domain.DocumentChunk) error
+	ListByDocument(documentID uuid.UUID) ([]*domain.DocumentChunk, error)
+	DeleteByDocument(documentID uuid.UUID) error
+	SearchFullText(siteID uuid.UUID, query string, identifiers []string, limit int) ([]*domain.DocumentChunk, error)
+	SearchSemantic(siteID uuid.UUID, embedding pgvector.Vector, limit int, threshold float64) ([]*domain.DocumentChunk, error)
+}
+
+type chunkRepository struct {
+	*BaseRepository
+}
+
+func NewChunkRepository(db *gorm.DB) ChunkRepository {
+	return &chunkRepository{
+		BaseRepository: NewBaseRepository(db),
+	}
+}
+
+// ReplaceForDocument swaps a document's chunks in one transaction so a
+// reprocessed document is never searchable with a mix of old and new chunks
+func (r *chunkRepository) ReplaceForDocument(documentID uuid.UUID, chunks []*domain.DocumentChunk) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", documentID).Delete(&domain.DocumentChunk{}).Error; err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(chunks, 100).Error
+	})
+}
+
+func (r *chunkRepository) ListByDocument(documentID uuid.UUID) ([]*domain.DocumentChunk, error) {
+	var chunks []*domain.DocumentChunk
+
+	err := r.db.Where("document_id = ?", documentID).
+		Order("chunk_index ASC").
+		Find(&chunks).Error
+
+	return chunks, err
+}
+
+func (r *chunkRepository) DeleteByDocument(documentID uuid.UUID) error {
+	return r.db.Where("document_id = ?", documentID).Delete(&domain.DocumentChunk{}).Error
+}
+
+// SearchFullText ranks chunks by text rank plus one point per exact
+// identifier match, so a chunk quoting the asked-for work order outranks
+// chunks that merely repeat common words from the question
+func (r *chunkRepository) SearchFullText(siteID uuid.UUID, query string, identifiers []string, limit int) ([]*domain.DocumentChunk, error) {
+	var chunks []*domain.DocumentChunk
+
+	patterns := make([]string, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		patterns = append(patterns, likeEscaper.Replace(identifier))
+	}
+
+	err := r.searchable(siteID).
+		Select("document_chunks.*, ts_rank(document_chunks.content_vector, "+anyTermQuery+") + "+identifierMatches+" AS score", query, pq.Array(patterns)).
+		Where("(document_chunks.content_vector @@ "+anyTermQuery+" OR "+identifierMatches+" > 0)", query, pq.Array(patterns)).
+		Order("score DESC").
+		Limit(limit).
+		Find(&chunks).Error
+
+	return chunks, err
+}
+
+func (r *chunkRepository) SearchSemantic(siteID uuid.UUID, embedding pgvector.Vector, limit int, threshold float64) ([]*domain.DocumentChunk, error) {
+	var chunks []*domain.DocumentChunk
+
+	// threshold is a cosine distance, matching documentRepository.SearchSemantic
+	err := r.searchable(siteID).
+		Select("document_chunks.*, 1 - (document_chunks.embedding <=> ?) AS score", embedding).
+		Where("document_chunks.embedding <=> ? < ?", embedding, threshold).
+		Order("score DESC").
+		Limit(limit).
+		Find(&chunks).Error
+
+	return chunks, err
+}
+
+// searchable limits chunks to a site's live documents and loads the parent
+// document without its (potentially very large) content columns. The join
+// also requires the document to belong to the site, so a chunk whose
+// site_id disagrees with its document never reaches another tenant.
+func (r *chunkRepository) searchable(siteID uuid.UUID) *gorm.DB {
+	return r.db.Model(&domain.DocumentChunk{}).
+		Joins("JOIN documents ON documents.id = document_chunks.document_id AND documents.site_id = document_chunks.site_id AND documents.deleted_at IS NULL").
+		Where("document_chunks.site_id = ?", siteID).
+		Preload("Document", func(db *gorm.DB) *gorm.DB {
+			return db.Omit("raw_content", "processed_content", "embedding", "content_vector")
+		})
+}
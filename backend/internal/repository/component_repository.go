@@ -3,6 +3,7 @@ package repository
 import (
 	"github.com/engramiq/engramiq-backend/internal/domain"
 	"github.com/google/uuid"
+	"github.com/pgvector/pgvector-go"
 	"gorm.io/gorm"
 )
 
@@ -16,6 +17,8 @@ type ComponentRepository interface {
 	GetHierarchy(siteID uuid.UUID) ([]*domain.SiteComponent, error)
 	FindBySpecification(siteID uuid.UUID, key string, value string) ([]*domain.SiteComponent, error)
 	BulkCreate(components []*domain.SiteComponent) error
+	GetUnembedded(limit int) ([]*domain.SiteComponent, error)
+	UpdateEmbedding(id uuid.UUID, embedding pgvector.Vector) error
 }
 
 type componentRepository struct {
@@ -53,21 +56,21 @@ func (r *componentRepository) GetByExternalID(siteID uuid.UUID, externalID strin
 
 func (r *componentRepository) ListBySite(siteID uuid.UUID, pagination *domain.Pagination, filters map[string]interface{}) ([]*domain.SiteComponent, error) {
 	var components []*domain.SiteComponent
-	
+
 	query := r.db.Model(&domain.SiteComponent{}).Where("site_id = ?", siteID)
 	query = r.ApplyFilters(query, filters)
-	
+
 	// Count total for pagination
 	count, err := r.CountTotal(query, &domain.SiteComponent{})
 	if err != nil {
 		return nil, err
 	}
 	pagination.SetTotalPages(count)
-	
+
 	// Apply pagination and get results
 	query = r.BuildQuery(query, pagination)
 	err = query.Find(&components).Error
-	
+
 	return components, err
 }
 
@@ -89,11 +92,11 @@ func (r *componentRepository) GetHierarchy(siteID uuid.UUID) ([]*domain.SiteComp
 
 func (r *componentRepository) FindBySpecification(siteID uuid.UUID, key string, value string) ([]*domain.SiteComponent, error) {
 	var components []*domain.SiteComponent
-	
+
 	// Use JSONB query to search in specifications
 	err := r.db.Where("site_id = ? AND specifications->>? = ?", siteID, key, value).
 		Find(&components).Error
-	
+
 	return components, err
 }
 
@@ -105,10 +108,27 @@ func (r *componentRepository) BulkCreate(components []*domain.SiteComponent) err
 		if end > len(components) {
 			end = len(components)
 		}
-		
+
 		if err := r.db.Create(components[i:end]).Error; err != nil {
 			return err
 		}
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// GetUnembedded finds components without a real embedding. Components
+// created before components were embedded hold a zero vector.
+func (r *componentRepository) GetUnembedded(limit int) ([]*domain.SiteComponent, error) {
+	var components []*domain.SiteComponent
+
+	err := r.db.Where("embedding IS NULL OR vector_norm(embedding) = 0").
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&components).Error
+
+	return components, err
+}
+
+func (r *componentRepository) UpdateEmbedding(id uuid.UUID, embedding pgvector.Vector) error {
+	return r.db.Model(&domain.SiteComponent{}).Where("id = ?", id).Update("embedding", embedding).Error
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ConversationRepository interface {
+	Create(conversation *domain.Conversation) error
+	GetByID(id uuid.UUID) (*domain.Conversation, error)
+	GetWithTurns(id uuid.UUID) (*domain.Conversation, error)
+	// ListBySite lists a user's conversations on a site
+	ListBySite(siteID, userID uuid.UUID, pagination *domain.Pagination) ([]*domain.Conversation, error)
+	Update(id uuid.UUID, updates map[string]interface{}) error
+	RecentTurns(conversationID uuid.UUID, limit int) ([]*domain.UserQuery, error)
+}
+
+type conversationRepository struct {
+	*BaseRepository
+}
+
+func NewConversationRepository(db *gorm.DB) ConversationRepository {
+	return &conversationRepository{
+		BaseRepository: NewBaseRepository(db),
+	}
+}
+
+func (r *conversationRepository) Create(conversation *domain.Conversation) error {
+	return r.db.Create(conversation).Error
+}
+
+func (r *conversationRepository) GetByID(id uuid.UUID) (*domain.Conversation, error) {
+	var conversation domain.Conversation
+	err := r.db.First(&conversation, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// GetWithTurns loads a conversation with its turns in order, each with the
+// sources its answer cited
+func (r *conversationRepository) GetWithTurns(id uuid.UUID) (*domain.Conversation, error) {
+	var conversation domain.Conversation
+	err := r.db.
+		Preload("Turns", func(db *gorm.DB) *gorm.DB {
+			return db.Omit("embedding").Order("turn_index ASC")
+		}).
+		Preload("Turns.QuerySources", orderedSources).
+		First(&conversation, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+func (r *conversationRepository) ListBySite(siteID, userID uuid.UUID, pagination *domain.Pagination) ([]*domain.Conversation, error) {
+	var conversations []*domain.Conversation
+
+	query := r.db.Model(&domain.Conversation{}).Where("site_id = ? AND user_id = ?", siteID, userID)
+
+	// Count total for pagination
+	count, err := r.CountTotal(query, &domain.Conversation{})
+	if err != nil {
+		return nil, err
+	}
+	pagination.SetTotalPages(count)
+
+	// Apply pagination and get results
+	query = r.BuildQuery(query, pagination)
+	if pagination.Sort == "" {
+		query = query.Order("updated_at DESC")
+	}
+
+	err = query.Find(&conversations).Error
+
+	return conversations, err
+}
+
+func (r *conversationRepository) Update(id uuid.UUID, updates map[string]interface{}) error {
+	return r.db.Model(&domain.Conversation{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// RecentTurns returns the last limit turns of a conversation, oldest first,
+// with their sources
+func (r *conversationRepository) RecentTurns(conversationID uuid.UUID, limit int) ([]*domain.UserQuery, error) {
+	var turns []*domain.UserQuery
+
+	err := r.db.Omit("embedding").
+		Where("conversation_id = ?", conversationID).
+		Order("turn_index DESC").
+		Limit(limit).
+		Preload("QuerySources", orderedSources).
+		Find(&turns).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(turns)-1; i < j; i, j = i+1, j-1 {
+		turns[i], turns[j] = turns[j], turns[i]
+	}
+	return turns, nil
+}
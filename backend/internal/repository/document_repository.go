@@ -2,11 +2,13 @@ package repository
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/engramiq/engramiq-backend/internal/domain"
 	"github.com/google/uuid"
 	"github.com/pgvector/pgvector-go"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type DocumentRepository interface {
@@ -15,11 +17,21 @@ type DocumentRepository interface {
 	ListBySite(siteID uuid.UUID, pagination *domain.Pagination, filters map[string]interface{}) ([]*domain.DocumentWithStats, error)
 	Update(id uuid.UUID, updates map[string]interface{}) error
 	Delete(id uuid.UUID) error
-	GetByContentHash(hash string) (*domain.Document, error)
+	// GetByContentHash finds a site's copy of a file, so identical uploads
+	// to different sites stay separate documents
+	GetByContentHash(siteID uuid.UUID, hash string) (*domain.Document, error)
 	UpdateProcessingStatus(id uuid.UUID, status domain.ProcessingStatus) error
+	// ClaimPending moves a pending document to processing and reports
+	// whether this caller was the one that moved it
+	ClaimPending(id uuid.UUID) (bool, error)
 	SearchFullText(siteID uuid.UUID, query string, limit int) ([]*domain.Document, error)
 	SearchSemantic(siteID uuid.UUID, embedding pgvector.Vector, limit int, threshold float64) ([]*domain.Document, error)
 	GetPendingProcessing(limit int) ([]*domain.Document, error)
+	GetStaleProcessing(startedBefore time.Time, limit int) ([]*domain.Document, error)
+	GetDueRetries(now time.Time, limit int) ([]*domain.Document, error)
+	ListFailed(siteID uuid.UUID, statuses []domain.ProcessingStatus, pagination *domain.Pagination) ([]*domain.Document, error)
+	ListByParent(parentID uuid.UUID) ([]*domain.Document, error)
+	GetUnchunked(limit int) ([]*domain.Document, error)
 }
 
 type documentRepository struct {
@@ -47,7 +59,7 @@ func (r *documentRepository) GetByID(id uuid.UUID) (*domain.Document, error) {
 
 func (r *documentRepository) ListBySite(siteID uuid.UUID, pagination *domain.Pagination, filters map[string]interface{}) ([]*domain.DocumentWithStats, error) {
 	var documents []*domain.DocumentWithStats
-	
+
 	query := `
 		SELECT 
 			d.*,
@@ -56,48 +68,48 @@ func (r *documentRepository) ListBySite(siteID uuid.UUID, pagination *domain.Pag
 		LEFT JOIN extracted_actions ea ON d.id = ea.document_id
 		WHERE d.site_id = ?
 	`
-	
+
 	args := []interface{}{siteID}
-	
+
 	// Add filters
 	if docType, ok := filters["document_type"].(string); ok && docType != "" {
 		query += " AND d.document_type = ?"
 		args = append(args, docType)
 	}
-	
+
 	if status, ok := filters["processing_status"].(string); ok && status != "" {
 		query += " AND d.processing_status = ?"
 		args = append(args, status)
 	}
-	
+
 	query += " GROUP BY d.id"
-	
+
 	// Add ordering and pagination
 	if pagination.Sort != "" {
 		query += fmt.Sprintf(" ORDER BY d.%s", pagination.Sort)
 	} else {
 		query += " ORDER BY d.created_at DESC"
 	}
-	
+
 	if pagination.Limit > 0 {
 		query += fmt.Sprintf(" LIMIT %d OFFSET %d", pagination.Limit, pagination.GetOffset())
 	}
-	
+
 	err := r.db.Raw(query, args...).Scan(&documents).Error
-	
+
 	// Count total for pagination
 	countQuery := "SELECT COUNT(DISTINCT d.id) FROM documents d WHERE d.site_id = ?"
 	countArgs := []interface{}{siteID}
-	
+
 	if docType, ok := filters["document_type"].(string); ok && docType != "" {
 		countQuery += " AND d.document_type = ?"
 		countArgs = append(countArgs, docType)
 	}
-	
+
 	var count int64
 	r.db.Raw(countQuery, countArgs...).Scan(&count)
 	pagination.SetTotalPages(count)
-	
+
 	return documents, err
 }
 
@@ -109,9 +121,9 @@ func (r *documentRepository) Delete(id uuid.UUID) error {
 	return r.db.Delete(&domain.Document{}, "id = ?", id).Error
 }
 
-func (r *documentRepository) GetByContentHash(hash string) (*domain.Document, error) {
+func (r *documentRepository) GetByContentHash(siteID uuid.UUID, hash string) (*domain.Document, error) {
 	var document domain.Document
-	err := r.db.First(&document, "content_hash = ?", hash).Error
+	err := r.db.First(&document, "site_id = ? AND content_hash = ?", siteID, hash).Error
 	if err != nil {
 		return nil, err
 	}
@@ -122,32 +134,49 @@ func (r *documentRepository) UpdateProcessingStatus(id uuid.UUID, status domain.
 	updates := map[string]interface{}{
 		"processing_status": status,
 	}
-	
+
 	if status == domain.ProcessingStatusProcessing {
-		updates["processing_started_at"] = "NOW()"
-	} else if status == domain.ProcessingStatusCompleted || status == domain.ProcessingStatusFailed {
-		updates["processing_completed_at"] = "NOW()"
+		updates["processing_started_at"] = time.Now()
+	} else if status == domain.ProcessingStatusCompleted || status == domain.ProcessingStatusFailed || status == domain.ProcessingStatusDeadLetter {
+		updates["processing_completed_at"] = time.Now()
 	}
-	
+
 	return r.db.Model(&domain.Document{}).Where("id = ?", id).Updates(updates).Error
 }
 
+func (r *documentRepository) ClaimPending(id uuid.UUID) (bool, error) {
+	result := r.db.Model(&domain.Document{}).
+		Where("id = ? AND processing_status = ?", id, domain.ProcessingStatusPending).
+		Updates(map[string]interface{}{
+			"processing_status":     domain.ProcessingStatusProcessing,
+			"processing_started_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
 func (r *documentRepository) SearchFullText(siteID uuid.UUID, query string, limit int) ([]*domain.Document, error) {
 	var documents []*domain.Document
-	
+
 	// Use PostgreSQL full-text search with computed tsvector
 	err := r.db.Where("site_id = ?", siteID).
 		Where("to_tsvector('english', COALESCE(title, '') || ' ' || COALESCE(processed_content, '')) @@ plainto_tsquery('english', ?)", query).
-		Order(fmt.Sprintf("ts_rank(to_tsvector('english', COALESCE(title, '') || ' ' || COALESCE(processed_content, '')), plainto_tsquery('english', '%s')) DESC", query)).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "ts_rank(to_tsvector('english', COALESCE(title, '') || ' ' || COALESCE(processed_content, '')), plainto_tsquery('english', ?)) DESC",
+			Vars:               []interface{}{query},
+			WithoutParentheses: true,
+		}}).
 		Limit(limit).
 		Find(&documents).Error
-	
+
 	return documents, err
 }
 
 func (r *documentRepository) SearchSemantic(siteID uuid.UUID, embedding pgvector.Vector, limit int, threshold float64) ([]*domain.Document, error) {
 	var documents []*domain.Document
-	
+
 	// Use pgvector for semantic similarity search
 	// Explicitly select all fields including content fields
 	err := r.db.Select("*").
@@ -156,17 +185,91 @@ func (r *documentRepository) SearchSemantic(siteID uuid.UUID, embedding pgvector
 		Order(fmt.Sprintf("embedding <=> '%v'", embedding)).
 		Limit(limit).
 		Find(&documents).Error
-	
+
 	return documents, err
 }
 
 func (r *documentRepository) GetPendingProcessing(limit int) ([]*domain.Document, error) {
 	var documents []*domain.Document
-	
+
 	err := r.db.Where("processing_status = ?", domain.ProcessingStatusPending).
 		Order("created_at ASC").
 		Limit(limit).
 		Find(&documents).Error
-	
+
+	return documents, err
+}
+
+// GetStaleProcessing finds documents that were picked up by a worker but never
+// finished, e.g. because the process crashed or was restarted mid-processing
+func (r *documentRepository) GetStaleProcessing(startedBefore time.Time, limit int) ([]*domain.Document, error) {
+	var documents []*domain.Document
+
+	err := r.db.Where("processing_status = ?", domain.ProcessingStatusProcessing).
+		Where("processing_started_at IS NULL OR processing_started_at < ?", startedBefore).
+		Order("processing_started_at ASC").
+		Limit(limit).
+		Find(&documents).Error
+
+	return documents, err
+}
+
+// GetDueRetries finds failed documents whose backoff has elapsed
+func (r *documentRepository) GetDueRetries(now time.Time, limit int) ([]*domain.Document, error) {
+	var documents []*domain.Document
+
+	err := r.db.Where("processing_status = ?", domain.ProcessingStatusFailed).
+		Where("next_retry_at IS NOT NULL AND next_retry_at <= ?", now).
+		Order("next_retry_at ASC").
+		Limit(limit).
+		Find(&documents).Error
+
+	return documents, err
+}
+
+func (r *documentRepository) ListFailed(siteID uuid.UUID, statuses []domain.ProcessingStatus, pagination *domain.Pagination) ([]*domain.Document, error) {
+	var documents []*domain.Document
+
+	query := r.db.Model(&domain.Document{}).
+		Where("site_id = ? AND processing_status IN ?", siteID, statuses)
+
+	// Count total for pagination
+	count, err := r.CountTotal(query, &domain.Document{})
+	if err != nil {
+		return nil, err
+	}
+	pagination.SetTotalPages(count)
+
+	// Apply pagination and get results
+	query = r.BuildQuery(query, pagination)
+	err = query.Find(&documents).Error
+
+	return documents, err
+}
+
+func (r *documentRepository) ListByParent(parentID uuid.UUID) ([]*domain.Document, error) {
+	var documents []*domain.Document
+
+	err := r.db.Where("parent_document_id = ?", parentID).
+		Order("created_at ASC").
+		Find(&documents).Error
+
+	return documents, err
+}
+
+// GetUnchunked finds processed documents that were never chunked, i.e. those
+// processed before chunk-level retrieval was introduced. Documents chunked
+// before chunked_at existed are recognised by their chunks.
+func (r *documentRepository) GetUnchunked(limit int) ([]*domain.Document, error) {
+	var documents []*domain.Document
+
+	err := r.db.Where("processing_status = ?", domain.ProcessingStatusCompleted).
+		Where("COALESCE(processed_content, '') <> ''").
+		Where("chunked_at IS NULL").
+		Where("NOT EXISTS (SELECT 1 FROM document_chunks dc WHERE dc.document_id = documents.id)").
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&documents).Error
+
 	return documents, err
-}
\ No newline at end of file
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OrganizationRepository stores organizations, which sites and users belong
+// to them, and the site grants of individual users
+type OrganizationRepository interface {
+	Create(org *domain.Organization) error
+	GetByID(id uuid.UUID) (*domain.Organization, error)
+	GetBySlug(slug string) (*domain.Organization, error)
+	List(pagination *domain.Pagination) ([]*domain.Organization, error)
+	// AssignSite moves a site to an organization
+	AssignSite(siteID, orgID uuid.UUID) error
+	// AssignUser moves a user to an organization and drops their grants on
+	// sites outside it
+	AssignUser(userID, orgID uuid.UUID) error
+	SiteIDs(orgID uuid.UUID) ([]uuid.UUID, error)
+	Grant(grant *domain.SiteGrant) error
+	Revoke(userID, siteID uuid.UUID) error
+	ListGrants(userID uuid.UUID) ([]*domain.SiteGrant, error)
+	// GrantedSiteIDs lists the sites a user is granted within an
+	// organization; grants on sites that have since left it don't count
+	GrantedSiteIDs(userID, orgID uuid.UUID) ([]uuid.UUID, error)
+	// SiteOf returns the site a record of model belongs to, reading only its
+	// site_id column
+	SiteOf(model interface{}, id uuid.UUID) (uuid.UUID, error)
+}
+
+type organizationRepository struct {
+	*BaseRepository
+}
+
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	return &organizationRepository{
+		BaseRepository: NewBaseRepository(db),
+	}
+}
+
+func (r *organizationRepository) Create(org *domain.Organization) error {
+	return r.db.Create(org).Error
+}
+
+func (r *organizationRepository) GetByID(id uuid.UUID) (*domain.Organization, error) {
+	var org domain.Organization
+	err := r.db.First(&org, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *organizationRepository) GetBySlug(slug string) (*domain.Organization, error) {
+	var org domain.Organization
+	err := r.db.First(&org, "slug = ?", slug).Error
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *organizationRepository) List(pagination *domain.Pagination) ([]*domain.Organization, error) {
+	var orgs []*domain.Organization
+
+	query := r.db.Model(&domain.Organization{})
+	count, err := r.CountTotal(query, &domain.Organization{})
+	if err != nil {
+		return nil, err
+	}
+	pagination.SetTotalPages(count)
+
+	err = r.BuildQuery(query.Order("name"), pagination).Find(&orgs).Error
+	return orgs, err
+}
+
+func (r *organizationRepository) AssignSite(siteID, orgID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.Site{}).Where("id = ?", siteID).
+			Update("organization_id", orgID).Error; err != nil {
+			return err
+		}
+		// Users of the old organization lose their grants on the site
+		return tx.Where("site_id = ? AND user_id NOT IN (?)", siteID,
+			tx.Model(&domain.User{}).Select("id").Where("organization_id = ?", orgID)).
+			Delete(&domain.SiteGrant{}).Error
+	})
+}
+
+func (r *organizationRepository) AssignUser(userID, orgID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.User{}).Where("id = ?", userID).
+			Update("organization_id", orgID).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND site_id NOT IN (?)", userID,
+			tx.Model(&domain.Site{}).Select("id").Where("organization_id = ?", orgID)).
+			Delete(&domain.SiteGrant{}).Error
+	})
+}
+
+func (r *organizationRepository) SiteIDs(orgID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&domain.Site{}).
+		Where("organization_id = ?", orgID).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (r *organizationRepository) Grant(grant *domain.SiteGrant) error {
+	// Granting twice is a no-op
+	return r.db.Where(domain.SiteGrant{UserID: grant.UserID, SiteID: grant.SiteID}).
+		FirstOrCreate(grant).Error
+}
+
+func (r *organizationRepository) Revoke(userID, siteID uuid.UUID) error {
+	return r.db.Delete(&domain.SiteGrant{}, "user_id = ? AND site_id = ?", userID, siteID).Error
+}
+
+func (r *organizationRepository) ListGrants(userID uuid.UUID) ([]*domain.SiteGrant, error) {
+	var grants []*domain.SiteGrant
+	err := r.db.Preload("Site").
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&grants).Error
+	return grants, err
+}
+
+func (r *organizationRepository) GrantedSiteIDs(userID, orgID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&domain.SiteGrant{}).
+		Joins("JOIN sites ON sites.id = user_site_grants.site_id AND sites.deleted_at IS NULL").
+		Where("user_site_grants.user_id = ? AND sites.organization_id = ?", userID, orgID).
+		Pluck("user_site_grants.site_id", &ids).Error
+	return ids, err
+}
+
+func (r *organizationRepository) SiteOf(model interface{}, id uuid.UUID) (uuid.UUID, error) {
+	var siteIDs []uuid.UUID
+	err := r.db.Model(model).Where("id = ?", id).Limit(1).Pluck("site_id", &siteIDs).Error
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if len(siteIDs) == 0 {
+		return uuid.Nil, gorm.ErrRecordNotFound
+	}
+	return siteIDs[0], nil
+}
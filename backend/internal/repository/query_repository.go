@@ -8,19 +8,29 @@ import (
 	"github.com/google/uuid"
 	"github.com/pgvector/pgvector-go"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type QueryRepository interface {
 	Create(query *domain.UserQuery) error
+	// CreateTurn saves a query as the next turn of its conversation,
+	// numbering it after the turns already saved
+	CreateTurn(query *domain.UserQuery) error
 	GetByID(id uuid.UUID) (*domain.UserQuery, error)
 	ListBySite(siteID uuid.UUID, pagination *domain.Pagination, filters map[string]interface{}) ([]*domain.UserQuery, error)
-	ListByUser(userID uuid.UUID, pagination *domain.Pagination) ([]*domain.UserQuery, error)
+	// ListByUser lists a user's queries on the sites in scope
+	ListByUser(userID uuid.UUID, scope domain.SiteScope, pagination *domain.Pagination) ([]*domain.UserQuery, error)
 	Update(id uuid.UUID, updates map[string]interface{}) error
 	Delete(id uuid.UUID) error
 	UpdateResults(id uuid.UUID, results domain.JSON, resultCount int) error
 	GetRecentQueries(siteID uuid.UUID, limit int) ([]*domain.UserQuery, error)
 	SearchSimilarQueries(siteID uuid.UUID, embedding pgvector.Vector, limit int, threshold float64) ([]*domain.UserQuery, error)
+	CreateAnalytics(analytics *domain.QueryAnalytics) error
+	// GetQueryAnalytics aggregates the analytics records of queries run in
+	// [startDate, endDate)
 	GetQueryAnalytics(siteID uuid.UUID, startDate, endDate time.Time) (*domain.QueryAnalytics, error)
+	CreateSources(sources []*domain.QuerySource) error
+	ListSources(queryID uuid.UUID) ([]*domain.QuerySource, error)
 }
 
 type queryRepository struct {
@@ -37,9 +47,35 @@ func (r *queryRepository) Create(query *domain.UserQuery) error {
 	return r.db.Create(query).Error
 }
 
+func (r *queryRepository) CreateTurn(query *domain.UserQuery) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Locking the conversation serialises concurrent follow-ups, so two
+		// can't take the same turn index
+		var conversation domain.Conversation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			First(&conversation, "id = ?", *query.ConversationID).Error; err != nil {
+			return err
+		}
+
+		var next int
+		if err := tx.Model(&domain.UserQuery{}).
+			Select("COALESCE(MAX(turn_index) + 1, 0)").
+			Where("conversation_id = ?", *query.ConversationID).
+			Scan(&next).Error; err != nil {
+			return err
+		}
+		query.TurnIndex = next
+
+		return tx.Create(query).Error
+	})
+}
+
 func (r *queryRepository) GetByID(id uuid.UUID) (*domain.UserQuery, error) {
 	var query domain.UserQuery
-	err := r.db.Preload("Site").First(&query, "id = ?", id).Error
+	err := r.db.Preload("Site").
+		Preload("QuerySources", orderedSources).
+		First(&query, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -48,19 +84,19 @@ func (r *queryRepository) GetByID(id uuid.UUID) (*domain.UserQuery, error) {
 
 func (r *queryRepository) ListBySite(siteID uuid.UUID, pagination *domain.Pagination, filters map[string]interface{}) ([]*domain.UserQuery, error) {
 	var queries []*domain.UserQuery
-	
+
 	query := r.db.Model(&domain.UserQuery{}).Where("site_id = ?", siteID)
 	query = r.ApplyFilters(query, filters)
-	
+
 	// Additional query-specific filters
 	if userID, ok := filters["user_id"].(uuid.UUID); ok {
 		query = query.Where("user_id = ?", userID)
 	}
-	
+
 	if queryType, ok := filters["query_type"].(domain.QueryType); ok {
 		query = query.Where("query_type = ?", queryType)
 	}
-	
+
 	if hasResults, ok := filters["has_results"].(bool); ok {
 		if hasResults {
 			query = query.Where("result_count > 0")
@@ -68,47 +104,47 @@ func (r *queryRepository) ListBySite(siteID uuid.UUID, pagination *domain.Pagina
 			query = query.Where("result_count = 0 OR result_count IS NULL")
 		}
 	}
-	
+
 	// Count total for pagination
 	count, err := r.CountTotal(query, &domain.UserQuery{})
 	if err != nil {
 		return nil, err
 	}
 	pagination.SetTotalPages(count)
-	
+
 	// Apply pagination and get results
 	query = r.BuildQuery(query, pagination)
 	if pagination.Sort == "" {
 		query = query.Order("created_at DESC")
 	}
-	
+
 	err = query.Find(&queries).Error
-	
+
 	return queries, err
 }
 
-func (r *queryRepository) ListByUser(userID uuid.UUID, pagination *domain.Pagination) ([]*domain.UserQuery, error) {
+func (r *queryRepository) ListByUser(userID uuid.UUID, scope domain.SiteScope, pagination *domain.Pagination) ([]*domain.UserQuery, error) {
 	var queries []*domain.UserQuery
-	
-	query := r.db.Model(&domain.UserQuery{}).
+
+	query := scopeSites(r.db.Model(&domain.UserQuery{}), scope, "site_id").
 		Where("user_id = ?", userID).
 		Preload("Site")
-	
+
 	// Count total for pagination
 	count, err := r.CountTotal(query, &domain.UserQuery{})
 	if err != nil {
 		return nil, err
 	}
 	pagination.SetTotalPages(count)
-	
+
 	// Apply pagination and get results
 	query = r.BuildQuery(query, pagination)
 	if pagination.Sort == "" {
 		query = query.Order("created_at DESC")
 	}
-	
+
 	err = query.Find(&queries).Error
-	
+
 	return queries, err
 }
 
@@ -126,83 +162,147 @@ func (r *queryRepository) UpdateResults(id uuid.UUID, results domain.JSON, resul
 		"result_count": resultCount,
 		"processed_at": time.Now(),
 	}
-	
+
 	return r.db.Model(&domain.UserQuery{}).Where("id = ?", id).Updates(updates).Error
 }
 
 func (r *queryRepository) GetRecentQueries(siteID uuid.UUID, limit int) ([]*domain.UserQuery, error) {
 	var queries []*domain.UserQuery
-	
+
 	err := r.db.Where("site_id = ?", siteID).
 		Where("result_count > 0").
 		Order("created_at DESC").
 		Limit(limit).
 		Find(&queries).Error
-	
+
 	return queries, err
 }
 
 func (r *queryRepository) SearchSimilarQueries(siteID uuid.UUID, embedding pgvector.Vector, limit int, threshold float64) ([]*domain.UserQuery, error) {
 	var queries []*domain.UserQuery
-	
+
 	err := r.db.Where("site_id = ?", siteID).
 		Where("embedding <=> ? < ?", embedding, threshold).
 		Order(fmt.Sprintf("embedding <=> '%v'", embedding)).
 		Limit(limit).
 		Find(&queries).Error
-	
+
 	return queries, err
 }
 
+func (r *queryRepository) CreateAnalytics(analytics *domain.QueryAnalytics) error {
+	return r.db.Create(analytics).Error
+}
+
+// queryAnalyticsTotals aggregates query_analytics rows; a query that was
+// neither answered nor rejected failed
+const queryAnalyticsTotals = `COUNT(*) AS total_queries,
+	COUNT(*) FILTER (WHERE response_generated) AS successful_queries,
+	COUNT(*) FILTER (WHERE rejected) AS rejected_queries,
+	COUNT(*) FILTER (WHERE NOT response_generated AND NOT rejected) AS failed_queries,
+	COALESCE(AVG(execution_time_ms), 0) AS average_response_time,
+	COALESCE(AVG(search_time_ms) FILTER (WHERE NOT rejected), 0) AS average_search_time,
+	COALESCE(AVG(llm_time_ms) FILTER (WHERE NOT rejected), 0) AS average_llm_time`
+
 func (r *queryRepository) GetQueryAnalytics(siteID uuid.UUID, startDate, endDate time.Time) (*domain.QueryAnalytics, error) {
-	var analytics domain.QueryAnalytics
-	
-	// Total queries count
-	r.db.Model(&domain.UserQuery{}).
-		Where("site_id = ? AND created_at BETWEEN ? AND ?", siteID, startDate, endDate).
-		Count(&analytics.TotalQueries)
-	
-	// Successful queries (with results)
-	r.db.Model(&domain.UserQuery{}).
-		Where("site_id = ? AND created_at BETWEEN ? AND ? AND result_count > 0", siteID, startDate, endDate).
-		Count(&analytics.SuccessfulQueries)
-	
+	analytics := domain.QueryAnalytics{SiteID: siteID}
+	inRange := func() *gorm.DB {
+		return r.db.Model(&domain.QueryAnalytics{}).
+			Where("site_id = ? AND created_at >= ? AND created_at < ?", siteID, startDate, endDate)
+	}
+
+	// Totals and average stage timings
+	var totals domain.QueryAnalyticsDay
+	if err := inRange().Select(queryAnalyticsTotals).Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	var cached int64
+	if err := inRange().Where("cached").Count(&cached).Error; err != nil {
+		return nil, err
+	}
+	analytics.TotalQueries = totals.TotalQueries
+	analytics.SuccessfulQueries = totals.SuccessfulQueries
+	analytics.RejectedQueries = totals.RejectedQueries
+	analytics.FailedQueries = totals.FailedQueries
+	analytics.CachedQueries = cached
+	analytics.AverageResponseTime = totals.AverageResponseTime
+	analytics.AverageSearchTime = totals.AverageSearchTime
+	analytics.AverageLLMTime = totals.AverageLLMTime
+
 	// Calculate success rate
 	if analytics.TotalQueries > 0 {
 		analytics.SuccessRate = float64(analytics.SuccessfulQueries) / float64(analytics.TotalQueries) * 100
 	}
-	
-	// Average response time
-	var avgResponseTime struct {
-		AvgTime *float64
-	}
-	r.db.Model(&domain.UserQuery{}).
-		Select("AVG(EXTRACT(EPOCH FROM (processed_at - created_at))) as avg_time").
-		Where("site_id = ? AND created_at BETWEEN ? AND ? AND processed_at IS NOT NULL", siteID, startDate, endDate).
-		Scan(&avgResponseTime)
-	
-	if avgResponseTime.AvgTime != nil {
-		analytics.AverageResponseTime = *avgResponseTime.AvgTime
-	}
-	
-	// Most common query types
-	var queryTypeStats []struct {
-		QueryType string `json:"query_type"`
-		Count     int64  `json:"count"`
-	}
-	
-	r.db.Model(&domain.UserQuery{}).
-		Select("query_type, COUNT(*) as count").
-		Where("site_id = ? AND created_at BETWEEN ? AND ?", siteID, startDate, endDate).
+
+	// Queries per day
+	analytics.DailyBreakdown = []domain.QueryAnalyticsDay{}
+	err := inRange().
+		Select("to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS date, " + queryAnalyticsTotals).
+		Group("date").
+		Order("date ASC").
+		Scan(&analytics.DailyBreakdown).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Answered queries by type, and rejected queries by reason
+	var counts []struct {
+		Key   string
+		Count int64
+	}
+	err = inRange().
+		Select("query_type AS key, COUNT(*) AS count").
+		Where("response_generated").
 		Group("query_type").
 		Order("count DESC").
-		Limit(10).
-		Scan(&queryTypeStats)
-	
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
 	analytics.QueryTypeBreakdown = make(map[string]int64)
-	for _, stat := range queryTypeStats {
-		analytics.QueryTypeBreakdown[stat.QueryType] = stat.Count
+	for _, stat := range counts {
+		analytics.QueryTypeBreakdown[stat.Key] = stat.Count
 	}
-	
+
+	counts = nil
+	err = inRange().
+		Select("rejection_reason AS key, COUNT(*) AS count").
+		Where("rejected").
+		Group("rejection_reason").
+		Order("count DESC").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	analytics.RejectionReasonBreakdown = make(map[string]int64)
+	for _, stat := range counts {
+		analytics.RejectionReasonBreakdown[stat.Key] = stat.Count
+	}
+
 	return &analytics, nil
-}
\ No newline at end of file
+}
+
+func (r *queryRepository) CreateSources(sources []*domain.QuerySource) error {
+	if len(sources) == 0 {
+		return nil
+	}
+	return r.db.Create(sources).Error
+}
+
+func (r *queryRepository) ListSources(queryID uuid.UUID) ([]*domain.QuerySource, error) {
+	var sources []*domain.QuerySource
+	err := orderedSources(r.db.Where("query_id = ?", queryID)).Find(&sources).Error
+	return sources, err
+}
+
+// orderedSources lists a query's sources in citation order and loads their
+// documents without the content columns, and the actions action sources quote
+func orderedSources(db *gorm.DB) *gorm.DB {
+	return db.Order("source_index ASC").
+		Preload("Document", func(db *gorm.DB) *gorm.DB {
+			return db.Omit("raw_content", "processed_content", "embedding", "content_vector")
+		}).
+		Preload("Action", func(db *gorm.DB) *gorm.DB {
+			return db.Omit("embedding")
+		})
+}
@@ -15,7 +15,7 @@ type SiteRepository interface {
 	Delete(id uuid.UUID) error
 	GetWithDetails(id uuid.UUID) (*domain.SiteWithDetails, error)
 	// Convenience methods for API handlers
-	GetSites(page, limit int) ([]*domain.Site, int64, error)
+	GetSites(scope domain.SiteScope, page, limit int) ([]*domain.Site, int64, error)
 	GetSite(siteID string) (*domain.Site, error)
 }
 
@@ -53,21 +53,21 @@ func (r *siteRepository) GetBySiteCode(siteCode string) (*domain.Site, error) {
 
 func (r *siteRepository) List(pagination *domain.Pagination, filters map[string]interface{}) ([]*domain.Site, error) {
 	var sites []*domain.Site
-	
+
 	query := r.db.Model(&domain.Site{})
 	query = r.ApplyFilters(query, filters)
-	
+
 	// Count total for pagination
 	count, err := r.CountTotal(query, &domain.Site{})
 	if err != nil {
 		return nil, err
 	}
 	pagination.SetTotalPages(count)
-	
+
 	// Apply pagination and get results
 	query = r.BuildQuery(query, pagination)
 	err = query.Find(&sites).Error
-	
+
 	return sites, err
 }
 
@@ -89,7 +89,7 @@ func (r *siteRepository) GetWithDetails(id uuid.UUID) (*domain.SiteWithDetails,
 	// Get component summary
 	var componentSummary struct {
 		Inverters       int64
-		Combiners       int64 
+		Combiners       int64
 		TotalComponents int64
 	}
 
@@ -98,7 +98,7 @@ func (r *siteRepository) GetWithDetails(id uuid.UUID) (*domain.SiteWithDetails,
 		Where("site_id = ? AND component_type = ?", id, "inverter").
 		Count(&componentSummary.Inverters)
 
-	// Count combiners  
+	// Count combiners
 	r.db.Model(&domain.SiteComponent{}).
 		Where("site_id = ? AND component_type = ?", id, "combiner").
 		Count(&componentSummary.Combiners)
@@ -126,17 +126,26 @@ func (r *siteRepository) GetWithDetails(id uuid.UUID) (*domain.SiteWithDetails,
 }
 
 // Convenience methods for API handlers
-func (r *siteRepository) GetSites(page, limit int) ([]*domain.Site, int64, error) {
+func (r *siteRepository) GetSites(scope domain.SiteScope, page, limit int) ([]*domain.Site, int64, error) {
 	pagination := &domain.Pagination{
 		Page:  page,
 		Limit: limit,
 	}
-	
-	sites, err := r.List(pagination, nil)
+
+	var sites []*domain.Site
+	query := scopeSites(r.db.Model(&domain.Site{}), scope, "id")
+
+	count, err := r.CountTotal(query, &domain.Site{})
+	if err != nil {
+		return nil, 0, err
+	}
+	pagination.SetTotalPages(count)
+
+	err = r.BuildQuery(query, pagination).Find(&sites).Error
 	if err != nil {
 		return nil, 0, err
 	}
-	
+
 	return sites, pagination.TotalItems, nil
 }
 
@@ -145,7 +154,7 @@ func (r *siteRepository) GetSite(siteID string) (*domain.Site, error) {
 	if id, err := uuid.Parse(siteID); err == nil {
 		return r.GetByID(id)
 	}
-	
+
 	// If not a UUID, try as site code
 	return r.GetBySiteCode(siteID)
-}
\ No newline at end of file
+}
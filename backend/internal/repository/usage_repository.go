@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// usageTotalsColumns aggregates llm_usage rows into domain.LLMUsageTotals
+const usageTotalsColumns = `COUNT(*) AS calls,
+	COUNT(*) FILTER (WHERE NOT succeeded) AS failed_calls,
+	COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens,
+	COALESCE(SUM(completion_tokens), 0) AS completion_tokens,
+	COALESCE(SUM(total_tokens), 0) AS total_tokens,
+	COALESCE(SUM(estimated_cost_usd), 0) AS estimated_cost_usd,
+	COALESCE(AVG(latency_ms), 0) AS average_latency_ms`
+
+type UsageRepository interface {
+	Create(usage *domain.LLMUsage) error
+	// SiteReport totals a site's calls made in [start, end)
+	SiteReport(siteID uuid.UUID, start, end time.Time) (*domain.LLMUsageReport, error)
+	// TotalsBySite totals the calls made in [start, end) per site in scope,
+	// most expensive first. Calls made for no site have an empty key and are
+	// only included when the scope covers every site.
+	TotalsBySite(scope domain.SiteScope, start, end time.Time) ([]domain.LLMUsageTotals, error)
+}
+
+type usageRepository struct {
+	*BaseRepository
+}
+
+func NewUsageRepository(db *gorm.DB) UsageRepository {
+	return &usageRepository{
+		BaseRepository: NewBaseRepository(db),
+	}
+}
+
+func (r *usageRepository) Create(usage *domain.LLMUsage) error {
+	return r.db.Create(usage).Error
+}
+
+func (r *usageRepository) SiteReport(siteID uuid.UUID, start, end time.Time) (*domain.LLMUsageReport, error) {
+	report := &domain.LLMUsageReport{SiteID: siteID}
+	site := func() *gorm.DB {
+		return r.db.Model(&domain.LLMUsage{}).
+			Where("site_id = ? AND created_at >= ? AND created_at < ?", siteID, start, end)
+	}
+
+	if err := site().Select("'total' AS key, " + usageTotalsColumns).Scan(&report.Total).Error; err != nil {
+		return nil, err
+	}
+
+	groups := []struct {
+		key    string
+		order  string
+		totals *[]domain.LLMUsageTotals
+	}{
+		{"to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')", "key ASC", &report.ByDay},
+		{"operation", "estimated_cost_usd DESC, calls DESC", &report.ByOperation},
+		{"model", "estimated_cost_usd DESC, calls DESC", &report.ByModel},
+	}
+	for _, group := range groups {
+		*group.totals = []domain.LLMUsageTotals{}
+		err := site().
+			Select(group.key + " AS key, " + usageTotalsColumns).
+			Group("key").
+			Order(group.order).
+			Scan(group.totals).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+func (r *usageRepository) TotalsBySite(scope domain.SiteScope, start, end time.Time) ([]domain.LLMUsageTotals, error) {
+	totals := []domain.LLMUsageTotals{}
+
+	err := scopeSites(r.db.Model(&domain.LLMUsage{}), scope, "site_id").
+		Select("COALESCE(site_id::text, '') AS key, "+usageTotalsColumns).
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Group("key").
+		Order("estimated_cost_usd DESC, calls DESC").
+		Scan(&totals).Error
+
+	return totals, err
+}
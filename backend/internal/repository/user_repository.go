@@ -57,39 +57,43 @@ func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
 
 func (r *userRepository) List(pagination *domain.Pagination, filters map[string]interface{}) ([]*domain.User, error) {
 	var users []*domain.User
-	
+
 	query := r.db.Model(&domain.User{})
 	query = r.ApplyFilters(query, filters)
-	
+
 	// Additional user-specific filters
 	if role, ok := filters["role"].(domain.UserRole); ok {
 		query = query.Where("role = ?", role)
 	}
-	
+
+	if orgID, ok := filters["organization_id"].(uuid.UUID); ok {
+		query = query.Where("organization_id = ?", orgID)
+	}
+
 	if isActive, ok := filters["is_active"].(bool); ok {
 		query = query.Where("is_active = ?", isActive)
 	}
-	
+
 	// Search by name or email
 	if search, ok := filters["search"].(string); ok && search != "" {
 		query = r.ApplySearch(query, search, "full_name", "email")
 	}
-	
+
 	// Count total for pagination
 	count, err := r.CountTotal(query, &domain.User{})
 	if err != nil {
 		return nil, err
 	}
 	pagination.SetTotalPages(count)
-	
+
 	// Apply pagination and get results
 	query = r.BuildQuery(query, pagination)
 	if pagination.Sort == "" {
 		query = query.Order("created_at DESC")
 	}
-	
+
 	err = query.Find(&users).Error
-	
+
 	return users, err
 }
 
@@ -103,7 +107,7 @@ func (r *userRepository) Delete(id uuid.UUID) error {
 		if err := tx.Delete(&domain.UserSession{}, "user_id = ?", id).Error; err != nil {
 			return err
 		}
-		
+
 		// Delete the user
 		return tx.Delete(&domain.User{}, "id = ?", id).Error
 	})
@@ -113,7 +117,7 @@ func (r *userRepository) UpdateLastLogin(id uuid.UUID) error {
 	updates := map[string]interface{}{
 		"last_login_at": time.Now(),
 	}
-	
+
 	return r.db.Model(&domain.User{}).Where("id = ?", id).Updates(updates).Error
 }
 
@@ -141,4 +145,4 @@ func (r *userRepository) DeleteSession(id uuid.UUID) error {
 
 func (r *userRepository) DeleteExpiredSessions() error {
 	return r.db.Delete(&domain.UserSession{}, "expires_at <= ?", time.Now()).Error
-}
\ No newline at end of file
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/repository"
+	apperrors "github.com/engramiq/engramiq-backend/pkg/errors"
+	"github.com/engramiq/engramiq-backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// apiKeyTouchInterval is how stale a key's last-used time may get before a
+// request records it again, so busy clients don't write on every call
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyService issues and checks API keys for machine clients. Keys act as
+// the admin who created them, limited to the key's scopes and sites.
+type APIKeyService interface {
+	// Create issues a key for creator on sites within scope, the sites the
+	// creator may access; the secret is only returned here
+	Create(creator *domain.User, scope domain.SiteScope, req *domain.CreateAPIKeyRequest) (*domain.CreatedAPIKey, error)
+	// List returns the keys of the organizations admin administers
+	List(admin *domain.User) ([]*domain.APIKey, error)
+	// Revoke revokes a key of an organization admin administers
+	Revoke(admin *domain.User, id uuid.UUID) error
+	// Authenticate returns a key and the user it acts as
+	Authenticate(key string) (*domain.APIKey, *domain.User, error)
+}
+
+type apiKeyService struct {
+	keyRepo  repository.APIKeyRepository
+	userRepo repository.UserRepository
+	siteRepo repository.SiteRepository
+	log      *logger.Logger
+}
+
+func NewAPIKeyService(keyRepo repository.APIKeyRepository, userRepo repository.UserRepository, siteRepo repository.SiteRepository, log *logger.Logger) APIKeyService {
+	return &apiKeyService{
+		keyRepo:  keyRepo,
+		userRepo: userRepo,
+		siteRepo: siteRepo,
+		log:      log,
+	}
+}
+
+func (s *apiKeyService) Create(creator *domain.User, scope domain.SiteScope, req *domain.CreateAPIKeyRequest) (*domain.CreatedAPIKey, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, apperrors.NewBadRequest("API key name is required")
+	}
+	if len(req.Scopes) == 0 {
+		return nil, apperrors.NewBadRequest("At least one scope is required")
+	}
+	scopes := make(pq.StringArray, 0, len(req.Scopes))
+	for _, keyScope := range req.Scopes {
+		if !keyScope.Valid() {
+			return nil, apperrors.NewBadRequest(fmt.Sprintf("Unknown scope %q; use read, upload or query", keyScope))
+		}
+		scopes = append(scopes, string(keyScope))
+	}
+	if len(req.SiteIDs) == 0 {
+		return nil, apperrors.NewBadRequest("At least one site is required")
+	}
+	siteIDs := make(pq.StringArray, 0, len(req.SiteIDs))
+	for _, siteID := range req.SiteIDs {
+		// Sites of other organizations look the same as missing ones
+		if _, err := s.siteRepo.GetByID(siteID); err != nil || !scope.Includes(siteID) {
+			return nil, apperrors.NewBadRequest(fmt.Sprintf("Site %s not found", siteID))
+		}
+		siteIDs = append(siteIDs, siteID.String())
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, apperrors.NewBadRequest("expires_at must be in the future")
+	}
+
+	secret, err := newAPIKeySecret()
+	if err != nil {
+		return nil, err
+	}
+	key := &domain.APIKey{
+		ID:        uuid.New(),
+		Name:      req.Name,
+		Prefix:    secret[:len(domain.APIKeyPrefix)+8],
+		KeyHash:   hashToken(secret),
+		UserID:    creator.ID,
+		Scopes:    scopes,
+		SiteIDs:   siteIDs,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.keyRepo.Create(key); err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return &domain.CreatedAPIKey{APIKey: *key, Key: secret}, nil
+}
+
+func (s *apiKeyService) List(admin *domain.User) ([]*domain.APIKey, error) {
+	if admin.PlatformOperator {
+		return s.keyRepo.List()
+	}
+	if admin.OrganizationID == nil {
+		return []*domain.APIKey{}, nil
+	}
+	return s.keyRepo.ListByOrganization(*admin.OrganizationID)
+}
+
+func (s *apiKeyService) Revoke(admin *domain.User, id uuid.UUID) error {
+	key, err := s.keyRepo.GetByID(id)
+	if err != nil {
+		return apperrors.NewNotFound("API key", id.String())
+	}
+	// Keys whose creator is gone no longer work, so only operators see them
+	creator, err := s.userRepo.GetByID(key.UserID)
+	if !admin.PlatformOperator && (err != nil || !admin.Administers(creator.OrganizationID)) {
+		return apperrors.NewNotFound("API key", id.String())
+	}
+	return s.keyRepo.Delete(id)
+}
+
+func (s *apiKeyService) Authenticate(secret string) (*domain.APIKey, *domain.User, error) {
+	key, err := s.keyRepo.GetByHash(hashToken(secret))
+	if err != nil {
+		return nil, nil, apperrors.NewUnauthorized("Invalid API key")
+	}
+	now := time.Now()
+	if key.Expired(now) {
+		return nil, nil, apperrors.NewUnauthorized("API key has expired")
+	}
+
+	// Keys stop working as soon as their creator is deleted
+	user, err := s.userRepo.GetByID(key.UserID)
+	if err != nil {
+		return nil, nil, apperrors.NewUnauthorized("Invalid API key")
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.keyRepo.UpdateLastUsed(key.ID, now); err != nil {
+			s.log.Warnw("Failed to record API key use", "api_key_id", key.ID, "error", err)
+		} else {
+			key.LastUsedAt = &now
+		}
+	}
+
+	return key, user, nil
+}
+
+func newAPIKeySecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return domain.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
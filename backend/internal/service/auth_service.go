@@ -0,0 +1,242 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/repository"
+	apperrors "github.com/engramiq/engramiq-backend/pkg/errors"
+	"github.com/engramiq/engramiq-backend/pkg/jwt"
+	"github.com/engramiq/engramiq-backend/pkg/logger"
+	"github.com/engramiq/engramiq-backend/pkg/validator"
+	"github.com/google/uuid"
+)
+
+const (
+	tokenTypeAccess = "access"
+	tokenTypeBearer = "Bearer"
+)
+
+// AuthService signs users in with short-lived JWT access tokens and opaque
+// refresh tokens. A refresh token is exchanged for a new pair once; using it
+// again fails.
+type AuthService interface {
+	// Register creates a user and signs them in
+	Register(req *domain.RegisterRequest) (*domain.AuthResponse, string, error)
+	// Login returns an access token and a refresh token for the user
+	Login(req *domain.LoginRequest) (*domain.AuthResponse, string, error)
+	// Refresh exchanges a refresh token for a new access and refresh token
+	Refresh(refreshToken string) (*domain.AuthResponse, string, error)
+	// Logout revokes a refresh token
+	Logout(refreshToken string) error
+	// Authenticate returns the user an access token was issued to
+	Authenticate(accessToken string) (*domain.User, error)
+}
+
+// RefreshTokenStore keeps refresh tokens until they expire or are used.
+// The Redis cache implements it.
+type RefreshTokenStore interface {
+	SetRefreshToken(userID, token string, expiration time.Duration) error
+	// ConsumeRefreshToken returns the token's user ID and removes it; the
+	// ID is empty for a token that is unknown, expired or already used
+	ConsumeRefreshToken(token string) (string, error)
+	DeleteRefreshToken(token string) error
+}
+
+// AuthSettings configures the tokens AuthService issues
+type AuthSettings struct {
+	Secret          []byte
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// OperatorEmail names the account that registers as admin and platform
+	// operator; empty grants nobody more than viewer at registration
+	OperatorEmail string
+}
+
+type authService struct {
+	userRepo repository.UserRepository
+	tokens   RefreshTokenStore
+	settings AuthSettings
+	log      *logger.Logger
+	// dummyHash is compared against when the email is unknown, so a login
+	// takes as long whether or not the account exists
+	dummyHash string
+}
+
+func NewAuthService(userRepo repository.UserRepository, tokens RefreshTokenStore, settings AuthSettings, log *logger.Logger) AuthService {
+	dummy := &domain.User{}
+	dummy.SetPassword(uuid.NewString())
+
+	return &authService{
+		userRepo:  userRepo,
+		tokens:    tokens,
+		settings:  settings,
+		log:       log,
+		dummyHash: dummy.PasswordHash,
+	}
+}
+
+func (s *authService) Register(req *domain.RegisterRequest) (*domain.AuthResponse, string, error) {
+	req.Email = normalizeEmail(req.Email)
+	req.FullName = strings.TrimSpace(req.FullName)
+	if err := validator.ValidateStruct(req); err != nil {
+		return nil, "", err
+	}
+
+	if existing, err := s.userRepo.GetByEmail(req.Email); err == nil && existing != nil {
+		return nil, "", apperrors.NewConflict("An account with this email already exists")
+	}
+
+	// Only the configured operator account administers the platform from
+	// the start; everyone else starts as a viewer until an admin grants
+	// more. The unique email column lets just one registration claim it.
+	operator := s.settings.OperatorEmail != "" && req.Email == normalizeEmail(s.settings.OperatorEmail)
+	role := domain.UserRoleViewer
+	if operator {
+		role = domain.UserRoleAdmin
+	}
+
+	user := &domain.User{
+		ID:               uuid.New(),
+		Email:            req.Email,
+		FullName:         req.FullName,
+		Role:             role,
+		PlatformOperator: operator,
+	}
+	if err := user.SetPassword(req.Password); err != nil {
+		return nil, "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, "", fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return s.issueTokens(user)
+}
+
+func (s *authService) Login(req *domain.LoginRequest) (*domain.AuthResponse, string, error) {
+	req.Email = normalizeEmail(req.Email)
+	if err := validator.ValidateStruct(req); err != nil {
+		return nil, "", err
+	}
+
+	user, err := s.userRepo.GetByEmail(req.Email)
+	if err != nil || user == nil {
+		(&domain.User{PasswordHash: s.dummyHash}).CheckPassword(req.Password)
+		return nil, "", apperrors.NewUnauthorized("Invalid email or password")
+	}
+	if !user.CheckPassword(req.Password) {
+		return nil, "", apperrors.NewUnauthorized("Invalid email or password")
+	}
+
+	if err := s.userRepo.UpdateLastLogin(user.ID); err != nil {
+		s.log.Warnw("Failed to record login", "user_id", user.ID, "error", err)
+	}
+
+	return s.issueTokens(user)
+}
+
+func (s *authService) Refresh(refreshToken string) (*domain.AuthResponse, string, error) {
+	if refreshToken == "" {
+		return nil, "", apperrors.NewUnauthorized("Refresh token is required")
+	}
+
+	userID, err := s.tokens.ConsumeRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read refresh token: %w", err)
+	}
+	if userID == "" {
+		return nil, "", apperrors.NewUnauthorized("Invalid or expired refresh token")
+	}
+
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, "", apperrors.NewUnauthorized("Invalid or expired refresh token")
+	}
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, "", apperrors.NewUnauthorized("Invalid or expired refresh token")
+	}
+
+	return s.issueTokens(user)
+}
+
+func (s *authService) Logout(refreshToken string) error {
+	if refreshToken == "" {
+		return nil
+	}
+	return s.tokens.DeleteRefreshToken(hashToken(refreshToken))
+}
+
+func (s *authService) Authenticate(accessToken string) (*domain.User, error) {
+	var claims domain.TokenClaims
+	if err := jwt.Verify(accessToken, s.settings.Secret, &claims); err != nil {
+		if err == jwt.ErrExpired {
+			return nil, apperrors.NewUnauthorized("Access token has expired")
+		}
+		return nil, apperrors.NewUnauthorized("Invalid access token")
+	}
+	if claims.TokenType != tokenTypeAccess {
+		return nil, apperrors.NewUnauthorized("Invalid access token")
+	}
+
+	// Users deleted since the token was issued are locked out at once
+	user, err := s.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		return nil, apperrors.NewUnauthorized("Invalid access token")
+	}
+	return user, nil
+}
+
+// issueTokens signs an access token for user and stores a new refresh token
+func (s *authService) issueTokens(user *domain.User) (*domain.AuthResponse, string, error) {
+	now := time.Now()
+	accessToken, err := jwt.Sign(domain.TokenClaims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenType: tokenTypeAccess,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.settings.AccessTokenTTL).Unix(),
+	}, s.settings.Secret)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+	// Only a hash is stored, so the store can't be used to sign in
+	if err := s.tokens.SetRefreshToken(user.ID.String(), hashToken(refreshToken), s.settings.RefreshTokenTTL); err != nil {
+		return nil, "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &domain.AuthResponse{
+		User:        *user,
+		AccessToken: accessToken,
+		TokenType:   tokenTypeBearer,
+		ExpiresIn:   int(s.settings.AccessTokenTTL.Seconds()),
+	}, refreshToken, nil
+}
+
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+)
+
+// citationMarker matches [Source N] and [Sources N, M] in an answer
+const citationMarker = `\[(?i:sources?)\s+(\d+(?:\s*(?i:,|and|&)\s*\d+)*)\]`
+
+var (
+	sourceCitation = regexp.MustCompile(citationMarker)
+	citationNumber = regexp.MustCompile(`\d+`)
+
+	// A sentence ends at terminal punctuation, taking along the citation
+	// markers that follow it, or at a line break. The punctuation must be
+	// followed by whitespace so decimals like 3.5 don't end a sentence.
+	sentenceEnd = regexp.MustCompile(`[.!?]+(?:\s*` + citationMarker + `)*(?:\s+|$)|\s*\n\s*`)
+
+	spaceBeforePunctuation = regexp.MustCompile(`\s+([.,;:!?])`)
+)
+
+// citedSources returns the source numbers an answer cites as [Source N] or
+// [Sources N, M], in order and without numbers outside 1..sourceCount
+func citedSources(answer string, sourceCount int) []int {
+	seen := make(map[int]bool)
+	cited := []int{}
+	for _, match := range sourceCitation.FindAllStringSubmatch(answer, -1) {
+		for _, digits := range citationNumber.FindAllString(match[1], -1) {
+			number, err := strconv.Atoi(digits)
+			if err != nil || number < 1 || number > sourceCount || seen[number] {
+				continue
+			}
+			seen[number] = true
+			cited = append(cited, number)
+		}
+	}
+	sort.Ints(cited)
+	return cited
+}
+
+// answerSegments splits an answer into sentences, each with the sources its
+// [Source N] markers cite; sources[i] is Source i+1. Markers left on their
+// own, as in "It was replaced. [Source 2]", belong to the sentence before,
+// and list numbers such as "1." join the sentence after.
+func answerSegments(answer string, sources []domain.AnswerCitation) []domain.AnswerSegment {
+	segments := []domain.AnswerSegment{}
+	carried := ""
+	paragraph := false
+
+	boundaries := append(sentenceEnd.FindAllStringIndex(answer, -1), []int{len(answer), len(answer)})
+	start := 0
+	for _, boundary := range boundaries {
+		raw := answer[start:boundary[1]]
+		lineBreak := strings.Contains(answer[boundary[0]:boundary[1]], "\n")
+		start = boundary[1]
+
+		numbers := citedSources(raw, len(sources))
+		text := strings.TrimSpace(carried + " " + segmentText(raw))
+
+		if strings.IndexFunc(text, unicode.IsLetter) < 0 {
+			if len(numbers) > 0 && len(segments) > 0 {
+				last := &segments[len(segments)-1]
+				last.Citations = segmentCitations(append(citationIndexes(last.Citations), numbers...), sources)
+			} else {
+				carried = text
+			}
+			paragraph = paragraph || lineBreak
+			continue
+		}
+
+		segments = append(segments, domain.AnswerSegment{
+			Text:            text,
+			Citations:       segmentCitations(numbers, sources),
+			StartsParagraph: paragraph && len(segments) > 0,
+		})
+		carried = ""
+		paragraph = lineBreak
+	}
+
+	return segments
+}
+
+// segmentText strips the citation markers from a sentence
+func segmentText(raw string) string {
+	text := sourceCitation.ReplaceAllString(raw, "")
+	text = strings.Join(strings.Fields(text), " ")
+	return spaceBeforePunctuation.ReplaceAllString(text, "$1")
+}
+
+func citationIndexes(citations []domain.AnswerCitation) []int {
+	indexes := make([]int, len(citations))
+	for i, citation := range citations {
+		indexes[i] = citation.SourceIndex
+	}
+	return indexes
+}
+
+// segmentCitations resolves source numbers, in ascending order and once each
+func segmentCitations(numbers []int, sources []domain.AnswerCitation) []domain.AnswerCitation {
+	sort.Ints(numbers)
+	citations := []domain.AnswerCitation{}
+	for i, number := range numbers {
+		if i > 0 && numbers[i-1] == number {
+			continue
+		}
+		citations = append(citations, sources[number-1])
+	}
+	return citations
+}
+
+// detailCitations are the citations of the sources an answer was generated
+// from
+func detailCitations(sources []domain.QuerySourceDetail) []domain.AnswerCitation {
+	citations := make([]domain.AnswerCitation, len(sources))
+	for i, source := range sources {
+		citations[i] = domain.AnswerCitation{
+			SourceIndex:      i + 1,
+			DocumentID:       source.DocumentID,
+			PageNumber:       source.PageNumber,
+			SectionReference: source.SectionReference,
+			Citation:         source.Citation,
+		}
+	}
+	return citations
+}
+
+// storedCitations are the citations of a saved query's sources, which are
+// loaded in citation order
+func (s *queryService) storedCitations(sources []domain.QuerySource) []domain.AnswerCitation {
+	citations := make([]domain.AnswerCitation, len(sources))
+	for i, source := range sources {
+		citation := source.DocumentTitle
+		if source.Document != nil {
+			citation = s.sourceAttribution.FormatCitation(source.Document, source.PageNumber, source.SectionReference)
+		}
+		citations[i] = domain.AnswerCitation{
+			SourceIndex:      i + 1,
+			DocumentID:       source.DocumentID,
+			PageNumber:       source.PageNumber,
+			SectionReference: source.SectionReference,
+			Citation:         citation,
+		}
+	}
+	return citations
+}
+
+// segmentStoredAnswer fills in the segments of a saved query's answer
+func (s *queryService) segmentStoredAnswer(query *domain.UserQuery) {
+	if query.Answer == "" {
+		return
+	}
+	query.Segments = answerSegments(query.Answer, s.storedCitations(query.QuerySources))
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+)
+
+func TestCitedSources(t *testing.T) {
+	tests := []struct {
+		name        string
+		answer      string
+		sourceCount int
+		want        []int
+	}{
+		{
+			name:        "single markers in ascending order",
+			answer:      "INV001 tripped [Source 3] and was reset [Source 1].",
+			sourceCount: 3,
+			want:        []int{1, 3},
+		},
+		{
+			name:        "grouped markers with commas, and, ampersands",
+			answer:      "Both [Sources 1, 3] and [sources 2 and 4] agree [SOURCES 5 & 1].",
+			sourceCount: 5,
+			want:        []int{1, 2, 3, 4, 5},
+		},
+		{
+			name:        "out of range numbers are dropped",
+			answer:      "See [Source 0], [Source 4] and [Source 2].",
+			sourceCount: 3,
+			want:        []int{2},
+		},
+		{
+			name:        "duplicates are listed once",
+			answer:      "Fuse F3 [Source 2]. Fuse F4 [Sources 2, 2, 1].",
+			sourceCount: 2,
+			want:        []int{1, 2},
+		},
+		{
+			name:        "malformed markers are ignored",
+			answer:      "[Source] [Source one] [Source 1,] (Source 1) [Src 1] Source 1",
+			sourceCount: 3,
+			want:        []int{},
+		},
+		{
+			name:        "no sources",
+			answer:      "Replaced the fuse [Source 1].",
+			sourceCount: 0,
+			want:        []int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := citedSources(tt.answer, tt.sourceCount); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("citedSources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnswerSegments(t *testing.T) {
+	first := domain.AnswerCitation{SourceIndex: 1, Citation: "Inspection report, p. 2"}
+	second := domain.AnswerCitation{SourceIndex: 2, Citation: "Work order 118"}
+	sources := []domain.AnswerCitation{first, second}
+	none := []domain.AnswerCitation{}
+
+	tests := []struct {
+		name   string
+		answer string
+		want   []domain.AnswerSegment
+	}{
+		{
+			name:   "empty answer",
+			answer: "",
+			want:   []domain.AnswerSegment{},
+		},
+		{
+			name:   "markers before and after the full stop",
+			answer: "INV001 tripped [Source 1]. It was reset. [Source 2]",
+			want: []domain.AnswerSegment{
+				{Text: "INV001 tripped.", Citations: []domain.AnswerCitation{first}},
+				{Text: "It was reset.", Citations: []domain.AnswerCitation{second}},
+			},
+		},
+		{
+			name:   "out of range and duplicate markers",
+			answer: "Fuse F3 failed [Source 5] [Source 1] [Sources 1, 1].",
+			want: []domain.AnswerSegment{
+				{Text: "Fuse F3 failed.", Citations: []domain.AnswerCitation{first}},
+			},
+		},
+		{
+			name:   "malformed markers stay in the text uncited",
+			answer: "Fuse F3 failed [Source one].",
+			want: []domain.AnswerSegment{
+				{Text: "Fuse F3 failed [Source one].", Citations: none},
+			},
+		},
+		{
+			name:   "marker on its own line joins the sentence before",
+			answer: "It was replaced\n[Source 2]\nNothing else changed",
+			want: []domain.AnswerSegment{
+				{Text: "It was replaced", Citations: []domain.AnswerCitation{second}},
+				{Text: "Nothing else changed", Citations: none, StartsParagraph: true},
+			},
+		},
+		{
+			name:   "list numbers join the item they number",
+			answer: "Steps:\n1. Reset INV001 [Source 1].\n2. Check the fuses.",
+			want: []domain.AnswerSegment{
+				{Text: "Steps:", Citations: none},
+				{Text: "1. Reset INV001.", Citations: []domain.AnswerCitation{first}, StartsParagraph: true},
+				{Text: "2. Check the fuses.", Citations: none, StartsParagraph: true},
+			},
+		},
+		{
+			name:   "decimals don't end a sentence",
+			answer: "Output was 3.5 kW [Sources 2, 1].",
+			want: []domain.AnswerSegment{
+				{Text: "Output was 3.5 kW.", Citations: []domain.AnswerCitation{first, second}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := answerSegments(tt.answer, sources); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("answerSegments() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/pgvector/pgvector-go"
+)
+
+const (
+	// chunkSize is the target chunk length in bytes of ProcessedContent
+	// (roughly 400 tokens), small enough that an excerpt stays on one topic
+	chunkSize = 1600
+	// chunkOverlap is repeated at the start of the next chunk so a sentence
+	// cut at a boundary is still whole in one of the two chunks
+	chunkOverlap = 200
+)
+
+// chunkSpan is a byte range of ProcessedContent
+type chunkSpan struct {
+	start int
+	end   int
+}
+
+// chunkDocument splits a document's ProcessedContent into overlapping chunks,
+// tagging each with the page and section it starts in
+func chunkDocument(document *domain.Document) []*domain.DocumentChunk {
+	text := document.ProcessedContent
+
+	// Section headings, pages and speaker turns are the natural places to cut
+	var breaks []int
+	for _, section := range document.Sections() {
+		breaks = append(breaks, section.Offset)
+	}
+	for _, page := range document.Pages() {
+		breaks = append(breaks, page.Offset)
+	}
+	for _, turn := range document.SpeakerTurns() {
+		breaks = append(breaks, turn.Offset)
+	}
+	sort.Ints(breaks)
+
+	var chunks []*domain.DocumentChunk
+	for i, span := range splitIntoChunks(text, chunkSize, chunkOverlap, breaks) {
+		chunks = append(chunks, &domain.DocumentChunk{
+			DocumentID:       document.ID,
+			SiteID:           document.SiteID,
+			ChunkIndex:       i,
+			Content:          text[span.start:span.end],
+			StartOffset:      span.start,
+			EndOffset:        span.end,
+			PageNumber:       document.PageForRange(span.start, span.end),
+			SectionReference: truncateSectionReference(document.ReferenceForRange(span.start, span.end)),
+		})
+	}
+	return chunks
+}
+
+// splitIntoChunks returns chunk spans of at most size bytes. Each chunk ends
+// at the best boundary in its second half: a structural break, then a
+// paragraph, line, sentence and finally a word boundary.
+func splitIntoChunks(text string, size, overlap int, breaks []int) []chunkSpan {
+	var spans []chunkSpan
+
+	start := skipSpace(text, 0)
+	for start < len(text) {
+		end, structural := start+size, false
+		if end >= len(text) {
+			end = len(text)
+		} else {
+			end, structural = chunkBoundary(text, start, end, breaks)
+		}
+
+		// Leading whitespace was skipped; drop the trailing side too
+		trimmed := start + len(strings.TrimRightFunc(text[start:end], isSpaceRune))
+		if trimmed > start {
+			spans = append(spans, chunkSpan{start: start, end: trimmed})
+		}
+		if end >= len(text) {
+			break
+		}
+
+		// Step back for the overlap, but always make progress and start on
+		// a word rather than mid-way through one. Chunks cut at a heading or
+		// page don't overlap so they stay attributed to their own section.
+		next := end - overlap
+		if structural || next <= start {
+			next = end
+		} else {
+			next = wordStart(text, next, end)
+		}
+		start = skipSpace(text, next)
+	}
+	return spans
+}
+
+// chunkBoundary picks where a chunk starting at start should end, given a
+// hard limit of end, and reports whether it is a structural break
+func chunkBoundary(text string, start, end int, breaks []int) (int, bool) {
+	floor := start + (end-start)/2
+
+	// A heading or page starting inside the window begins the next chunk
+	for i := len(breaks) - 1; i >= 0; i-- {
+		if breaks[i] <= floor {
+			break
+		}
+		if breaks[i] < end {
+			return breaks[i], true
+		}
+	}
+
+	window := text[floor:end]
+	for _, sep := range []string{"\n\n", "\n", ". ", "? ", "! ", "; ", " "} {
+		if i := strings.LastIndex(window, sep); i >= 0 {
+			return floor + i + len(sep), false
+		}
+	}
+
+	// No whitespace at all (e.g. a long table cell); cut on a rune boundary
+	for end > start && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return end, false
+}
+
+// wordStart moves pos forward to the start of the next word, stopping at limit
+func wordStart(text string, pos, limit int) int {
+	for pos > 0 && pos < limit && !utf8.RuneStart(text[pos]) {
+		pos++
+	}
+	if pos == 0 || isSpaceByte(text[pos-1]) {
+		return pos
+	}
+	if i := strings.IndexAny(text[pos:limit], " \n\t"); i >= 0 {
+		return pos + i
+	}
+	return pos
+}
+
+func skipSpace(text string, pos int) int {
+	for pos < len(text) && isSpaceByte(text[pos]) {
+		pos++
+	}
+	return pos
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\t' || b == '\r'
+}
+
+func isSpaceRune(r rune) bool {
+	return r < utf8.RuneSelf && isSpaceByte(byte(r))
+}
+
+// chunkEmbeddingInput prefixes the chunk with its document title and section
+// so a chunk like "Replaced fuse F3" still embeds near "inverter 31 repair"
+func chunkEmbeddingInput(document *domain.Document, chunk *domain.DocumentChunk) string {
+	parts := []string{}
+	if document.Title != "" {
+		parts = append(parts, document.Title)
+	}
+	if chunk.SectionReference != "" {
+		parts = append(parts, chunk.SectionReference)
+	}
+	parts = append(parts, chunk.Content)
+	return strings.Join(parts, "\n\n")
+}
+
+// meanEmbedding averages chunk embeddings into a single normalised document
+// embedding, which avoids embedding the whole document in one request
+func meanEmbedding(embeddings []pgvector.Vector) pgvector.Vector {
+	if len(embeddings) == 0 {
+		return pgvector.Vector{}
+	}
+
+	sum := make([]float32, len(embeddings[0].Slice()))
+	for _, embedding := range embeddings {
+		for i, value := range embedding.Slice() {
+			if i < len(sum) {
+				sum[i] += value
+			}
+		}
+	}
+
+	var norm float64
+	for _, value := range sum {
+		norm += float64(value) * float64(value)
+	}
+	norm = math.Sqrt(norm)
+	if norm > 0 {
+		for i := range sum {
+			sum[i] = float32(float64(sum[i]) / norm)
+		}
+	}
+	return pgvector.NewVector(sum)
+}
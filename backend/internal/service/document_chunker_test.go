@@ -0,0 +1,143 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+)
+
+func TestSplitIntoChunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		size    int
+		overlap int
+		breaks  []int
+		want    []string
+	}{
+		{name: "empty input", text: "", size: 10, overlap: 2},
+		{name: "whitespace only", text: " \n\t\n ", size: 10, overlap: 2},
+		{
+			name:    "short text is one trimmed chunk",
+			text:    "\n  Inverter INV001 inspected  \n",
+			size:    100,
+			overlap: 20,
+			want:    []string{"Inverter INV001 inspected"},
+		},
+		{
+			name:    "overlap repeats the last word of the previous chunk",
+			text:    "one two three four five six",
+			size:    10,
+			overlap: 4,
+			want:    []string{"one two", "two three", "four five", "six"},
+		},
+		{
+			name:    "paragraph boundary wins over a later word boundary",
+			text:    "Fuse F3 failed.\n\nReplaced it today",
+			size:    24,
+			overlap: 0,
+			want:    []string{"Fuse F3 failed.", "Replaced it today"},
+		},
+		{
+			name:    "structural break ends the chunk without overlap",
+			text:    "Intro text here\n# Heading\nBody text",
+			size:    20,
+			overlap: 5,
+			breaks:  []int{16},
+			want:    []string{"Intro text here", "# Heading\nBody text"},
+		},
+		{
+			name:    "break in the first half of the window is ignored",
+			text:    "Intro\n# Heading text that runs on",
+			size:    30,
+			overlap: 0,
+			breaks:  []int{6},
+			want:    []string{"Intro\n# Heading text that", "runs on"},
+		},
+		{
+			name:    "text without spaces is cut on rune boundaries",
+			text:    "éééééé",
+			size:    5,
+			overlap: 0,
+			want:    []string{"éé", "éé", "éé"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, span := range splitIntoChunks(tt.text, tt.size, tt.overlap, tt.breaks) {
+				got = append(got, tt.text[span.start:span.end])
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("splitIntoChunks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitIntoChunksCoversText(t *testing.T) {
+	const size, overlap = 120, 30
+	text := strings.Repeat("The combiner box CB-05 was opened and every string fuse tested. ", 40)
+
+	spans := splitIntoChunks(text, size, overlap, nil)
+	if len(spans) < 2 {
+		t.Fatalf("splitIntoChunks() returned %d spans, want several", len(spans))
+	}
+
+	for i, span := range spans {
+		chunk := text[span.start:span.end]
+		if len(chunk) > size {
+			t.Errorf("span %d is %d bytes, want at most %d", i, len(chunk), size)
+		}
+		if strings.TrimSpace(chunk) != chunk {
+			t.Errorf("span %d = %q, want no surrounding whitespace", i, chunk)
+		}
+		if i == 0 {
+			continue
+		}
+
+		previous := spans[i-1]
+		if span.start <= previous.start {
+			t.Errorf("span %d starts at %d, want after %d", i, span.start, previous.start)
+		}
+		if span.start > previous.end+1 {
+			t.Errorf("span %d starts at %d, leaving a gap after %d", i, span.start, previous.end)
+		}
+		if previous.end-span.start > overlap {
+			t.Errorf("span %d overlaps the previous one by %d bytes, want at most %d", i, previous.end-span.start, overlap)
+		}
+		if text[span.start-1] != ' ' {
+			t.Errorf("span %d = %q, want it to start on a word", i, chunk)
+		}
+	}
+	if last := spans[len(spans)-1]; last.end != len(strings.TrimSpace(text)) {
+		t.Errorf("last span ends at %d, want %d", last.end, len(strings.TrimSpace(text)))
+	}
+}
+
+func TestChunkDocument(t *testing.T) {
+	document := &domain.Document{ProcessedContent: "   "}
+	if chunks := chunkDocument(document); len(chunks) != 0 {
+		t.Errorf("chunkDocument() of blank content = %d chunks, want none", len(chunks))
+	}
+
+	document.ProcessedContent = strings.Repeat("Tracker row 12 stalled at noon and was reset. ", 100)
+	chunks := chunkDocument(document)
+	if len(chunks) < 2 {
+		t.Fatalf("chunkDocument() = %d chunks, want several", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.ChunkIndex != i {
+			t.Errorf("chunks[%d].ChunkIndex = %d", i, chunk.ChunkIndex)
+		}
+		if chunk.Content != document.ProcessedContent[chunk.StartOffset:chunk.EndOffset] {
+			t.Errorf("chunks[%d].Content doesn't match its offsets", i)
+		}
+		if len(chunk.Content) > chunkSize || !utf8.ValidString(chunk.Content) {
+			t.Errorf("chunks[%d] is %d bytes or not valid UTF-8", i, len(chunk.Content))
+		}
+	}
+}
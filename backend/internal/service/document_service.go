@@ -1,7 +1,7 @@
 package service
 
 import (
-	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -12,9 +12,11 @@ import (
 	"time"
 
 	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/extractor"
 	"github.com/engramiq/engramiq-backend/internal/repository"
+	apperrors "github.com/engramiq/engramiq-backend/pkg/errors"
+	"github.com/engramiq/engramiq-backend/pkg/logger"
 	"github.com/google/uuid"
-	"github.com/ledongthuc/pdf"
 	"github.com/pgvector/pgvector-go"
 )
 
@@ -23,32 +25,98 @@ type DocumentService interface {
 	GetDocument(id uuid.UUID) (*domain.Document, error)
 	ListDocuments(siteID uuid.UUID, pagination *domain.Pagination, filters map[string]interface{}) ([]*domain.DocumentWithStats, error)
 	DeleteDocument(id uuid.UUID) error
-	ProcessDocument(id uuid.UUID) error
+	ProcessDocument(ctx context.Context, id uuid.UUID) error
 	SearchDocuments(siteID uuid.UUID, query string, limit int) ([]*domain.Document, error)
-	SearchDocumentsSemantic(siteID uuid.UUID, queryText string, limit int, threshold float64) ([]*domain.Document, error)
+	SearchDocumentsSemantic(ctx context.Context, siteID uuid.UUID, queryText string, limit int, threshold float64) ([]*domain.Document, error)
 	SearchDocumentsSemanticWithEmbedding(siteID uuid.UUID, embedding pgvector.Vector, limit int, threshold float64) ([]*domain.Document, error)
 	GetPendingProcessing(limit int) ([]*domain.Document, error)
+	GetStaleProcessing(startedBefore time.Time, limit int) ([]*domain.Document, error)
 	UpdateProcessingStatus(id uuid.UUID, status domain.ProcessingStatus) error
+	// ClaimDocument marks a pending document as processing; false means
+	// another worker got to it first or it no longer needs work
+	ClaimDocument(id uuid.UUID) (bool, error)
+	EnqueueDocument(document *domain.Document) error
+	ListAttachments(parentID uuid.UUID) ([]*domain.Document, error)
+	RecordProcessingFailure(id uuid.UUID, cause error) error
+	GetDueRetries(limit int) ([]*domain.Document, error)
+	ListFailedDocuments(siteID uuid.UUID, status domain.ProcessingStatus, pagination *domain.Pagination) ([]*domain.Document, error)
+	RequeueDocument(id uuid.UUID) (*domain.Document, error)
+	RequeueFailedDocuments(siteID uuid.UUID, status domain.ProcessingStatus) (int, error)
+	ChunkDocument(ctx context.Context, id uuid.UUID) error
+	GetUnchunkedDocuments(limit int) ([]*domain.Document, error)
 }
 
+// DocumentQueue is the background processing queue uploads are pushed onto.
+// The Redis cache implements it with a priority sorted set.
+type DocumentQueue interface {
+	EnqueueDocument(documentID string, priority int) error
+	DequeueDocument() (string, error)
+}
+
+// RetryPolicy controls how failed documents are retried. Attempts back off
+// exponentially from BaseDelay up to MaxDelay; once MaxAttempts failures have
+// been recorded the document is moved to the dead-letter state.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Backoff returns the delay before the given (1-based) retry attempt
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// maxProcessingErrorLength keeps provider error bodies from bloating the row
+const maxProcessingErrorLength = 2000
+
 type documentService struct {
-	docRepo      repository.DocumentRepository
-	siteRepo     repository.SiteRepository
-	actionRepo   repository.ActionRepository
-	llmService   LLMService
+	docRepo     repository.DocumentRepository
+	chunkRepo   repository.ChunkRepository
+	siteRepo    repository.SiteRepository
+	actionRepo  repository.ActionRepository
+	llmService  LLMService
+	embeddings  EmbeddingService
+	queue       DocumentQueue
+	versions    ContentVersions
+	retryPolicy RetryPolicy
+	log         *logger.Logger
 }
 
 func NewDocumentService(
 	docRepo repository.DocumentRepository,
+	chunkRepo repository.ChunkRepository,
 	siteRepo repository.SiteRepository,
 	actionRepo repository.ActionRepository,
 	llmService LLMService,
+	embeddings EmbeddingService,
+	queue DocumentQueue,
+	versions ContentVersions,
+	retryPolicy RetryPolicy,
+	log *logger.Logger,
 ) DocumentService {
 	return &documentService{
-		docRepo:      docRepo,
-		siteRepo:     siteRepo,
-		actionRepo:   actionRepo,
-		llmService:   llmService,
+		docRepo:     docRepo,
+		chunkRepo:   chunkRepo,
+		siteRepo:    siteRepo,
+		actionRepo:  actionRepo,
+		llmService:  llmService,
+		embeddings:  embeddings,
+		queue:       queue,
+		versions:    versions,
+		retryPolicy: retryPolicy,
+		log:         log,
 	}
 }
 
@@ -72,55 +140,113 @@ func (s *documentService) UploadDocument(siteID uuid.UUID, file *multipart.FileH
 		return nil, fmt.Errorf("failed to read file content: %w", err)
 	}
 
+	return s.ingestDocument(&documentUpload{
+		siteID:       siteID,
+		filename:     file.Filename,
+		mimeType:     file.Header.Get("Content-Type"),
+		content:      content,
+		documentType: documentType,
+	})
+}
+
+// maxAttachmentDepth stops attachments of attached emails from recursing
+// indefinitely
+const maxAttachmentDepth = 3
+
+// documentUpload is a file to ingest, either uploaded directly or found as an
+// attachment of another document
+type documentUpload struct {
+	siteID       uuid.UUID
+	filename     string
+	mimeType     string
+	content      []byte
+	documentType domain.DocumentType
+	parentID     *uuid.UUID
+	depth        int
+}
+
+// ingestDocument extracts, stores and enqueues a single file, then ingests
+// any attachments it carries as child documents
+func (s *documentService) ingestDocument(upload *documentUpload) (*domain.Document, error) {
 	// Calculate content hash for deduplication
-	hash := sha256.Sum256(content)
+	hash := sha256.Sum256(upload.content)
 	contentHash := hex.EncodeToString(hash[:])
 
-	// Check if document already exists
-	existingDoc, err := s.docRepo.GetByContentHash(contentHash)
+	// Check if the site already has this document
+	existingDoc, err := s.docRepo.GetByContentHash(upload.siteID, contentHash)
 	if err == nil && existingDoc != nil {
 		return existingDoc, nil
 	}
 
+	// Emails and caption files are recognised by extension regardless of the
+	// requested type
+	fileExt := strings.ToLower(filepath.Ext(upload.filename))
+	documentType := upload.documentType
+	switch fileExt {
+	case ".eml", ".msg":
+		documentType = domain.DocumentTypeEmail
+	case ".vtt", ".srt":
+		documentType = domain.DocumentTypeMeetingTranscript
+	}
+
 	// Extract text content based on file type
-	textContent, err := s.extractTextContent(content, filepath.Ext(file.Filename))
+	extracted, err := s.extractTextContent(upload.content, fileExt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract text content: %w", err)
 	}
 
+	// Transcripts exported as text or Word documents only get speaker turns
+	// when they were uploaded as a meeting transcript
+	if documentType == domain.DocumentTypeMeetingTranscript && fileExt != ".vtt" && fileExt != ".srt" {
+		if transcript, ok := extractor.ParseTranscriptText(extracted.Text); ok {
+			transcript.Author, transcript.Date = extracted.Author, extracted.Date
+			extracted = transcript
+		}
+	}
+
 	// Determine what to store as raw content based on file type
 	var rawContent string
-	fileExt := filepath.Ext(file.Filename)
-	if fileExt == ".pdf" || fileExt == ".docx" || fileExt == ".doc" {
-		// For binary files, don't store raw content to avoid UTF-8 encoding issues
+	if fileExt == ".pdf" || fileExt == ".docx" || fileExt == ".doc" || fileExt == ".eml" || fileExt == ".msg" {
+		// For binary files (and emails, whose attachments are stored as their
+		// own documents), don't store raw content to avoid UTF-8 encoding issues
 		rawContent = ""
 	} else {
 		// For text files, store the original content
-		rawContent = string(content)
+		rawContent = string(upload.content)
+	}
+
+	title := upload.filename
+	if extracted.Title != "" {
+		title = extracted.Title
 	}
 
 	// Create document record
 	document := &domain.Document{
 		ID:               uuid.New(),
-		SiteID:          siteID,
-		Title:           file.Filename,
-		OriginalFilename: file.Filename,
-		ContentHash:     contentHash,
-		FileSize:        file.Size,
-		MimeType:        file.Header.Get("Content-Type"),
-		DocumentType:    documentType,
-		RawContent:      rawContent,
-		ProcessedContent: textContent,
+		SiteID:           upload.siteID,
+		ParentDocumentID: upload.parentID,
+		Title:            title,
+		OriginalFilename: upload.filename,
+		ContentHash:      contentHash,
+		FileSize:         int64(len(upload.content)),
+		MimeType:         upload.mimeType,
+		DocumentType:     documentType,
+		RawContent:       rawContent,
+		ProcessedContent: extracted.Text,
 		ProcessingStatus: domain.ProcessingStatusPending,
-		DocumentMetadata: domain.JSON{}, // Initialize empty JSON
-		Embedding:       pgvector.NewVector(make([]float32, 1536)), // Initialize empty vector
-		CreatedAt:       time.Now(),
-		UpdatedAt:       time.Now(),
+		AuthorName:       extracted.Author,
+		AuthorEmail:      extracted.AuthorEmail,
+		DocumentMetadata: extracted.Metadata(),                      // Structure such as section offsets
+		Embedding:        pgvector.NewVector(make([]float32, 1536)), // Initialize empty vector
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
 	}
 
-	// Set document date based on filename or current time
-	extractedDate := s.extractDateFromFilename(file.Filename)
-	if !extractedDate.IsZero() {
+	// Set document date from file metadata, the filename or current time
+	extractedDate := s.extractDateFromFilename(upload.filename)
+	if extracted.Date != nil {
+		document.DocumentDate = extracted.Date
+	} else if !extractedDate.IsZero() {
 		document.DocumentDate = &extractedDate
 	} else {
 		now := time.Now()
@@ -132,10 +258,61 @@ func (s *documentService) UploadDocument(siteID uuid.UUID, file *multipart.FileH
 	if err != nil {
 		return nil, fmt.Errorf("failed to create document: %w", err)
 	}
+	InvalidateAnswers(s.versions, document.SiteID, s.log)
+
+	// Hand off to the background workers; if the queue is unavailable the
+	// document stays pending and the worker sweep will enqueue it later
+	if err := s.EnqueueDocument(document); err != nil {
+		s.log.Warnw("Failed to enqueue document", "document_id", document.ID, "error", err)
+	}
+
+	if len(extracted.Attachments) > 0 {
+		s.ingestAttachments(document, extracted.Attachments, upload.depth+1)
+	}
 
 	return document, nil
 }
 
+// ingestAttachments stores each attachment as a child document. Attachments
+// we can't read (images, archives, ...) are skipped and listed on the parent
+// rather than failing the whole upload.
+func (s *documentService) ingestAttachments(parent *domain.Document, attachments []extractor.Attachment, depth int) {
+	var skipped []map[string]string
+	for _, attachment := range attachments {
+		if depth > maxAttachmentDepth {
+			skipped = append(skipped, map[string]string{"filename": attachment.Filename, "reason": "nested too deeply"})
+			continue
+		}
+
+		_, err := s.ingestDocument(&documentUpload{
+			siteID:       parent.SiteID,
+			filename:     attachment.Filename,
+			mimeType:     attachment.ContentType,
+			content:      attachment.Content,
+			documentType: attachmentDocumentType(attachment.Filename),
+			parentID:     &parent.ID,
+			depth:        depth,
+		})
+		if err != nil {
+			s.log.Warnw("Skipping attachment", "document_id", parent.ID, "filename", attachment.Filename, "error", err)
+			skipped = append(skipped, map[string]string{"filename": attachment.Filename, "reason": err.Error()})
+		}
+	}
+
+	if len(skipped) == 0 {
+		return
+	}
+
+	parent.DocumentMetadata["skipped_attachments"] = skipped
+	if err := s.docRepo.Update(parent.ID, map[string]interface{}{"document_metadata": parent.DocumentMetadata}); err != nil {
+		s.log.Errorw("Failed to record skipped attachments", "document_id", parent.ID, "error", err)
+	}
+}
+
+func (s *documentService) ListAttachments(parentID uuid.UUID) ([]*domain.Document, error) {
+	return s.docRepo.ListByParent(parentID)
+}
+
 func (s *documentService) GetDocument(id uuid.UUID) (*domain.Document, error) {
 	return s.docRepo.GetByID(id)
 }
@@ -145,15 +322,41 @@ func (s *documentService) ListDocuments(siteID uuid.UUID, pagination *domain.Pag
 }
 
 func (s *documentService) DeleteDocument(id uuid.UUID) error {
-	return s.docRepo.Delete(id)
+	document, err := s.docRepo.GetByID(id)
+	if err != nil {
+		return apperrors.NewNotFound("document", id.String())
+	}
+
+	// Attachments go with the email they were ingested from
+	children, err := s.docRepo.ListByParent(id)
+	if err != nil {
+		return fmt.Errorf("failed to list attachments: %w", err)
+	}
+	for _, child := range children {
+		if err := s.DeleteDocument(child.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.chunkRepo.DeleteByDocument(id); err != nil {
+		return fmt.Errorf("failed to delete document chunks: %w", err)
+	}
+
+	if err := s.docRepo.Delete(id); err != nil {
+		return err
+	}
+
+	InvalidateAnswers(s.versions, document.SiteID, s.log)
+	return nil
 }
 
-func (s *documentService) ProcessDocument(id uuid.UUID) error {
+func (s *documentService) ProcessDocument(ctx context.Context, id uuid.UUID) error {
 	// Get document
 	document, err := s.docRepo.GetByID(id)
 	if err != nil {
 		return fmt.Errorf("failed to get document: %w", err)
 	}
+	ctx = documentUsageScope(ctx, document)
 
 	// Update status to processing
 	err = s.UpdateProcessingStatus(id, domain.ProcessingStatusProcessing)
@@ -161,60 +364,126 @@ func (s *documentService) ProcessDocument(id uuid.UUID) error {
 		return fmt.Errorf("failed to update status: %w", err)
 	}
 
-	// Generate embeddings for semantic search
-	embedding, err := s.llmService.GenerateEmbedding(document.ProcessedContent)
+	// Split into chunks and embed each one for retrieval
+	embedding, err := s.storeChunks(ctx, document)
 	if err != nil {
-		s.UpdateProcessingStatus(id, domain.ProcessingStatusFailed)
-		return fmt.Errorf("failed to generate embedding: %w", err)
+		s.recordFailure(ctx, id, err)
+		return err
 	}
 
 	// Extract actions from document content
-	actions, err := s.llmService.ExtractActions(document.ProcessedContent, document.SiteID)
+	actions, err := s.llmService.ExtractActions(ctx, document.ProcessedContent, document.SiteID)
 	if err != nil {
-		s.UpdateProcessingStatus(id, domain.ProcessingStatusFailed)
-		return fmt.Errorf("failed to extract actions: %w", err)
+		err = fmt.Errorf("failed to extract actions: %w", err)
+		s.recordFailure(ctx, id, err)
+		return err
 	}
 
-	// Save extracted actions to database
-	extractedCount := 0
-	fmt.Printf("Attempting to save %d extracted actions\n", len(actions))
-	for i, action := range actions {
-		// Associate action with the document it came from
+	// Associate actions with the document they came from and embed them
+	// for semantic search
+	for _, action := range actions {
 		action.DocumentID = document.ID
-		fmt.Printf("Saving action %d: %s\n", i+1, action.Title)
-		if err := s.actionRepo.Create(action); err != nil {
-			fmt.Printf("Failed to save action %d: %v\n", i+1, err)
-			// Continue processing other actions even if one fails
-		} else {
-			fmt.Printf("Successfully saved action %d\n", i+1)
-			extractedCount++
-		}
 	}
-	fmt.Printf("Total actions saved: %d\n", extractedCount)
+	s.embeddings.EmbedActions(ctx, actions)
+
+	// Replace whatever an earlier attempt saved for this document
+	if err := s.actionRepo.ReplaceForDocument(document.ID, actions); err != nil {
+		err = fmt.Errorf("failed to save actions: %w", err)
+		s.recordFailure(ctx, id, err)
+		return err
+	}
 
 	// Update document with processing results
 	updates := map[string]interface{}{
-		"embedding":           embedding,
-		"processing_status":   domain.ProcessingStatusCompleted,
+		"embedding":               embedding,
+		"chunked_at":              time.Now(),
+		"processing_status":       domain.ProcessingStatusCompleted,
 		"processing_completed_at": time.Now(),
-		// "extracted_actions_count": extractedCount, // Column doesn't exist in database
+		"last_processing_error":   "",
+		"next_retry_at":           nil,
 	}
 
 	err = s.docRepo.Update(id, updates)
 	if err != nil {
-		return fmt.Errorf("failed to update document: %w", err)
+		err = fmt.Errorf("failed to update document: %w", err)
+		s.recordFailure(ctx, id, err)
+		return err
 	}
 
+	// New chunks and actions change what the site's questions are answered from
+	InvalidateAnswers(s.versions, document.SiteID, s.log)
 	return nil
 }
 
+// ChunkDocument (re)builds the chunks of an already processed document
+// without extracting its actions again. The worker uses it to backfill
+// documents processed before chunk-level retrieval existed.
+func (s *documentService) ChunkDocument(ctx context.Context, id uuid.UUID) error {
+	document, err := s.docRepo.GetByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to get document: %w", err)
+	}
+	ctx = documentUsageScope(ctx, document)
+
+	embedding, err := s.storeChunks(ctx, document)
+	if err != nil {
+		return err
+	}
+
+	if err := s.docRepo.Update(id, map[string]interface{}{"embedding": embedding, "chunked_at": time.Now()}); err != nil {
+		return err
+	}
+
+	InvalidateAnswers(s.versions, document.SiteID, s.log)
+	return nil
+}
+
+func (s *documentService) GetUnchunkedDocuments(limit int) ([]*domain.Document, error) {
+	return s.docRepo.GetUnchunked(limit)
+}
+
+// storeChunks embeds each chunk of the document, replaces its stored chunks
+// and returns the document-level embedding derived from them
+func (s *documentService) storeChunks(ctx context.Context, document *domain.Document) (pgvector.Vector, error) {
+	ctx = withUsageOperation(ctx, usageEmbedChunks)
+	chunks := chunkDocument(document)
+	if len(chunks) == 0 {
+		// Nothing to chunk; keep a title embedding so the document is
+		// still reachable through document-level search
+		embedding, err := s.llmService.GenerateEmbedding(ctx, document.Title)
+		if err != nil {
+			return pgvector.Vector{}, fmt.Errorf("failed to generate embedding: %w", err)
+		}
+		return embedding, s.chunkRepo.ReplaceForDocument(document.ID, nil)
+	}
+
+	inputs := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		inputs[i] = chunkEmbeddingInput(document, chunk)
+	}
+	embeddings, err := s.llmService.GenerateEmbeddings(ctx, inputs)
+	if err != nil {
+		return pgvector.Vector{}, fmt.Errorf("failed to generate chunk embeddings: %w", err)
+	}
+	for i, chunk := range chunks {
+		chunk.Embedding = embeddings[i]
+	}
+
+	if err := s.chunkRepo.ReplaceForDocument(document.ID, chunks); err != nil {
+		return pgvector.Vector{}, fmt.Errorf("failed to save document chunks: %w", err)
+	}
+
+	return meanEmbedding(embeddings), nil
+}
+
 func (s *documentService) SearchDocuments(siteID uuid.UUID, query string, limit int) ([]*domain.Document, error) {
 	return s.docRepo.SearchFullText(siteID, query, limit)
 }
 
-func (s *documentService) SearchDocumentsSemantic(siteID uuid.UUID, queryText string, limit int, threshold float64) ([]*domain.Document, error) {
+func (s *documentService) SearchDocumentsSemantic(ctx context.Context, siteID uuid.UUID, queryText string, limit int, threshold float64) ([]*domain.Document, error) {
 	// Generate embedding for search query
-	embedding, err := s.llmService.GenerateEmbedding(queryText)
+	ctx = withUsageOperation(siteUsageScope(ctx, siteID), usageEmbedSearch)
+	embedding, err := s.llmService.GenerateEmbedding(ctx, queryText)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
@@ -230,81 +499,241 @@ func (s *documentService) GetPendingProcessing(limit int) ([]*domain.Document, e
 	return s.docRepo.GetPendingProcessing(limit)
 }
 
+func (s *documentService) GetStaleProcessing(startedBefore time.Time, limit int) ([]*domain.Document, error) {
+	return s.docRepo.GetStaleProcessing(startedBefore, limit)
+}
+
 func (s *documentService) UpdateProcessingStatus(id uuid.UUID, status domain.ProcessingStatus) error {
 	return s.docRepo.UpdateProcessingStatus(id, status)
 }
 
-// Helper methods
+func (s *documentService) ClaimDocument(id uuid.UUID) (bool, error) {
+	return s.docRepo.ClaimPending(id)
+}
 
-func (s *documentService) extractTextContent(content []byte, fileExt string) (string, error) {
-	switch fileExt {
-	case ".txt":
-		return string(content), nil
-	case ".pdf":
-		// Try to extract PDF text, but don't fail if it can't be parsed
-		extracted, err := s.extractPDFText(content)
-		if err != nil {
-			// If PDF extraction fails, return a safe placeholder
-			return "[PDF content - text extraction failed: " + err.Error() + "]", nil
-		}
-		return extracted, nil
-	case ".docx", ".doc":
-		// TODO: Implement Word document text extraction
-		// For now, return empty string for binary Word documents to avoid encoding issues
-		return "", fmt.Errorf("Word document text extraction not implemented yet")
-	default:
-		// For unknown types, check if content is valid UTF-8
-		if strings.ToValidUTF8(string(content), "") != string(content) {
-			return "", fmt.Errorf("file contains binary content that cannot be processed as text")
-		}
-		return string(content), nil
+func (s *documentService) EnqueueDocument(document *domain.Document) error {
+	if s.queue == nil {
+		return fmt.Errorf("document queue not configured")
 	}
+	return s.queue.EnqueueDocument(document.ID.String(), processingPriority(document.DocumentType))
 }
 
-func (s *documentService) extractPDFText(content []byte) (string, error) {
-	// Create a reader from the byte slice
-	reader := bytes.NewReader(content)
+// RecordProcessingFailure stores the error on the document and either
+// schedules a retry with exponential backoff or, once the retry budget is
+// spent, moves the document to the dead-letter state
+func (s *documentService) RecordProcessingFailure(id uuid.UUID, cause error) error {
+	document, err := s.docRepo.GetByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to get document: %w", err)
+	}
+
+	message := cause.Error()
+	if len(message) > maxProcessingErrorLength {
+		message = message[:maxProcessingErrorLength]
+	}
 
-	// Create a PDF reader
-	pdfReader, err := pdf.NewReader(reader, int64(len(content)))
+	now := time.Now()
+	attempts := document.ProcessingAttempts + 1
+	updates := map[string]interface{}{
+		"processing_attempts":     attempts,
+		"last_processing_error":   message,
+		"processing_completed_at": now,
+	}
+
+	if attempts >= s.retryPolicy.MaxAttempts {
+		updates["processing_status"] = domain.ProcessingStatusDeadLetter
+		updates["next_retry_at"] = nil
+		s.log.Warnw("Document moved to dead letter", "document_id", id, "attempts", attempts, "error", message)
+	} else {
+		updates["processing_status"] = domain.ProcessingStatusFailed
+		updates["next_retry_at"] = now.Add(s.retryPolicy.Backoff(attempts))
+	}
+
+	return s.docRepo.Update(id, updates)
+}
+
+// recordFailure records a failed processing attempt unless ctx is done. A
+// cancelled attempt didn't fail on its own; whoever cancelled it decides what
+// happens to the document.
+func (s *documentService) recordFailure(ctx context.Context, id uuid.UUID, cause error) {
+	if ctx.Err() != nil {
+		return
+	}
+	if err := s.RecordProcessingFailure(id, cause); err != nil {
+		s.log.Errorw("Failed to record processing failure", "document_id", id, "error", err)
+	}
+}
+
+func (s *documentService) GetDueRetries(limit int) ([]*domain.Document, error) {
+	return s.docRepo.GetDueRetries(time.Now(), limit)
+}
+
+// ListFailedDocuments lists documents in the given failure state, or in
+// either failure state when status is empty
+func (s *documentService) ListFailedDocuments(siteID uuid.UUID, status domain.ProcessingStatus, pagination *domain.Pagination) ([]*domain.Document, error) {
+	statuses, err := failureStatuses(status)
+	if err != nil {
+		return nil, err
+	}
+	return s.docRepo.ListFailed(siteID, statuses, pagination)
+}
+
+// RequeueDocument gives a failed or dead-lettered document a fresh retry
+// budget and puts it straight back on the processing queue
+func (s *documentService) RequeueDocument(id uuid.UUID) (*domain.Document, error) {
+	document, err := s.docRepo.GetByID(id)
 	if err != nil {
-		return "", fmt.Errorf("failed to create PDF reader: %w", err)
+		return nil, apperrors.NewNotFound("document", id.String())
 	}
 
-	var textContent strings.Builder
+	if document.ProcessingStatus != domain.ProcessingStatusFailed && document.ProcessingStatus != domain.ProcessingStatusDeadLetter {
+		return nil, apperrors.NewBadRequest(fmt.Sprintf("document is %s, only failed documents can be requeued", document.ProcessingStatus))
+	}
 
-	// Extract text from each page
-	for pageNum := 1; pageNum <= pdfReader.NumPage(); pageNum++ {
-		page := pdfReader.Page(pageNum)
-		if page.V.IsNull() {
-			continue
-		}
+	if err := s.resetForRequeue(document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+func (s *documentService) RequeueFailedDocuments(siteID uuid.UUID, status domain.ProcessingStatus) (int, error) {
+	statuses, err := failureStatuses(status)
+	if err != nil {
+		return 0, err
+	}
 
-		// Get page content - pass an empty font map since we just want text
-		pageText, err := page.GetPlainText(map[string]*pdf.Font{})
+	// Page through in batches; each requeued document leaves the failed set,
+	// so we always read the first page
+	const batchSize = 100
+	requeued := 0
+	for {
+		pagination := &domain.Pagination{Page: 1, Limit: batchSize, Sort: "created_at ASC"}
+		documents, err := s.docRepo.ListFailed(siteID, statuses, pagination)
 		if err != nil {
-			// If we can't extract text from this page, continue with others
-			continue
+			return requeued, fmt.Errorf("failed to list failed documents: %w", err)
+		}
+
+		for _, document := range documents {
+			if err := s.resetForRequeue(document); err != nil {
+				return requeued, err
+			}
+			requeued++
+		}
+
+		if len(documents) < batchSize {
+			return requeued, nil
 		}
+	}
+}
 
-		textContent.WriteString(pageText)
-		textContent.WriteString("\n\n") // Add spacing between pages
+func (s *documentService) resetForRequeue(document *domain.Document) error {
+	updates := map[string]interface{}{
+		"processing_status":   domain.ProcessingStatusPending,
+		"processing_attempts": 0,
+		"next_retry_at":       nil,
+	}
+	if err := s.docRepo.Update(document.ID, updates); err != nil {
+		return fmt.Errorf("failed to reset document %s: %w", document.ID, err)
 	}
 
-	extracted := strings.TrimSpace(textContent.String())
-	if extracted == "" {
-		// Return a placeholder instead of error to avoid UTF-8 issues
-		return "[PDF content - text extraction failed]", nil
+	document.ProcessingStatus = domain.ProcessingStatusPending
+	document.ProcessingAttempts = 0
+	document.NextRetryAt = nil
+
+	// The document is already pending, so the worker sweep will pick it up
+	// even if Redis is unavailable right now
+	if err := s.EnqueueDocument(document); err != nil {
+		s.log.Warnw("Failed to enqueue document", "document_id", document.ID, "error", err)
 	}
 
-	return extracted, nil
+	return nil
+}
+
+// Helper methods
+
+func failureStatuses(status domain.ProcessingStatus) ([]domain.ProcessingStatus, error) {
+	switch status {
+	case "":
+		return []domain.ProcessingStatus{domain.ProcessingStatusFailed, domain.ProcessingStatusDeadLetter}, nil
+	case domain.ProcessingStatusFailed, domain.ProcessingStatusDeadLetter:
+		return []domain.ProcessingStatus{status}, nil
+	default:
+		return nil, apperrors.NewBadRequest(fmt.Sprintf("invalid failure status: %s", status))
+	}
+}
+
+// attachmentDocumentType picks the type for a document ingested from an
+// attachment. We can't tell a report from a drawing by name alone, so only
+// attached emails and caption files get a specific type.
+func attachmentDocumentType(filename string) domain.DocumentType {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".eml", ".msg":
+		return domain.DocumentTypeEmail
+	case ".vtt", ".srt":
+		return domain.DocumentTypeMeetingTranscript
+	default:
+		return domain.DocumentTypeOther
+	}
+}
+
+// processingPriority orders the queue so operational reports that feed the
+// timeline are processed ahead of bulky reference material
+func processingPriority(documentType domain.DocumentType) int {
+	switch documentType {
+	case domain.DocumentTypeFieldServiceReport, domain.DocumentTypeWorkOrder, domain.DocumentTypeInspectionReport:
+		return 2
+	case domain.DocumentTypeEmail, domain.DocumentTypeMeetingTranscript, domain.DocumentTypeWarrantyClaim:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (s *documentService) extractTextContent(content []byte, fileExt string) (*extractor.Result, error) {
+	switch strings.ToLower(fileExt) {
+	case ".txt":
+		return &extractor.Result{Text: string(content)}, nil
+	case ".pdf":
+		// Try to extract PDF text, but don't fail if it can't be parsed
+		extracted, err := extractor.ExtractPDF(content)
+		if err != nil {
+			// If PDF extraction fails, return a safe placeholder
+			return &extractor.Result{Text: "[PDF content - text extraction failed: " + err.Error() + "]"}, nil
+		}
+		return extracted, nil
+	case ".docx":
+		return extractor.ExtractDOCX(content)
+	case ".doc":
+		return extractor.ExtractDOC(content)
+	case ".eml":
+		return extractor.ExtractEML(content)
+	case ".msg":
+		return extractor.ExtractMSG(content)
+	case ".vtt":
+		return extractor.ExtractVTT(content)
+	case ".srt":
+		return extractor.ExtractSRT(content)
+	default:
+		// For unknown types, check if content is valid UTF-8
+		if strings.ToValidUTF8(string(content), "") != string(content) {
+			return nil, fmt.Errorf("file contains binary content that cannot be processed as text")
+		}
+		return &extractor.Result{Text: string(content)}, nil
+	}
 }
 
 func (s *documentService) extractDateFromFilename(filename string) time.Time {
 	// Common date patterns in filenames
 	// Examples: "report_2023-12-15.pdf", "maintenance_20231215.txt"
 	// This is a simplified implementation - real implementation would use regex
-	
+
 	// For now, return zero time to use current time
 	return time.Time{}
-}
\ No newline at end of file
+}
+
+// documentUsageScope attributes the LLM calls made while processing a
+// document to it and its site
+func documentUsageScope(ctx context.Context, document *domain.Document) context.Context {
+	return WithUsageScope(ctx, UsageScope{SiteID: &document.SiteID, DocumentID: &document.ID})
+}
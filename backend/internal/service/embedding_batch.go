@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/llm"
+	"github.com/pgvector/pgvector-go"
+)
+
+// defaultEmbeddingBatchSize applies when no batch size is configured
+const defaultEmbeddingBatchSize = 64
+
+// EmbeddingCache keeps embeddings by a hash of the embedded text. The Redis
+// cache implements it.
+type EmbeddingCache interface {
+	// GetEmbeddings returns an entry per key, nil where the key isn't cached
+	GetEmbeddings(keys []string) ([][]float32, error)
+	SetEmbeddings(embeddings map[string][]float32, ttl time.Duration) error
+}
+
+// EmbeddingPolicy controls how embeddings are generated. Model scopes the
+// cache so that switching embedding models doesn't serve vectors from the
+// old one. A nil Cache or a CacheTTL of 0 disables caching.
+type EmbeddingPolicy struct {
+	Model     string
+	BatchSize int
+	Cache     EmbeddingCache
+	CacheTTL  time.Duration
+}
+
+func (s *llmService) GenerateEmbedding(ctx context.Context, text string) (pgvector.Vector, error) {
+	embeddings, err := s.GenerateEmbeddings(ctx, []string{text})
+	if err != nil {
+		return pgvector.Vector{}, err
+	}
+	return embeddings[0], nil
+}
+
+// GenerateEmbeddings embeds texts, in order. Cached embeddings are reused
+// and the rest are requested from the provider in batches, with repeated
+// texts embedded once.
+func (s *llmService) GenerateEmbeddings(ctx context.Context, texts []string) ([]pgvector.Vector, error) {
+	embeddings := make([]pgvector.Vector, len(texts))
+	if len(texts) == 0 {
+		return embeddings, nil
+	}
+
+	keys := make([]string, len(texts))
+	for i, text := range texts {
+		keys[i] = s.embeddingKey(text)
+	}
+	found := s.cachedEmbeddings(keys)
+
+	// Texts still to embed, each once, with the positions they fill
+	var missing []string
+	positions := make(map[string][]int)
+	for i, key := range keys {
+		if vector, ok := found[key]; ok {
+			embeddings[i] = pgvector.NewVector(vector)
+			continue
+		}
+		if _, queued := positions[key]; !queued {
+			missing = append(missing, texts[i])
+		}
+		positions[key] = append(positions[key], i)
+	}
+
+	batchSize := s.embeddings.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultEmbeddingBatchSize
+	}
+
+	for start := 0; start < len(missing); start += batchSize {
+		end := start + batchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		batch := missing[start:end]
+
+		vectors, err := s.embedBatch(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		generated := make(map[string][]float32, len(batch))
+		for i, text := range batch {
+			key := s.embeddingKey(text)
+			generated[key] = vectors[i]
+			for _, position := range positions[key] {
+				embeddings[position] = pgvector.NewVector(vectors[i])
+			}
+		}
+		s.cacheEmbeddings(generated)
+	}
+
+	return embeddings, nil
+}
+
+// embedBatch requests one batch of embeddings from the provider
+func (s *llmService) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	ctx, cancel := s.callContext(ctx)
+	defer cancel()
+
+	started := time.Now()
+	resp, err := s.provider.Embed(ctx, texts)
+	model, usage := s.embeddings.Model, llm.Usage{}
+	if resp != nil {
+		if resp.Model != "" {
+			model = resp.Model
+		}
+		usage = resp.Usage
+	}
+	s.recordUsage(ctx, domain.LLMCallEmbedding, model, usage, started, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Vectors) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Vectors))
+	}
+	return resp.Vectors, nil
+}
+
+// embeddingKey hashes a text together with the provider and model that
+// embed it
+func (s *llmService) embeddingKey(text string) string {
+	hash := sha256.New()
+	hash.Write([]byte(s.provider.Name()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(s.embeddings.Model))
+	hash.Write([]byte{0})
+	hash.Write([]byte(text))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (s *llmService) embeddingCacheEnabled() bool {
+	return s.embeddings.Cache != nil && s.embeddings.CacheTTL > 0
+}
+
+// cachedEmbeddings returns the cached embeddings among keys. A cache that
+// can't be read is treated as empty.
+func (s *llmService) cachedEmbeddings(keys []string) map[string][]float32 {
+	found := make(map[string][]float32)
+	if !s.embeddingCacheEnabled() {
+		return found
+	}
+
+	vectors, err := s.embeddings.Cache.GetEmbeddings(keys)
+	if err != nil {
+		s.log.Warnw("Failed to read embedding cache", "error", err)
+		return found
+	}
+	for i, vector := range vectors {
+		if vector != nil && i < len(keys) {
+			found[keys[i]] = vector
+		}
+	}
+	return found
+}
+
+func (s *llmService) cacheEmbeddings(embeddings map[string][]float32) {
+	if !s.embeddingCacheEnabled() {
+		return
+	}
+	if err := s.embeddings.Cache.SetEmbeddings(embeddings, s.embeddings.CacheTTL); err != nil {
+		s.log.Warnw("Failed to cache embeddings", "error", err)
+	}
+}
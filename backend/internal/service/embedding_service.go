@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/llm"
+	"github.com/engramiq/engramiq-backend/internal/repository"
+	"github.com/engramiq/engramiq-backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/pgvector/pgvector-go"
+)
+
+// EmbeddingService keeps extracted actions and site components embedded so
+// they can be found by meaning rather than exact wording
+type EmbeddingService interface {
+	// EmbedActions sets the embedding of actions about to be saved. Actions
+	// that can't be embedded are saved without one and picked up by the
+	// backfill.
+	EmbedActions(ctx context.Context, actions []*domain.ExtractedAction)
+	// QueueComponents gives components about to be saved the zero vector
+	// the backfill looks for, so the worker embeds them and saving them
+	// doesn't wait on the provider
+	QueueComponents(components []*domain.SiteComponent)
+	// RequeueAction drops an edited action's embedding for the backfill to
+	// redo, rather than leave it describing the old action
+	RequeueAction(action *domain.ExtractedAction)
+	RequeueComponent(component *domain.SiteComponent)
+	// BackfillEmbeddings embeds up to limit actions and limit components
+	// saved without an embedding and returns how many it embedded
+	BackfillEmbeddings(ctx context.Context, limit int) (int, error)
+	SearchActions(ctx context.Context, siteID uuid.UUID, queryText string, limit int, threshold float64) ([]*domain.ExtractedAction, error)
+}
+
+type embeddingService struct {
+	llmService    LLMService
+	actionRepo    repository.ActionRepository
+	componentRepo repository.ComponentRepository
+	log           *logger.Logger
+}
+
+func NewEmbeddingService(
+	llmService LLMService,
+	actionRepo repository.ActionRepository,
+	componentRepo repository.ComponentRepository,
+	log *logger.Logger,
+) EmbeddingService {
+	return &embeddingService{
+		llmService:    llmService,
+		actionRepo:    actionRepo,
+		componentRepo: componentRepo,
+		log:           log,
+	}
+}
+
+func (s *embeddingService) EmbedActions(ctx context.Context, actions []*domain.ExtractedAction) {
+	if len(actions) == 0 {
+		return
+	}
+
+	inputs := make([]string, len(actions))
+	for i, action := range actions {
+		inputs[i] = actionEmbeddingInput(action)
+	}
+	ctx = withUsageOperation(siteUsageScope(ctx, actions[0].SiteID), usageEmbedActions)
+	embeddings, err := s.llmService.GenerateEmbeddings(ctx, inputs)
+	if err != nil {
+		s.log.Warnw("Failed to embed actions", "count", len(actions), "error", err)
+		return
+	}
+	for i, action := range actions {
+		action.Embedding = embeddings[i]
+	}
+}
+
+func (s *embeddingService) QueueComponents(components []*domain.SiteComponent) {
+	for _, component := range components {
+		component.Embedding = unembeddedComponent()
+	}
+}
+
+func (s *embeddingService) RequeueAction(action *domain.ExtractedAction) {
+	if err := s.actionRepo.UpdateEmbedding(action.ID, nil); err != nil {
+		s.log.Errorw("Failed to clear action embedding", "action_id", action.ID, "error", err)
+	}
+}
+
+func (s *embeddingService) RequeueComponent(component *domain.SiteComponent) {
+	if err := s.componentRepo.UpdateEmbedding(component.ID, unembeddedComponent()); err != nil {
+		s.log.Errorw("Failed to clear component embedding", "component_id", component.ID, "error", err)
+	}
+}
+
+// unembeddedComponent is the embedding of a component waiting for the
+// backfill; the column can't hold an empty vector
+func unembeddedComponent() pgvector.Vector {
+	return pgvector.NewVector(make([]float32, llm.EmbeddingDimensions))
+}
+
+// BackfillEmbeddings runs the action and component phases independently, so
+// actions that keep failing to embed don't hold up components or the reverse
+func (s *embeddingService) BackfillEmbeddings(ctx context.Context, limit int) (int, error) {
+	ctx = withUsageOperation(ctx, usageEmbeddingsBackfill)
+
+	actions, actionErr := s.backfillActions(ctx, limit)
+	components, componentErr := s.backfillComponents(ctx, limit)
+	return actions + components, errors.Join(actionErr, componentErr)
+}
+
+func (s *embeddingService) backfillActions(ctx context.Context, limit int) (int, error) {
+	actions, err := s.actionRepo.GetUnembedded(limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load actions without embeddings: %w", err)
+	}
+	inputs := make([]string, len(actions))
+	sites := make([]uuid.UUID, len(actions))
+	for i, action := range actions {
+		inputs[i] = actionEmbeddingInput(action)
+		sites[i] = action.SiteID
+	}
+	embeddings, err := s.embedBySite(ctx, inputs, sites)
+	if err != nil {
+		return 0, fmt.Errorf("failed to embed actions: %w", err)
+	}
+
+	embedded := 0
+	for i, action := range actions {
+		if err := s.actionRepo.UpdateEmbedding(action.ID, &embeddings[i]); err != nil {
+			return embedded, fmt.Errorf("failed to save embedding of action %s: %w", action.ID, err)
+		}
+		embedded++
+	}
+	return embedded, nil
+}
+
+func (s *embeddingService) backfillComponents(ctx context.Context, limit int) (int, error) {
+	components, err := s.componentRepo.GetUnembedded(limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load components without embeddings: %w", err)
+	}
+	inputs := make([]string, len(components))
+	sites := make([]uuid.UUID, len(components))
+	for i, component := range components {
+		inputs[i] = componentEmbeddingInput(component)
+		sites[i] = component.SiteID
+	}
+	embeddings, err := s.embedBySite(ctx, inputs, sites)
+	if err != nil {
+		return 0, fmt.Errorf("failed to embed components: %w", err)
+	}
+
+	embedded := 0
+	for i, component := range components {
+		if err := s.componentRepo.UpdateEmbedding(component.ID, embeddings[i]); err != nil {
+			return embedded, fmt.Errorf("failed to save embedding of component %s: %w", component.ID, err)
+		}
+		embedded++
+	}
+	return embedded, nil
+}
+
+// embedBySite embeds inputs[i] on behalf of sites[i], one batch per site so
+// each site is charged for its own embeddings
+func (s *embeddingService) embedBySite(ctx context.Context, inputs []string, sites []uuid.UUID) ([]pgvector.Vector, error) {
+	positions := make(map[uuid.UUID][]int)
+	var order []uuid.UUID
+	for i, siteID := range sites {
+		if _, seen := positions[siteID]; !seen {
+			order = append(order, siteID)
+		}
+		positions[siteID] = append(positions[siteID], i)
+	}
+
+	embeddings := make([]pgvector.Vector, len(inputs))
+	for _, siteID := range order {
+		siteInputs := make([]string, len(positions[siteID]))
+		for j, i := range positions[siteID] {
+			siteInputs[j] = inputs[i]
+		}
+		siteEmbeddings, err := s.llmService.GenerateEmbeddings(siteUsageScope(ctx, siteID), siteInputs)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range positions[siteID] {
+			embeddings[i] = siteEmbeddings[j]
+		}
+	}
+	return embeddings, nil
+}
+
+// SearchActions finds a site's actions closest in meaning to the query.
+// threshold is the largest cosine distance a match may have.
+func (s *embeddingService) SearchActions(ctx context.Context, siteID uuid.UUID, queryText string, limit int, threshold float64) ([]*domain.ExtractedAction, error) {
+	ctx = withUsageOperation(siteUsageScope(ctx, siteID), usageEmbedSearch)
+	embedding, err := s.llmService.GenerateEmbedding(ctx, queryText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed search query: %w", err)
+	}
+	return s.actionRepo.SearchSemantic(siteID, embedding, limit, threshold)
+}
+
+// actionEmbeddingInput describes an action the way a question about it
+// would: what was done, to what, when and under which work order
+func actionEmbeddingInput(action *domain.ExtractedAction) string {
+	lines := []string{action.Title}
+	if action.Description != "" && action.Description != action.Title {
+		lines = append(lines, action.Description)
+	}
+
+	lines = append(lines, fmt.Sprintf("Action type: %s", strings.ReplaceAll(string(action.ActionType), "_", " ")))
+	if action.ActionStatus != "" {
+		lines = append(lines, fmt.Sprintf("Status: %s", strings.ReplaceAll(string(action.ActionStatus), "_", " ")))
+	}
+	if action.ActionDate != nil {
+		lines = append(lines, fmt.Sprintf("Date: %s", action.ActionDate.Format("2006-01-02")))
+	}
+	if action.WorkOrderNumber != "" {
+		lines = append(lines, fmt.Sprintf("Work order: %s", action.WorkOrderNumber))
+	}
+	if componentType, ok := action.ExtractionMetadata["component_type"].(string); ok && componentType != "" {
+		lines = append(lines, fmt.Sprintf("Component type: %s", componentType))
+	}
+	if len(action.FaultCodes) > 0 {
+		lines = append(lines, fmt.Sprintf("Fault codes: %s", strings.Join(action.FaultCodes, ", ")))
+	}
+	if details, ok := action.ExtractionMetadata["details"].(string); ok && details != "" {
+		lines = append(lines, details)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// componentEmbeddingInput describes a component by its names, type, place
+// in the site and specifications
+func componentEmbeddingInput(component *domain.SiteComponent) string {
+	lines := []string{
+		component.Name,
+		fmt.Sprintf("Component type: %s", strings.ReplaceAll(string(component.ComponentType), "_", " ")),
+		fmt.Sprintf("ID: %s", component.ExternalID),
+	}
+	if component.Label != "" {
+		lines = append(lines, fmt.Sprintf("Label: %s", component.Label))
+	}
+	if component.GroupName != "" {
+		lines = append(lines, fmt.Sprintf("Group: %s", component.GroupName))
+	}
+
+	// Sorted so the same specifications always embed the same way
+	keys := make([]string, 0, len(component.Specifications))
+	for key := range component.Specifications {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s: %v", strings.ReplaceAll(key, "_", " "), component.Specifications[key]))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,466 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+)
+
+// GroundingJudge selects how claims without a failed fact check are judged
+type GroundingJudge string
+
+const (
+	// GroundingJudgeLLM asks the LLM whether the sources entail each claim
+	GroundingJudgeLLM GroundingJudge = "llm"
+	// GroundingJudgeRules only measures how many of a claim's terms the
+	// sources contain
+	GroundingJudgeRules GroundingJudge = "rules"
+)
+
+// GroundingPolicy controls how answers are verified against their sources
+type GroundingPolicy struct {
+	Judge GroundingJudge
+}
+
+// GroundingResult is the verdict on every claim of an answer. Score is the
+// share of claims the sources support.
+type GroundingResult struct {
+	Claims          []domain.ClaimCheck
+	Score           float64
+	NoHallucination bool
+}
+
+// UnsupportedClaims lists the claims the sources don't support
+func (r *GroundingResult) UnsupportedClaims() []domain.ClaimCheck {
+	unsupported := []domain.ClaimCheck{}
+	for _, claim := range r.Claims {
+		if !claim.Supported {
+			unsupported = append(unsupported, claim)
+		}
+	}
+	return unsupported
+}
+
+// groundingClaim is an atomic statement of an answer with the source
+// numbers it cites
+type groundingClaim struct {
+	text    string
+	sources []int
+}
+
+// claimJudgement is an entailment judge's verdict on one claim
+type claimJudgement struct {
+	Claim   int    `json:"claim"`
+	Verdict string `json:"verdict"`
+	Reason  string `json:"reason"`
+}
+
+// Verdicts of the entailment judge
+var claimVerdicts = []string{"supported", "contradicted", "not_enough_information"}
+
+// minLexicalSupport is the share of a claim's terms that must appear in its
+// evidence when no judge verdict is available
+const minLexicalSupport = 0.6
+
+var (
+	// Clauses that assert nothing about the site: refusals, statements
+	// that the sources are silent, and offers of further help
+	nonClaimPattern = regexp.MustCompile(`(?i)\b(?:cannot|can't|unable to|no (?:information|record|mention|data)|not (?:mentioned|available|specified|provided|documented|stated)|(?:do|does) not (?:contain|include|mention|specify|say|state)|please let me know|further assistance)\b`)
+	listMarker      = regexp.MustCompile(`^(?:\d+[.)]|[-*\x{2022}])\s+`)
+	// Claims are split at semicolons and contrasts, so "replaced on 5 March,
+	// but the report does not say by whom" still checks the replacement
+	clauseBreak = regexp.MustCompile(`(?i)\s*(?:;|,\s*(?:but|although|though|whereas)\b)\s*`)
+
+	monthName = `(jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sep(?:t(?:ember)?)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?)\.?`
+
+	isoDate       = regexp.MustCompile(`\b(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})\b`)
+	numericDate   = regexp.MustCompile(`\b(\d{1,2})[-/.](\d{1,2})[-/.](\d{4}|\d{2})\b`)
+	monthDayDate  = regexp.MustCompile(`(?i)\b` + monthName + `\s+(\d{1,2})(?:st|nd|rd|th)?\b(?:,?\s+(\d{4})\b)?`)
+	dayMonthDate  = regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?` + monthName + `(?:,?\s+(\d{4})\b)?`)
+	monthYearDate = regexp.MustCompile(`(?i)\b` + monthName + `,?\s+(\d{4})\b`)
+
+	// Identifiers mix letters and digits: INV001, WO-12345, CB-05, 480V
+	claimIdentifier = regexp.MustCompile(`\b(?:[A-Za-z]+[-_#]?\d[\w-]*|\d+[A-Za-z][\w-]*)\b`)
+	ordinalPattern  = regexp.MustCompile(`^\d+(?:st|nd|rd|th)$`)
+	// Sources often space what an answer writes as one identifier
+	spacedIdentifiers = []*regexp.Regexp{
+		regexp.MustCompile(`\b[A-Za-z]+[\s#-]*\d+\b`),
+		regexp.MustCompile(`\b\d+(?:\.\d+)?\s*[A-Za-z]{1,4}\b`),
+	}
+	numberPattern = regexp.MustCompile(`\d+(?:[.,]\d+)*`)
+	digitRun      = regexp.MustCompile(`\d+`)
+	termPattern   = regexp.MustCompile(`[\p{L}]+`)
+)
+
+var monthNumbers = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// stopwords carry no facts, so they don't count towards lexical support
+var stopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "was": true, "were": true, "are": true, "has": true, "had": true,
+	"have": true, "been": true, "with": true, "that": true, "this": true, "these": true, "those": true,
+	"from": true, "into": true, "onto": true, "its": true, "their": true, "there": true, "which": true,
+	"who": true, "whom": true, "what": true, "when": true, "where": true, "also": true, "after": true,
+	"before": true, "during": true, "while": true, "then": true, "than": true, "both": true, "each": true,
+	"any": true, "all": true, "some": true, "such": true, "not": true, "but": true, "per": true, "via": true,
+	"based": true, "according": true, "source": true, "sources": true, "report": true, "reported": true,
+	"indicates": true, "shows": true, "states": true, "noted": true, "notes": true,
+}
+
+// extractClaims splits an answer into atomic claims: its sentences, split
+// further into clauses, without list markers, and without clauses that
+// assert nothing
+func extractClaims(answer string, sourceCount int) []groundingClaim {
+	numbered := make([]domain.AnswerCitation, sourceCount)
+	for i := range numbered {
+		numbered[i].SourceIndex = i + 1
+	}
+
+	claims := []groundingClaim{}
+	for _, segment := range answerSegments(answer, numbered) {
+		text := listMarker.ReplaceAllString(segment.Text, "")
+		if strings.HasSuffix(text, "?") {
+			continue
+		}
+		for _, clause := range clauseBreak.Split(text, -1) {
+			if strings.IndexFunc(clause, unicode.IsLetter) < 0 || isNonClaim(clause) {
+				continue
+			}
+			claims = append(claims, groundingClaim{
+				text:    strings.TrimSpace(clause),
+				sources: citationIndexes(segment.Citations),
+			})
+		}
+	}
+	return claims
+}
+
+// isNonClaim reports whether a clause is wholly a refusal or a note that the
+// sources are silent. One that still states a date, number or identifier,
+// all of which contain digits, is checked like any other claim.
+func isNonClaim(clause string) bool {
+	return nonClaimPattern.MatchString(clause) && !digitRun.MatchString(clause)
+}
+
+// sourceEvidence is the text each numbered source contributes as evidence
+func sourceEvidence(sources []domain.QuerySourceDetail) []string {
+	evidence := make([]string, len(sources))
+	for i, source := range sources {
+		evidence[i] = source.DocumentTitle + "\n" + source.RelevantExcerpt
+	}
+	return evidence
+}
+
+// checkClaims decides each claim. A date, number or identifier missing from
+// the claim's evidence fails it outright, since that is how fabricated
+// details show; otherwise the judge's verdict stands, or lexical support
+// when the judge gave none.
+func checkClaims(claims []groundingClaim, evidence []string, judgements map[int]claimJudgement) *GroundingResult {
+	result := &GroundingResult{Claims: make([]domain.ClaimCheck, len(claims))}
+
+	supported := 0
+	for i, claim := range claims {
+		check := domain.ClaimCheck{Text: claim.text, Sources: claim.sources}
+
+		facts := newEvidenceFacts(claimEvidence(claim, evidence))
+		missing := facts.missing(claim.text)
+		judgement, judged := judgements[i+1]
+		switch {
+		case len(evidence) == 0:
+			check.Reason = "no sources were available"
+		case missing != "":
+			check.Reason = missing + " does not appear in " + evidenceName(claim)
+		case judged:
+			check.Supported = judgement.Verdict == "supported"
+			if !check.Supported {
+				check.Reason = strings.ReplaceAll(judgement.Verdict, "_", " ")
+				if judgement.Reason != "" {
+					check.Reason += ": " + judgement.Reason
+				}
+			}
+		default:
+			support := facts.lexicalSupport(claim.text)
+			check.Supported = support >= minLexicalSupport
+			if !check.Supported {
+				check.Reason = fmt.Sprintf("only %.0f%% of its terms appear in %s", support*100, evidenceName(claim))
+			}
+		}
+
+		if check.Supported {
+			supported++
+		}
+		result.Claims[i] = check
+	}
+
+	if len(claims) > 0 {
+		result.Score = float64(supported) / float64(len(claims))
+	}
+	result.NoHallucination = supported == len(claims)
+	return result
+}
+
+// claimEvidence is the text of the sources a claim cites, or of every source
+// when it cites none
+func claimEvidence(claim groundingClaim, evidence []string) string {
+	if len(claim.sources) == 0 {
+		return strings.Join(evidence, "\n")
+	}
+	cited := make([]string, 0, len(claim.sources))
+	for _, number := range claim.sources {
+		cited = append(cited, evidence[number-1])
+	}
+	return strings.Join(cited, "\n")
+}
+
+func evidenceName(claim groundingClaim) string {
+	if len(claim.sources) == 0 {
+		return "the sources"
+	}
+	return "the cited sources"
+}
+
+// evidenceFacts indexes the dates, identifiers, numbers and terms of a
+// claim's evidence
+type evidenceFacts struct {
+	dates       map[string]bool
+	identifiers map[string]bool
+	numbers     map[string]bool
+	terms       map[string]bool
+}
+
+func newEvidenceFacts(text string) *evidenceFacts {
+	facts := &evidenceFacts{
+		dates:       make(map[string]bool),
+		identifiers: make(map[string]bool),
+		numbers:     make(map[string]bool),
+		terms:       make(map[string]bool),
+	}
+
+	mentions, _ := findDates(text)
+	for _, forms := range mentions {
+		for _, form := range forms {
+			facts.dates[form] = true
+			// A full date also backs a claim giving only its month or day
+			if len(form) == len("2006-01-02") {
+				facts.dates[form[:7]] = true
+				facts.dates[form[5:]] = true
+			}
+		}
+	}
+
+	for _, id := range claimIdentifier.FindAllString(text, -1) {
+		facts.identifiers[normalizeIdentifier(id)] = true
+	}
+	for _, pattern := range spacedIdentifiers {
+		for _, match := range pattern.FindAllString(text, -1) {
+			facts.identifiers[normalizeIdentifier(match)] = true
+		}
+	}
+
+	// Numbers are matched loosely: any figure in the evidence, including
+	// the parts of its dates and identifiers
+	for _, number := range numberPattern.FindAllString(text, -1) {
+		facts.numbers[normalizeNumber(number)] = true
+	}
+	for _, digits := range digitRun.FindAllString(text, -1) {
+		facts.numbers[normalizeNumber(digits)] = true
+	}
+
+	for _, term := range termPattern.FindAllString(strings.ToLower(text), -1) {
+		facts.terms[stem(term)] = true
+	}
+	return facts
+}
+
+// missing returns the first date, identifier or number of the claim that
+// the evidence doesn't contain
+func (f *evidenceFacts) missing(claim string) string {
+	mentions, rest := findDates(claim)
+	for i, forms := range mentions {
+		found := false
+		for _, form := range forms {
+			found = found || f.dates[form]
+		}
+		if !found {
+			return "the date " + mentionText(claim, rest, i)
+		}
+	}
+
+	for _, id := range claimIdentifier.FindAllString(rest, -1) {
+		if ordinalPattern.MatchString(id) {
+			continue
+		}
+		if !f.identifiers[normalizeIdentifier(id)] {
+			return id
+		}
+	}
+	rest = claimIdentifier.ReplaceAllStringFunc(rest, blank)
+
+	for _, number := range numberPattern.FindAllString(rest, -1) {
+		if !f.numbers[normalizeNumber(number)] {
+			return number
+		}
+	}
+	return ""
+}
+
+// lexicalSupport is the share of the claim's content terms found in the
+// evidence. Dates are left out; missing checks them exactly.
+func (f *evidenceFacts) lexicalSupport(claim string) float64 {
+	_, rest := findDates(claim)
+	total, found := 0, 0
+	for _, term := range termPattern.FindAllString(strings.ToLower(rest), -1) {
+		if len(term) < 3 || stopwords[term] {
+			continue
+		}
+		total++
+		if f.terms[stem(term)] {
+			found++
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(found) / float64(total)
+}
+
+// findDates returns the canonical forms of each date mentioned in text
+// (YYYY-MM-DD, or YYYY-MM and MM-DD for partial dates; both readings of an
+// ambiguous numeric date) and the text with the dates blanked out
+func findDates(text string) ([][]string, string) {
+	var mentions [][]string
+	rest := text
+
+	collect := func(pattern *regexp.Regexp, forms func(groups []string) []string) {
+		for _, match := range pattern.FindAllStringSubmatchIndex(rest, -1) {
+			groups := make([]string, len(match)/2)
+			for g := range groups {
+				if match[2*g] >= 0 {
+					groups[g] = rest[match[2*g]:match[2*g+1]]
+				}
+			}
+			if candidates := forms(groups); len(candidates) > 0 {
+				mentions = append(mentions, candidates)
+				rest = rest[:match[0]] + blank(rest[match[0]:match[1]]) + rest[match[1]:]
+			}
+		}
+	}
+
+	collect(isoDate, func(g []string) []string {
+		return dateForms(atoi(g[1]), atoi(g[2]), atoi(g[3]))
+	})
+	collect(numericDate, func(g []string) []string {
+		year := atoi(g[3])
+		if year < 100 {
+			year += 2000
+		}
+		// 03/04/2024 is 4 March in the US and 3 April elsewhere
+		forms := dateForms(year, atoi(g[1]), atoi(g[2]))
+		return append(forms, dateForms(year, atoi(g[2]), atoi(g[1]))...)
+	})
+	collect(monthDayDate, func(g []string) []string {
+		return dateForms(atoi(g[3]), monthNumber(g[1]), atoi(g[2]))
+	})
+	collect(dayMonthDate, func(g []string) []string {
+		return dateForms(atoi(g[3]), monthNumber(g[2]), atoi(g[1]))
+	})
+	collect(monthYearDate, func(g []string) []string {
+		return []string{fmt.Sprintf("%04d-%02d", atoi(g[2]), monthNumber(g[1]))}
+	})
+
+	return mentions, rest
+}
+
+// dateForms is the canonical form of a valid date; year is 0 when unknown
+func dateForms(year, month, day int) []string {
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return nil
+	}
+	if year == 0 {
+		return []string{fmt.Sprintf("%02d-%02d", month, day)}
+	}
+	return []string{fmt.Sprintf("%04d-%02d-%02d", year, month, day)}
+}
+
+// mentionText recovers the i-th blanked date of claim for the report
+func mentionText(claim, rest string, i int) string {
+	count := 0
+	start := -1
+	for pos := 0; pos <= len(rest); pos++ {
+		blanked := pos < len(rest) && rest[pos] == 0
+		if blanked && start < 0 {
+			start = pos
+		}
+		if !blanked && start >= 0 {
+			if count == i {
+				return strings.TrimSpace(claim[start:pos])
+			}
+			count++
+			start = -1
+		}
+	}
+	return "in the claim"
+}
+
+// blank replaces text with NUL bytes of the same length, so positions stay
+// valid and nothing else matches there
+func blank(text string) string {
+	return strings.Repeat("\x00", len(text))
+}
+
+func monthNumber(name string) int {
+	name = strings.ToLower(name)
+	if len(name) > 3 {
+		name = name[:3]
+	}
+	return monthNumbers[name]
+}
+
+func atoi(text string) int {
+	value, _ := strconv.Atoi(text)
+	return value
+}
+
+func normalizeIdentifier(id string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(id) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// normalizeNumber drops thousands separators and insignificant zeros, so
+// 1,200 matches 1200 and 3.50 matches 3.5
+func normalizeNumber(number string) string {
+	plain := strings.ReplaceAll(number, ",", "")
+	if value, err := strconv.ParseFloat(plain, 64); err == nil {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return plain
+}
+
+// stemLength is how much of a word is compared, so "replaced" matches
+// "replacement" and "inspected" matches "inspection"
+const stemLength = 6
+
+// stem strips common inflections and keeps the start of the word
+func stem(term string) string {
+	for _, suffix := range []string{"ing", "ed", "es", "s"} {
+		if strings.HasSuffix(term, suffix) && len(term)-len(suffix) >= 3 {
+			term = strings.TrimSuffix(term, suffix)
+			break
+		}
+	}
+	if runes := []rune(term); len(runes) > stemLength {
+		return string(runes[:stemLength])
+	}
+	return term
+}
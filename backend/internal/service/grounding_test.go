@@ -0,0 +1,182 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+)
+
+func TestCheckClaims(t *testing.T) {
+	workOrder := domain.QuerySourceDetail{
+		DocumentTitle:   "Work order WO-118",
+		RelevantExcerpt: "Technician reset INV001 on 2024-03-05 after a ground fault and replaced fuse F3 in combiner CB-05.",
+	}
+	inspection := domain.QuerySourceDetail{
+		DocumentTitle:   "Inspection report",
+		RelevantExcerpt: "Measured 1,200 V on string 4. Tracker row 12 was stowed for high wind.",
+	}
+
+	tests := []struct {
+		name                string
+		answer              string
+		sources             []domain.QuerySourceDetail
+		judgements          map[int]claimJudgement
+		want                []domain.ClaimCheck
+		wantScore           float64
+		wantNoHallucination bool
+	}{
+		{
+			name:    "supported claims",
+			answer:  "INV001 was reset on 5 March 2024 [Source 1]. String 4 measured 1200 V [Source 2].",
+			sources: []domain.QuerySourceDetail{workOrder, inspection},
+			want: []domain.ClaimCheck{
+				{Text: "INV001 was reset on 5 March 2024.", Sources: []int{1}, Supported: true},
+				{Text: "String 4 measured 1200 V.", Sources: []int{2}, Supported: true},
+			},
+			wantScore:           1,
+			wantNoHallucination: true,
+		},
+		{
+			name:    "uncited claim is checked against every source",
+			answer:  "Tracker row 12 was stowed.",
+			sources: []domain.QuerySourceDetail{workOrder, inspection},
+			want: []domain.ClaimCheck{
+				{Text: "Tracker row 12 was stowed.", Sources: []int{}, Supported: true},
+			},
+			wantScore:           1,
+			wantNoHallucination: true,
+		},
+		{
+			name:    "unsupported date, identifier and number",
+			answer:  "INV001 was reset on 9 March 2024 [Source 1]. Fuse F7 was replaced [Source 1]. String 4 measured 1,300 V [Source 2].",
+			sources: []domain.QuerySourceDetail{workOrder, inspection},
+			want: []domain.ClaimCheck{
+				{Text: "INV001 was reset on 9 March 2024.", Sources: []int{1}, Reason: "the date 9 March 2024 does not appear in the cited sources"},
+				{Text: "Fuse F7 was replaced.", Sources: []int{1}, Reason: "F7 does not appear in the cited sources"},
+				{Text: "String 4 measured 1,300 V.", Sources: []int{2}, Reason: "1,300 does not appear in the cited sources"},
+			},
+		},
+		{
+			name:    "fact from a source the claim doesn't cite",
+			answer:  "Tracker row 12 was stowed [Source 1].",
+			sources: []domain.QuerySourceDetail{workOrder, inspection},
+			want: []domain.ClaimCheck{
+				{Text: "Tracker row 12 was stowed.", Sources: []int{1}, Reason: "12 does not appear in the cited sources"},
+			},
+		},
+		{
+			name:    "partially supported answer",
+			answer:  "Fuse F3 was replaced [Source 1]. The tracker motor overheated badly [Source 2].",
+			sources: []domain.QuerySourceDetail{workOrder, inspection},
+			want: []domain.ClaimCheck{
+				{Text: "Fuse F3 was replaced.", Sources: []int{1}, Supported: true},
+				{Text: "The tracker motor overheated badly.", Sources: []int{2}, Reason: "only 25% of its terms appear in the cited sources"},
+			},
+			wantScore: 0.5,
+		},
+		{
+			name:    "silent clause of a claim is not checked",
+			answer:  "Fuse F3 was replaced on 5 March 2024, but the report does not say by whom [Source 1].",
+			sources: []domain.QuerySourceDetail{workOrder},
+			want: []domain.ClaimCheck{
+				{Text: "Fuse F3 was replaced on 5 March 2024", Sources: []int{1}, Supported: true},
+			},
+			wantScore:           1,
+			wantNoHallucination: true,
+		},
+		{
+			name:    "judge verdict overrides lexical support",
+			answer:  "INV001 was reset [Source 1]. Fuse F3 was replaced [Source 1].",
+			sources: []domain.QuerySourceDetail{workOrder},
+			judgements: map[int]claimJudgement{
+				1: {Claim: 1, Verdict: "supported"},
+				2: {Claim: 2, Verdict: "not_enough_information", Reason: "the fuse rating is not given"},
+			},
+			want: []domain.ClaimCheck{
+				{Text: "INV001 was reset.", Sources: []int{1}, Supported: true},
+				{Text: "Fuse F3 was replaced.", Sources: []int{1}, Reason: "not enough information: the fuse rating is not given"},
+			},
+			wantScore: 0.5,
+		},
+		{
+			name:       "missing fact fails a claim the judge supports",
+			answer:     "Fuse F9 was replaced [Source 1].",
+			sources:    []domain.QuerySourceDetail{workOrder},
+			judgements: map[int]claimJudgement{1: {Claim: 1, Verdict: "supported"}},
+			want: []domain.ClaimCheck{
+				{Text: "Fuse F9 was replaced.", Sources: []int{1}, Reason: "F9 does not appear in the cited sources"},
+			},
+		},
+		{
+			name:   "no sources",
+			answer: "INV001 was reset [Source 1].",
+			want: []domain.ClaimCheck{
+				{Text: "INV001 was reset.", Sources: []int{}, Reason: "no sources were available"},
+			},
+		},
+		{
+			name:                "refusal has no claims",
+			answer:              "I cannot find any information about the tracker in the documents.",
+			sources:             []domain.QuerySourceDetail{workOrder},
+			want:                []domain.ClaimCheck{},
+			wantNoHallucination: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := extractClaims(tt.answer, len(tt.sources))
+			result := checkClaims(claims, sourceEvidence(tt.sources), tt.judgements)
+
+			if !reflect.DeepEqual(result.Claims, tt.want) {
+				t.Errorf("checkClaims() claims = %+v, want %+v", result.Claims, tt.want)
+			}
+			if result.Score != tt.wantScore {
+				t.Errorf("checkClaims() score = %v, want %v", result.Score, tt.wantScore)
+			}
+			if result.NoHallucination != tt.wantNoHallucination {
+				t.Errorf("checkClaims() NoHallucination = %v, want %v", result.NoHallucination, tt.wantNoHallucination)
+			}
+		})
+	}
+}
+
+func TestIsNonClaim(t *testing.T) {
+	tests := []struct {
+		clause string
+		want   bool
+	}{
+		{"The documents do not mention who performed the repair", true},
+		{"I'm unable to find a record of that", true},
+		{"Please let me know if you need further assistance", true},
+		{"INV001 was reset on 5 March", false},
+		{"No information was recorded after WO-118", false},
+	}
+
+	for _, tt := range tests {
+		if got := isNonClaim(tt.clause); got != tt.want {
+			t.Errorf("isNonClaim(%q) = %v, want %v", tt.clause, got, tt.want)
+		}
+	}
+}
+
+func TestFindDates(t *testing.T) {
+	tests := []struct {
+		text string
+		want [][]string
+	}{
+		{"reset on 2024-03-05", [][]string{{"2024-03-05"}}},
+		{"reset on 03/04/2024", [][]string{{"2024-03-04", "2024-04-03"}}},
+		{"reset on March 5th, 2024", [][]string{{"2024-03-05"}}},
+		{"reset on the 5th of March", [][]string{{"03-05"}}},
+		{"reset in March 2024", [][]string{{"2024-03"}}},
+		{"reset on 13/13/2024", nil},
+	}
+
+	for _, tt := range tests {
+		if got, _ := findDates(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findDates(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
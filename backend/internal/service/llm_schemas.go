@@ -0,0 +1,94 @@
+package service
+
+import (
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/llm"
+)
+
+// Response schemas for the LLM calls that return JSON. Enumerated fields
+// mirror the database enums, so a reply that passes validation can be
+// stored as is.
+
+// Query intent types understood by the query service. "search" comes first
+// as the neutral choice for providers that can only fill in defaults.
+var queryIntentTypes = []string{"search", "timeline", "maintenance_history", "component_status", "analysis"}
+
+// Response types of an enhanced query answer
+var responseTypes = []string{"summary", "timeline", "list", "analysis"}
+
+var actionExtractionSchema = llm.ResponseSchema{
+	Name: "action_extraction",
+	Schema: llm.Object(map[string]*llm.JSONSchema{
+		"actions": llm.Array(llm.Object(map[string]*llm.JSONSchema{
+			"action_type":       llm.Enum(domain.ActionTypes...),
+			"description":       llm.String("Brief description of what was done"),
+			"component_type":    llm.Enum(domain.ComponentTypes...),
+			"component_id":      llm.String("External ID of the component if identifiable, otherwise empty"),
+			"technician_names":  llm.Array(llm.String("")),
+			"work_order_number": llm.String("Work order number, otherwise empty"),
+			"action_date":       llm.String("RFC 3339 date-time of the action"),
+			"action_status":     llm.Enum(domain.ActionStatuses...),
+			"confidence_score":  llm.Number("Confidence in the extraction between 0 and 1"),
+			"details":           llm.String("Additional context, otherwise empty"),
+		})),
+	}),
+}
+
+// entityLists are the entity kinds returned by entity extraction
+func entityLists(kinds ...string) *llm.JSONSchema {
+	properties := make(map[string]*llm.JSONSchema, len(kinds))
+	for _, kind := range kinds {
+		properties[kind] = llm.Array(llm.String(""))
+	}
+	return llm.Object(properties)
+}
+
+var queryIntentSchema = llm.ResponseSchema{
+	Name: "query_intent",
+	Schema: llm.Object(map[string]*llm.JSONSchema{
+		"type":               llm.Enum(queryIntentTypes...),
+		"confidence":         llm.Number("Confidence in the classification between 0 and 1"),
+		"extracted_entities": entityLists("components", "dates", "maintenance_types", "technicians", "work_orders"),
+		"related_concepts":   llm.Array(llm.String("")),
+		"required_sources":   llm.Array(llm.Enum(domain.DocumentTypes...)),
+		"date_range": llm.Object(map[string]*llm.JSONSchema{
+			"start": llm.String("YYYY-MM-DD, or empty when the query has no date range"),
+			"end":   llm.String("YYYY-MM-DD, or empty when the query has no date range"),
+		}),
+		"component_filters": llm.Array(llm.Enum(domain.ComponentTypes...)),
+	}),
+}
+
+var entitiesSchema = llm.ResponseSchema{
+	Name:   "entities",
+	Schema: entityLists("components", "dates", "maintenance_types", "technicians", "work_orders", "locations", "issues"),
+}
+
+var enhancedResponseSchema = llm.ResponseSchema{
+	Name: "enhanced_response",
+	Schema: llm.Object(map[string]*llm.JSONSchema{
+		"answer":           llm.String("Answer with [Source N] citations"),
+		"confidence_score": llm.Number("Confidence in the answer between 0 and 1"),
+		"related_concepts": llm.Array(llm.String("")),
+		"response_type":    llm.Enum(responseTypes...),
+	}),
+}
+
+var followUpRewriteSchema = llm.ResponseSchema{
+	Name: "follow_up_rewrite",
+	Schema: llm.Object(map[string]*llm.JSONSchema{
+		"standalone_query":        llm.String("The follow-up rewritten to stand on its own"),
+		"reuses_previous_sources": llm.Boolean("Whether the follow-up is about the sources of the previous answer"),
+	}),
+}
+
+var claimJudgementSchema = llm.ResponseSchema{
+	Name: "claim_judgements",
+	Schema: llm.Object(map[string]*llm.JSONSchema{
+		"judgements": llm.Array(llm.Object(map[string]*llm.JSONSchema{
+			"claim":   llm.Integer("Number of the claim"),
+			"verdict": llm.Enum(claimVerdicts...),
+			"reason":  llm.String("What the sources say, briefly, when the claim isn't supported"),
+		})),
+	}),
+}
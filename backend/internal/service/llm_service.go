@@ -1,15 +1,17 @@
 package service
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/llm"
 	"github.com/engramiq/engramiq-backend/internal/repository"
+	"github.com/engramiq/engramiq-backend/pkg/logger"
 	"github.com/google/uuid"
 	"github.com/pgvector/pgvector-go"
 )
@@ -25,145 +27,182 @@ type QueryResult struct {
 }
 
 type LLMService interface {
-	GenerateEmbedding(text string) (pgvector.Vector, error)
-	ExtractActions(content string, siteID uuid.UUID) ([]*domain.ExtractedAction, error)
-	ProcessNaturalLanguageQuery(query string, siteID uuid.UUID) (*QueryResult, error)
-	SummarizeDocument(content string) (string, error)
-	
-	// Enhanced methods per PRD requirements
-	AnalyzeQueryIntent(query string, siteID uuid.UUID) (*domain.QueryIntent, error)
-	ExtractEntities(text string) (map[string][]string, error)
-	GenerateEnhancedResponse(query string, sources []domain.QuerySourceDetail) (*domain.EnhancedQueryResponse, error)
-	ValidateResponseAgainstSources(answer string, sources []domain.QuerySourceDetail) (float64, error)
-}
-
-type llmService struct {
-	apiKey      string
-	apiURL      string
-	model       string
-	client      *http.Client
-	actionRepo  repository.ActionRepository
-	componentRepo repository.ComponentRepository
-}
+	GenerateEmbedding(ctx context.Context, text string) (pgvector.Vector, error)
+	// GenerateEmbeddings embeds many texts with as few provider calls as
+	// possible, returning one vector per text in order
+	GenerateEmbeddings(ctx context.Context, texts []string) ([]pgvector.Vector, error)
+	ExtractActions(ctx context.Context, content string, siteID uuid.UUID) ([]*domain.ExtractedAction, error)
+	ProcessNaturalLanguageQuery(ctx context.Context, query string, siteID uuid.UUID) (*QueryResult, error)
+	SummarizeDocument(ctx context.Context, content string) (string, error)
 
-type OpenAIRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	Stream   bool      `json:"stream,omitempty"`
-}
-
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	// Enhanced methods per PRD requirements
+	AnalyzeQueryIntent(ctx context.Context, query string, siteID uuid.UUID) (*domain.QueryIntent, error)
+	ExtractEntities(ctx context.Context, text string) (map[string][]string, error)
+	RewriteFollowUpQuery(ctx context.Context, history []*domain.UserQuery, query string) (*FollowUpRewrite, error)
+	GenerateEnhancedResponse(ctx context.Context, query string, sources []domain.QuerySourceDetail) (*domain.EnhancedQueryResponse, error)
+	StreamEnhancedResponse(ctx context.Context, query string, sources []domain.QuerySourceDetail, onToken func(string) error) (*domain.EnhancedQueryResponse, error)
+	ValidateResponseAgainstSources(ctx context.Context, answer string, sources []domain.QuerySourceDetail) (*GroundingResult, error)
 }
 
-type OpenAIResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
-	Error *struct {
-		Message string `json:"message"`
-		Type    string `json:"type"`
-	} `json:"error,omitempty"`
+// GenerationSettings are applied to every LLM call. Timeout bounds each call
+// on its own; the caller's context can still cancel it sooner.
+type GenerationSettings struct {
+	Temperature float64
+	MaxTokens   int
+	Timeout     time.Duration
 }
 
-type EmbeddingRequest struct {
-	Model string   `json:"model"`
-	Input []string `json:"input"`
+type llmService struct {
+	provider      llm.Provider
+	settings      GenerationSettings
+	grounding     GroundingPolicy
+	embeddings    EmbeddingPolicy
+	pricing       *llm.Pricing
+	usageRepo     repository.UsageRepository
+	actionRepo    repository.ActionRepository
+	componentRepo repository.ComponentRepository
+	log           *logger.Logger
 }
 
-type EmbeddingResponse struct {
-	Data []struct {
-		Embedding []float64 `json:"embedding"`
-	} `json:"data"`
-	Error *struct {
-		Message string `json:"message"`
-		Type    string `json:"type"`
-	} `json:"error,omitempty"`
+// FollowUpRewrite is a conversation follow-up rewritten to stand on its own.
+// ReusesPreviousSources is set when the follow-up asks about what the
+// previous answer was based on.
+type FollowUpRewrite struct {
+	StandaloneQuery       string `json:"standalone_query"`
+	ReusesPreviousSources bool   `json:"reuses_previous_sources"`
 }
 
 type ActionExtractionResult struct {
 	Actions []struct {
-		ActionType        string    `json:"action_type"`
-		Description       string    `json:"description"`
-		ComponentType     string    `json:"component_type"`
-		ComponentID       string    `json:"component_id,omitempty"`
-		TechnicianNames   []string  `json:"technician_names"`
-		WorkOrderNumber   string    `json:"work_order_number,omitempty"`
-		ActionDate        string    `json:"action_date"`
-		ActionStatus      string    `json:"action_status"`
-		ConfidenceScore   float64   `json:"confidence_score"`
-		Details           string    `json:"details"`
+		ActionType      string   `json:"action_type"`
+		Description     string   `json:"description"`
+		ComponentType   string   `json:"component_type"`
+		ComponentID     string   `json:"component_id,omitempty"`
+		TechnicianNames []string `json:"technician_names"`
+		WorkOrderNumber string   `json:"work_order_number,omitempty"`
+		ActionDate      string   `json:"action_date"`
+		ActionStatus    string   `json:"action_status"`
+		ConfidenceScore float64  `json:"confidence_score"`
+		Details         string   `json:"details"`
 	} `json:"actions"`
 }
 
 func NewLLMService(
-	apiKey string,
-	apiURL string, 
-	model string,
+	provider llm.Provider,
+	settings GenerationSettings,
+	grounding GroundingPolicy,
+	embeddings EmbeddingPolicy,
+	pricing *llm.Pricing,
+	usageRepo repository.UsageRepository,
 	actionRepo repository.ActionRepository,
 	componentRepo repository.ComponentRepository,
+	log *logger.Logger,
 ) LLMService {
 	return &llmService{
-		apiKey:        apiKey,
-		apiURL:        apiURL,
-		model:         model,
-		client:        &http.Client{Timeout: 120 * time.Second},
+		provider:      provider,
+		settings:      settings,
+		grounding:     grounding,
+		embeddings:    embeddings,
+		pricing:       pricing,
+		usageRepo:     usageRepo,
 		actionRepo:    actionRepo,
 		componentRepo: componentRepo,
+		log:           log,
 	}
 }
 
-func (s *llmService) GenerateEmbedding(text string) (pgvector.Vector, error) {
-	reqBody := EmbeddingRequest{
-		Model: "text-embedding-ada-002",
-		Input: []string{text},
+// callContext bounds a single LLM call by the configured timeout
+func (s *llmService) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.settings.Timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, s.settings.Timeout)
+}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return pgvector.Vector{}, fmt.Errorf("failed to marshal request: %w", err)
-	}
+// maxRepairAttempts bounds how often a reply that doesn't match its schema
+// is sent back to the model for correction
+const maxRepairAttempts = 2
 
-	req, err := http.NewRequest("POST", s.apiURL+"/embeddings", bytes.NewBuffer(jsonData))
+// chat sends req to the configured provider with the generation settings
+// applied and returns the reply
+func (s *llmService) chat(ctx context.Context, req llm.ChatRequest) (string, error) {
+	ctx, cancel := s.callContext(ctx)
+	defer cancel()
+
+	req = s.withSettings(req)
+	started := time.Now()
+	resp, err := s.provider.Chat(ctx, req)
+	s.recordUsage(ctx, domain.LLMCallChat, chatModel(resp), chatUsage(req, resp), started, err)
 	if err != nil {
-		return pgvector.Vector{}, fmt.Errorf("failed to create request: %w", err)
+		return "", err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	return resp.Content, nil
+}
+
+// chatStream is chat with the reply passed to onDelta as it is generated
+func (s *llmService) chatStream(ctx context.Context, req llm.ChatRequest, onDelta func(string) error) (string, error) {
+	ctx, cancel := s.callContext(ctx)
+	defer cancel()
 
-	resp, err := s.client.Do(req)
+	req = s.withSettings(req)
+	started := time.Now()
+	resp, err := s.provider.ChatStream(ctx, req, onDelta)
+	s.recordUsage(ctx, domain.LLMCallChat, chatModel(resp), chatUsage(req, resp), started, err)
 	if err != nil {
-		return pgvector.Vector{}, fmt.Errorf("failed to send request: %w", err)
+		return "", err
 	}
-	defer resp.Body.Close()
 
-	var embeddingResp EmbeddingResponse
-	if err := json.NewDecoder(resp.Body).Decode(&embeddingResp); err != nil {
-		return pgvector.Vector{}, fmt.Errorf("failed to decode response: %w", err)
-	}
+	return resp.Content, nil
+}
 
-	if embeddingResp.Error != nil {
-		return pgvector.Vector{}, fmt.Errorf("OpenAI API error: %s", embeddingResp.Error.Message)
-	}
+func (s *llmService) withSettings(req llm.ChatRequest) llm.ChatRequest {
+	temperature := s.settings.Temperature
+	req.Temperature = &temperature
+	req.MaxTokens = s.settings.MaxTokens
+	return req
+}
 
-	if len(embeddingResp.Data) == 0 {
-		return pgvector.Vector{}, fmt.Errorf("no embedding data returned")
-	}
+// chatStructured asks for a reply following schema and decodes it into out.
+// A reply that isn't valid JSON or breaks the schema (a missing field, an
+// enum value the database would reject) is returned to the model with the
+// problems listed, up to maxRepairAttempts times, before giving up.
+func (s *llmService) chatStructured(ctx context.Context, messages []llm.Message, schema llm.ResponseSchema, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		reply, err := s.chat(ctx, llm.ChatRequest{Messages: messages, Schema: &schema})
+		if err != nil {
+			return err
+		}
+
+		problems := schema.Check(reply)
+		if len(problems) == 0 {
+			return json.Unmarshal([]byte(reply), out)
+		}
+		if attempt == maxRepairAttempts {
+			return fmt.Errorf("LLM %s reply did not match its schema after %d attempts: %s",
+				schema.Name, attempt+1, strings.Join(problems, "; "))
+		}
 
-	// Convert to pgvector format
-	embedding := make([]float32, len(embeddingResp.Data[0].Embedding))
-	for i, val := range embeddingResp.Data[0].Embedding {
-		embedding[i] = float32(val)
+		messages = append(messages,
+			llm.Message{Role: "assistant", Content: reply},
+			llm.Message{Role: "user", Content: repairPrompt(problems)},
+		)
 	}
+}
+
+// maxReportedProblems keeps repair prompts short when a reply is badly off
+const maxReportedProblems = 10
 
-	return pgvector.NewVector(embedding), nil
+func repairPrompt(problems []string) string {
+	if len(problems) > maxReportedProblems {
+		problems = append(problems[:maxReportedProblems:maxReportedProblems], fmt.Sprintf("and %d more", len(problems)-maxReportedProblems))
+	}
+	return "Your reply did not match the required JSON schema:\n- " + strings.Join(problems, "\n- ") +
+		"\n\nReply again with only the corrected JSON object, using only the allowed values for enumerated fields."
 }
 
-func (s *llmService) ExtractActions(content string, siteID uuid.UUID) ([]*domain.ExtractedAction, error) {
+func (s *llmService) ExtractActions(ctx context.Context, content string, siteID uuid.UUID) ([]*domain.ExtractedAction, error) {
+	ctx = withUsageOperation(ctx, usageExtractActions)
 	// Get site components for context
 	components, err := s.componentRepo.ListBySite(siteID, &domain.Pagination{Limit: 100}, nil)
 	if err != nil {
@@ -189,14 +228,14 @@ Return ONLY this JSON structure (no other text):
 {
   "actions": [
     {
-      "action_type": "maintenance|repair|replacement|inspection|troubleshoot|other",
+      "action_type": "%s",
       "description": "Brief description of what was done",
-      "component_type": "inverter|combiner|panel|transformer|meter|switchgear|monitoring|other",
+      "component_type": "%s",
       "component_id": "external ID if identifiable or empty string",
       "technician_names": ["name1", "name2"],
       "work_order_number": "WO number or empty string",
       "action_date": "2024-11-05T00:00:00Z",
-      "action_status": "completed|pending|failed",
+      "action_status": "%s",
       "confidence_score": 0.95,
       "details": "Additional context or empty string"
     }
@@ -205,85 +244,21 @@ Return ONLY this JSON structure (no other text):
 
 If no actions are found, return: {"actions": []}
 
-REMEMBER: Return ONLY the JSON, nothing else.`, componentContext, content)
+REMEMBER: Return ONLY the JSON, nothing else.`, componentContext, content,
+		joinEnum(domain.ActionTypes), joinEnum(domain.ComponentTypes), joinEnum(domain.ActionStatuses))
 
-	messages := []Message{
+	messages := []llm.Message{
 		{Role: "system", Content: "You are a maintenance action extraction specialist for solar power systems."},
 		{Role: "user", Content: prompt},
 	}
 
-	reqBody := OpenAIRequest{
-		Model:    s.model,
-		Messages: messages,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", s.apiURL+"/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	var openAIResp OpenAIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&openAIResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if openAIResp.Error != nil {
-		return nil, fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
-	}
-
-	if len(openAIResp.Choices) == 0 {
-		return nil, fmt.Errorf("no response choices returned")
-	}
-
-	// Parse the JSON response
-	responseContent := openAIResp.Choices[0].Message.Content
-	fmt.Printf("LLM ExtractActions Response: %s\n", responseContent)
-	fmt.Printf("DEBUG: Response length: %d\n", len(responseContent))
-	
 	var extractionResult ActionExtractionResult
-	if err := json.Unmarshal([]byte(responseContent), &extractionResult); err != nil {
-		fmt.Printf("JSON parsing error: %v\nRaw response: %s\n", err, responseContent)
-		
-		// Try to extract JSON from response if it's wrapped in text
-		if startIdx := strings.Index(responseContent, "{"); startIdx >= 0 {
-			if endIdx := strings.LastIndex(responseContent, "}"); endIdx > startIdx {
-				jsonPart := responseContent[startIdx : endIdx+1]
-				fmt.Printf("Attempting to parse extracted JSON: %s\n", jsonPart)
-				if err := json.Unmarshal([]byte(jsonPart), &extractionResult); err == nil {
-					fmt.Printf("Successfully parsed extracted JSON\n")
-				} else {
-					fmt.Printf("Failed to parse extracted JSON: %v\n", err)
-					// Return empty result instead of failing
-					return []*domain.ExtractedAction{}, nil
-				}
-			} else {
-				// Return empty result instead of failing
-				return []*domain.ExtractedAction{}, nil
-			}
-		} else {
-			// Return empty result instead of failing
-			return []*domain.ExtractedAction{}, nil
-		}
+	if err := s.chatStructured(ctx, messages, actionExtractionSchema, &extractionResult); err != nil {
+		return nil, err
 	}
 
 	// Convert to domain models
 	actions := make([]*domain.ExtractedAction, 0, len(extractionResult.Actions))
-	fmt.Printf("DEBUG: Found %d actions in extraction result\n", len(extractionResult.Actions))
-
 	for _, result := range extractionResult.Actions {
 		actionDate, _ := time.Parse(time.RFC3339, result.ActionDate)
 		if actionDate.IsZero() {
@@ -301,30 +276,28 @@ REMEMBER: Return ONLY the JSON, nothing else.`, componentContext, content)
 
 		action := &domain.ExtractedAction{
 			ID:                   uuid.New(),
-			SiteID:              siteID,
-			ActionType:          domain.ActionType(result.ActionType),
-			Title:               result.Description, // Set the required Title field
-			Description:         result.Description,
-			TechnicianNames:     result.TechnicianNames,
-			WorkOrderNumber:     result.WorkOrderNumber,
-			ActionDate:          &actionDate,
-			ActionStatus:        domain.ActionStatus(result.ActionStatus),
+			SiteID:               siteID,
+			ActionType:           domain.ActionType(result.ActionType),
+			Title:                result.Description, // Set the required Title field
+			Description:          result.Description,
+			TechnicianNames:      result.TechnicianNames,
+			WorkOrderNumber:      result.WorkOrderNumber,
+			ActionDate:           &actionDate,
+			ActionStatus:         domain.ActionStatus(result.ActionStatus),
 			ExtractionConfidence: result.ConfidenceScore,
-			ExtractionMetadata:  domain.JSON{"details": result.Details},
-			PrimaryComponentID:  primaryComponentID,
-			// Don't set Embedding - let GORM use database default (null)
-			CreatedAt:           time.Now(),
-			UpdatedAt:           time.Now(),
+			ExtractionMetadata:   domain.JSON{"details": result.Details, "component_type": result.ComponentType},
+			PrimaryComponentID:   primaryComponentID,
+			// Embedded by the caller before saving; left unset it's stored as null
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
 		}
 
 		actions = append(actions, action)
 	}
-
-	fmt.Printf("DEBUG: Returning %d actions from ExtractActions\n", len(actions))
 	return actions, nil
 }
 
-func (s *llmService) ProcessNaturalLanguageQuery(query string, siteID uuid.UUID) (*QueryResult, error) {
+func (s *llmService) ProcessNaturalLanguageQuery(ctx context.Context, query string, siteID uuid.UUID) (*QueryResult, error) {
 	// This is a placeholder implementation
 	// In a real implementation, this would:
 	// 1. Analyze the query to determine intent
@@ -345,50 +318,19 @@ func (s *llmService) ProcessNaturalLanguageQuery(query string, siteID uuid.UUID)
 	return result, nil
 }
 
-func (s *llmService) SummarizeDocument(content string) (string, error) {
-	messages := []Message{
+func (s *llmService) SummarizeDocument(ctx context.Context, content string) (string, error) {
+	ctx = withUsageOperation(ctx, usageSummarizeDocument)
+	messages := []llm.Message{
 		{Role: "system", Content: "You are a document summarization specialist for solar maintenance reports."},
 		{Role: "user", Content: fmt.Sprintf("Please provide a concise summary of this solar field service report:\n\n%s", content)},
 	}
 
-	reqBody := OpenAIRequest{
-		Model:    s.model,
-		Messages: messages,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
+	responseContent, err := s.chat(ctx, llm.ChatRequest{Messages: messages})
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", s.apiURL+"/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	var openAIResp OpenAIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&openAIResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if openAIResp.Error != nil {
-		return "", fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
+		return "", err
 	}
 
-	if len(openAIResp.Choices) == 0 {
-		return "", fmt.Errorf("no response choices returned")
-	}
-
-	return openAIResp.Choices[0].Message.Content, nil
+	return responseContent, nil
 }
 
 func (s *llmService) buildComponentContext(components []*domain.SiteComponent) string {
@@ -401,7 +343,8 @@ func (s *llmService) buildComponentContext(components []*domain.SiteComponent) s
 
 // Enhanced methods per PRD requirements
 
-func (s *llmService) AnalyzeQueryIntent(query string, siteID uuid.UUID) (*domain.QueryIntent, error) {
+func (s *llmService) AnalyzeQueryIntent(ctx context.Context, query string, siteID uuid.UUID) (*domain.QueryIntent, error) {
+	ctx = withUsageOperation(ctx, usageAnalyzeIntent)
 	// Get site components for context
 	components, err := s.componentRepo.ListBySite(siteID, &domain.Pagination{Limit: 100}, nil)
 	if err != nil {
@@ -431,71 +374,36 @@ Return JSON with the following structure:
     "work_orders": ["WO-12345"]
   },
   "related_concepts": ["power output", "electrical issues", "warranty"],
-  "required_sources": ["field_service_report", "maintenance_log"],
+  "required_sources": ["field_service_report", "work_order"],
   "date_range": {
     "start": "2023-11-01",
     "end": "2023-12-31"
   },
   "component_filters": ["inverter", "combiner"]
-}`, componentContext, query)
+}
 
-	messages := []Message{
+Use empty strings for the date range "start" and "end" when the query has no date range.`, componentContext, query)
+
+	messages := []llm.Message{
 		{Role: "system", Content: "You are a solar asset management query analysis specialist. Always return valid JSON."},
 		{Role: "user", Content: prompt},
 	}
 
-	reqBody := OpenAIRequest{
-		Model:    s.model,
-		Messages: messages,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", s.apiURL+"/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	var openAIResp OpenAIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&openAIResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if openAIResp.Error != nil {
-		return nil, fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
-	}
-
-	if len(openAIResp.Choices) == 0 {
-		return nil, fmt.Errorf("no response choices returned")
+	var intent domain.QueryIntent
+	if err := s.chatStructured(ctx, messages, queryIntentSchema, &intent); err != nil {
+		return nil, err
 	}
 
-	// Parse the JSON response
-	responseContent := openAIResp.Choices[0].Message.Content
-	fmt.Printf("LLM Intent Response: %s\n", responseContent)
-	
-	var intent domain.QueryIntent
-	if err := json.Unmarshal([]byte(responseContent), &intent); err != nil {
-		// Log the raw response for debugging
-		fmt.Printf("Failed to parse intent JSON. Raw response: %s\n", responseContent)
-		return nil, fmt.Errorf("failed to parse intent result: %w", err)
+	// The schema always includes a date range; empty bounds mean none
+	if intent.DateRange != nil && intent.DateRange.Start == "" && intent.DateRange.End == "" {
+		intent.DateRange = nil
 	}
 
 	return &intent, nil
 }
 
-func (s *llmService) ExtractEntities(text string) (map[string][]string, error) {
+func (s *llmService) ExtractEntities(ctx context.Context, text string) (map[string][]string, error) {
+	ctx = withUsageOperation(ctx, usageExtractEntities)
 	prompt := fmt.Sprintf(`Extract entities from this solar asset management text. Return JSON format:
 
 Text: "%s"
@@ -511,74 +419,65 @@ Return:
   "issues": ["problems or issues mentioned"]
 }`, text)
 
-	messages := []Message{
+	messages := []llm.Message{
 		{Role: "system", Content: "You are an entity extraction specialist for solar asset data. Always return valid JSON."},
 		{Role: "user", Content: prompt},
 	}
 
-	reqBody := OpenAIRequest{
-		Model:    s.model,
-		Messages: messages,
+	var entities map[string][]string
+	if err := s.chatStructured(ctx, messages, entitiesSchema, &entities); err != nil {
+		return nil, err
 	}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
+	return entities, nil
+}
 
-	req, err := http.NewRequest("POST", s.apiURL+"/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// maxHistoryAnswerLength caps how much of each earlier answer is shown when
+// rewriting a follow-up
+const maxHistoryAnswerLength = 600
+
+func (s *llmService) RewriteFollowUpQuery(ctx context.Context, history []*domain.UserQuery, query string) (*FollowUpRewrite, error) {
+	ctx = withUsageOperation(ctx, usageRewriteFollowUp)
+	var conversation strings.Builder
+	for _, turn := range history {
+		answer := turn.Answer
+		if len(answer) > maxHistoryAnswerLength {
+			answer = strings.ToValidUTF8(answer[:maxHistoryAnswerLength], "") + "..."
+		}
+		conversation.WriteString(fmt.Sprintf("User: %s\nAssistant: %s\n\n", turn.QueryText, answer))
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
+	prompt := fmt.Sprintf(`Rewrite the follow-up question from a conversation about a solar site so it can be understood without the conversation.
 
-	var openAIResp OpenAIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&openAIResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
+Conversation so far:
+%s
+Follow-up question: "%s"
 
-	if openAIResp.Error != nil {
-		return nil, fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
-	}
+Return JSON with:
+- "standalone_query": the follow-up with pronouns and references such as "it", "that inverter" or "the same period" replaced by what they refer to. Keep the user's wording otherwise; return the question unchanged if it already stands alone.
+- "reuses_previous_sources": true if the follow-up asks about the documents or details behind the previous answer (e.g. "what did that report say about the cause?"), false if it needs a new search.`, conversation.String(), query)
 
-	if len(openAIResp.Choices) == 0 {
-		return nil, fmt.Errorf("no response choices returned")
+	messages := []llm.Message{
+		{Role: "system", Content: "You rewrite follow-up questions into standalone search queries. Always return valid JSON."},
+		{Role: "user", Content: prompt},
 	}
 
-	// Parse the JSON response
-	var entities map[string][]string
-	if err := json.Unmarshal([]byte(openAIResp.Choices[0].Message.Content), &entities); err != nil {
-		return nil, fmt.Errorf("failed to parse entities: %w", err)
+	var rewrite FollowUpRewrite
+	if err := s.chatStructured(ctx, messages, followUpRewriteSchema, &rewrite); err != nil {
+		return nil, err
 	}
 
-	return entities, nil
+	return &rewrite, nil
 }
 
-func (s *llmService) GenerateEnhancedResponse(query string, sources []domain.QuerySourceDetail) (*domain.EnhancedQueryResponse, error) {
+func (s *llmService) GenerateEnhancedResponse(ctx context.Context, query string, sources []domain.QuerySourceDetail) (*domain.EnhancedQueryResponse, error) {
+	ctx = withUsageOperation(ctx, usageGenerateAnswer)
 	startTime := time.Now()
 
 	// Build source context for the LLM
-	sourceContext := ""
-	for i, source := range sources {
-		sourceContext += fmt.Sprintf("\nSource %d (%s - %s):\n%s\n", 
-			i+1, source.DocumentTitle, source.DocumentType, source.RelevantExcerpt)
-	}
+	sourceContext := buildSourceContext(sources)
 
-	prompt := fmt.Sprintf(`You are a professional solar asset management assistant. Answer the query using ONLY the provided sources. Follow these requirements:
-
-1. Only use information from the provided sources - no external knowledge
-2. Include specific citations in your answer
-3. If you cannot answer from the sources, say so explicitly
-4. Maintain professional tone
-5. Be concise but complete
+	prompt := fmt.Sprintf(groundedAnswerInstructions+`
 
 Query: "%s"
 
@@ -593,118 +492,197 @@ Provide your response in the following JSON format:
   "response_type": "summary|timeline|list|analysis"
 }`, query, sourceContext)
 
-	messages := []Message{
+	messages := []llm.Message{
 		{Role: "system", Content: "You are a professional solar asset management assistant. Always provide accurate, source-based answers with citations."},
 		{Role: "user", Content: prompt},
 	}
 
-	reqBody := OpenAIRequest{
-		Model:    s.model,
-		Messages: messages,
+	var responseData struct {
+		Answer          string   `json:"answer"`
+		ConfidenceScore float64  `json:"confidence_score"`
+		RelatedConcepts []string `json:"related_concepts"`
+		ResponseType    string   `json:"response_type"`
 	}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	if err := s.chatStructured(ctx, messages, enhancedResponseSchema, &responseData); err != nil {
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", s.apiURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	// Extract entities from the query; the answer stands without them
+	entities, err := s.ExtractEntities(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		s.log.Warnw("Failed to extract query entities", "error", err)
+		entities = nil
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	processingTime := int(time.Since(startTime).Milliseconds())
 
-	resp, err := s.client.Do(req)
+	// Validate response against sources
+	grounding, err := s.ValidateResponseAgainstSources(ctx, responseData.Answer, sources)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	var openAIResp OpenAIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&openAIResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	response := &domain.EnhancedQueryResponse{
+		Answer:            responseData.Answer,
+		ConfidenceScore:   grounding.Score,
+		Sources:           sources,
+		RelatedConcepts:   responseData.RelatedConcepts,
+		ExtractedEntities: entities,
+		ResponseType:      responseData.ResponseType,
+		NoHallucination:   grounding.NoHallucination,
+		UnsupportedClaims: grounding.UnsupportedClaims(),
+		ProcessingTimeMs:  processingTime,
 	}
 
-	if openAIResp.Error != nil {
-		return nil, fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
-	}
+	return response, nil
+}
 
-	if len(openAIResp.Choices) == 0 {
-		return nil, fmt.Errorf("no response choices returned")
-	}
+// StreamEnhancedResponse answers like GenerateEnhancedResponse, but asks for
+// plain text so the answer can be passed to onToken as it is generated.
+// Related concepts and entities aren't requested, so nothing holds up the
+// answer; the confidence score is computed once it is complete.
+func (s *llmService) StreamEnhancedResponse(ctx context.Context, query string, sources []domain.QuerySourceDetail, onToken func(string) error) (*domain.EnhancedQueryResponse, error) {
+	ctx = withUsageOperation(ctx, usageStreamAnswer)
+	startTime := time.Now()
 
-	// Parse the JSON response
-	responseContent := openAIResp.Choices[0].Message.Content
-	fmt.Printf("LLM Enhanced Response: %s\n", responseContent)
-	
-	var responseData struct {
-		Answer         string   `json:"answer"`
-		ConfidenceScore float64 `json:"confidence_score"`
-		RelatedConcepts []string `json:"related_concepts"`
-		ResponseType   string   `json:"response_type"`
-	}
+	prompt := fmt.Sprintf(groundedAnswerInstructions+`
 
-	if err := json.Unmarshal([]byte(responseContent), &responseData); err != nil {
-		// Log the raw response for debugging
-		fmt.Printf("Failed to parse enhanced response JSON. Raw response: %s\n", responseContent)
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
+Query: "%s"
 
-	// Extract entities from the query
-	entities, _ := s.ExtractEntities(query)
+Available Sources:
+%s
 
-	processingTime := int(time.Since(startTime).Milliseconds())
+Reply with the answer text only, citing sources inline as [Source 1].`, query, buildSourceContext(sources))
 
-	// Validate response against sources
-	confidence, _ := s.ValidateResponseAgainstSources(responseData.Answer, sources)
+	messages := []llm.Message{
+		{Role: "system", Content: "You are a professional solar asset management assistant. Always provide accurate, source-based answers with citations."},
+		{Role: "user", Content: prompt},
+	}
 
-	response := &domain.EnhancedQueryResponse{
-		Answer:            responseData.Answer,
-		ConfidenceScore:   confidence,
-		Sources:          sources,
-		RelatedConcepts:  responseData.RelatedConcepts,
-		ExtractedEntities: entities,
-		ResponseType:     responseData.ResponseType,
-		NoHallucination:  confidence > 0.7, // Flag if confidence is high
-		ProcessingTimeMs: processingTime,
+	answer, err := s.chatStream(ctx, llm.ChatRequest{Messages: messages}, onToken)
+	if err != nil {
+		return nil, err
 	}
 
-	return response, nil
+	grounding, err := s.ValidateResponseAgainstSources(ctx, answer, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.EnhancedQueryResponse{
+		Answer:            answer,
+		ConfidenceScore:   grounding.Score,
+		Sources:           sources,
+		RelatedConcepts:   []string{},
+		ExtractedEntities: map[string][]string{},
+		ResponseType:      "summary",
+		NoHallucination:   grounding.NoHallucination,
+		UnsupportedClaims: grounding.UnsupportedClaims(),
+		ProcessingTimeMs:  int(time.Since(startTime).Milliseconds()),
+	}, nil
 }
 
-func (s *llmService) ValidateResponseAgainstSources(answer string, sources []domain.QuerySourceDetail) (float64, error) {
-	if len(sources) == 0 {
-		return 0.0, nil
+// ValidateResponseAgainstSources checks each claim of the answer against
+// the sources it cites. Fabricated dates, numbers and identifiers are caught
+// by rule; the remaining claims go to the LLM as an entailment judge, or are
+// scored by term overlap when the judge is off or gives no verdict.
+func (s *llmService) ValidateResponseAgainstSources(ctx context.Context, answer string, sources []domain.QuerySourceDetail) (*GroundingResult, error) {
+	claims := extractClaims(answer, len(sources))
+
+	var judgements map[int]claimJudgement
+	if s.grounding.Judge == GroundingJudgeLLM && len(claims) > 0 && len(sources) > 0 {
+		var err error
+		judgements, err = s.judgeClaims(ctx, claims, sources)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			s.log.Warnw("Claim judge failed, falling back to term overlap", "error", err)
+		}
 	}
 
-	// Basic validation - check if answer content appears in sources
-	answerWords := strings.Fields(strings.ToLower(answer))
-	supportedWords := 0
+	return checkClaims(claims, sourceEvidence(sources), judgements), nil
+}
 
-	// Remove citations and common words for better accuracy
-	filteredWords := []string{}
-	for _, word := range answerWords {
-		// Skip citations like [Source 1]
-		if !strings.Contains(word, "source") && !strings.Contains(word, "[") && 
-		   !strings.Contains(word, "]") && len(word) > 3 {
-			filteredWords = append(filteredWords, word)
+// judgeClaims asks the LLM whether the sources entail each claim, keyed by
+// claim number
+func (s *llmService) judgeClaims(ctx context.Context, claims []groundingClaim, sources []domain.QuerySourceDetail) (map[int]claimJudgement, error) {
+	ctx = withUsageOperation(ctx, usageJudgeClaims)
+	var claimList strings.Builder
+	for i, claim := range claims {
+		cites := "no source"
+		if len(claim.sources) > 0 {
+			numbers := make([]string, len(claim.sources))
+			for j, number := range claim.sources {
+				numbers[j] = strconv.Itoa(number)
+			}
+			cites = "Sources " + strings.Join(numbers, ", ")
 		}
+		claimList.WriteString(fmt.Sprintf("Claim %d (cites %s): %s\n", i+1, cites, claim.text))
 	}
 
-	for _, source := range sources {
-		sourceText := strings.ToLower(source.RelevantExcerpt)
-		for _, word := range filteredWords {
-			if strings.Contains(sourceText, word) {
-				supportedWords++
-			}
+	prompt := fmt.Sprintf(`Check whether each claim is supported by the sources below.
+
+Sources:
+%s
+Claims:
+%s
+Judge every claim against the sources only, preferring the sources it cites:
+- "supported": the sources state it; dates, numbers, component IDs and names must match exactly
+- "contradicted": the sources state something different
+- "not_enough_information": the sources don't say
+
+Return one judgement per claim, with "claim" set to the claim number.`, buildSourceContext(sources), claimList.String())
+
+	messages := []llm.Message{
+		{Role: "system", Content: "You are a strict fact checker for solar asset management answers. Always return valid JSON."},
+		{Role: "user", Content: prompt},
+	}
+
+	var reply struct {
+		Judgements []claimJudgement `json:"judgements"`
+	}
+	if err := s.chatStructured(ctx, messages, claimJudgementSchema, &reply); err != nil {
+		return nil, err
+	}
+
+	judgements := make(map[int]claimJudgement, len(reply.Judgements))
+	for _, judgement := range reply.Judgements {
+		if judgement.Claim >= 1 && judgement.Claim <= len(claims) {
+			judgements[judgement.Claim] = judgement
 		}
 	}
+	return judgements, nil
+}
 
-	if len(filteredWords) > 0 {
-		return float64(supportedWords) / float64(len(filteredWords)), nil
+// joinEnum formats enum values for a prompt, e.g. "repair|inspection"
+func joinEnum[T ~string](values []T) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = string(value)
 	}
+	return strings.Join(parts, "|")
+}
 
-	return 0.5, nil // Default moderate confidence if no content to validate
-}
\ No newline at end of file
+// groundedAnswerInstructions are the rules for answering from retrieved sources
+const groundedAnswerInstructions = `You are a professional solar asset management assistant. Answer the query using ONLY the provided sources. Follow these requirements:
+
+1. Only use information from the provided sources - no external knowledge
+2. End each sentence with citations of the sources that support it, as [Source N] or [Sources N, M]
+3. If you cannot answer from the sources, say so explicitly
+4. Maintain professional tone
+5. Be concise but complete`
+
+// buildSourceContext numbers the sources for the prompt as Source 1, 2, ...
+func buildSourceContext(sources []domain.QuerySourceDetail) string {
+	sourceContext := ""
+	for i, source := range sources {
+		sourceContext += fmt.Sprintf("\nSource %d (%s - %s):\n%s\n",
+			i+1, source.DocumentTitle, source.DocumentType, source.RelevantExcerpt)
+	}
+	return sourceContext
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/llm"
+	"github.com/google/uuid"
+)
+
+// Operations LLM calls are recorded under. Calls made without one are
+// recorded under their call type, "chat" or "embedding".
+const (
+	usageExtractActions     = "extract_actions"
+	usageSummarizeDocument  = "summarize_document"
+	usageAnalyzeIntent      = "analyze_intent"
+	usageExtractEntities    = "extract_entities"
+	usageRewriteFollowUp    = "rewrite_follow_up"
+	usageGenerateAnswer     = "generate_answer"
+	usageStreamAnswer       = "stream_answer"
+	usageJudgeClaims        = "judge_claims"
+	usageEmbedChunks        = "embed_chunks"
+	usageEmbedActions       = "embed_actions"
+	usageEmbedComponents    = "embed_components"
+	usageEmbedQuery         = "embed_query"
+	usageEmbedSearch        = "embed_search"
+	usageEmbeddingsBackfill = "embed_backfill"
+)
+
+// UsageScope attributes the LLM calls made with a context to the site,
+// document or query they were made for
+type UsageScope struct {
+	SiteID     *uuid.UUID
+	DocumentID *uuid.UUID
+	QueryID    *uuid.UUID
+}
+
+type usageScopeKey struct{}
+
+type usageOperationKey struct{}
+
+// WithUsageScope attributes the LLM calls made with ctx to scope. Fields left
+// nil keep the attribution of an enclosing scope.
+func WithUsageScope(ctx context.Context, scope UsageScope) context.Context {
+	outer := usageScopeFrom(ctx)
+	if scope.SiteID == nil {
+		scope.SiteID = outer.SiteID
+	}
+	if scope.DocumentID == nil {
+		scope.DocumentID = outer.DocumentID
+	}
+	if scope.QueryID == nil {
+		scope.QueryID = outer.QueryID
+	}
+	return context.WithValue(ctx, usageScopeKey{}, scope)
+}
+
+// siteUsageScope attributes the LLM calls made with ctx to a site
+func siteUsageScope(ctx context.Context, siteID uuid.UUID) context.Context {
+	return WithUsageScope(ctx, UsageScope{SiteID: &siteID})
+}
+
+func usageScopeFrom(ctx context.Context) UsageScope {
+	scope, _ := ctx.Value(usageScopeKey{}).(UsageScope)
+	return scope
+}
+
+// withUsageOperation records the LLM calls made with ctx under operation
+func withUsageOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, usageOperationKey{}, operation)
+}
+
+func usageOperationFrom(ctx context.Context, fallback string) string {
+	if operation, ok := ctx.Value(usageOperationKey{}).(string); ok && operation != "" {
+		return operation
+	}
+	return fallback
+}
+
+// recordUsage stores a provider call with its estimated cost. Providers that
+// don't report usage, like some streaming endpoints, get an estimate from
+// the text sent and received. Failing to record is only logged.
+func (s *llmService) recordUsage(ctx context.Context, callType domain.LLMCallType, model string, usage llm.Usage, started time.Time, callErr error) {
+	if s.usageRepo == nil {
+		return
+	}
+
+	scope := usageScopeFrom(ctx)
+	operation := usageOperationFrom(ctx, string(callType))
+
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+
+	record := &domain.LLMUsage{
+		ID:               uuid.New(),
+		SiteID:           scope.SiteID,
+		DocumentID:       scope.DocumentID,
+		QueryID:          scope.QueryID,
+		Operation:        operation,
+		CallType:         callType,
+		Provider:         s.provider.Name(),
+		Model:            model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		LatencyMs:        int(time.Since(started).Milliseconds()),
+		EstimatedCostUSD: s.pricing.Cost(model, usage),
+		Succeeded:        callErr == nil,
+		CreatedAt:        time.Now(),
+	}
+	if err := s.usageRepo.Create(record); err != nil {
+		s.log.Warnw("Failed to record LLM usage", "operation", operation, "error", err)
+	}
+}
+
+// chatUsage is the usage of a chat call, estimated when the provider didn't
+// report it
+func chatUsage(req llm.ChatRequest, resp *llm.ChatResponse) llm.Usage {
+	if resp == nil {
+		return llm.Usage{}
+	}
+	if resp.Usage.TotalTokens > 0 || resp.Usage.PromptTokens > 0 {
+		return resp.Usage
+	}
+
+	usage := llm.Usage{CompletionTokens: llm.EstimateTokens(resp.Content)}
+	for _, message := range req.Messages {
+		usage.PromptTokens += llm.EstimateTokens(message.Content)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// chatModel is the model a chat call reports; failed calls report none
+func chatModel(resp *llm.ChatResponse) string {
+	if resp == nil {
+		return ""
+	}
+	return resp.Model
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// QueryClient identifies where a query came from, for its analytics record
+type QueryClient struct {
+	SessionID *uuid.UUID
+	UserAgent string
+}
+
+type queryClientKey struct{}
+
+// WithQueryClient records the queries run with ctx as coming from client
+func WithQueryClient(ctx context.Context, client QueryClient) context.Context {
+	return context.WithValue(ctx, queryClientKey{}, client)
+}
+
+func queryClientFrom(ctx context.Context) QueryClient {
+	client, _ := ctx.Value(queryClientKey{}).(QueryClient)
+	return client
+}
+
+// queryRun collects the analytics record of one query as it runs. A run
+// that is neither answered nor rejected is recorded as failed.
+type queryRun struct {
+	started   time.Time
+	search    time.Duration
+	llm       time.Duration
+	analytics *domain.QueryAnalytics
+}
+
+func newQueryRun(ctx context.Context, queryID, userID, siteID uuid.UUID, queryText string) *queryRun {
+	client := queryClientFrom(ctx)
+	return &queryRun{
+		started: time.Now(),
+		analytics: &domain.QueryAnalytics{
+			ID:        uuid.New(),
+			SiteID:    siteID,
+			UserID:    &userID,
+			QueryID:   &queryID,
+			QueryText: queryText,
+			QueryType: domain.QueryTypeGeneral,
+			SessionID: client.SessionID,
+			UserAgent: client.UserAgent,
+		},
+	}
+}
+
+// searched adds the time since start to the retrieval stage
+func (r *queryRun) searched(start time.Time) {
+	r.search += time.Since(start)
+}
+
+// prompted adds the time since start to the LLM stage
+func (r *queryRun) prompted(start time.Time) {
+	r.llm += time.Since(start)
+}
+
+func (r *queryRun) rejected(reason string) {
+	r.analytics.Rejected = true
+	r.analytics.RejectionReason = reason
+}
+
+func (r *queryRun) answered(queryType domain.QueryType, resultsCount int, cached bool) {
+	if queryType != "" {
+		r.analytics.QueryType = queryType
+	}
+	r.analytics.ResultsCount = resultsCount
+	r.analytics.ResponseGenerated = true
+	r.analytics.Cached = cached
+}
+
+// recordQueryRun saves the run's analytics record. Failing to save it is
+// only logged.
+func (s *queryService) recordQueryRun(r *queryRun) {
+	r.analytics.ExecutionTimeMs = int(time.Since(r.started).Milliseconds())
+	r.analytics.SearchTimeMs = int(r.search.Milliseconds())
+	r.analytics.LLMTimeMs = int(r.llm.Milliseconds())
+	r.analytics.CreatedAt = time.Now()
+
+	if err := s.queryRepo.CreateAnalytics(r.analytics); err != nil {
+		s.log.Warnw("Failed to record query analytics", "query_id", r.analytics.QueryID, "error", err)
+	}
+}
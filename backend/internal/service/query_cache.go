@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// ContentVersions keeps a version per site that moves on whenever the
+// site's documents or actions change. The Redis cache implements it.
+type ContentVersions interface {
+	BumpContentVersion(siteID string) error
+}
+
+// AnswerCache keeps answers per site alongside the site's content version,
+// which cache keys include. The Redis cache implements it.
+type AnswerCache interface {
+	GetContentVersion(siteID string) (int64, error)
+	GetQueryCache(siteID, queryHash string, result interface{}) error
+	SetQueryCache(siteID, queryHash string, result interface{}, ttl time.Duration) error
+}
+
+// InvalidateAnswers moves the site's content version on, so answers cached
+// before a change to its documents or actions are no longer served. A
+// failure is only logged; cached answers then expire with their TTL.
+func InvalidateAnswers(versions ContentVersions, siteID uuid.UUID, log *logger.Logger) {
+	if err := versions.BumpContentVersion(siteID.String()); err != nil {
+		log.Warnw("Failed to bump content version", "site_id", siteID, "error", err)
+	}
+}
+
+// cachedAnswer is an enhanced query answer as kept in the query cache, with
+// the intent it was answered under
+type cachedAnswer struct {
+	Intent   *domain.QueryIntent           `json:"intent"`
+	Response *domain.EnhancedQueryResponse `json:"response"`
+}
+
+// answerCacheKey identifies an answer by the normalized query and the
+// site's content version, so answers given before documents or actions
+// changed are never looked up again. It returns "" when caching is off or
+// the version can't be read.
+func (s *queryService) answerCacheKey(siteID uuid.UUID, queryText string) string {
+	if s.cache == nil || s.cacheTTL <= 0 {
+		return ""
+	}
+
+	version, err := s.cache.GetContentVersion(siteID.String())
+	if err != nil {
+		s.log.Warnw("Failed to read content version", "site_id", siteID, "error", err)
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(normalizeQuery(queryText)))
+	return fmt.Sprintf("v%d:%s", version, hex.EncodeToString(hash[:]))
+}
+
+// lookupAnswer returns the cached answer for key, or nil on a miss
+func (s *queryService) lookupAnswer(siteID uuid.UUID, key string) *cachedAnswer {
+	if key == "" {
+		return nil
+	}
+
+	var cached cachedAnswer
+	if err := s.cache.GetQueryCache(siteID.String(), key, &cached); err != nil || cached.Intent == nil || cached.Response == nil {
+		return nil
+	}
+	return &cached
+}
+
+// storeAnswer caches a completed answer. Rejections and failures aren't
+// cached.
+func (s *queryService) storeAnswer(siteID uuid.UUID, key string, intent *domain.QueryIntent, response *domain.EnhancedQueryResponse) {
+	if key == "" || response.ResponseType == "error" {
+		return
+	}
+
+	if err := s.cache.SetQueryCache(siteID.String(), key, cachedAnswer{Intent: intent, Response: response}, s.cacheTTL); err != nil {
+		s.log.Warnw("Failed to cache query answer", "site_id", siteID, "error", err)
+	}
+}
+
+// answerFromCache records a query answered from the cache and returns the
+// cached answer marked as such
+func (s *queryService) answerFromCache(ctx context.Context, queryID, userID, siteID uuid.UUID, queryText string, cached *cachedAnswer, startTime time.Time) (*domain.EnhancedQueryResponse, *domain.UserQuery, error) {
+	response := cached.Response
+	query, err := s.recordEnhancedQuery(ctx, queryID, userID, siteID, queryText, &conversationTurn{query: queryText}, cached.Intent, response)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response.Cached = true
+	response.ProcessingTimeMs = int(time.Since(startTime).Milliseconds())
+	return response, query, nil
+}
+
+// normalizeQuery folds case, whitespace and closing punctuation, so "What
+// failed last week?" and "what failed  last week" share an answer
+func normalizeQuery(queryText string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(queryText)), " ")
+	return strings.TrimRight(normalized, "?!. ")
+}
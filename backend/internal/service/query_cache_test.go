@@ -0,0 +1,146 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var errCacheUnavailable = errors.New("cache unavailable")
+
+// memoryAnswerCache keeps content versions and answers in memory, storing
+// answers as JSON the way the Redis cache does
+type memoryAnswerCache struct {
+	versions map[string]int64
+	answers  map[string][]byte
+	err      error
+}
+
+func newMemoryAnswerCache() *memoryAnswerCache {
+	return &memoryAnswerCache{versions: make(map[string]int64), answers: make(map[string][]byte)}
+}
+
+func (c *memoryAnswerCache) GetContentVersion(siteID string) (int64, error) {
+	return c.versions[siteID], c.err
+}
+
+func (c *memoryAnswerCache) BumpContentVersion(siteID string) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.versions[siteID]++
+	return nil
+}
+
+func (c *memoryAnswerCache) GetQueryCache(siteID, queryHash string, result interface{}) error {
+	data, ok := c.answers[siteID+":"+queryHash]
+	if !ok {
+		return errors.New("cache miss")
+	}
+	return json.Unmarshal(data, result)
+}
+
+func (c *memoryAnswerCache) SetQueryCache(siteID, queryHash string, result interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	c.answers[siteID+":"+queryHash] = data
+	return nil
+}
+
+func TestAnswerCacheInvalidation(t *testing.T) {
+	log := &logger.Logger{SugaredLogger: zap.NewNop().Sugar()}
+	site := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	otherSite := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	intent := &domain.QueryIntent{Type: "maintenance_history"}
+	response := &domain.EnhancedQueryResponse{Answer: "INV001 was reset on 5 March.", ResponseType: "summary"}
+
+	cache := newMemoryAnswerCache()
+	s := &queryService{cache: cache, cacheTTL: time.Hour, log: log}
+
+	key := s.answerCacheKey(site, "When was INV001 reset?")
+	otherKey := s.answerCacheKey(otherSite, "When was INV001 reset?")
+	s.storeAnswer(site, key, intent, response)
+	s.storeAnswer(otherSite, otherKey, intent, response)
+
+	if cached := s.lookupAnswer(site, s.answerCacheKey(site, "  when was inv001 reset ")); cached == nil || cached.Response.Answer != response.Answer {
+		t.Fatalf("lookupAnswer() of an equivalent query = %+v, want the stored answer", cached)
+	}
+
+	InvalidateAnswers(cache, site, log)
+
+	newKey := s.answerCacheKey(site, "When was INV001 reset?")
+	if newKey == key {
+		t.Fatalf("answerCacheKey() = %q after a content change, want a new key", newKey)
+	}
+	if cached := s.lookupAnswer(site, newKey); cached != nil {
+		t.Errorf("lookupAnswer() after a content change = %+v, want a miss", cached)
+	}
+	if cached := s.lookupAnswer(otherSite, s.answerCacheKey(otherSite, "When was INV001 reset?")); cached == nil {
+		t.Error("lookupAnswer() for another site = miss, want its answer kept")
+	}
+}
+
+func TestAnswerCacheKey(t *testing.T) {
+	log := &logger.Logger{SugaredLogger: zap.NewNop().Sugar()}
+	site := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+
+	failing := newMemoryAnswerCache()
+	failing.err = errCacheUnavailable
+
+	tests := []struct {
+		name    string
+		service *queryService
+		wantKey bool
+	}{
+		{name: "cache configured", service: &queryService{cache: newMemoryAnswerCache(), cacheTTL: time.Hour, log: log}, wantKey: true},
+		{name: "no cache", service: &queryService{cacheTTL: time.Hour, log: log}},
+		{name: "caching disabled", service: &queryService{cache: newMemoryAnswerCache(), log: log}},
+		{name: "version unreadable", service: &queryService{cache: failing, cacheTTL: time.Hour, log: log}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if key := tt.service.answerCacheKey(site, "What failed last week?"); (key != "") != tt.wantKey {
+				t.Errorf("answerCacheKey() = %q, want a key %v", key, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestStoreAnswerSkipsErrors(t *testing.T) {
+	site := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	cache := newMemoryAnswerCache()
+	s := &queryService{cache: cache, cacheTTL: time.Hour, log: &logger.Logger{SugaredLogger: zap.NewNop().Sugar()}}
+
+	key := s.answerCacheKey(site, "What failed last week?")
+	s.storeAnswer(site, key, &domain.QueryIntent{}, &domain.EnhancedQueryResponse{ResponseType: "error"})
+	if cached := s.lookupAnswer(site, key); cached != nil {
+		t.Errorf("lookupAnswer() = %+v, want error responses left uncached", cached)
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"What failed last week?", "what failed last week"},
+		{"  what   failed\tlast week!! ", "what failed last week"},
+		{"Status of INV001.", "status of inv001"},
+		{"?", ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeQuery(tt.query); got != tt.want {
+			t.Errorf("normalizeQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
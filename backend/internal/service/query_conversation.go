@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	apperrors "github.com/engramiq/engramiq-backend/pkg/errors"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxConversationTurns is how many earlier turns are shown to the LLM
+	// when rewriting a follow-up
+	maxConversationTurns = 5
+	// maxConversationTitleLength caps titles taken from a first question
+	maxConversationTitleLength = 100
+)
+
+// conversationTurn carries a query's place in its conversation through the
+// enhanced query pipeline. Queries outside a conversation get an empty turn
+// whose query is the text as asked.
+type conversationTurn struct {
+	conversation *domain.Conversation
+	// query is the text intent analysis, retrieval and generation work on:
+	// the standalone rewrite of a follow-up
+	query string
+	// previousSources are the previous turn's sources, kept when the
+	// follow-up asks about them
+	previousSources []domain.QuerySourceDetail
+}
+
+// startTurn loads the conversation a query continues and rewrites the query
+// using the earlier turns. Only the user who started a conversation can
+// continue it.
+func (s *queryService) startTurn(ctx context.Context, userID, siteID uuid.UUID, conversationID *uuid.UUID, queryText string) (*conversationTurn, error) {
+	turn := &conversationTurn{query: queryText}
+	if conversationID == nil {
+		return turn, nil
+	}
+
+	conversation, err := s.conversationRepo.GetByID(*conversationID)
+	if err != nil || conversation.SiteID != siteID || conversation.UserID != userID {
+		return nil, apperrors.NewNotFound("conversation", conversationID.String())
+	}
+	turn.conversation = conversation
+
+	history, err := s.conversationRepo.RecentTurns(conversation.ID, maxConversationTurns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conversation history: %w", err)
+	}
+	if len(history) == 0 {
+		return turn, nil
+	}
+	previous := history[len(history)-1]
+
+	rewrite, err := s.llmService.RewriteFollowUpQuery(ctx, history, queryText)
+	if err != nil {
+		return nil, fmt.Errorf("follow-up rewrite failed: %w", err)
+	}
+	if standalone := strings.TrimSpace(rewrite.StandaloneQuery); standalone != "" {
+		turn.query = standalone
+	}
+	if rewrite.ReusesPreviousSources {
+		turn.previousSources = s.storedSourceDetails(previous.QuerySources)
+	}
+
+	return turn, nil
+}
+
+// storedSourceDetails turns a previous turn's stored sources back into the
+// details the LLM is given, skipping sources whose document or action has
+// since been deleted
+func (s *queryService) storedSourceDetails(sources []domain.QuerySource) []domain.QuerySourceDetail {
+	details := make([]domain.QuerySourceDetail, 0, len(sources))
+	for _, source := range sources {
+		if source.SourceType == domain.QuerySourceAction {
+			if source.Action != nil {
+				details = append(details, actionSourceDetail(source.Action, source.RelevanceScore))
+			}
+			continue
+		}
+		if source.Document == nil {
+			continue
+		}
+
+		detail := domain.QuerySourceDetail{
+			SourceType:       domain.QuerySourceDocument,
+			DocumentID:       source.DocumentID,
+			DocumentTitle:    source.DocumentTitle,
+			DocumentType:     string(source.Document.DocumentType),
+			RelevantExcerpt:  source.RelevantExcerpt,
+			RelevanceScore:   source.RelevanceScore,
+			PageNumber:       source.PageNumber,
+			SectionReference: source.SectionReference,
+			Citation:         s.sourceAttribution.FormatCitation(source.Document, source.PageNumber, source.SectionReference),
+		}
+		if source.Document.DocumentDate != nil {
+			detail.DocumentDate = *source.Document.DocumentDate
+		}
+		details = append(details, detail)
+	}
+	return details
+}
+
+// withPreviousSources puts the previous turn's sources first, when the
+// follow-up refers back to them, and fills the remaining slots with newly
+// retrieved sources that aren't among them
+func (t *conversationTurn) withPreviousSources(sources []domain.QuerySourceDetail) []domain.QuerySourceDetail {
+	if len(t.previousSources) == 0 {
+		return sources
+	}
+
+	key := func(source domain.QuerySourceDetail) string {
+		return source.DocumentID.String() + "\x00" + source.RelevantExcerpt
+	}
+
+	merged := make([]domain.QuerySourceDetail, 0, maxChunkSources)
+	seen := make(map[string]bool)
+	for _, group := range [][]domain.QuerySourceDetail{t.previousSources, sources} {
+		for _, source := range group {
+			if len(merged) >= maxChunkSources {
+				return merged
+			}
+			if seen[key(source)] {
+				continue
+			}
+			seen[key(source)] = true
+			merged = append(merged, source)
+		}
+	}
+	return merged
+}
+
+// apply records the turn on the query about to be saved; its index is
+// assigned when it is saved
+func (t *conversationTurn) apply(query *domain.UserQuery) {
+	if t.conversation == nil {
+		return
+	}
+	query.ConversationID = &t.conversation.ID
+	if t.query != query.QueryText {
+		query.StandaloneQuery = t.query
+	}
+}
+
+// touchConversation moves the conversation to the top of the site's list and
+// titles an untitled conversation after its first question
+func (s *queryService) touchConversation(turn *conversationTurn, queryText string) {
+	if turn.conversation == nil {
+		return
+	}
+
+	updates := map[string]interface{}{"updated_at": time.Now()}
+	if turn.conversation.Title == "" {
+		updates["title"] = conversationTitle(queryText)
+	}
+	if err := s.conversationRepo.Update(turn.conversation.ID, updates); err != nil {
+		s.log.Warnw("Failed to update conversation", "conversation_id", turn.conversation.ID, "error", err)
+	}
+}
+
+func conversationTitle(queryText string) string {
+	title := strings.Join(strings.Fields(queryText), " ")
+	if len(title) > maxConversationTitleLength {
+		title = strings.ToValidUTF8(title[:maxConversationTitleLength], "") + "..."
+	}
+	return title
+}
+
+func (s *queryService) CreateConversation(userID uuid.UUID, siteID uuid.UUID, title string) (*domain.Conversation, error) {
+	conversation := &domain.Conversation{
+		ID:     uuid.New(),
+		SiteID: siteID,
+		UserID: userID,
+		Title:  strings.TrimSpace(title),
+	}
+
+	if err := s.conversationRepo.Create(conversation); err != nil {
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
+	return conversation, nil
+}
+
+// GetConversation returns a user's conversation with its turns and their
+// sources. Other users' conversations look the same as missing ones.
+func (s *queryService) GetConversation(userID, id uuid.UUID) (*domain.Conversation, error) {
+	conversation, err := s.conversationRepo.GetWithTurns(id)
+	if err != nil || conversation.UserID != userID {
+		return nil, apperrors.NewNotFound("conversation", id.String())
+	}
+	for i := range conversation.Turns {
+		s.segmentStoredAnswer(&conversation.Turns[i])
+	}
+	return conversation, nil
+}
+
+func (s *queryService) ListConversations(userID, siteID uuid.UUID, pagination *domain.Pagination) ([]*domain.Conversation, error) {
+	return s.conversationRepo.ListBySite(siteID, userID, pagination)
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -9,57 +10,107 @@ import (
 
 	"github.com/engramiq/engramiq-backend/internal/domain"
 	"github.com/engramiq/engramiq-backend/internal/repository"
+	"github.com/engramiq/engramiq-backend/pkg/logger"
 	"github.com/google/uuid"
+	"github.com/pgvector/pgvector-go"
 )
 
 type QueryService interface {
-	ProcessQuery(userID uuid.UUID, siteID uuid.UUID, queryText string, queryType domain.QueryType) (*domain.UserQuery, error)
-	ProcessEnhancedQuery(userID uuid.UUID, siteID uuid.UUID, queryText string) (*domain.EnhancedQueryResponse, error)
+	ProcessQuery(ctx context.Context, userID uuid.UUID, siteID uuid.UUID, queryText string, queryType domain.QueryType) (*domain.UserQuery, error)
+	ProcessEnhancedQuery(ctx context.Context, userID uuid.UUID, siteID uuid.UUID, queryText string, conversationID *uuid.UUID) (*domain.EnhancedQueryResponse, error)
+	StreamEnhancedQuery(ctx context.Context, userID uuid.UUID, siteID uuid.UUID, queryText string, conversationID *uuid.UUID, emit QueryEventFunc) error
 	GetQueryResult(queryID uuid.UUID) (*domain.UserQuery, error)
-	GetQueryHistory(userID uuid.UUID, pagination *domain.Pagination) ([]*domain.UserQuery, error)
-	SearchSimilarQueries(siteID uuid.UUID, queryText string, limit int) ([]*domain.UserQuery, error)
+	GetQueryHistory(userID uuid.UUID, scope domain.SiteScope, pagination *domain.Pagination) ([]*domain.UserQuery, error)
+	SearchSimilarQueries(ctx context.Context, siteID uuid.UUID, queryText string, limit int) ([]*domain.UserQuery, error)
 	GetQueryAnalytics(siteID uuid.UUID, startDate, endDate time.Time) (*domain.QueryAnalytics, error)
+
+	// Conversations
+	CreateConversation(userID uuid.UUID, siteID uuid.UUID, title string) (*domain.Conversation, error)
+	// GetConversation and ListConversations only return the user's own
+	// conversations
+	GetConversation(userID, id uuid.UUID) (*domain.Conversation, error)
+	ListConversations(userID, siteID uuid.UUID, pagination *domain.Pagination) ([]*domain.Conversation, error)
 }
 
 type queryService struct {
-	queryRepo        repository.QueryRepository
-	actionRepo       repository.ActionRepository
-	docRepo          repository.DocumentRepository
-	componentRepo    repository.ComponentRepository
-	llmService       LLMService
-	contentFilter    ContentFilterService
+	queryRepo         repository.QueryRepository
+	conversationRepo  repository.ConversationRepository
+	actionRepo        repository.ActionRepository
+	docRepo           repository.DocumentRepository
+	chunkRepo         repository.ChunkRepository
+	componentRepo     repository.ComponentRepository
+	llmService        LLMService
+	embeddings        EmbeddingService
+	contentFilter     ContentFilterService
 	sourceAttribution SourceAttributionService
+	fusion            FusionPolicy
+	cache             AnswerCache
+	cacheTTL          time.Duration
+	log               *logger.Logger
 }
 
+const (
+	// maxChunkCandidates is how many chunks are fetched before capping them
+	// per document
+	maxChunkCandidates = 30
+	// maxChunkSources is how many chunk excerpts are passed to the LLM
+	maxChunkSources = 10
+	// maxChunksPerDocument keeps one document from taking every source slot
+	maxChunksPerDocument = 3
+	// maxActionSources is how many extracted actions are added as sources
+	// to questions about specific components
+	maxActionSources = 5
+	// actionSourceThreshold is the largest cosine distance an action source
+	// may have, the default of the action search endpoint
+	actionSourceThreshold = 0.8
+)
+
 type QueryIntent struct {
-	Type       string                 `json:"type"`        // timeline, search, maintenance_history, component_status
-	Entities   map[string]interface{} `json:"entities"`    // extracted entities (dates, components, etc.)
+	Type       string                 `json:"type"`     // timeline, search, maintenance_history, component_status
+	Entities   map[string]interface{} `json:"entities"` // extracted entities (dates, components, etc.)
 	Confidence float64                `json:"confidence"`
 }
 
-
 func NewQueryService(
 	queryRepo repository.QueryRepository,
+	conversationRepo repository.ConversationRepository,
 	actionRepo repository.ActionRepository,
 	docRepo repository.DocumentRepository,
+	chunkRepo repository.ChunkRepository,
 	componentRepo repository.ComponentRepository,
 	llmService LLMService,
+	embeddings EmbeddingService,
 	contentFilter ContentFilterService,
 	sourceAttribution SourceAttributionService,
+	fusion FusionPolicy,
+	answers AnswerCache,
+	cacheTTL time.Duration,
+	log *logger.Logger,
 ) QueryService {
 	return &queryService{
-		queryRepo:        queryRepo,
-		actionRepo:       actionRepo,
-		docRepo:          docRepo,
-		componentRepo:    componentRepo,
-		llmService:       llmService,
-		contentFilter:    contentFilter,
+		queryRepo:         queryRepo,
+		conversationRepo:  conversationRepo,
+		actionRepo:        actionRepo,
+		docRepo:           docRepo,
+		chunkRepo:         chunkRepo,
+		componentRepo:     componentRepo,
+		llmService:        llmService,
+		embeddings:        embeddings,
+		contentFilter:     contentFilter,
 		sourceAttribution: sourceAttribution,
+		fusion:            fusion,
+		cache:             answers,
+		cacheTTL:          cacheTTL,
+		log:               log,
 	}
 }
 
-func (s *queryService) ProcessEnhancedQuery(userID uuid.UUID, siteID uuid.UUID, queryText string) (*domain.EnhancedQueryResponse, error) {
+func (s *queryService) ProcessEnhancedQuery(ctx context.Context, userID uuid.UUID, siteID uuid.UUID, queryText string, conversationID *uuid.UUID) (*domain.EnhancedQueryResponse, error) {
 	startTime := time.Now()
+	queryID := uuid.New()
+	ctx = WithUsageScope(ctx, UsageScope{SiteID: &siteID, QueryID: &queryID})
+	run := newQueryRun(ctx, queryID, userID, siteID, queryText)
+	defer s.recordQueryRun(run)
 
 	// Step 1: Content filtering and validation
 	validationResult, err := s.contentFilter.ValidateQuery(queryText)
@@ -68,89 +119,140 @@ func (s *queryService) ProcessEnhancedQuery(userID uuid.UUID, siteID uuid.UUID,
 	}
 
 	if !validationResult.IsValid {
-		return &domain.EnhancedQueryResponse{
-			Answer:            fmt.Sprintf("I cannot process this query: %s", validationResult.Reason),
-			ConfidenceScore:   0.0,
-			Sources:          []domain.QuerySourceDetail{},
-			RelatedConcepts:  []string{},
-			ExtractedEntities: map[string][]string{},
-			ResponseType:     "error",
-			NoHallucination:  true,
-			ProcessingTimeMs: int(time.Since(startTime).Milliseconds()),
-		}, nil
+		run.rejected(validationResult.Reason)
+		return rejectedQueryResponse(validationResult.Reason, startTime), nil
+	}
+
+	// Repeated questions are answered from the cache until the site's
+	// content changes. Conversation turns depend on the earlier turns, so
+	// they are always answered afresh.
+	cacheKey := ""
+	if conversationID == nil {
+		cacheKey = s.answerCacheKey(siteID, queryText)
+		if cached := s.lookupAnswer(siteID, cacheKey); cached != nil {
+			response, query, err := s.answerFromCache(ctx, queryID, userID, siteID, queryText, cached, startTime)
+			if err != nil {
+				return nil, err
+			}
+			run.answered(query.QueryType, len(response.Sources), true)
+			return response, nil
+		}
+	}
+
+	// Follow-ups in a conversation are rewritten to stand on their own
+	stage := time.Now()
+	turn, err := s.startTurn(ctx, userID, siteID, conversationID, queryText)
+	run.prompted(stage)
+	if err != nil {
+		return nil, err
 	}
 
 	// Step 2: Enhanced intent analysis using LLM
-	intent, err := s.llmService.AnalyzeQueryIntent(queryText, siteID)
+	stage = time.Now()
+	intent, err := s.llmService.AnalyzeQueryIntent(ctx, turn.query, siteID)
+	run.prompted(stage)
 	if err != nil {
 		return nil, fmt.Errorf("intent analysis failed: %w", err)
 	}
 
 	// Step 3: Retrieve relevant documents using RAG pattern
-	sources, err := s.retrieveRelevantSources(siteID, queryText, intent)
+	stage = time.Now()
+	sources, err := s.retrieveRelevantSources(ctx, siteID, turn.query, intent)
+	run.searched(stage)
 	if err != nil {
 		return nil, fmt.Errorf("source retrieval failed: %w", err)
 	}
+	sources = turn.withPreviousSources(sources)
 
 	// Step 4: Generate response using only retrieved sources
-	response, err := s.llmService.GenerateEnhancedResponse(queryText, sources)
+	stage = time.Now()
+	response, err := s.llmService.GenerateEnhancedResponse(ctx, turn.query, sources)
+	run.prompted(stage)
 	if err != nil {
 		return nil, fmt.Errorf("response generation failed: %w", err)
 	}
 
-	// Step 5: Apply professional tone enforcement
+	// Steps 5 and 6: Apply professional tone enforcement, then store the
+	// query and sources for traceability
+	query, err := s.recordEnhancedQuery(ctx, queryID, userID, siteID, queryText, turn, intent, response)
+	if err != nil {
+		return nil, err
+	}
+	response.Segments = answerSegments(response.Answer, detailCitations(response.Sources))
+	response.ConversationID = query.ConversationID
+	response.StandaloneQuery = query.StandaloneQuery
+
+	response.ProcessingTimeMs = int(time.Since(startTime).Milliseconds())
+	s.storeAnswer(siteID, cacheKey, intent, response)
+	run.answered(query.QueryType, len(response.Sources), false)
+	return response, nil
+}
+
+// rejectedQueryResponse answers a query the content filter refused
+func rejectedQueryResponse(reason string, startTime time.Time) *domain.EnhancedQueryResponse {
+	return &domain.EnhancedQueryResponse{
+		Answer:            fmt.Sprintf("I cannot process this query: %s", reason),
+		ConfidenceScore:   0.0,
+		Sources:           []domain.QuerySourceDetail{},
+		RelatedConcepts:   []string{},
+		ExtractedEntities: map[string][]string{},
+		ResponseType:      "error",
+		NoHallucination:   true,
+		ProcessingTimeMs:  int(time.Since(startTime).Milliseconds()),
+		Segments:          []domain.AnswerSegment{},
+		UnsupportedClaims: []domain.ClaimCheck{},
+	}
+}
+
+// recordEnhancedQuery enforces the professional tone on the answer, then
+// saves the query with its answer and source attributions, as the next turn
+// of its conversation if it has one
+func (s *queryService) recordEnhancedQuery(ctx context.Context, queryID, userID, siteID uuid.UUID, queryText string, turn *conversationTurn, intent *domain.QueryIntent, response *domain.EnhancedQueryResponse) (*domain.UserQuery, error) {
 	response.Answer = s.contentFilter.EnforceProfessionalTone(response.Answer)
 	response.Answer = s.contentFilter.SanitizeResponse(response.Answer)
+	sources := response.Sources
 
-	// Step 6: Store query and sources for traceability
 	query := &domain.UserQuery{
-		ID:               uuid.New(),
-		UserID:           userID,
-		SiteID:           siteID,
-		QueryText:        queryText,
-		QueryType:        domain.QueryType(intent.Type),
-		ConfidenceScore:  response.ConfidenceScore,
+		ID:                queryID,
+		UserID:            userID,
+		SiteID:            siteID,
+		QueryText:         queryText,
+		QueryType:         domain.QueryType(intent.Type),
+		ConfidenceScore:   response.ConfidenceScore,
 		ExtractedEntities: convertToJSON(response.ExtractedEntities),
-		CreatedAt:        time.Now(),
+		Answer:            response.Answer,
+		CreatedAt:         time.Now(),
 	}
+	turn.apply(query)
 
 	// Generate and store embedding
-	embedding, _ := s.llmService.GenerateEmbedding(queryText)
+	embedding, _ := s.llmService.GenerateEmbedding(withUsageOperation(ctx, usageEmbedQuery), queryText)
 	query.Embedding = embedding
 
 	// Save query record
-	err = s.queryRepo.Create(query)
+	var err error
+	if query.ConversationID != nil {
+		err = s.queryRepo.CreateTurn(query)
+	} else {
+		err = s.queryRepo.Create(query)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to save query: %w", err)
 	}
 
 	// Store source attributions
-	documents := make([]*domain.Document, len(sources))
-	excerpts := make([]string, len(sources))
-	relevanceScores := make([]float64, len(sources))
-
-	for i, source := range sources {
-		// This would normally retrieve the full document
-		// For now, we'll create minimal document records
-		documents[i] = &domain.Document{
-			ID:    source.DocumentID,
-			Title: source.DocumentTitle,
-		}
-		excerpts[i] = source.RelevantExcerpt
-		relevanceScores[i] = source.RelevanceScore
-	}
-
-	err = s.sourceAttribution.AttributeSources(query.ID, documents, excerpts, relevanceScores)
+	err = s.sourceAttribution.AttributeSources(query.ID, sources)
 	if err != nil {
 		// Log error but don't fail the request
-		fmt.Printf("Warning: failed to attribute sources: %v\n", err)
+		s.log.Warnw("Failed to attribute sources", "query_id", query.ID, "error", err)
 	}
 
-	response.ProcessingTimeMs = int(time.Since(startTime).Milliseconds())
-	return response, nil
+	s.touchConversation(turn, queryText)
+
+	return query, nil
 }
 
-func (s *queryService) ProcessQuery(userID uuid.UUID, siteID uuid.UUID, queryText string, queryType domain.QueryType) (*domain.UserQuery, error) {
+func (s *queryService) ProcessQuery(ctx context.Context, userID uuid.UUID, siteID uuid.UUID, queryText string, queryType domain.QueryType) (*domain.UserQuery, error) {
 	// Create query record
 	query := &domain.UserQuery{
 		ID:        uuid.New(),
@@ -161,8 +263,15 @@ func (s *queryService) ProcessQuery(userID uuid.UUID, siteID uuid.UUID, queryTex
 		CreatedAt: time.Now(),
 	}
 
+	// The analytics record is saved once the query has been processed
+	run := newQueryRun(ctx, query.ID, userID, siteID, queryText)
+	run.analytics.QueryType = queryType
+
 	// Generate embedding for similarity search
-	embedding, err := s.llmService.GenerateEmbedding(queryText)
+	ctx = WithUsageScope(ctx, UsageScope{SiteID: &siteID, QueryID: &query.ID})
+	stage := time.Now()
+	embedding, err := s.llmService.GenerateEmbedding(withUsageOperation(ctx, usageEmbedQuery), queryText)
+	run.searched(stage)
 	if err == nil {
 		query.Embedding = embedding
 	}
@@ -170,16 +279,19 @@ func (s *queryService) ProcessQuery(userID uuid.UUID, siteID uuid.UUID, queryTex
 	// Save initial query
 	err = s.queryRepo.Create(query)
 	if err != nil {
+		s.recordQueryRun(run)
 		return nil, fmt.Errorf("failed to create query: %w", err)
 	}
 
 	// Process query based on type
-	go s.processQueryAsync(query.ID, queryText, siteID)
+	go s.processQueryAsync(run, query.ID, queryText, siteID)
 
 	return query, nil
 }
 
-func (s *queryService) processQueryAsync(queryID uuid.UUID, queryText string, siteID uuid.UUID) {
+func (s *queryService) processQueryAsync(run *queryRun, queryID uuid.UUID, queryText string, siteID uuid.UUID) {
+	defer s.recordQueryRun(run)
+
 	// Analyze query intent
 	intent, err := s.analyzeQueryIntent(queryText)
 	if err != nil {
@@ -188,6 +300,7 @@ func (s *queryService) processQueryAsync(queryID uuid.UUID, queryText string, si
 	}
 
 	// Execute appropriate search based on intent
+	stage := time.Now()
 	var result *QueryResult
 	switch intent.Type {
 	case "timeline":
@@ -201,11 +314,13 @@ func (s *queryService) processQueryAsync(queryID uuid.UUID, queryText string, si
 	default:
 		result, err = s.processGeneralQuery(siteID, queryText)
 	}
+	run.searched(stage)
 
 	if err != nil {
 		s.updateQueryError(queryID, err.Error())
 		return
 	}
+	run.answered("", result.Count, false)
 
 	// Convert result to JSON map
 	var resultMap domain.JSON
@@ -216,41 +331,178 @@ func (s *queryService) processQueryAsync(queryID uuid.UUID, queryText string, si
 	} else {
 		resultMap = domain.JSON{}
 	}
-	
+
 	// Update query with results
 	s.queryRepo.UpdateResults(queryID, resultMap, result.Count)
 }
 
-func (s *queryService) retrieveRelevantSources(siteID uuid.UUID, queryText string, intent *domain.QueryIntent) ([]domain.QuerySourceDetail, error) {
+func (s *queryService) retrieveRelevantSources(ctx context.Context, siteID uuid.UUID, queryText string, intent *domain.QueryIntent) ([]domain.QuerySourceDetail, error) {
 	sources := []domain.QuerySourceDetail{}
 
 	// Generate embedding for semantic search
-	embedding, err := s.llmService.GenerateEmbedding(queryText)
+	embedding, err := s.llmService.GenerateEmbedding(withUsageOperation(ctx, usageEmbedQuery), queryText)
 	if err != nil {
 		return sources, err
 	}
 
-	// Search documents using semantic similarity
-	documents, err := s.docRepo.SearchSemantic(siteID, embedding, 10, 0.7)
+	// Search chunks so long documents can match on any section, not just
+	// their opening. Vector search misses exact identifiers like work order
+	// numbers and fault codes, so keyword search always runs alongside it.
+	semantic, err := s.chunkRepo.SearchSemantic(siteID, embedding, maxChunkCandidates, 0.7)
+	if err != nil {
+		return sources, err
+	}
+	keyword, err := s.chunkRepo.SearchFullText(siteID, queryText, extractIdentifiers(queryText), maxChunkCandidates)
 	if err != nil {
 		return sources, err
 	}
-	
-	// If no documents found with semantic search, try full-text search as fallback
-	if len(documents) == 0 {
-		fmt.Printf("No documents found with semantic search, trying full-text search for query '%s'\n", queryText)
-		documents, err = s.docRepo.SearchFullText(siteID, queryText, 5)
+
+	chunks := s.fuseChunks(semantic, keyword)
+	if len(chunks) > 0 {
+		sources = s.chunkSources(chunks)
+	} else {
+		// Documents processed before chunking existed have no chunks until
+		// the worker backfills them, so search them as whole documents
+		sources, err = s.documentSources(siteID, queryText, embedding)
 		if err != nil {
 			return sources, err
 		}
-		fmt.Printf("Full-text search found %d documents\n", len(documents))
 	}
-	
-	// Log found documents for debugging
-	fmt.Printf("Found %d documents for query '%s'\n", len(documents), queryText)
-	for _, doc := range documents {
-		fmt.Printf("- Document: %s (Type: %s, RawContent len: %d, ProcessedContent len: %d)\n", 
-			doc.Title, doc.DocumentType, len(doc.RawContent), len(doc.ProcessedContent))
+
+	// Questions about specific components also get the site's closest
+	// extracted actions as sources of their own
+	if len(intent.ComponentFilters) > 0 {
+		actions, err := s.embeddings.SearchActions(ctx, siteID, queryText, maxActionSources, actionSourceThreshold)
+		if err != nil {
+			s.log.Warnw("Action search failed", "site_id", siteID, "error", err)
+		}
+		for _, action := range actions {
+			sources = append(sources, actionSourceDetail(action, action.Score))
+		}
+	}
+
+	return sources, nil
+}
+
+// actionSourceDetail describes an extracted action as a source, with its
+// search score, citing it by title and date
+func actionSourceDetail(action *domain.ExtractedAction, score float64) domain.QuerySourceDetail {
+	actionID := action.ID
+	source := domain.QuerySourceDetail{
+		SourceType:      domain.QuerySourceAction,
+		DocumentID:      action.DocumentID,
+		ActionID:        &actionID,
+		DocumentTitle:   action.Title,
+		DocumentType:    string(action.ActionType),
+		RelevantExcerpt: actionEmbeddingInput(action),
+		RelevanceScore:  score,
+		Citation:        "Action: " + action.Title,
+	}
+	if action.ActionDate != nil {
+		source.DocumentDate = *action.ActionDate
+		source.Citation += fmt.Sprintf(" (%s)", action.ActionDate.Format("2006-01-02"))
+	}
+	return source
+}
+
+// fuseChunks merges semantic and keyword chunk results, replacing each
+// chunk's score with the fused score
+func (s *queryService) fuseChunks(semantic, keyword []*domain.DocumentChunk) []*domain.DocumentChunk {
+	byID := make(map[uuid.UUID]*domain.DocumentChunk)
+	toHits := func(chunks []*domain.DocumentChunk) []searchHit {
+		hits := make([]searchHit, 0, len(chunks))
+		for _, chunk := range chunks {
+			byID[chunk.ID] = chunk
+			hits = append(hits, searchHit{ID: chunk.ID, Score: chunk.Score})
+		}
+		return hits
+	}
+
+	semanticHits, keywordHits := toHits(semantic), toHits(keyword)
+	fused := []*domain.DocumentChunk{}
+	for _, hit := range s.fusion.fuse(semanticHits, keywordHits) {
+		chunk := byID[hit.ID]
+		chunk.Score = hit.Score
+		fused = append(fused, chunk)
+	}
+	return fused
+}
+
+// chunkSources turns matched chunks into sources, keeping the best few chunks
+// per document so one long manual can't crowd out everything else
+func (s *queryService) chunkSources(chunks []*domain.DocumentChunk) []domain.QuerySourceDetail {
+	sources := []domain.QuerySourceDetail{}
+	perDocument := make(map[uuid.UUID]int)
+
+	for _, chunk := range chunks {
+		if chunk.Document == nil || perDocument[chunk.DocumentID] >= maxChunksPerDocument {
+			continue
+		}
+		perDocument[chunk.DocumentID]++
+
+		doc := chunk.Document
+		source := domain.QuerySourceDetail{
+			SourceType:       domain.QuerySourceDocument,
+			DocumentID:       doc.ID,
+			DocumentTitle:    doc.Title,
+			DocumentType:     string(doc.DocumentType),
+			RelevantExcerpt:  chunk.Content,
+			RelevanceScore:   chunk.Score,
+			PageNumber:       chunk.PageNumber,
+			SectionReference: chunk.SectionReference,
+			Citation:         s.sourceAttribution.FormatCitation(doc, chunk.PageNumber, chunk.SectionReference),
+		}
+
+		if doc.DocumentDate != nil {
+			source.DocumentDate = *doc.DocumentDate
+		}
+
+		sources = append(sources, source)
+		if len(sources) >= maxChunkSources {
+			break
+		}
+	}
+
+	return sources
+}
+
+// documentSources searches whole documents and picks an excerpt from each
+func (s *queryService) documentSources(siteID uuid.UUID, queryText string, embedding pgvector.Vector) ([]domain.QuerySourceDetail, error) {
+	sources := []domain.QuerySourceDetail{}
+
+	// Search documents using both semantic similarity and full-text search
+	semantic, err := s.docRepo.SearchSemantic(siteID, embedding, 10, 0.7)
+	if err != nil {
+		return sources, err
+	}
+	keyword, err := s.docRepo.SearchFullText(siteID, queryText, 10)
+	if err != nil {
+		return sources, err
+	}
+
+	// Document-level searches don't return scores, so only rank fusion
+	// applies here whatever the configured method
+	byID := make(map[uuid.UUID]*domain.Document)
+	toHits := func(documents []*domain.Document) []searchHit {
+		hits := make([]searchHit, 0, len(documents))
+		for _, doc := range documents {
+			byID[doc.ID] = doc
+			hits = append(hits, searchHit{ID: doc.ID})
+		}
+		return hits
+	}
+	rankFusion := s.fusion
+	rankFusion.Method = FusionRRF
+	fused := rankFusion.fuse(toHits(semantic), toHits(keyword))
+	if len(fused) > 10 {
+		fused = fused[:10]
+	}
+
+	documents := make([]*domain.Document, 0, len(fused))
+	scores := make(map[uuid.UUID]float64)
+	for _, hit := range fused {
+		documents = append(documents, byID[hit.ID])
+		scores[hit.ID] = hit.Score
 	}
 
 	// Convert documents to source details
@@ -263,9 +515,10 @@ func (s *queryService) retrieveRelevantSources(siteID uuid.UUID, queryText strin
 				doc = fullDoc
 			}
 		}
-		
+
 		// Extract relevant excerpt - try ProcessedContent first, then RawContent
 		excerpt := doc.ProcessedContent
+		fromProcessed := excerpt != ""
 		if excerpt == "" {
 			excerpt = doc.RawContent
 		}
@@ -282,15 +535,27 @@ func (s *queryService) retrieveRelevantSources(siteID uuid.UUID, queryText strin
 		}
 
 		// Extract relevant chunk based on query instead of just truncating
-		excerpt = s.extractRelevantChunk(excerpt, queryText, 8000) // Increased from 500 to 8000 chars
+		excerpt, chunkStart, chunkEnd := s.extractRelevantChunk(excerpt, queryText, 8000) // Increased from 500 to 8000 chars
+
+		// Page, section and speaker offsets recorded at extraction time refer
+		// to ProcessedContent
+		sectionRef := ""
+		var pageNumber *int
+		if fromProcessed {
+			sectionRef = doc.ReferenceForRange(chunkStart, chunkEnd)
+			pageNumber = doc.PageForRange(chunkStart, chunkEnd)
+		}
 
 		source := domain.QuerySourceDetail{
+			SourceType:       domain.QuerySourceDocument,
 			DocumentID:       doc.ID,
 			DocumentTitle:    doc.Title,
 			DocumentType:     string(doc.DocumentType),
 			RelevantExcerpt:  excerpt,
-			RelevanceScore:   0.8, // Would be calculated from similarity
-			Citation:         s.sourceAttribution.FormatCitation(doc, nil, ""),
+			RelevanceScore:   scores[doc.ID],
+			PageNumber:       pageNumber,
+			SectionReference: sectionRef,
+			Citation:         s.sourceAttribution.FormatCitation(doc, pageNumber, sectionRef),
 		}
 
 		if doc.DocumentDate != nil {
@@ -300,42 +565,15 @@ func (s *queryService) retrieveRelevantSources(siteID uuid.UUID, queryText strin
 		sources = append(sources, source)
 	}
 
-	// If we have specific component filters, also search actions
-	if len(intent.ComponentFilters) > 0 {
-		// Search for relevant maintenance actions - use action_type filter instead of component_type
-		actions, err := s.actionRepo.ListBySite(siteID, &domain.Pagination{Limit: 5}, map[string]interface{}{
-			"action_type": "maintenance",
-		})
-		if err == nil {
-			for _, action := range actions {
-				// Add action as a source
-				source := domain.QuerySourceDetail{
-					DocumentID:      action.ID, // Using action ID as document ID
-					DocumentTitle:   fmt.Sprintf("Maintenance Action: %s", action.ActionType),
-					DocumentType:    "maintenance_action",
-					RelevantExcerpt: action.Description,
-					RelevanceScore:  0.7,
-					Citation:        fmt.Sprintf("Action %s (%s)", action.ActionType, action.CreatedAt.Format("2006-01-02")),
-				}
-
-				if action.ActionDate != nil {
-					source.DocumentDate = *action.ActionDate
-				}
-
-				sources = append(sources, source)
-			}
-		}
-	}
-
 	return sources, nil
 }
 
 func (s *queryService) analyzeQueryIntent(queryText string) (*QueryIntent, error) {
 	// Simple rule-based intent detection
 	// In production, this would use ML models or LLM for better accuracy
-	
+
 	lowercaseQuery := strings.ToLower(queryText)
-	
+
 	intent := &QueryIntent{
 		Entities:   make(map[string]interface{}),
 		Confidence: 0.8,
@@ -371,7 +609,7 @@ func (s *queryService) processTimelineQuery(siteID uuid.UUID, intent *QueryInten
 	// Extract date range or use default (last 30 days)
 	endDate := time.Now()
 	startDate := endDate.AddDate(0, 0, -30)
-	
+
 	if dateRange, ok := intent.Entities["date_range"].(map[string]time.Time); ok {
 		if start, exists := dateRange["start"]; exists {
 			startDate = start
@@ -498,16 +736,22 @@ func (s *queryService) processGeneralQuery(siteID uuid.UUID, queryText string) (
 }
 
 func (s *queryService) GetQueryResult(queryID uuid.UUID) (*domain.UserQuery, error) {
-	return s.queryRepo.GetByID(queryID)
+	query, err := s.queryRepo.GetByID(queryID)
+	if err != nil {
+		return nil, err
+	}
+	s.segmentStoredAnswer(query)
+	return query, nil
 }
 
-func (s *queryService) GetQueryHistory(userID uuid.UUID, pagination *domain.Pagination) ([]*domain.UserQuery, error) {
-	return s.queryRepo.ListByUser(userID, pagination)
+func (s *queryService) GetQueryHistory(userID uuid.UUID, scope domain.SiteScope, pagination *domain.Pagination) ([]*domain.UserQuery, error) {
+	return s.queryRepo.ListByUser(userID, scope, pagination)
 }
 
-func (s *queryService) SearchSimilarQueries(siteID uuid.UUID, queryText string, limit int) ([]*domain.UserQuery, error) {
+func (s *queryService) SearchSimilarQueries(ctx context.Context, siteID uuid.UUID, queryText string, limit int) ([]*domain.UserQuery, error) {
 	// Generate embedding for the query
-	embedding, err := s.llmService.GenerateEmbedding(queryText)
+	ctx = withUsageOperation(siteUsageScope(ctx, siteID), usageEmbedSearch)
+	embedding, err := s.llmService.GenerateEmbedding(ctx, queryText)
 	if err != nil {
 		return nil, err
 	}
@@ -537,15 +781,35 @@ func containsAny(text string, keywords []string) bool {
 	return false
 }
 
+// identifierPattern matches tokens like "WO-12345", "F0023" or "INV-31A":
+// anything containing a digit and at least three characters long
+var identifierPattern = regexp.MustCompile(`\b(?:[A-Za-z]+[-/#])*[A-Za-z0-9]*\d[A-Za-z0-9]*(?:[-/#.][A-Za-z0-9]+)*`)
+
+// extractIdentifiers returns the work order numbers, fault codes and similar
+// identifiers mentioned in a query, for exact matching
+func extractIdentifiers(query string) []string {
+	var identifiers []string
+	seen := make(map[string]bool)
+	for _, match := range identifierPattern.FindAllString(query, -1) {
+		key := strings.ToLower(match)
+		if len(match) < 3 || seen[key] {
+			continue
+		}
+		seen[key] = true
+		identifiers = append(identifiers, match)
+	}
+	return identifiers
+}
+
 func (s *queryService) extractDateRange(query string) map[string]time.Time {
 	// Simple date extraction using regex
 	// In production, use more sophisticated NLP
 	dateRange := make(map[string]time.Time)
-	
+
 	// Look for date patterns like "2023-12-15", "last month", "this year"
 	dateRegex := regexp.MustCompile(`(\d{4}-\d{2}-\d{2})`)
 	matches := dateRegex.FindAllString(query, -1)
-	
+
 	if len(matches) >= 2 {
 		if start, err := time.Parse("2006-01-02", matches[0]); err == nil {
 			dateRange["start"] = start
@@ -554,23 +818,23 @@ func (s *queryService) extractDateRange(query string) map[string]time.Time {
 			dateRange["end"] = end
 		}
 	}
-	
+
 	return dateRange
 }
 
 func (s *queryService) extractComponents(query string) []string {
 	// Extract component mentions from query
 	components := make([]string, 0)
-	
+
 	componentKeywords := []string{"inverter", "combiner", "transformer", "string", "module", "panel"}
 	lowercaseQuery := strings.ToLower(query)
-	
+
 	for _, keyword := range componentKeywords {
 		if strings.Contains(lowercaseQuery, keyword) {
 			components = append(components, keyword)
 		}
 	}
-	
+
 	return components
 }
 
@@ -584,10 +848,11 @@ func convertToJSON(entities map[string][]string) domain.JSON {
 }
 
 // extractRelevantChunk intelligently extracts the most relevant portion of a document
-// based on the query, rather than just truncating
-func (s *queryService) extractRelevantChunk(content, query string, maxChars int) string {
+// based on the query, rather than just truncating. It also returns the byte range
+// of the chunk within content so callers can map it back to document structure.
+func (s *queryService) extractRelevantChunk(content, query string, maxChars int) (string, int, int) {
 	if len(content) <= maxChars {
-		return content
+		return content, 0, len(content)
 	}
 
 	// Convert to lowercase for better matching
@@ -683,5 +948,5 @@ func (s *queryService) extractRelevantChunk(content, query string, maxChars int)
 		chunk = chunk + "..."
 	}
 
-	return chunk
-}
\ No newline at end of file
+	return chunk, bestStart, end
+}
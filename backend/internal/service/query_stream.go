@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// Events of a streamed query, in the order they are sent
+const (
+	QueryEventIntent  = "intent"  // *domain.QueryIntent
+	QueryEventSources = "sources" // []domain.QuerySourceDetail
+	QueryEventToken   = "token"   // domain.QueryStreamToken, repeated
+	QueryEventDone    = "done"    // domain.QueryStreamResult
+)
+
+// QueryEventFunc receives the events of a streamed query. Returning an error
+// (the client went away) stops the query, including the LLM call.
+type QueryEventFunc func(event string, data interface{}) error
+
+// StreamEnhancedQuery runs the same pipeline as ProcessEnhancedQuery but
+// reports each stage as it completes and the answer as it is generated
+func (s *queryService) StreamEnhancedQuery(ctx context.Context, userID uuid.UUID, siteID uuid.UUID, queryText string, conversationID *uuid.UUID, emit QueryEventFunc) error {
+	startTime := time.Now()
+	queryID := uuid.New()
+	ctx = WithUsageScope(ctx, UsageScope{SiteID: &siteID, QueryID: &queryID})
+	run := newQueryRun(ctx, queryID, userID, siteID, queryText)
+	defer s.recordQueryRun(run)
+
+	validationResult, err := s.contentFilter.ValidateQuery(queryText)
+	if err != nil {
+		return fmt.Errorf("query validation failed: %w", err)
+	}
+	if !validationResult.IsValid {
+		run.rejected(validationResult.Reason)
+		response := rejectedQueryResponse(validationResult.Reason, startTime)
+		if err := emit(QueryEventToken, domain.QueryStreamToken{Text: response.Answer}); err != nil {
+			return err
+		}
+		return emit(QueryEventDone, domain.QueryStreamResult{
+			Answer:            response.Answer,
+			NoHallucination:   response.NoHallucination,
+			CitedSources:      []int{},
+			CitedDocumentIDs:  []uuid.UUID{},
+			Segments:          response.Segments,
+			UnsupportedClaims: response.UnsupportedClaims,
+			ProcessingTimeMs:  int(time.Since(startTime).Milliseconds()),
+		})
+	}
+
+	cacheKey := ""
+	if conversationID == nil {
+		cacheKey = s.answerCacheKey(siteID, queryText)
+		if cached := s.lookupAnswer(siteID, cacheKey); cached != nil {
+			return s.streamCachedAnswer(ctx, run, queryID, userID, siteID, queryText, cached, startTime, emit)
+		}
+	}
+
+	stage := time.Now()
+	turn, err := s.startTurn(ctx, userID, siteID, conversationID, queryText)
+	run.prompted(stage)
+	if err != nil {
+		return err
+	}
+
+	stage = time.Now()
+	intent, err := s.llmService.AnalyzeQueryIntent(ctx, turn.query, siteID)
+	run.prompted(stage)
+	if err != nil {
+		return fmt.Errorf("intent analysis failed: %w", err)
+	}
+	if err := emit(QueryEventIntent, intent); err != nil {
+		return err
+	}
+
+	stage = time.Now()
+	sources, err := s.retrieveRelevantSources(ctx, siteID, turn.query, intent)
+	run.searched(stage)
+	if err != nil {
+		return fmt.Errorf("source retrieval failed: %w", err)
+	}
+	sources = turn.withPreviousSources(sources)
+	if err := emit(QueryEventSources, sources); err != nil {
+		return err
+	}
+
+	stage = time.Now()
+	response, err := s.llmService.StreamEnhancedResponse(ctx, turn.query, sources, func(token string) error {
+		return emit(QueryEventToken, domain.QueryStreamToken{Text: token})
+	})
+	run.prompted(stage)
+	if err != nil {
+		return fmt.Errorf("response generation failed: %w", err)
+	}
+	// The streamed answer skips entity extraction; the intent has them
+	response.ExtractedEntities = intent.ExtractedEntities
+	if intent.RelatedConcepts != nil {
+		response.RelatedConcepts = intent.RelatedConcepts
+	}
+
+	query, err := s.recordEnhancedQuery(ctx, queryID, userID, siteID, queryText, turn, intent, response)
+	if err != nil {
+		return err
+	}
+	response.Segments = answerSegments(response.Answer, detailCitations(sources))
+	response.ProcessingTimeMs = int(time.Since(startTime).Milliseconds())
+	s.storeAnswer(siteID, cacheKey, intent, response)
+	run.answered(query.QueryType, len(sources), false)
+
+	return emit(QueryEventDone, streamResult(query, response))
+}
+
+// streamCachedAnswer replays a cached answer as a stream: the intent and
+// sources it was answered from, then the whole answer as a single token
+func (s *queryService) streamCachedAnswer(ctx context.Context, run *queryRun, queryID, userID, siteID uuid.UUID, queryText string, cached *cachedAnswer, startTime time.Time, emit QueryEventFunc) error {
+	if err := emit(QueryEventIntent, cached.Intent); err != nil {
+		return err
+	}
+	if err := emit(QueryEventSources, cached.Response.Sources); err != nil {
+		return err
+	}
+
+	response, query, err := s.answerFromCache(ctx, queryID, userID, siteID, queryText, cached, startTime)
+	if err != nil {
+		return err
+	}
+	run.answered(query.QueryType, len(response.Sources), true)
+	if err := emit(QueryEventToken, domain.QueryStreamToken{Text: response.Answer}); err != nil {
+		return err
+	}
+
+	return emit(QueryEventDone, streamResult(query, response))
+}
+
+// streamResult is the done event of a recorded answer
+func streamResult(query *domain.UserQuery, response *domain.EnhancedQueryResponse) domain.QueryStreamResult {
+	sources := response.Sources
+	cited := citedSources(response.Answer, len(sources))
+	documentIDs := make([]uuid.UUID, 0, len(cited))
+	seen := make(map[uuid.UUID]bool)
+	for _, number := range cited {
+		id := sources[number-1].DocumentID
+		if !seen[id] {
+			seen[id] = true
+			documentIDs = append(documentIDs, id)
+		}
+	}
+
+	return domain.QueryStreamResult{
+		QueryID:           &query.ID,
+		Answer:            response.Answer,
+		ConfidenceScore:   response.ConfidenceScore,
+		NoHallucination:   response.NoHallucination,
+		CitedSources:      cited,
+		CitedDocumentIDs:  documentIDs,
+		Segments:          response.Segments,
+		UnsupportedClaims: response.UnsupportedClaims,
+		ProcessingTimeMs:  response.ProcessingTimeMs,
+		Cached:            response.Cached,
+		ConversationID:    query.ConversationID,
+		StandaloneQuery:   query.StandaloneQuery,
+	}
+}
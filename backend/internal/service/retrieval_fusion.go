@@ -0,0 +1,106 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// FusionMethod selects how vector and keyword search results are combined
+type FusionMethod string
+
+const (
+	// FusionRRF scores each result by reciprocal rank, ignoring the raw
+	// scores, which aren't comparable between the two searches
+	FusionRRF FusionMethod = "rrf"
+	// FusionWeighted mixes cosine similarity with the keyword rank
+	// normalised against the best keyword hit
+	FusionWeighted FusionMethod = "weighted"
+)
+
+// FusionPolicy controls hybrid retrieval. Both searches always run; their
+// results are fused into a single score between 0 and 1.
+type FusionPolicy struct {
+	Method         FusionMethod
+	RRFK           int
+	SemanticWeight float64
+	KeywordWeight  float64
+}
+
+// searchHit is one ranked search result with the score its search assigned
+// (cosine similarity or text rank)
+type searchHit struct {
+	ID    uuid.UUID
+	Score float64
+}
+
+// fuse merges the two ranked lists and returns every hit once, best first,
+// with its fused score
+func (p FusionPolicy) fuse(semantic, keyword []searchHit) []searchHit {
+	semanticWeight, keywordWeight := p.SemanticWeight, p.KeywordWeight
+	if semanticWeight <= 0 && keywordWeight <= 0 {
+		semanticWeight, keywordWeight = 0.5, 0.5
+	}
+	totalWeight := semanticWeight + keywordWeight
+
+	scores := make(map[uuid.UUID]float64)
+	var order []uuid.UUID
+	add := func(id uuid.UUID, score float64) {
+		if _, seen := scores[id]; !seen {
+			order = append(order, id)
+		}
+		scores[id] += score
+	}
+
+	switch p.Method {
+	case FusionWeighted:
+		maxKeyword := 0.0
+		for _, hit := range keyword {
+			if hit.Score > maxKeyword {
+				maxKeyword = hit.Score
+			}
+		}
+		for _, hit := range semantic {
+			add(hit.ID, semanticWeight*clampScore(hit.Score)/totalWeight)
+		}
+		for _, hit := range keyword {
+			normalised := 0.0
+			if maxKeyword > 0 {
+				normalised = hit.Score / maxKeyword
+			}
+			add(hit.ID, keywordWeight*normalised/totalWeight)
+		}
+	default:
+		k := p.RRFK
+		if k <= 0 {
+			k = 60
+		}
+		// Scale so a hit ranked first by both searches scores 1
+		best := totalWeight / float64(k+1)
+		for rank, hit := range semantic {
+			add(hit.ID, semanticWeight/float64(k+rank+1)/best)
+		}
+		for rank, hit := range keyword {
+			add(hit.ID, keywordWeight/float64(k+rank+1)/best)
+		}
+	}
+
+	fused := make([]searchHit, 0, len(order))
+	for _, id := range order {
+		fused = append(fused, searchHit{ID: id, Score: scores[id]})
+	}
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].Score > fused[j].Score
+	})
+	return fused
+}
+
+func clampScore(score float64) float64 {
+	if score < 0 {
+		return 0
+	}
+	if score > 1 {
+		return 1
+	}
+	return score
+}
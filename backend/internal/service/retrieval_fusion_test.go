@@ -0,0 +1,118 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFusionPolicyFuse(t *testing.T) {
+	a := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	b := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	c := uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+
+	tests := []struct {
+		name     string
+		policy   FusionPolicy
+		semantic []searchHit
+		keyword  []searchHit
+		want     []searchHit
+	}{
+		{
+			name:     "rrf ranks a hit found by both searches first",
+			policy:   FusionPolicy{Method: FusionRRF, RRFK: 60, SemanticWeight: 0.5, KeywordWeight: 0.5},
+			semantic: []searchHit{{ID: a}, {ID: b}},
+			keyword:  []searchHit{{ID: b}, {ID: c}},
+			want:     []searchHit{{ID: b, Score: 0.5*61.0/62 + 0.5}, {ID: a, Score: 0.5}, {ID: c, Score: 0.5 * 61.0 / 62}},
+		},
+		{
+			name:     "rrf scores the top hit of both searches 1",
+			policy:   FusionPolicy{Method: FusionRRF, RRFK: 60, SemanticWeight: 0.7, KeywordWeight: 0.3},
+			semantic: []searchHit{{ID: a, Score: 0.2}},
+			keyword:  []searchHit{{ID: a, Score: 9}},
+			want:     []searchHit{{ID: a, Score: 1}},
+		},
+		{
+			name:     "rrf ignores raw scores",
+			policy:   FusionPolicy{Method: FusionRRF, RRFK: 60, SemanticWeight: 0.5, KeywordWeight: 0.5},
+			semantic: []searchHit{{ID: a, Score: 0.01}, {ID: b, Score: 0.99}},
+			want:     []searchHit{{ID: a, Score: 0.5}, {ID: b, Score: 0.5 * 61.0 / 62}},
+		},
+		{
+			name:     "rrf defaults k to 60",
+			policy:   FusionPolicy{Method: FusionRRF, SemanticWeight: 0.5, KeywordWeight: 0.5},
+			semantic: []searchHit{{ID: a}, {ID: b}},
+			want:     []searchHit{{ID: a, Score: 0.5}, {ID: b, Score: 0.5 * 61.0 / 62}},
+		},
+		{
+			name:     "rrf weights favour keyword hits",
+			policy:   FusionPolicy{Method: FusionRRF, RRFK: 60, SemanticWeight: 0.2, KeywordWeight: 0.8},
+			semantic: []searchHit{{ID: a}},
+			keyword:  []searchHit{{ID: b}},
+			want:     []searchHit{{ID: b, Score: 0.8}, {ID: a, Score: 0.2}},
+		},
+		{
+			name:     "unset weights count both searches equally",
+			policy:   FusionPolicy{Method: FusionRRF, RRFK: 60},
+			semantic: []searchHit{{ID: a}},
+			keyword:  []searchHit{{ID: b}},
+			want:     []searchHit{{ID: a, Score: 0.5}, {ID: b, Score: 0.5}},
+		},
+		{
+			name:     "unknown method falls back to rrf",
+			policy:   FusionPolicy{Method: "bogus", RRFK: 60, SemanticWeight: 0.5, KeywordWeight: 0.5},
+			semantic: []searchHit{{ID: a}},
+			keyword:  []searchHit{{ID: a}},
+			want:     []searchHit{{ID: a, Score: 1}},
+		},
+		{
+			name:     "weighted mixes similarity with normalised keyword rank",
+			policy:   FusionPolicy{Method: FusionWeighted, SemanticWeight: 0.5, KeywordWeight: 0.5},
+			semantic: []searchHit{{ID: a, Score: 0.9}, {ID: b, Score: 0.5}},
+			keyword:  []searchHit{{ID: b, Score: 2}, {ID: c, Score: 1}},
+			want:     []searchHit{{ID: b, Score: 0.75}, {ID: a, Score: 0.45}, {ID: c, Score: 0.25}},
+		},
+		{
+			name:     "weighted normalises weights that don't sum to 1",
+			policy:   FusionPolicy{Method: FusionWeighted, SemanticWeight: 3, KeywordWeight: 1},
+			semantic: []searchHit{{ID: a, Score: 1}},
+			keyword:  []searchHit{{ID: a, Score: 5}},
+			want:     []searchHit{{ID: a, Score: 1}},
+		},
+		{
+			name:     "weighted clamps similarities outside 0 to 1",
+			policy:   FusionPolicy{Method: FusionWeighted, SemanticWeight: 0.5, KeywordWeight: 0.5},
+			semantic: []searchHit{{ID: a, Score: 1.4}, {ID: b, Score: -0.2}},
+			want:     []searchHit{{ID: a, Score: 0.5}, {ID: b, Score: 0}},
+		},
+		{
+			name:    "weighted keyword hits without rank score nothing",
+			policy:  FusionPolicy{Method: FusionWeighted, SemanticWeight: 0.5, KeywordWeight: 0.5},
+			keyword: []searchHit{{ID: c, Score: 0}},
+			want:    []searchHit{{ID: c, Score: 0}},
+		},
+		{
+			name:   "no hits",
+			policy: FusionPolicy{Method: FusionRRF},
+			want:   []searchHit{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.fuse(tt.semantic, tt.keyword)
+			if len(got) != len(tt.want) {
+				t.Fatalf("fuse() returned %d hits, want %d: %v", len(got), len(tt.want), got)
+			}
+			for i := range tt.want {
+				if got[i].ID != tt.want[i].ID {
+					t.Errorf("hit %d = %s, want %s", i, got[i].ID, tt.want[i].ID)
+				}
+				if math.Abs(got[i].Score-tt.want[i].Score) > 1e-9 {
+					t.Errorf("hit %d score = %v, want %v", i, got[i].Score, tt.want[i].Score)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// SiteResource is a kind of record that belongs to a site
+type SiteResource string
+
+const (
+	SiteResourceDocument     SiteResource = "document"
+	SiteResourceAction       SiteResource = "action"
+	SiteResourceComponent    SiteResource = "component"
+	SiteResourceConversation SiteResource = "conversation"
+	SiteResourceQuery        SiteResource = "query"
+)
+
+// siteResourceModels maps each resource to the model its site_id is read from
+var siteResourceModels = map[SiteResource]func() interface{}{
+	SiteResourceDocument:     func() interface{} { return &domain.Document{} },
+	SiteResourceAction:       func() interface{} { return &domain.ExtractedAction{} },
+	SiteResourceComponent:    func() interface{} { return &domain.SiteComponent{} },
+	SiteResourceConversation: func() interface{} { return &domain.Conversation{} },
+	SiteResourceQuery:        func() interface{} { return &domain.UserQuery{} },
+}
+
+// SiteAccessService decides which sites a user may see. Platform operators
+// see every site; everyone else only sees sites of their own organization,
+// admins and managers all of them and viewers and technicians the ones they
+// are granted. Users without an organization see none.
+type SiteAccessService interface {
+	// Scope is the set of sites user may access
+	Scope(user *domain.User) (domain.SiteScope, error)
+	// SiteOf returns the site the resource with id belongs to
+	SiteOf(resource SiteResource, id uuid.UUID) (uuid.UUID, error)
+}
+
+type siteAccessService struct {
+	orgRepo repository.OrganizationRepository
+}
+
+func NewSiteAccessService(orgRepo repository.OrganizationRepository) SiteAccessService {
+	return &siteAccessService{
+		orgRepo: orgRepo,
+	}
+}
+
+func (s *siteAccessService) Scope(user *domain.User) (domain.SiteScope, error) {
+	if user.PlatformOperator {
+		return domain.SiteScope{All: true}, nil
+	}
+	if user.OrganizationID == nil {
+		return domain.SiteScope{}, nil
+	}
+
+	var (
+		siteIDs []uuid.UUID
+		err     error
+	)
+	if user.Role == domain.UserRoleAdmin || user.Role == domain.UserRoleManager {
+		siteIDs, err = s.orgRepo.SiteIDs(*user.OrganizationID)
+	} else {
+		siteIDs, err = s.orgRepo.GrantedSiteIDs(user.ID, *user.OrganizationID)
+	}
+	if err != nil {
+		return domain.SiteScope{}, fmt.Errorf("failed to load accessible sites: %w", err)
+	}
+	return domain.SiteScope{SiteIDs: siteIDs}, nil
+}
+
+func (s *siteAccessService) SiteOf(resource SiteResource, id uuid.UUID) (uuid.UUID, error) {
+	model, ok := siteResourceModels[resource]
+	if !ok {
+		return uuid.Nil, fmt.Errorf("unknown site resource %q", resource)
+	}
+	return s.orgRepo.SiteOf(model(), id)
+}
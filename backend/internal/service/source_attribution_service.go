@@ -11,7 +11,9 @@ import (
 )
 
 type SourceAttributionService interface {
-	AttributeSources(queryID uuid.UUID, documents []*domain.Document, excerpts []string, relevanceScores []float64) error
+	// AttributeSources saves the sources an answer was given, numbered as
+	// the answer cites them
+	AttributeSources(queryID uuid.UUID, sources []domain.QuerySourceDetail) error
 	GetQuerySources(queryID uuid.UUID) ([]*domain.QuerySource, error)
 	FormatCitation(document *domain.Document, pageNumber *int, sectionRef string) string
 	ValidateSourceContent(answer string, sources []*domain.QuerySource) (*SourceValidationResult, error)
@@ -39,61 +41,59 @@ func NewSourceAttributionService(
 	}
 }
 
-func (s *sourceAttributionService) AttributeSources(queryID uuid.UUID, documents []*domain.Document, excerpts []string, relevanceScores []float64) error {
-	if len(documents) != len(excerpts) || len(documents) != len(relevanceScores) {
-		return fmt.Errorf("mismatched array lengths: documents=%d, excerpts=%d, scores=%d", 
-			len(documents), len(excerpts), len(relevanceScores))
-	}
-
+func (s *sourceAttributionService) AttributeSources(queryID uuid.UUID, sources []domain.QuerySourceDetail) error {
 	// Get the query to validate it exists
 	_, err := s.queryRepo.GetByID(queryID)
 	if err != nil {
 		return fmt.Errorf("query not found: %w", err)
 	}
 
-	// Create query source records for each document
-	for i, doc := range documents {
-		_ = &domain.QuerySource{
-			ID:              uuid.New(),
-			QueryID:         queryID,
-			DocumentID:      doc.ID,
-			DocumentTitle:   doc.Title,
-			RelevantExcerpt: excerpts[i],
-			RelevanceScore:  relevanceScores[i],
-			PageNumber:      s.extractPageNumber(excerpts[i]),
-			SectionReference: s.extractSectionReference(excerpts[i]),
-			CreatedAt:       time.Now(),
+	// Page and section come from the chunk or document range the excerpt
+	// was taken from, recorded when the document was extracted
+	records := make([]*domain.QuerySource, len(sources))
+	for i, source := range sources {
+		records[i] = &domain.QuerySource{
+			ID:               uuid.New(),
+			QueryID:          queryID,
+			SourceType:       source.SourceType,
+			DocumentID:       source.DocumentID,
+			ActionID:         source.ActionID,
+			DocumentTitle:    source.DocumentTitle,
+			RelevantExcerpt:  source.RelevantExcerpt,
+			RelevanceScore:   source.RelevanceScore,
+			PageNumber:       source.PageNumber,
+			SectionReference: truncateSectionReference(source.SectionReference),
+			SourceIndex:      i + 1,
+			CreatedAt:        time.Now(),
 		}
+	}
 
-		// For now, we'll store this in the query's results field as JSONB
-		// In a full implementation, you'd create a QuerySourceRepository
-		// and persist these relationships properly
+	if err := s.queryRepo.CreateSources(records); err != nil {
+		return fmt.Errorf("failed to save query sources: %w", err)
 	}
 
 	return nil
 }
 
 func (s *sourceAttributionService) GetQuerySources(queryID uuid.UUID) ([]*domain.QuerySource, error) {
-	// This would retrieve from the query_sources table
-	// For now, return empty slice since we're storing in query.results
-	return []*domain.QuerySource{}, nil
+	return s.queryRepo.ListSources(queryID)
 }
 
 func (s *sourceAttributionService) FormatCitation(document *domain.Document, pageNumber *int, sectionRef string) string {
 	citation := document.Title
-	
+
 	if document.DocumentDate != nil {
 		citation += fmt.Sprintf(" (%s)", document.DocumentDate.Format("2006-01-02"))
 	}
-	
+
 	if pageNumber != nil {
 		citation += fmt.Sprintf(", p. %d", *pageNumber)
 	}
-	
+
 	if sectionRef != "" {
 		citation += fmt.Sprintf(", %s", sectionRef)
 	}
-	
+
 	return citation
 }
 
@@ -112,58 +112,30 @@ func (s *sourceAttributionService) ValidateSourceContent(answer string, sources
 		return result, nil
 	}
 
-	// Basic content validation - check if answer content appears in sources
-	answerWords := strings.Fields(strings.ToLower(answer))
-	totalWords := len(answerWords)
-	supportedWords := 0
-
-	for _, source := range sources {
-		sourceText := strings.ToLower(source.RelevantExcerpt)
-		for _, word := range answerWords {
-			// Skip common words for better accuracy
-			if len(word) > 3 && strings.Contains(sourceText, word) {
-				supportedWords++
-			}
-		}
+	// Check each claim of the answer against the sources it cites; without
+	// an LLM at hand, claims are judged by their facts and term overlap
+	evidence := make([]string, len(sources))
+	for i, source := range sources {
+		evidence[i] = source.DocumentTitle + "\n" + source.RelevantExcerpt
 	}
-
-	if totalWords > 0 {
-		supportRatio := float64(supportedWords) / float64(totalWords)
-		result.ConfidenceScore = supportRatio
-		result.HallucinationRisk = 1.0 - supportRatio
-
-		// Flag potential hallucinations if support is low
-		if supportRatio < 0.6 {
-			result.IsValid = false
-			result.UnsupportedClaims = append(result.UnsupportedClaims, 
-				fmt.Sprintf("Low source support ratio: %.2f", supportRatio))
-		}
+	grounding := checkClaims(extractClaims(answer, len(sources)), evidence, nil)
+
+	result.ConfidenceScore = grounding.Score
+	result.HallucinationRisk = 1.0 - grounding.Score
+	result.IsValid = grounding.NoHallucination
+	for _, claim := range grounding.UnsupportedClaims() {
+		result.UnsupportedClaims = append(result.UnsupportedClaims,
+			fmt.Sprintf("%s (%s)", claim.Text, claim.Reason))
 	}
 
-	// Additional validation rules can be added here:
-	// - Date consistency checks
-	// - Fact contradiction detection
-	// - Confidence threshold enforcement
-
 	return result, nil
 }
 
-// Helper methods for extracting metadata from excerpts
-func (s *sourceAttributionService) extractPageNumber(excerpt string) *int {
-	// Simple regex to find page references like "Page 5" or "p. 5"
-	// In production, this would be more sophisticated
-	if strings.Contains(strings.ToLower(excerpt), "page ") {
-		// This is a simplified implementation
-		return nil // Return actual page number when found
+// truncateSectionReference keeps references within the varchar(255) column
+func truncateSectionReference(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if len(ref) > 255 {
+		ref = strings.ToValidUTF8(ref[:255], "")
 	}
-	return nil
+	return ref
 }
-
-func (s *sourceAttributionService) extractSectionReference(excerpt string) string {
-	// Look for section references like "Section 3.1" or "Chapter 2"
-	// In production, this would use more sophisticated text analysis
-	if strings.Contains(strings.ToLower(excerpt), "section ") {
-		// Extract and return section reference
-	}
-	return ""
-}
\ No newline at end of file
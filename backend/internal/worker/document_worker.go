@@ -0,0 +1,285 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/engramiq/engramiq-backend/internal/config"
+	"github.com/engramiq/engramiq-backend/internal/domain"
+	"github.com/engramiq/engramiq-backend/internal/service"
+	"github.com/engramiq/engramiq-backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// sweepBatchSize bounds how many documents a single sweep re-enqueues
+const sweepBatchSize = 100
+
+// chunkBackfillBatchSize bounds how many previously processed documents are
+// chunked per sweep interval; each chunk costs an embedding request
+const chunkBackfillBatchSize = 10
+
+// embeddingBackfillBatchSize bounds how many actions, and how many
+// components, are embedded per sweep interval
+const embeddingBackfillBatchSize = 100
+
+// DocumentWorker drains the document processing queue with a fixed pool of
+// goroutines. Uploads are enqueued by the document service; the worker also
+// periodically sweeps the database for documents that never made it onto the
+// queue or were abandoned mid-processing.
+type DocumentWorker struct {
+	docService service.DocumentService
+	embeddings service.EmbeddingService
+	queue      service.DocumentQueue
+	cfg        config.WorkerConfig
+	log        *logger.Logger
+}
+
+// NewDocumentWorker creates a new document processing worker pool
+func NewDocumentWorker(
+	docService service.DocumentService,
+	embeddings service.EmbeddingService,
+	queue service.DocumentQueue,
+	cfg config.WorkerConfig,
+	log *logger.Logger,
+) *DocumentWorker {
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	// An attempt must end before the sweep would count it as stale
+	if cfg.ProcessingTimeout <= 0 || cfg.ProcessingTimeout >= cfg.StaleTimeout {
+		cfg.ProcessingTimeout = cfg.StaleTimeout * 2 / 3
+	}
+
+	return &DocumentWorker{
+		docService: docService,
+		embeddings: embeddings,
+		queue:      queue,
+		cfg:        cfg,
+		log:        log,
+	}
+}
+
+// Run processes queued documents until ctx is cancelled. In-flight documents
+// are allowed to finish before Run returns.
+func (w *DocumentWorker) Run(ctx context.Context) {
+	// Unbuffered so the dispatcher only pops a document once a worker is free
+	jobs := make(chan uuid.UUID)
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				w.process(id)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.backfillChunks(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.backfillEmbeddings(ctx)
+	}()
+
+	w.log.Infow("Document worker started", "concurrency", w.cfg.Concurrency)
+
+	// Recover anything left over from a previous run before taking new work
+	w.sweep()
+
+	sweepTicker := time.NewTicker(w.cfg.SweepInterval)
+	defer sweepTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			w.log.Infow("Document worker stopped")
+			return
+		case <-sweepTicker.C:
+			w.sweep()
+			continue
+		default:
+		}
+
+		id, ok := w.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.cfg.PollInterval):
+			}
+			continue
+		}
+
+		select {
+		case jobs <- id:
+		case <-ctx.Done():
+			// Put it back so the next run picks it up
+			if err := w.queue.EnqueueDocument(id.String(), 0); err != nil {
+				w.log.Errorw("Failed to requeue document on shutdown", "document_id", id, "error", err)
+			}
+		}
+	}
+}
+
+// next pops the highest priority document off the queue
+func (w *DocumentWorker) next() (uuid.UUID, bool) {
+	member, err := w.queue.DequeueDocument()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			w.log.Errorw("Failed to dequeue document", "error", err)
+		}
+		return uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(member)
+	if err != nil {
+		w.log.Warnw("Dropping invalid document ID from queue", "member", member)
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+func (w *DocumentWorker) process(id uuid.UUID) {
+	// The same document can be queued more than once (sweep + upload, or a
+	// manual trigger), so only the worker that flips it from pending to
+	// processing works on it
+	claimed, err := w.docService.ClaimDocument(id)
+	if err != nil {
+		w.log.Errorw("Failed to claim document", "document_id", id, "error", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	// Deliberately not tied to the pool's context: shutdown waits for
+	// in-flight documents rather than failing them half way. The processing
+	// timeout still bounds a document whose LLM calls hang.
+	ctx, cancel := context.WithTimeout(context.Background(), w.cfg.ProcessingTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := w.docService.ProcessDocument(ctx, id); err != nil {
+		w.log.Errorw("Document processing failed", "document_id", id, "error", err)
+		// The service leaves cancelled attempts to us; count the timeout
+		// here, before the document could look stale to the sweep
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			cause := fmt.Errorf("processing did not finish within %s", w.cfg.ProcessingTimeout)
+			if err := w.docService.RecordProcessingFailure(id, cause); err != nil {
+				w.log.Errorw("Failed to record document timeout", "document_id", id, "error", err)
+			}
+		}
+		return
+	}
+
+	w.log.Infow("Document processed", "document_id", id, "duration_ms", time.Since(start).Milliseconds())
+}
+
+// sweep re-enqueues pending documents and failed documents whose backoff has
+// elapsed. A worker gives up on a document well before the stale timeout, so
+// documents still processing after it belong to a worker that died; they are
+// counted as a failed attempt so a document that crashes the worker can't
+// loop forever.
+func (w *DocumentWorker) sweep() {
+	stale, err := w.docService.GetStaleProcessing(time.Now().Add(-w.cfg.StaleTimeout), sweepBatchSize)
+	if err != nil {
+		w.log.Errorw("Failed to load stale documents", "error", err)
+	}
+	for _, document := range stale {
+		w.log.Warnw("Document stuck in processing", "document_id", document.ID, "started_at", document.ProcessingStartedAt)
+		cause := fmt.Errorf("processing did not finish within %s", w.cfg.StaleTimeout)
+		if err := w.docService.RecordProcessingFailure(document.ID, cause); err != nil {
+			w.log.Errorw("Failed to record stale document failure", "document_id", document.ID, "error", err)
+		}
+	}
+
+	retries, err := w.docService.GetDueRetries(sweepBatchSize)
+	if err != nil {
+		w.log.Errorw("Failed to load documents due for retry", "error", err)
+	}
+	for _, document := range retries {
+		w.log.Infow("Retrying document", "document_id", document.ID, "attempts", document.ProcessingAttempts)
+		if err := w.docService.UpdateProcessingStatus(document.ID, domain.ProcessingStatusPending); err != nil {
+			w.log.Errorw("Failed to reset document for retry", "document_id", document.ID, "error", err)
+		}
+	}
+
+	pending, err := w.docService.GetPendingProcessing(sweepBatchSize)
+	if err != nil {
+		w.log.Errorw("Failed to load pending documents", "error", err)
+		return
+	}
+	for _, document := range pending {
+		if err := w.docService.EnqueueDocument(document); err != nil {
+			w.log.Errorw("Failed to enqueue pending document", "document_id", document.ID, "error", err)
+		}
+	}
+}
+
+// backfillChunks chunks documents that were processed before chunk-level
+// retrieval existed. It runs beside the pool so a long manual doesn't hold
+// up the queue.
+func (w *DocumentWorker) backfillChunks(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		documents, err := w.docService.GetUnchunkedDocuments(chunkBackfillBatchSize)
+		if err != nil {
+			w.log.Errorw("Failed to load documents without chunks", "error", err)
+		}
+		for _, document := range documents {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := w.docService.ChunkDocument(ctx, document.ID); err != nil {
+				w.log.Errorw("Failed to chunk document", "document_id", document.ID, "error", err)
+				continue
+			}
+			w.log.Infow("Chunked previously processed document", "document_id", document.ID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// backfillEmbeddings embeds actions and components saved without an
+// embedding: those from before they were embedded, those whose embedding
+// failed when they were saved, and those created or edited through the API
+func (w *DocumentWorker) backfillEmbeddings(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		embedded, err := w.embeddings.BackfillEmbeddings(ctx, embeddingBackfillBatchSize)
+		if err != nil && ctx.Err() == nil {
+			w.log.Errorw("Failed to backfill embeddings", "error", err)
+		}
+		if embedded > 0 {
+			w.log.Infow("Embedded previously saved actions and components", "count", embedded)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Package cfb reads Microsoft Compound File Binary (OLE2) containers, the
+// storage format behind legacy Office documents such as .doc and Outlook .msg
+// files. Only reading is supported.
+package cfb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+var signature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// Special sector numbers
+const (
+	maxRegSect = 0xFFFFFFFA
+	endOfChain = 0xFFFFFFFE
+	freeSect   = 0xFFFFFFFF
+	noStream   = 0xFFFFFFFF
+)
+
+// EntryType identifies what a directory entry holds
+type EntryType byte
+
+const (
+	EntryEmpty   EntryType = 0
+	EntryStorage EntryType = 1
+	EntryStream  EntryType = 2
+	EntryRoot    EntryType = 5
+)
+
+var (
+	ErrNotCompoundFile = errors.New("cfb: not a compound file")
+	ErrCorrupt         = errors.New("cfb: corrupt compound file")
+	ErrNotFound        = errors.New("cfb: entry not found")
+)
+
+// Entry is a storage or stream in the compound file directory
+type Entry struct {
+	Name string
+	Type EntryType
+	Size uint64
+
+	file        *File
+	startSector uint32
+	left        uint32
+	right       uint32
+	child       uint32
+	children    []*Entry
+}
+
+// File is a parsed compound file held in memory
+type File struct {
+	data           []byte
+	sectorSize     int
+	miniSectorSize int
+	miniCutoff     uint64
+	fat            []uint32
+	miniFAT        []uint32
+	miniStream     []byte
+	entries        []*Entry
+}
+
+// IsCompoundFile reports whether data starts with the compound file signature
+func IsCompoundFile(data []byte) bool {
+	return len(data) >= len(signature) && bytes.Equal(data[:len(signature)], signature)
+}
+
+// Open parses the compound file held in data
+func Open(data []byte) (*File, error) {
+	if len(data) < 512 || !IsCompoundFile(data) {
+		return nil, ErrNotCompoundFile
+	}
+
+	sectorShift := binary.LittleEndian.Uint16(data[0x1E:])
+	miniSectorShift := binary.LittleEndian.Uint16(data[0x20:])
+	if sectorShift != 9 && sectorShift != 12 || miniSectorShift != 6 {
+		return nil, fmt.Errorf("%w: unsupported sector size", ErrCorrupt)
+	}
+
+	f := &File{
+		data:           data,
+		sectorSize:     1 << sectorShift,
+		miniSectorSize: 1 << miniSectorShift,
+		miniCutoff:     uint64(binary.LittleEndian.Uint32(data[0x38:])),
+	}
+
+	if err := f.loadFAT(); err != nil {
+		return nil, err
+	}
+
+	firstDirSector := binary.LittleEndian.Uint32(data[0x30:])
+	dir, err := f.readChain(firstDirSector, f.fat, f.sector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	if err := f.loadDirectory(dir); err != nil {
+		return nil, err
+	}
+
+	root := f.entries[0]
+	if root.Type != EntryRoot {
+		return nil, fmt.Errorf("%w: missing root entry", ErrCorrupt)
+	}
+
+	// The mini stream lives in the root entry's regular sector chain
+	if root.startSector != endOfChain && root.Size > 0 {
+		miniStream, err := f.readChain(root.startSector, f.fat, f.sector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mini stream: %w", err)
+		}
+		f.miniStream = miniStream
+	}
+
+	firstMiniFATSector := binary.LittleEndian.Uint32(data[0x3C:])
+	if firstMiniFATSector != endOfChain && firstMiniFATSector != freeSect {
+		raw, err := f.readChain(firstMiniFATSector, f.fat, f.sector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mini FAT: %w", err)
+		}
+		f.miniFAT = toUint32s(raw)
+	}
+
+	return f, nil
+}
+
+// Root returns the root storage
+func (f *File) Root() *Entry {
+	return f.entries[0]
+}
+
+// Find walks the directory from the root following the given names, which are
+// matched case-insensitively as the format requires
+func (f *File) Find(path ...string) (*Entry, error) {
+	entry := f.Root()
+	for _, name := range path {
+		entry = entry.Child(name)
+		if entry == nil {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, strings.Join(path, "/"))
+		}
+	}
+	return entry, nil
+}
+
+// ReadStream returns the full contents of a stream at the given path
+func (f *File) ReadStream(path ...string) ([]byte, error) {
+	entry, err := f.Find(path...)
+	if err != nil {
+		return nil, err
+	}
+	return entry.Read()
+}
+
+// Children returns the entries directly inside a storage
+func (e *Entry) Children() []*Entry {
+	return e.children
+}
+
+// Child returns the named direct child of a storage, or nil
+func (e *Entry) Child(name string) *Entry {
+	for _, child := range e.children {
+		if strings.EqualFold(child.Name, name) {
+			return child
+		}
+	}
+	return nil
+}
+
+// Read returns the contents of a stream entry
+func (e *Entry) Read() ([]byte, error) {
+	if e.Type != EntryStream {
+		return nil, fmt.Errorf("cfb: %s is not a stream", e.Name)
+	}
+	if e.Size == 0 {
+		return []byte{}, nil
+	}
+
+	f := e.file
+	var (
+		raw []byte
+		err error
+	)
+	if e.Size < f.miniCutoff {
+		raw, err = f.readChain(e.startSector, f.miniFAT, f.miniSector)
+	} else {
+		raw, err = f.readChain(e.startSector, f.fat, f.sector)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(raw)) < e.Size {
+		return nil, fmt.Errorf("%w: stream %s is truncated", ErrCorrupt, e.Name)
+	}
+	return raw[:e.Size], nil
+}
+
+func (f *File) loadFAT() error {
+	numFATSectors := int(binary.LittleEndian.Uint32(f.data[0x2C:]))
+
+	// The first 109 FAT sector locations live in the header; the rest are
+	// chained through DIFAT sectors
+	var fatSectors []uint32
+	for i := 0; i < 109 && len(fatSectors) < numFATSectors; i++ {
+		fatSectors = append(fatSectors, binary.LittleEndian.Uint32(f.data[0x4C+i*4:]))
+	}
+
+	difatSector := binary.LittleEndian.Uint32(f.data[0x44:])
+	perSector := f.sectorSize/4 - 1
+	for seen := 0; len(fatSectors) < numFATSectors && difatSector <= maxRegSect; seen++ {
+		if seen > len(f.data)/f.sectorSize {
+			return fmt.Errorf("%w: DIFAT loop", ErrCorrupt)
+		}
+		sector, err := f.sector(difatSector)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < perSector && len(fatSectors) < numFATSectors; i++ {
+			fatSectors = append(fatSectors, binary.LittleEndian.Uint32(sector[i*4:]))
+		}
+		difatSector = binary.LittleEndian.Uint32(sector[perSector*4:])
+	}
+
+	for _, sectorID := range fatSectors {
+		sector, err := f.sector(sectorID)
+		if err != nil {
+			return err
+		}
+		f.fat = append(f.fat, toUint32s(sector)...)
+	}
+	return nil
+}
+
+func (f *File) loadDirectory(dir []byte) error {
+	const entrySize = 128
+	for off := 0; off+entrySize <= len(dir); off += entrySize {
+		raw := dir[off : off+entrySize]
+
+		nameLen := int(binary.LittleEndian.Uint16(raw[64:]))
+		if nameLen > 64 {
+			nameLen = 64
+		}
+
+		f.entries = append(f.entries, &Entry{
+			Name:        decodeName(raw[:nameLen]),
+			Type:        EntryType(raw[66]),
+			left:        binary.LittleEndian.Uint32(raw[68:]),
+			right:       binary.LittleEndian.Uint32(raw[72:]),
+			child:       binary.LittleEndian.Uint32(raw[76:]),
+			startSector: binary.LittleEndian.Uint32(raw[116:]),
+			// Version 3 files only use the low 32 bits of the size
+			Size: uint64(binary.LittleEndian.Uint32(raw[120:])),
+			file: f,
+		})
+	}
+	if len(f.entries) == 0 {
+		return fmt.Errorf("%w: empty directory", ErrCorrupt)
+	}
+
+	// Children of a storage are kept in a red-black tree hanging off its
+	// child pointer; an in-order walk over the sibling links flattens it
+	visited := make(map[uint32]bool)
+	var collect func(id uint32, into *[]*Entry)
+	collect = func(id uint32, into *[]*Entry) {
+		if id == noStream || int(id) >= len(f.entries) || visited[id] {
+			return
+		}
+		visited[id] = true
+		entry := f.entries[id]
+		collect(entry.left, into)
+		*into = append(*into, entry)
+		collect(entry.right, into)
+	}
+
+	for _, entry := range f.entries {
+		if entry.Type == EntryStorage || entry.Type == EntryRoot {
+			collect(entry.child, &entry.children)
+		}
+	}
+	return nil
+}
+
+// readChain concatenates the sectors of a chain in the given allocation table
+func (f *File) readChain(start uint32, table []uint32, read func(uint32) ([]byte, error)) ([]byte, error) {
+	var out []byte
+	for id, steps := start, 0; id != endOfChain; steps++ {
+		if id > maxRegSect || int(id) >= len(table) || steps > len(table) {
+			return nil, fmt.Errorf("%w: broken sector chain", ErrCorrupt)
+		}
+		sector, err := read(id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sector...)
+		id = table[id]
+	}
+	return out, nil
+}
+
+func (f *File) sector(id uint32) ([]byte, error) {
+	start := (int(id) + 1) * f.sectorSize
+	end := start + f.sectorSize
+	if id > maxRegSect || end > len(f.data) {
+		// Writers commonly leave the final sector short
+		if id <= maxRegSect && start < len(f.data) {
+			padded := make([]byte, f.sectorSize)
+			copy(padded, f.data[start:])
+			return padded, nil
+		}
+		return nil, fmt.Errorf("%w: sector %d out of range", ErrCorrupt, id)
+	}
+	return f.data[start:end], nil
+}
+
+func (f *File) miniSector(id uint32) ([]byte, error) {
+	start := int(id) * f.miniSectorSize
+	end := start + f.miniSectorSize
+	if end > len(f.miniStream) {
+		return nil, fmt.Errorf("%w: mini sector %d out of range", ErrCorrupt, id)
+	}
+	return f.miniStream[start:end], nil
+}
+
+func toUint32s(raw []byte) []uint32 {
+	out := make([]uint32, len(raw)/4)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint32(raw[i*4:])
+	}
+	return out
+}
+
+// decodeName converts a UTF-16LE directory name, dropping the terminator
+func decodeName(raw []byte) string {
+	units := make([]uint16, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		unit := binary.LittleEndian.Uint16(raw[i:])
+		if unit == 0 {
+			break
+		}
+		units = append(units, unit)
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,287 @@
+package cfb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"unicode/utf16"
+)
+
+type testStream struct {
+	name string
+	data []byte
+}
+
+// buildCompoundFile writes a version 3 compound file with 512 byte sectors:
+// the FAT in sector 0, the directory in sector 1 and each stream's data
+// after it. The mini stream cutoff is 0, so every stream lives in regular
+// sectors. Up to three streams fit in the single directory sector.
+func buildCompoundFile(t *testing.T, streams ...testStream) []byte {
+	t.Helper()
+	const sectorSize = 512
+	if len(streams) > 3 {
+		t.Fatalf("buildCompoundFile supports at most 3 streams")
+	}
+
+	fat := []uint32{0xFFFFFFFD, endOfChain} // FAT sector, directory
+	var data []byte
+	starts := make([]uint32, len(streams))
+	for i, stream := range streams {
+		if len(stream.data) == 0 {
+			starts[i] = endOfChain
+			continue
+		}
+		sectors := (len(stream.data) + sectorSize - 1) / sectorSize
+		starts[i] = uint32(len(fat))
+		for s := 0; s < sectors; s++ {
+			next := uint32(len(fat) + 1)
+			if s == sectors-1 {
+				next = endOfChain
+			}
+			fat = append(fat, next)
+		}
+		padded := make([]byte, sectors*sectorSize)
+		copy(padded, stream.data)
+		data = append(data, padded...)
+	}
+	if len(fat) > sectorSize/4 {
+		t.Fatalf("buildCompoundFile streams need more than one FAT sector")
+	}
+
+	header := make([]byte, sectorSize)
+	copy(header, signature)
+	binary.LittleEndian.PutUint16(header[0x18:], 0x3E)
+	binary.LittleEndian.PutUint16(header[0x1A:], 3)
+	binary.LittleEndian.PutUint16(header[0x1C:], 0xFFFE)
+	binary.LittleEndian.PutUint16(header[0x1E:], 9)
+	binary.LittleEndian.PutUint16(header[0x20:], 6)
+	binary.LittleEndian.PutUint32(header[0x2C:], 1)          // FAT sectors
+	binary.LittleEndian.PutUint32(header[0x30:], 1)          // first directory sector
+	binary.LittleEndian.PutUint32(header[0x38:], 0)          // mini stream cutoff
+	binary.LittleEndian.PutUint32(header[0x3C:], endOfChain) // no mini FAT
+	binary.LittleEndian.PutUint32(header[0x44:], endOfChain) // no DIFAT sectors
+	for i := 0; i < 109; i++ {
+		binary.LittleEndian.PutUint32(header[0x4C+i*4:], freeSect)
+	}
+	binary.LittleEndian.PutUint32(header[0x4C:], 0)
+
+	fatSector := make([]byte, sectorSize)
+	for i := range fatSector {
+		fatSector[i] = 0xFF
+	}
+	for i, next := range fat {
+		binary.LittleEndian.PutUint32(fatSector[i*4:], next)
+	}
+
+	// The root's children are chained through right siblings, which the
+	// in-order walk reads back in order
+	dir := make([]byte, sectorSize)
+	child := uint32(noStream)
+	if len(streams) > 0 {
+		child = 1
+	}
+	writeEntry(dir[0:], "Root Entry", EntryRoot, noStream, child, endOfChain, 0)
+	for i, stream := range streams {
+		right := uint32(noStream)
+		if i+1 < len(streams) {
+			right = uint32(i + 2)
+		}
+		writeEntry(dir[(i+1)*128:], stream.name, EntryStream, right, noStream, starts[i], len(stream.data))
+	}
+
+	out := append(header, fatSector...)
+	out = append(out, dir...)
+	return append(out, data...)
+}
+
+func writeEntry(raw []byte, name string, entryType EntryType, right, child, start uint32, size int) {
+	units := utf16.Encode([]rune(name))
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(raw[i*2:], unit)
+	}
+	binary.LittleEndian.PutUint16(raw[64:], uint16((len(units)+1)*2))
+	raw[66] = byte(entryType)
+	binary.LittleEndian.PutUint32(raw[68:], noStream)
+	binary.LittleEndian.PutUint32(raw[72:], right)
+	binary.LittleEndian.PutUint32(raw[76:], child)
+	binary.LittleEndian.PutUint32(raw[116:], start)
+	binary.LittleEndian.PutUint32(raw[120:], uint32(size))
+}
+
+func TestOpenReadsStreams(t *testing.T) {
+	small := []byte("hello compound file")
+	large := bytes.Repeat([]byte("0123456789"), 120)
+	data := buildCompoundFile(t,
+		testStream{name: "WordDocument", data: large},
+		testStream{name: "1Table", data: small},
+		testStream{name: "Empty"},
+	)
+
+	file, err := Open(data)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want []byte
+	}{
+		{"WordDocument", large},
+		{"worddocument", large},
+		{"1Table", small},
+		{"Empty", []byte{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := file.ReadStream(tt.name)
+			if err != nil {
+				t.Fatalf("ReadStream(%q): %v", tt.name, err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("ReadStream(%q) = %d bytes, want %d", tt.name, len(got), len(tt.want))
+			}
+		})
+	}
+
+	if _, err := file.ReadStream("Missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadStream(Missing) error = %v, want %v", err, ErrNotFound)
+	}
+	if _, err := file.Root().Read(); err == nil {
+		t.Errorf("reading the root storage as a stream succeeded")
+	}
+}
+
+func TestOpenRejectsMalformed(t *testing.T) {
+	valid := buildCompoundFile(t, testStream{name: "Data", data: bytes.Repeat([]byte("x"), 1500)})
+
+	// corrupt returns a copy of valid with edit applied
+	corrupt := func(edit func(data []byte) []byte) []byte {
+		return edit(append([]byte(nil), valid...))
+	}
+	fatEntry := func(data []byte, index int, value uint32) []byte {
+		binary.LittleEndian.PutUint32(data[512+index*4:], value)
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrNotCompoundFile},
+		{"shorter than a header", valid[:511], ErrNotCompoundFile},
+		{"bad signature", corrupt(func(d []byte) []byte { d[0] = 0; return d }), ErrNotCompoundFile},
+		{"text file", bytes.Repeat([]byte("plain text "), 100), ErrNotCompoundFile},
+		{"unsupported sector size", corrupt(func(d []byte) []byte {
+			binary.LittleEndian.PutUint16(d[0x1E:], 10)
+			return d
+		}), ErrCorrupt},
+		{"unsupported mini sector size", corrupt(func(d []byte) []byte {
+			binary.LittleEndian.PutUint16(d[0x20:], 7)
+			return d
+		}), ErrCorrupt},
+		{"header only", valid[:512], ErrCorrupt},
+		{"FAT sector out of range", corrupt(func(d []byte) []byte {
+			binary.LittleEndian.PutUint32(d[0x4C:], 5000)
+			return d
+		}), ErrCorrupt},
+		{"directory sector out of range", corrupt(func(d []byte) []byte {
+			binary.LittleEndian.PutUint32(d[0x30:], 5000)
+			return d
+		}), ErrCorrupt},
+		{"directory chain loops", corrupt(func(d []byte) []byte { return fatEntry(d, 1, 1) }), ErrCorrupt},
+		{"directory chain leaves the FAT", corrupt(func(d []byte) []byte { return fatEntry(d, 1, 500) }), ErrCorrupt},
+		{"missing root entry", corrupt(func(d []byte) []byte { d[1024+66] = byte(EntryStorage); return d }), ErrCorrupt},
+		{"DIFAT loop", corrupt(func(d []byte) []byte {
+			binary.LittleEndian.PutUint32(d[0x2C:], 200) // more FAT sectors than the header holds
+			binary.LittleEndian.PutUint32(d[0x44:], 0)   // DIFAT chain through the FAT sector
+			return fatEntry(d, 127, 0)
+		}), ErrCorrupt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Open(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("Open() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRejectsBrokenStreams(t *testing.T) {
+	valid := buildCompoundFile(t, testStream{name: "Data", data: bytes.Repeat([]byte("x"), 1500)})
+
+	tests := []struct {
+		name string
+		edit func(data []byte)
+	}{
+		// Data starts at sector 2 and spans sectors 2-4
+		{"chain loops", func(d []byte) { binary.LittleEndian.PutUint32(d[512+3*4:], 2) }},
+		{"chain ends early", func(d []byte) { binary.LittleEndian.PutUint32(d[512+2*4:], endOfChain) }},
+		{"chain points past the FAT", func(d []byte) { binary.LittleEndian.PutUint32(d[512+2*4:], 400) }},
+		{"size larger than chain", func(d []byte) { binary.LittleEndian.PutUint32(d[1024+128+120:], 4000) }},
+		{"start sector past the file", func(d []byte) { binary.LittleEndian.PutUint32(d[1024+128+116:], 100) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append([]byte(nil), valid...)
+			tt.edit(data)
+
+			file, err := Open(data)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			if _, err := file.ReadStream("Data"); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("ReadStream() error = %v, want %v", err, ErrCorrupt)
+			}
+		})
+	}
+}
+
+func TestOpenTruncated(t *testing.T) {
+	valid := buildCompoundFile(t,
+		testStream{name: "First", data: bytes.Repeat([]byte("a"), 700)},
+		testStream{name: "Second", data: bytes.Repeat([]byte("b"), 1300)},
+	)
+
+	// Every prefix must fail cleanly, never panic. A cut through the
+	// directory loses entries, which then aren't found.
+	for size := 0; size < len(valid); size += 17 {
+		file, err := Open(valid[:size])
+		if err != nil {
+			if !errors.Is(err, ErrNotCompoundFile) && !errors.Is(err, ErrCorrupt) {
+				t.Errorf("Open(%d bytes) error = %v", size, err)
+			}
+			continue
+		}
+		for _, name := range []string{"First", "Second"} {
+			if _, err := file.ReadStream(name); err != nil && !errors.Is(err, ErrCorrupt) && !errors.Is(err, ErrNotFound) {
+				t.Errorf("ReadStream(%q) on %d bytes error = %v", name, size, err)
+			}
+		}
+	}
+}
+
+func TestIsCompoundFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"signature", signature, true},
+		{"signature and more", append(append([]byte(nil), signature...), 0, 1, 2), true},
+		{"short", signature[:7], false},
+		{"zip", []byte("PK\x03\x04rest"), false},
+		{"empty", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsCompoundFile(tt.data); got != tt.want {
+				t.Errorf("IsCompoundFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
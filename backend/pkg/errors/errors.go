@@ -36,6 +36,22 @@ func NewUnauthorized(message string) AppError {
 	}
 }
 
+func NewForbidden(message string) AppError {
+	return AppError{
+		Code:       "FORBIDDEN",
+		Message:    message,
+		StatusCode: http.StatusForbidden,
+	}
+}
+
+func NewConflict(message string) AppError {
+	return AppError{
+		Code:       "CONFLICT",
+		Message:    message,
+		StatusCode: http.StatusConflict,
+	}
+}
+
 func NewNotFound(resource string, id string) AppError {
 	return AppError{
 		Code:       "NOT_FOUND",
@@ -90,4 +106,4 @@ func mergeDetails(details ...map[string]interface{}) map[string]interface{} {
 func IsAppError(err error) (AppError, bool) {
 	appErr, ok := err.(AppError)
 	return appErr, ok
-}
\ No newline at end of file
+}
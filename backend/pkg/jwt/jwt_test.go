@@ -0,0 +1,130 @@
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testClaims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+var testSecret = []byte("test-secret")
+
+// signWithHeader builds a token with an arbitrary header, signed with
+// secret as HS256 would be
+func signWithHeader(t *testing.T, rawHeader string, claims testClaims, secret []byte) string {
+	t.Helper()
+	unsigned := encodeSegment([]byte(rawHeader)) + "." + encodeSegment(mustMarshal(t, claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + encodeSegment(mac.Sum(nil))
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	return data
+}
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	want := testClaims{Subject: "user-1", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	token, err := Sign(want, testSecret)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	var got testClaims
+	if err := Verify(token, testSecret, &got); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got != want {
+		t.Errorf("claims = %+v, want %+v", got, want)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	valid := testClaims{Subject: "user-1", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	validToken, err := Sign(valid, testSecret)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	parts := strings.Split(validToken, ".")
+
+	expiredToken, err := Sign(testClaims{Subject: "user-1", ExpiresAt: time.Now().Add(-time.Minute).Unix()}, testSecret)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	tamperedPayload := encodeSegment(mustMarshal(t, testClaims{Subject: "admin", ExpiresAt: valid.ExpiresAt}))
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"empty", "", ErrInvalidToken},
+		{"two segments", parts[0] + "." + parts[1], ErrInvalidToken},
+		{"four segments", validToken + ".extra", ErrInvalidToken},
+		{"header not base64", "!!!." + parts[1] + "." + parts[2], ErrInvalidToken},
+		{"header not json", encodeSegment([]byte("nope")) + "." + parts[1] + "." + parts[2], ErrInvalidToken},
+		{"alg none", signWithHeader(t, `{"alg":"none","typ":"JWT"}`, valid, testSecret), ErrInvalidToken},
+		{"alg none unsigned", encodeSegment([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + ".", ErrInvalidToken},
+		{"alg HS512", signWithHeader(t, `{"alg":"HS512","typ":"JWT"}`, valid, testSecret), ErrInvalidToken},
+		{"alg RS256", signWithHeader(t, `{"alg":"RS256","typ":"JWT"}`, valid, testSecret), ErrInvalidToken},
+		{"alg lower case", signWithHeader(t, `{"alg":"hs256","typ":"JWT"}`, valid, testSecret), ErrInvalidToken},
+		{"wrong secret", signWithHeader(t, `{"alg":"HS256","typ":"JWT"}`, valid, []byte("other-secret")), ErrInvalidToken},
+		{"tampered payload", parts[0] + "." + tamperedPayload + "." + parts[2], ErrInvalidToken},
+		{"missing signature", parts[0] + "." + parts[1] + ".", ErrInvalidToken},
+		{"signature not base64", parts[0] + "." + parts[1] + ".!!!", ErrInvalidToken},
+		{"truncated signature", parts[0] + "." + parts[1] + "." + parts[2][:len(parts[2])-4], ErrInvalidToken},
+		{"expired", expiredToken, ErrExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claims testClaims
+			err := Verify(tt.token, testSecret, &claims)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutExpiry(t *testing.T) {
+	token, err := Sign(testClaims{Subject: "user-1"}, testSecret)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	var claims testClaims
+	if err := Verify(token, testSecret, &claims); err != nil {
+		t.Errorf("Verify() error = %v, want nil", err)
+	}
+}
+
+func TestVerifyChecksSignatureBeforeExpiry(t *testing.T) {
+	// A forged token must not learn whether its exp has passed
+	expired := signWithHeader(t, `{"alg":"HS256","typ":"JWT"}`,
+		testClaims{Subject: "user-1", ExpiresAt: time.Now().Add(-time.Minute).Unix()}, []byte("other-secret"))
+
+	var claims testClaims
+	if err := Verify(expired, testSecret, &claims); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrInvalidToken)
+	}
+}
@@ -3,6 +3,7 @@ package validator
 import (
 	"reflect"
 	"strings"
+	"unicode"
 
 	"github.com/engramiq/engramiq-backend/pkg/errors"
 	"github.com/go-playground/validator/v10"
@@ -12,7 +13,7 @@ var validate *validator.Validate
 
 func init() {
 	validate = validator.New()
-	
+
 	// Use JSON tag names in validation errors
 	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
 		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
@@ -21,6 +22,22 @@ func init() {
 		}
 		return name
 	})
+
+	validate.RegisterValidation("password", validatePassword)
+}
+
+// validatePassword requires a password to mix letters with digits or symbols,
+// on top of its length rules
+func validatePassword(fl validator.FieldLevel) bool {
+	var letters, others bool
+	for _, r := range fl.Field().String() {
+		if unicode.IsLetter(r) {
+			letters = true
+		} else if !unicode.IsSpace(r) {
+			others = true
+		}
+	}
+	return letters && others
 }
 
 // ValidateStruct validates a struct and returns formatted errors
@@ -31,7 +48,7 @@ func ValidateStruct(s interface{}) error {
 	}
 
 	var validationErrors []errors.ValidationError
-	
+
 	if errs, ok := err.(validator.ValidationErrors); ok {
 		for _, e := range errs {
 			validationErrors = append(validationErrors, errors.ValidationError{
@@ -56,7 +73,9 @@ func getErrorMessage(e validator.FieldError) string {
 		return "Must be at most " + e.Param() + " characters long"
 	case "uuid":
 		return "Must be a valid UUID"
+	case "password":
+		return "Must contain letters and at least one digit or symbol"
 	default:
 		return "Invalid value"
 	}
-}
\ No newline at end of file
+}